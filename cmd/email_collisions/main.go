@@ -0,0 +1,107 @@
+// Команда email_collisions проверяет email действующих пользователей перед миграцией
+// на уникальность email без учёта регистра (000014_users_email_case_insensitive).
+// Выводит группы пользователей, чьи адреса относятся к одному ящику, и адреса не в каноническом виде.
+// Завершается с кодом 1, если дубликаты найдены: миграцию применять нельзя, пока они не разрешены.
+//
+// С флагом -apply, если дубликатов нет, адреса не в каноническом виде переписываются в канонический.
+// Это нужно сделать после включения EMAIL_CANONICALIZE_PROVIDERS: вход и проверка уникальности
+// сравнивают адреса в каноническом виде.
+//
+//	go run ./cmd/email_collisions [-json] [-apply]
+//
+// Конфигурация читается так же, как у сервиса (config.yaml, secret_config.yaml и переменные окружения)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/config"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "вывести отчёт в JSON")
+	apply := flag.Bool("apply", false, "переписать адреса не в каноническом виде, если дубликатов нет")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig("secret_config.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	email_address.SetProviderRules(cfg.EmailCanonicalizeProviders)
+
+	ctx := context.Background()
+	poll, err := database.CreatePool(ctx, &database.DbConfig{
+		DbName:              cfg.DbName,
+		DbUser:              cfg.DbUser,
+		DbPassword:          cfg.DbPassword,
+		DbHost:              cfg.DbHost,
+		DbPort:              cfg.DbPort,
+		DbMaxConnections:    cfg.DbMaxConnections,
+		DbMinConnections:    cfg.DbMinConnections,
+		DbMaxConnLifetime:   cfg.DbMaxConnLifetime,
+		DbMaxConnIdleTime:   cfg.DbMaxConnIdleTime,
+		DbHealthCheckPeriod: cfg.DbHealthCheckPeriod,
+	}, logger)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer poll.Close()
+
+	userRepository := users_db.NewUsersDB(poll, logger)
+	report, err := user_service.FindEmailCollisions(logger, userRepository, ctx)
+	if err != nil {
+		log.Fatalf("failed to check emails: %v", err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printReport(report)
+	}
+	if len(report.Collisions) > 0 {
+		os.Exit(1)
+	}
+	if *apply {
+		rewritten, err := user_service.CanonicalizeStoredEmails(logger, userRepository, ctx, report)
+		if err != nil {
+			log.Fatalf("failed to canonicalize emails: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Canonicalized %d emails\n", rewritten)
+	}
+}
+
+func printReport(report user_service.EmailCollisionReport) {
+	fmt.Printf("Checked %d active users\n", report.Checked)
+	if len(report.Collisions) == 0 {
+		fmt.Println("No email collisions found")
+	} else {
+		fmt.Printf("\n%d email collisions found, resolve them before applying the migration:\n", len(report.Collisions))
+		for _, collision := range report.Collisions {
+			fmt.Printf("\n%s\n", collision.Key)
+			for _, user := range collision.Users {
+				fmt.Printf("  id=%d  email=%s  created_at=%s\n", user.UserID, user.Email, user.CreatedAt.Format(time.RFC3339))
+			}
+		}
+	}
+	if len(report.NotCanonical) > 0 {
+		fmt.Printf("\n%d emails are not in canonical form:\n", len(report.NotCanonical))
+		for _, user := range report.NotCanonical {
+			fmt.Printf("  id=%d  email=%s  canonical=%s\n", user.UserID, user.Email, user.Canonical)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Команда phone_normalize проверяет телефоны действующих пользователей: миграция 000013 добавила формат E.164,
+// но телефоны, сохранённые до неё, остались как были. Выводит телефоны не в формате E.164, телефоны,
+// которые нельзя разобрать, и телефоны, которые после нормализации есть у нескольких пользователей.
+// Завершается с кодом 1, если при включённой PHONE_UNIQUE есть одинаковые телефоны: сервис с ней не запустится.
+//
+// С флагом -apply телефоны не в формате E.164 переписываются в E.164.
+//
+//	go run ./cmd/phone_normalize [-json] [-apply]
+//
+// Конфигурация читается так же, как у сервиса (config.yaml, secret_config.yaml и переменные окружения)
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/config"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "вывести отчёт в JSON")
+	apply := flag.Bool("apply", false, "переписать телефоны не в формате E.164")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig("secret_config.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	if err = phone_numbers.SetDefaultRegion(cfg.PhoneDefaultRegion); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	poll, err := database.CreatePool(ctx, &database.DbConfig{
+		DbName:              cfg.DbName,
+		DbUser:              cfg.DbUser,
+		DbPassword:          cfg.DbPassword,
+		DbHost:              cfg.DbHost,
+		DbPort:              cfg.DbPort,
+		DbMaxConnections:    cfg.DbMaxConnections,
+		DbMinConnections:    cfg.DbMinConnections,
+		DbMaxConnLifetime:   cfg.DbMaxConnLifetime,
+		DbMaxConnIdleTime:   cfg.DbMaxConnIdleTime,
+		DbHealthCheckPeriod: cfg.DbHealthCheckPeriod,
+	}, logger)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer poll.Close()
+
+	userRepository := users_db.NewUsersDB(poll, logger)
+	report, err := user_service.FindPhonesToNormalize(logger, userRepository, ctx)
+	if err != nil {
+		log.Fatalf("failed to check phones: %v", err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printReport(report)
+	}
+	if *apply {
+		rewritten, err := user_service.NormalizeStoredPhones(logger, userRepository, ctx, report)
+		if err != nil {
+			log.Fatalf("failed to normalize phones: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Normalized %d phones\n", rewritten)
+	}
+	if cfg.PhoneUnique && len(report.Duplicates) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printReport(report user_service.PhoneNormalizationReport) {
+	fmt.Printf("Checked %d active users\n", report.Checked)
+	if len(report.NotNormalized) == 0 {
+		fmt.Println("All phones are in E.164 format")
+	} else {
+		fmt.Printf("\n%d phones are not in E.164 format:\n", len(report.NotNormalized))
+		for _, user := range report.NotNormalized {
+			fmt.Printf("  id=%d  phone=%s  normalized=%s\n", user.UserID, user.Phone, user.Normalized)
+		}
+	}
+	if len(report.Invalid) > 0 {
+		fmt.Printf("\n%d phones cannot be normalized, fix them manually:\n", len(report.Invalid))
+		for _, user := range report.Invalid {
+			fmt.Printf("  id=%d  phone=%s\n", user.UserID, user.Phone)
+		}
+	}
+	if len(report.Duplicates) > 0 {
+		fmt.Printf("\n%d phones belong to several users, resolve them before enabling PHONE_UNIQUE:\n", len(report.Duplicates))
+		for _, duplicate := range report.Duplicates {
+			fmt.Printf("\n%s\n", duplicate.Phone)
+			for _, user := range duplicate.Users {
+				fmt.Printf("  id=%d  phone=%s  created_at=%s\n", user.UserID, user.Phone, user.CreatedAt.Format(time.RFC3339))
+			}
+		}
+	}
+}
@@ -3,14 +3,49 @@ package app
 import (
 	"context"
 	"github.com/ShlykovPavel/users-microservice/internal/config"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/confirmation"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/cursor"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/middlewares"
 	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/preferences"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/registration"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/auth_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/sms"
+	"github.com/ShlykovPavel/users-microservice/internal/server/attributes"
+	email_domains_handlers "github.com/ShlykovPavel/users-microservice/internal/server/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/server/invitations"
+	users_batch "github.com/ShlykovPavel/users-microservice/internal/server/users/batch"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/bulk"
 	users "github.com/ShlykovPavel/users-microservice/internal/server/users/create"
 	users_delete "github.com/ShlykovPavel/users-microservice/internal/server/users/delete"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/email_change"
+	users_export "github.com/ShlykovPavel/users-microservice/internal/server/users/export"
 	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user"
 	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user/get_user_list"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/impersonate"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/login"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/login_events"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/phone_verification"
+	users_preferences "github.com/ShlykovPavel/users-microservice/internal/server/users/preferences"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/restore"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/status"
 	"github.com/ShlykovPavel/users-microservice/internal/server/users/update_user"
+	users_emails "github.com/ShlykovPavel/users-microservice/internal/server/users/user_emails"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/users_import"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/attributes_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/email_changes_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/impersonation_audit_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/phone_verifications_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/preferences_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/user_emails_db"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
 	"github.com/ShlykovPavel/users-microservice/metrics"
 	"github.com/go-chi/chi/v5"
@@ -27,9 +62,10 @@ import (
 
 // App Структура приложения. Включает в себя все необходимые элементы для запуска приложения. (в последствии сюда можно докинуть gRPC итп)
 type App struct {
-	HTTPServer *http.Server
-	logger     *slog.Logger
-	cfg        *config.Config
+	HTTPServer     *http.Server
+	logger         *slog.Logger
+	cfg            *config.Config
+	userRepository users_db.UserRepository
 }
 
 // NewApp создаёт экземпляр приложения, инициализируя все зависимости:
@@ -66,8 +102,94 @@ func NewApp(logger *slog.Logger, cfg *config.Config) *App {
 		logger.Error("Failed to initialize validator", "error", err)
 	}
 
+	registrationPolicy, err := registration.NewPolicy(cfg.RegistrationMode, cfg.RegistrationAllowedDomains)
+	if err != nil {
+		logger.Error("Invalid registration settings", "error", err)
+		os.Exit(1)
+	}
+	registrationPolicy.RequireApproval = cfg.RegistrationApproval
+	domainRules, err := email_domains.NewRules(email_domains.Config{
+		AllowedDomains:         cfg.EmailAllowedDomains,
+		DeniedDomains:          cfg.EmailDeniedDomains,
+		AllowedDomainsFile:     cfg.EmailAllowedDomainsFile,
+		DeniedDomainsFile:      cfg.EmailDeniedDomainsFile,
+		DisposableDomainsFile:  cfg.EmailDisposableDomainsFile,
+		BlockDisposableDomains: cfg.EmailBlockDisposable,
+	})
+	if err != nil {
+		logger.Error("Failed to load email domain lists", "error", err)
+		os.Exit(1)
+	}
+
+	preferencesSchema, err := preferences.LoadSchema(cfg.PreferencesSchemaFile, cfg.PreferencesMaxBytes)
+	if err != nil {
+		logger.Error("Failed to load preferences schema", "error", err)
+		os.Exit(1)
+	}
+	email_address.SetProviderRules(cfg.EmailCanonicalizeProviders)
+	if err = phone_numbers.SetDefaultRegion(cfg.PhoneDefaultRegion); err != nil {
+		logger.Error("Invalid phone settings", "error", err)
+		os.Exit(1)
+	}
+
 	// Инициализируем объекты репозиториев
 	userRepository := users_db.NewUsersDB(poll, logger)
+	loginEventsRepository := login_events_db.NewLoginEventsDB(poll, logger)
+	impersonationAuditRepository := impersonation_audit_db.NewImpersonationAuditDB(poll, logger)
+	invitationRepository := invitations_db.NewInvitationsDB(poll, logger)
+	emailChangeRepository := email_changes_db.NewEmailChangesDB(poll, logger)
+	attributeRepository := attributes_db.NewAttributesDB(poll, logger)
+	preferencesRepository := preferences_db.NewPreferencesDB(poll, logger)
+	phoneVerificationRepository := phone_verifications_db.NewPhoneVerificationsDB(poll, logger)
+	userEmailRepository := user_emails_db.NewUserEmailsDB(poll, logger)
+
+	// Настройка сохраняется в БД, её проверяет триггер; схема при запуске не меняется
+	if err = userRepository.SetPhoneUnique(context.Background(), cfg.PhoneUnique); err != nil {
+		logger.Error("Failed to apply phone uniqueness setting", "error", err, "phone_unique", cfg.PhoneUnique)
+		os.Exit(1)
+	}
+
+	notifier := notifications.NewLogNotifier(logger)
+	tokenConfig := auth_service.TokenConfig{
+		SecretKey: cfg.JWTSecretKey,
+		Duration:  cfg.JWTDuration,
+	}
+	lockoutConfig := auth_service.LockoutConfig{
+		MaxFailedAttempts: cfg.LoginMaxFailedAttempts,
+		Duration:          cfg.LoginLockDuration,
+	}
+	impersonationTokenConfig := auth_service.TokenConfig{
+		SecretKey: cfg.JWTSecretKey,
+		Duration:  cfg.ImpersonationTTL,
+	}
+	emailChanger := user_service.EmailChanger{
+		Repository:  emailChangeRepository,
+		DomainRules: domainRules,
+		Notifier:    notifier,
+		TTL:         cfg.EmailChangeTTL,
+	}
+	userEmails := user_service.UserEmails{
+		Repository:  userEmailRepository,
+		DomainRules: domainRules,
+		Notifier:    notifier,
+		TTL:         cfg.UserEmailVerificationTTL,
+		MaxEmails:   cfg.UserEmailsMax,
+	}
+	phoneVerifier := user_service.PhoneVerifier{
+		Repository:     phoneVerificationRepository,
+		Sender:         sms.NewLogSender(logger),
+		TTL:            cfg.PhoneVerificationTTL,
+		ResendInterval: cfg.PhoneVerificationResend,
+		MaxAttempts:    cfg.PhoneVerificationAttempts,
+	}
+
+	// Курсоры списков подписываются производным от JWT секрета ключом
+	cursorCodec := cursor.NewCodec(cfg.JWTSecretKey)
+	bulkUpdater := user_service.BulkUpdater{
+		UserRepository:  userRepository,
+		Confirmations:   confirmation.NewCodec(cfg.JWTSecretKey, cfg.BulkUpdateConfirmationTTL),
+		AttributeSchema: attributeRepository,
+	}
 
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
@@ -76,6 +198,7 @@ func NewApp(logger *slog.Logger, cfg *config.Config) *App {
 	router.Use(middleware.URLFormat)
 	router.Use(middleware.Heartbeat("/health"))
 	router.Use(metricsMiddleware)
+	router.Use(middlewares.LocaleMiddleware)
 
 	router.Route("/api/v1", func(apiRouter chi.Router) {
 		apiRouter.Get("/swagger/*", httpSwagger.Handler(
@@ -84,11 +207,71 @@ func NewApp(logger *slog.Logger, cfg *config.Config) *App {
 
 		apiRouter.Handle("/metrics", promhttp.Handler())
 
-		apiRouter.Post("/register", users.CreateUser(logger, userRepository, cfg.ServerTimeout))
-		apiRouter.Get("/users/{id}", get_user.GetUserById(logger, userRepository, cfg.ServerTimeout))
-		apiRouter.Get("/users", get_user_list.GetUserList(logger, userRepository, cfg.ServerTimeout))
-		apiRouter.Put("/users/{id}", update_user.UpdateUserHandler(logger, userRepository, cfg.ServerTimeout))
-		apiRouter.Delete("/users/{id}", users_delete.DeleteUserHandler(logger, userRepository, cfg.ServerTimeout))
+		apiRouter.Post("/register", users.CreateUser(logger, userRepository, invitationRepository, attributeRepository, registrationPolicy, domainRules, cfg.ServerTimeout))
+
+		// Роуты с необязательной авторизацией. Запросы с токеном входа от имени пользователя попадают в журнал
+		apiRouter.Group(func(optionalAuthRouter chi.Router) {
+			optionalAuthRouter.Use(middlewares.OptionalAuthMiddleware(cfg.JWTSecretKey, userRepository, logger))
+			optionalAuthRouter.Use(middlewares.ImpersonationAuditMiddleware(impersonationAuditRepository, logger))
+			optionalAuthRouter.Get("/users/{id}", get_user.GetUserById(logger, userRepository, loginEventsRepository, attributeRepository, cfg.ServerTimeout))
+			optionalAuthRouter.Get("/users", get_user_list.GetUserList(logger, userRepository, loginEventsRepository, attributeRepository, cursorCodec, cfg.ServerTimeout))
+		})
+		apiRouter.Post("/users/email-change/confirm", email_change.ConfirmEmailChangeHandler(logger, emailChanger, cfg.ServerTimeout))
+		apiRouter.Post("/users/emails/verify", users_emails.VerifyUserEmailHandler(logger, userEmails, cfg.ServerTimeout))
+		apiRouter.Post("/login", login.LoginHandler(logger, userRepository, loginEventsRepository, notifier, tokenConfig, lockoutConfig, cfg.ServerTimeout))
+
+		// Роуты, требующие авторизации
+		apiRouter.Group(func(authRouter chi.Router) {
+			authRouter.Use(middlewares.AuthMiddleware(cfg.JWTSecretKey, userRepository, logger))
+			authRouter.Use(middlewares.ImpersonationAuditMiddleware(impersonationAuditRepository, logger))
+			authRouter.Get("/users/{id}/login-events", login_events.GetLoginEvents(logger, loginEventsRepository, cfg.ServerTimeout))
+			authRouter.Get("/users/me/preferences/{namespace}", users_preferences.GetPreferencesHandler(logger, preferencesRepository, preferencesSchema, cfg.ServerTimeout))
+			authRouter.Put("/users/me/preferences/{namespace}", users_preferences.PutPreferencesHandler(logger, preferencesRepository, preferencesSchema, cfg.ServerTimeout))
+			authRouter.Delete("/users/me/preferences/{namespace}", users_preferences.DeletePreferencesHandler(logger, preferencesRepository, preferencesSchema, cfg.ServerTimeout))
+			authRouter.Get("/users/me/emails", users_emails.GetUserEmailsHandler(logger, userEmails, cfg.ServerTimeout))
+
+			// Смена email, телефона, адресов входа и удаление пользователя недоступны при входе от имени пользователя
+			authRouter.Group(func(credentialsRouter chi.Router) {
+				credentialsRouter.Use(middlewares.DenyImpersonationMiddleware(logger))
+				credentialsRouter.Put("/users/{id}", update_user.UpdateUserHandler(logger, userRepository, emailChanger, attributeRepository, cfg.ServerTimeout))
+				credentialsRouter.Delete("/users/{id}", users_delete.DeleteUserHandler(logger, userRepository, cfg.ServerTimeout))
+				credentialsRouter.Patch("/users/{id}", update_user.PatchUserHandler(logger, userRepository, emailChanger, attributeRepository, cfg.ServerTimeout))
+				credentialsRouter.Post("/users/me/phone/verification", phone_verification.SendPhoneVerificationHandler(logger, userRepository, phoneVerifier, cfg.ServerTimeout))
+				credentialsRouter.Post("/users/me/phone/verification/confirm", phone_verification.ConfirmPhoneVerificationHandler(logger, phoneVerifier, cfg.ServerTimeout))
+				credentialsRouter.Post("/users/me/emails", users_emails.AddUserEmailHandler(logger, userRepository, userEmails, cfg.ServerTimeout))
+				credentialsRouter.Delete("/users/me/emails/{id}", users_emails.DeleteUserEmailHandler(logger, userEmails, cfg.ServerTimeout))
+				credentialsRouter.Post("/users/me/emails/{id}/primary", users_emails.SetPrimaryUserEmailHandler(logger, userEmails, cfg.ServerTimeout))
+			})
+		})
+
+		// Роуты пользователей, доступные только администратору
+		apiRouter.Group(func(adminUsersRouter chi.Router) {
+			adminUsersRouter.Use(middlewares.AuthAdminMiddleware(cfg.JWTSecretKey, userRepository, logger))
+			adminUsersRouter.Use(middlewares.ImpersonationAuditMiddleware(impersonationAuditRepository, logger))
+			adminUsersRouter.Post("/users/{id}/restore", restore.RestoreUserHandler(logger, userRepository, cfg.ServerTimeout))
+			adminUsersRouter.Get("/users/export", users_export.ExportUsersHandler(logger, userRepository, attributeRepository, metricses, cfg.UsersExportTimeout))
+			adminUsersRouter.Post("/users/import", users_import.ImportUsersHandler(logger, userRepository, attributeRepository, cfg.UsersImportMaxRows, cfg.UsersImportTimeout))
+			adminUsersRouter.Post("/users/batch", users_batch.BatchUsersHandler(logger, userRepository, attributeRepository, cfg.UsersImportTimeout))
+			adminUsersRouter.Post("/users/bulk-update", bulk.BulkUpdateHandler(logger, bulkUpdater, cfg.UsersBulkUpdateTimeout))
+		})
+
+		// Роуты администратора
+		apiRouter.Route("/admin", func(adminRouter chi.Router) {
+			adminRouter.Use(middlewares.AuthAdminMiddleware(cfg.JWTSecretKey, userRepository, logger))
+			adminRouter.Use(middlewares.ImpersonationAuditMiddleware(impersonationAuditRepository, logger))
+			adminRouter.With(middlewares.DenyImpersonationMiddleware(logger)).
+				Post("/users/{id}/impersonate", impersonate.ImpersonateHandler(logger, userRepository, impersonationAuditRepository, impersonationTokenConfig, cfg.ServerTimeout))
+			adminRouter.Post("/users/{id}/suspend", status.SuspendUserHandler(logger, userRepository, cfg.ServerTimeout))
+			adminRouter.Post("/users/{id}/reactivate", status.ReactivateUserHandler(logger, userRepository, cfg.ServerTimeout))
+			adminRouter.Post("/invitations", invitations.CreateInvitationHandler(logger, invitationRepository, notifier, cfg.InvitationTTL, cfg.ServerTimeout))
+			adminRouter.Get("/invitations", invitations.GetInvitationsHandler(logger, invitationRepository, cfg.ServerTimeout))
+			adminRouter.Delete("/invitations/{id}", invitations.RevokeInvitationHandler(logger, invitationRepository, cfg.ServerTimeout))
+			adminRouter.Post("/email-domains/reload", email_domains_handlers.ReloadHandler(logger, domainRules))
+			adminRouter.Get("/attributes", attributes.GetAttributesHandler(logger, attributeRepository, cfg.ServerTimeout))
+			adminRouter.Post("/attributes", attributes.CreateAttributeHandler(logger, attributeRepository, cfg.ServerTimeout))
+			adminRouter.Put("/attributes/{name}", attributes.UpdateAttributeHandler(logger, attributeRepository, cfg.ServerTimeout))
+			adminRouter.Delete("/attributes/{name}", attributes.DeleteAttributeHandler(logger, attributeRepository, cfg.ServerTimeout))
+		})
 
 	})
 
@@ -99,7 +282,7 @@ func NewApp(logger *slog.Logger, cfg *config.Config) *App {
 		ReadHeaderTimeout: cfg.ServerTimeout,
 		WriteTimeout:      cfg.ServerTimeout,
 	}
-	return &App{cfg: cfg, logger: logger, HTTPServer: srv}
+	return &App{cfg: cfg, logger: logger, HTTPServer: srv, userRepository: userRepository}
 }
 
 // Run запускает HTTP-сервер и ожидает сигналов для graceful shutdown.
@@ -107,6 +290,11 @@ func NewApp(logger *slog.Logger, cfg *config.Config) *App {
 func (a *App) Run() {
 	a.logger.Info("Starting HTTP server", slog.String("address", a.cfg.Address))
 
+	// Фоновые задачи останавливаются вместе с сервером
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go user_service.RunPurge(backgroundCtx, a.logger, a.userRepository, a.cfg.DeletedUsersRetention, a.cfg.DeletedUsersPurgeInterval)
+
 	// Запуск сервера в горутине для возможности graceful shutdown
 	go func() {
 		if err := a.HTTPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -120,6 +308,7 @@ func (a *App) Run() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	a.logger.Info("Shutting down server...")
+	stopBackground()
 
 	// Graceful shutdown с таймаутом
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Можно вынести в config
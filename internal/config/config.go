@@ -8,21 +8,52 @@ import (
 
 // Config представляет конфигурацию приложения
 type Config struct {
-	Env                 string        `yaml:"ENV" env:"ENV" env-default:"production"`
-	Address             string        `yaml:"address" env:"ADDRESS" env-default:"localhost:8080"`
-	DbHost              string        `yaml:"db_host" env:"DB_HOST" env-required:"true" `
-	DbPort              string        `yaml:"db_port" env:"DB_PORT" env-required:"true"`
-	DbName              string        `yaml:"db_name" env:"DB_NAME" env-required:"true"`
-	DbUser              string        `yaml:"db_user" env:"DB_USER" env-required:"true"`
-	DbPassword          string        `yaml:"db_password" env:"DB_PASSWORD" env-required:"true"`
-	DbMaxConnections    int32         `yaml:"db_max_connections" env:"DB_MAX_CONNECTIONS"`
-	DbMinConnections    int32         `yaml:"db_min_connections" env:"DB_MIN_CONNECTIONS"`
-	DbMaxConnLifetime   time.Duration `yaml:"db_max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
-	DbMaxConnIdleTime   time.Duration `yaml:"db_max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
-	DbHealthCheckPeriod time.Duration `yaml:"db_health_check_period" env:"DB_HEALTH_CHECK_PERIOD"`
-	JWTSecretKey        string        `yaml:"jwt_secret_key" env:"JWT_SECRET_KEY" env-required:"true"`
-	JWTDuration         time.Duration `yaml:"jwt_duration"  env:"JWT_DURATION" env-default:"5m"`
-	ServerTimeout       time.Duration `yaml:"server_timeout" env:"SERVER_TIMEOUT" env-default:"10s"`
+	Env                        string        `yaml:"ENV" env:"ENV" env-default:"production"`
+	Address                    string        `yaml:"address" env:"ADDRESS" env-default:"localhost:8080"`
+	DbHost                     string        `yaml:"db_host" env:"DB_HOST" env-required:"true" `
+	DbPort                     string        `yaml:"db_port" env:"DB_PORT" env-required:"true"`
+	DbName                     string        `yaml:"db_name" env:"DB_NAME" env-required:"true"`
+	DbUser                     string        `yaml:"db_user" env:"DB_USER" env-required:"true"`
+	DbPassword                 string        `yaml:"db_password" env:"DB_PASSWORD" env-required:"true"`
+	DbMaxConnections           int32         `yaml:"db_max_connections" env:"DB_MAX_CONNECTIONS"`
+	DbMinConnections           int32         `yaml:"db_min_connections" env:"DB_MIN_CONNECTIONS"`
+	DbMaxConnLifetime          time.Duration `yaml:"db_max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+	DbMaxConnIdleTime          time.Duration `yaml:"db_max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
+	DbHealthCheckPeriod        time.Duration `yaml:"db_health_check_period" env:"DB_HEALTH_CHECK_PERIOD"`
+	JWTSecretKey               string        `yaml:"jwt_secret_key" env:"JWT_SECRET_KEY" env-required:"true"`
+	JWTDuration                time.Duration `yaml:"jwt_duration"  env:"JWT_DURATION" env-default:"5m"`
+	ServerTimeout              time.Duration `yaml:"server_timeout" env:"SERVER_TIMEOUT" env-default:"10s"`
+	ImpersonationTTL           time.Duration `yaml:"impersonation_ttl" env:"IMPERSONATION_TTL" env-default:"15m"`
+	RegistrationMode           string        `yaml:"registration_mode" env:"REGISTRATION_MODE" env-default:"open"`
+	RegistrationAllowedDomains []string      `yaml:"registration_allowed_domains" env:"REGISTRATION_ALLOWED_DOMAINS" env-separator:","`
+	RegistrationApproval       bool          `yaml:"registration_require_approval" env:"REGISTRATION_REQUIRE_APPROVAL" env-default:"false"`
+	InvitationTTL              time.Duration `yaml:"invitation_ttl" env:"INVITATION_TTL" env-default:"168h"`
+	EmailAllowedDomains        []string      `yaml:"email_allowed_domains" env:"EMAIL_ALLOWED_DOMAINS" env-separator:","`
+	EmailDeniedDomains         []string      `yaml:"email_denied_domains" env:"EMAIL_DENIED_DOMAINS" env-separator:","`
+	EmailAllowedDomainsFile    string        `yaml:"email_allowed_domains_file" env:"EMAIL_ALLOWED_DOMAINS_FILE"`
+	EmailDeniedDomainsFile     string        `yaml:"email_denied_domains_file" env:"EMAIL_DENIED_DOMAINS_FILE"`
+	EmailDisposableDomainsFile string        `yaml:"email_disposable_domains_file" env:"EMAIL_DISPOSABLE_DOMAINS_FILE"`
+	EmailBlockDisposable       bool          `yaml:"email_block_disposable" env:"EMAIL_BLOCK_DISPOSABLE" env-default:"true"`
+	EmailChangeTTL             time.Duration `yaml:"email_change_ttl" env:"EMAIL_CHANGE_TTL" env-default:"24h"`
+	EmailCanonicalizeProviders bool          `yaml:"email_canonicalize_providers" env:"EMAIL_CANONICALIZE_PROVIDERS" env-default:"false"`
+	UserEmailVerificationTTL   time.Duration `yaml:"user_email_verification_ttl" env:"USER_EMAIL_VERIFICATION_TTL" env-default:"24h"`
+	UserEmailsMax              int           `yaml:"user_emails_max" env:"USER_EMAILS_MAX" env-default:"5"`
+	DeletedUsersRetention      time.Duration `yaml:"deleted_users_retention" env:"DELETED_USERS_RETENTION" env-default:"720h"`
+	DeletedUsersPurgeInterval  time.Duration `yaml:"deleted_users_purge_interval" env:"DELETED_USERS_PURGE_INTERVAL" env-default:"1h"`
+	UsersExportTimeout         time.Duration `yaml:"users_export_timeout" env:"USERS_EXPORT_TIMEOUT" env-default:"10m"`
+	UsersImportTimeout         time.Duration `yaml:"users_import_timeout" env:"USERS_IMPORT_TIMEOUT" env-default:"5m"`
+	UsersImportMaxRows         int           `yaml:"users_import_max_rows" env:"USERS_IMPORT_MAX_ROWS" env-default:"5000"`
+	UsersBulkUpdateTimeout     time.Duration `yaml:"users_bulk_update_timeout" env:"USERS_BULK_UPDATE_TIMEOUT" env-default:"10m"`
+	BulkUpdateConfirmationTTL  time.Duration `yaml:"bulk_update_confirmation_ttl" env:"BULK_UPDATE_CONFIRMATION_TTL" env-default:"10m"`
+	PreferencesSchemaFile      string        `yaml:"preferences_schema_file" env:"PREFERENCES_SCHEMA_FILE"`
+	PreferencesMaxBytes        int           `yaml:"preferences_max_bytes" env:"PREFERENCES_MAX_BYTES" env-default:"16384"`
+	PhoneDefaultRegion         string        `yaml:"phone_default_region" env:"PHONE_DEFAULT_REGION" env-default:"RU"`
+	PhoneUnique                bool          `yaml:"phone_unique" env:"PHONE_UNIQUE" env-default:"false"`
+	PhoneVerificationTTL       time.Duration `yaml:"phone_verification_ttl" env:"PHONE_VERIFICATION_TTL" env-default:"10m"`
+	PhoneVerificationResend    time.Duration `yaml:"phone_verification_resend_interval" env:"PHONE_VERIFICATION_RESEND_INTERVAL" env-default:"1m"`
+	PhoneVerificationAttempts  int           `yaml:"phone_verification_max_attempts" env:"PHONE_VERIFICATION_MAX_ATTEMPTS" env-default:"5"`
+	LoginMaxFailedAttempts     int           `yaml:"login_max_failed_attempts" env:"LOGIN_MAX_FAILED_ATTEMPTS" env-default:"10"`
+	LoginLockDuration          time.Duration `yaml:"login_lock_duration" env:"LOGIN_LOCK_DURATION" env-default:"15m"`
 }
 
 // LoadConfig загружает конфигурацию из файла и переменных окружения
@@ -1,10 +1,19 @@
 package authorization
 
 import (
+	"context"
+	"errors"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/jwt_tokens"
 	"github.com/golang-jwt/jwt/v5"
+	"strconv"
 )
 
+// TokenClaimsKey ключ, под которым AuthMiddleware кладёт claims токена в контекст запроса
+const TokenClaimsKey = "tokenClaims"
+
+var ErrNoClaims = errors.New("token claims not found in context")
+var ErrInvalidSubject = errors.New("token subject is invalid")
+
 // Authorization проверяет предоставленный токен и получает аргументы тела токена
 func Authorization(tokenString string, secretKey string) (jwt.MapClaims, error) {
 	jwtClaims, err := jwt_tokens.VerifyToken(tokenString, secretKey)
@@ -13,3 +22,59 @@ func Authorization(tokenString string, secretKey string) (jwt.MapClaims, error)
 	}
 	return jwtClaims, nil
 }
+
+// GetClaims достаёт claims токена из контекста запроса
+func GetClaims(ctx context.Context) (jwt.MapClaims, error) {
+	claims, ok := ctx.Value(TokenClaimsKey).(jwt.MapClaims)
+	if !ok {
+		return nil, ErrNoClaims
+	}
+	return claims, nil
+}
+
+// GetUserID возвращает Id пользователя из claim sub
+func GetUserID(claims jwt.MapClaims) (int64, error) {
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return 0, ErrInvalidSubject
+	}
+	id, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSubject
+	}
+	return id, nil
+}
+
+// GetActorID возвращает Id администратора из claim act, если токен выпущен для входа от имени пользователя.
+// Второе значение false означает, что токен обычный
+func GetActorID(claims jwt.MapClaims) (int64, bool) {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	subject, ok := act["sub"].(string)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// IsAdmin проверяет, есть ли у владельца токена роль администратора
+func IsAdmin(claims jwt.MapClaims) bool {
+	role, ok := claims["user_role"].(string)
+	return ok && role == "admin"
+}
+
+// CanAccessUser проверяет, может ли владелец токена работать с данными пользователя userId.
+// Доступ есть у самого пользователя и у администратора
+func CanAccessUser(claims jwt.MapClaims, userId int64) bool {
+	if IsAdmin(claims) {
+		return true
+	}
+	tokenUserId, err := GetUserID(claims)
+	return err == nil && tokenUserId == userId
+}
@@ -0,0 +1,94 @@
+package body
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/go-playground/validator"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// MergePatchContentType тип содержимого JSON Merge Patch (RFC 7396)
+const MergePatchContentType = "application/merge-patch+json"
+
+// MaxMergePatchBytes наибольший размер тела JSON Merge Patch
+const MaxMergePatchBytes = 1 << 20
+
+var ErrUnsupportedMediaType = errors.New("content type must be application/merge-patch+json or application/json")
+var ErrPatchNotObject = errors.New("merge patch must be a JSON object")
+var ErrPatchTooLarge = errors.New("merge patch is too large")
+
+// PatchFieldError Ошибка в отдельном поле патча (неизвестное поле, поле только для чтения, null для поля, которое нельзя удалить)
+type PatchFieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *PatchFieldError) Error() string {
+	return fmt.Sprintf("field %s %s", e.Field, e.Reason)
+}
+
+// DecodeMergePatch декодирует тело запроса в формате JSON Merge Patch (RFC 7396) в структуру v и валидирует её.
+//
+// Поля структуры v должны быть указателями с тегом validate "omitempty,...": так валидируются только переданные поля.
+// allowed — список полей патча (по имени в JSON), которые можно менять, removable — те из них, которые можно удалить,
+// передав null. Для остальных полей null считается ошибкой. Тело больше MaxMergePatchBytes отклоняется с ErrPatchTooLarge.
+//
+// Возвращает поля, переданные в патче: true — поле передано со значением null и должно быть удалено
+func DecodeMergePatch(w http.ResponseWriter, r *http.Request, v interface{}, allowed, removable []string) (map[string]bool, error) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != MergePatchContentType && mediaType != "application/json") {
+			return nil, ErrUnsupportedMediaType
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMergePatchBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, ErrPatchTooLarge
+		}
+		return nil, ErrDecodeJSON
+	}
+	var patch map[string]json.RawMessage
+	if err = json.Unmarshal(data, &patch); err != nil {
+		return nil, ErrDecodeJSON
+	}
+	if patch == nil {
+		return nil, ErrPatchNotObject
+	}
+
+	allowedFields := make(map[string]struct{}, len(allowed))
+	for _, field := range allowed {
+		allowedFields[field] = struct{}{}
+	}
+	removableFields := make(map[string]struct{}, len(removable))
+	for _, field := range removable {
+		removableFields[field] = struct{}{}
+	}
+	fields := make(map[string]bool, len(patch))
+	for field, value := range patch {
+		if _, ok := allowedFields[field]; !ok {
+			return nil, &PatchFieldError{Field: field, Reason: "cannot be changed"}
+		}
+		removed := bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+		if _, ok := removableFields[field]; removed && !ok {
+			return nil, &PatchFieldError{Field: field, Reason: "cannot be removed"}
+		}
+		fields[field] = removed
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return nil, ErrDecodeJSON
+	}
+	if err = validators.GetValidator().Struct(v); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		return nil, validateErr
+	}
+	return fields, nil
+}
@@ -0,0 +1,70 @@
+package confirmation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/signed"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("confirmation token is invalid")
+var ErrTokenExpired = errors.New("confirmation token has expired, run the preview again")
+var ErrTokenMismatch = errors.New("confirmation token was issued for another request")
+
+// token Содержимое токена подтверждения: хеш подтверждаемого запроса, результат предпросмотра и срок действия
+type token struct {
+	Subject   string `json:"h"`
+	Count     int64  `json:"n"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// Codec Выдаёт и проверяет токены подтверждения опасных операций.
+// Токен выдаётся при предпросмотре операции и подтверждает, что клиент видел его результат для того же запроса.
+// Хеш subject, результат предпросмотра и срок действия подписываются вместе, изменить срок без ключа нельзя
+type Codec struct {
+	signer signed.Signer
+	ttl    time.Duration
+}
+
+// NewCodec создаёт Codec. Ключ подписи выводится из secret, ttl — срок действия токенов
+func NewCodec(secret string, ttl time.Duration) *Codec {
+	return &Codec{signer: signed.NewSigner(secret, "confirmation-token"), ttl: ttl}
+}
+
+// Issue выдаёт токен для запроса subject с результатом предпросмотра count
+func (c *Codec) Issue(subject []byte, count int64) (string, time.Time, error) {
+	expiresAt := time.Now().Add(c.ttl).Truncate(time.Second)
+	payload, err := json.Marshal(token{Subject: hashSubject(subject), Count: count, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return c.signer.Seal(payload), expiresAt, nil
+}
+
+// Verify проверяет подпись и срок токена и то, что он выдан для запроса subject.
+// Возвращает результат предпросмотра, для которого выдан токен
+func (c *Codec) Verify(value string, subject []byte) (int64, error) {
+	payload, err := c.signer.Open(value)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	var decoded token
+	if err = json.Unmarshal(payload, &decoded); err != nil {
+		return 0, ErrInvalidToken
+	}
+	if time.Now().Unix() >= decoded.ExpiresAt {
+		return 0, ErrTokenExpired
+	}
+	if !hmac.Equal([]byte(decoded.Subject), []byte(hashSubject(subject))) {
+		return 0, ErrTokenMismatch
+	}
+	return decoded.Count, nil
+}
+
+func hashSubject(subject []byte) string {
+	sum := sha256.Sum256(subject)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,35 @@
+package confirmation_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/confirmation"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCodec(t *testing.T) {
+	codec := confirmation.NewCodec("secret", time.Minute)
+	subject := []byte(`{"query":"filter%5Bemail%5D%5Bsuffix%5D=%40contractor.com"}`)
+
+	token, expiresAt, err := codec.Issue(subject, 42)
+	require.NoError(t, err)
+	require.True(t, expiresAt.After(time.Now()))
+
+	count, err := codec.Verify(token, subject)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), count)
+
+	_, err = codec.Verify(token, []byte(`{"query":""}`))
+	require.ErrorIs(t, err, confirmation.ErrTokenMismatch)
+
+	_, err = confirmation.NewCodec("other", time.Minute).Verify(token, subject)
+	require.ErrorIs(t, err, confirmation.ErrInvalidToken)
+
+	_, err = codec.Verify("garbage", subject)
+	require.ErrorIs(t, err, confirmation.ErrInvalidToken)
+
+	expired, _, err := confirmation.NewCodec("secret", -time.Minute).Issue(subject, 42)
+	require.NoError(t, err)
+	_, err = codec.Verify(expired, subject)
+	require.ErrorIs(t, err, confirmation.ErrTokenExpired)
+}
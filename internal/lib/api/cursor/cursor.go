@@ -0,0 +1,56 @@
+package cursor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/signed"
+)
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// Cursor Позиция в списке для keyset пагинации.
+// Values — значения ключа сортировки (полей Sort) у граничной записи страницы
+type Cursor struct {
+	Sort     []query_params.SortParam `json:"s"`
+	Values   []interface{}            `json:"v"`
+	Backward bool                     `json:"b,omitempty"` // Курсор указывает на записи перед граничной (предыдущая страница)
+}
+
+// Codec Кодирует курсоры в непрозрачные строки и проверяет их подпись,
+// что б клиент не мог подставить в запрос произвольные значения ключа
+type Codec struct {
+	signer signed.Signer
+}
+
+// NewCodec создаёт Codec. Ключ подписи выводится из secret, что б не использовать секрет напрямую
+func NewCodec(secret string) *Codec {
+	return &Codec{signer: signed.NewSigner(secret, "list-cursor")}
+}
+
+// Encode возвращает курсор в виде строки payload.signature (base64url)
+func (c *Codec) Encode(cursor Cursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return c.signer.Seal(payload), nil
+}
+
+// Decode проверяет подпись и разбирает курсор.
+// Числа в Values возвращаются как json.Number
+func (c *Codec) Decode(value string) (Cursor, error) {
+	payload, err := c.signer.Open(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err = decoder.Decode(&cursor); err != nil || len(cursor.Sort) == 0 || len(cursor.Sort) != len(cursor.Values) {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,48 @@
+package cursor_test
+
+import (
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/cursor"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	codec := cursor.NewCodec("secret")
+	original := cursor.Cursor{
+		Sort: []query_params.SortParam{
+			{Field: "last_name", Order: "desc"},
+			{Field: "id", Order: "asc"},
+		},
+		Values:   []interface{}{"Gosling", int64(42)},
+		Backward: true,
+	}
+
+	encoded, err := codec.Encode(original)
+	require.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		decoded, err := codec.Decode(encoded)
+		require.NoError(t, err)
+		require.Equal(t, original.Sort, decoded.Sort)
+		require.Equal(t, []interface{}{"Gosling", json.Number("42")}, decoded.Values)
+		require.True(t, decoded.Backward)
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		tampered := "x" + encoded[1:]
+		_, err := codec.Decode(tampered)
+		require.ErrorIs(t, err, cursor.ErrInvalidCursor)
+	})
+
+	t.Run("signed with another secret", func(t *testing.T) {
+		_, err := cursor.NewCodec("another secret").Decode(encoded)
+		require.ErrorIs(t, err, cursor.ErrInvalidCursor)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		_, err := codec.Decode("not-a-cursor")
+		require.ErrorIs(t, err, cursor.ErrInvalidCursor)
+	})
+}
@@ -0,0 +1,70 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AnyVersion версия, которую возвращает ParseIfMatch для "If-Match: *": проверка версии не нужна
+const AnyVersion int64 = 0
+
+var ErrPreconditionRequired = errors.New("If-Match header is required")
+var ErrInvalidPrecondition = errors.New("If-Match header must contain a single strong ETag or *")
+
+// Format возвращает сильный ETag для версии ресурса
+func Format(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// representationDigestLength сколько символов хеша представления попадает в ETag
+const representationDigestLength = 16
+
+// ForRepresentation возвращает сильный ETag конкретного представления ресурса версии version:
+// версия и хеш тела ответа. Представления одной версии с разным набором полей или для разных
+// пользователей получают разные ETag. Версию из такого ETag понимает ParseIfMatch
+func ForRepresentation(version int64, body []byte) string {
+	digest := sha256.Sum256(body)
+	return `"` + strconv.FormatInt(version, 10) + "-" + hex.EncodeToString(digest[:])[:representationDigestLength] + `"`
+}
+
+// ParseIfMatch достаёт из заголовка If-Match версию, которую клиент видел последней.
+// Подходят ETag из Format и ForRepresentation, у последнего учитывается только версия.
+// Для "*" возвращается AnyVersion. Слабые ETag (W/"...") для If-Match не подходят
+func ParseIfMatch(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		return 0, ErrPreconditionRequired
+	}
+	if value == "*" {
+		return AnyVersion, nil
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, ErrInvalidPrecondition
+	}
+	versionValue, _, _ := strings.Cut(value[1:len(value)-1], "-")
+	version, err := strconv.ParseInt(versionValue, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalidPrecondition
+	}
+	return version, nil
+}
+
+// NoneMatch проверяет, совпадает ли один из ETag в If-None-Match с текущим.
+// Для If-None-Match используется слабое сравнение, поэтому префикс W/ игнорируется
+func NoneMatch(r *http.Request, current string) bool {
+	value := r.Header.Get("If-None-Match")
+	if value == "" {
+		return false
+	}
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
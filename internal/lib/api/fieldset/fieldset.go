@@ -0,0 +1,26 @@
+package fieldset
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Select возвращает JSON представление value, в котором оставлены только поля с перечисленными json именами.
+// Используется для ответа на запросы с ?fields=...
+func Select(value interface{}, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value: %w", err)
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if raw, ok := all[field]; ok {
+			selected[field] = raw
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/client_info"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/impersonation_audit_db"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ImpersonationAuditMiddleware записывает в лог и в журнал каждый запрос, выполненный
+// с токеном входа от имени пользователя. В записи сохраняются обе личности: администратор и пользователь.
+//
+// Должен подключаться после AuthMiddleware, так как использует claims из контекста
+func ImpersonationAuditMiddleware(auditRepository impersonation_audit_db.ImpersonationAuditRepository, log *slog.Logger) func(next http.Handler) http.Handler {
+	const op = "internal/lib/api/middlewares/impersonation.go/ImpersonationAuditMiddleware"
+	log = log.With(slog.String("op", op))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := authorization.GetClaims(r.Context())
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			adminId, impersonated := authorization.GetActorID(claims)
+			if !impersonated {
+				next.ServeHTTP(w, r)
+				return
+			}
+			targetId, _ := authorization.GetUserID(claims)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			record := impersonation_audit_db.AuditRecord{
+				AdminID:      adminId,
+				TargetUserID: targetId,
+				Action:       impersonation_audit_db.ActionRequest,
+				Method:       r.Method,
+				Path:         r.URL.RequestURI(),
+				Status:       ww.Status(),
+				RequestID:    middleware.GetReqID(r.Context()),
+				IPAddress:    client_info.ClientIP(r),
+			}
+			log.Info("Request made under impersonation",
+				slog.Int64("admin_id", record.AdminID),
+				slog.Int64("target_user_id", record.TargetUserID),
+				slog.String("method", record.Method),
+				slog.String("path", record.Path),
+				slog.Int("status", record.Status),
+				slog.String("request_id", record.RequestID))
+
+			// Запрос уже обработан, поэтому журнал пишем с отдельным контекстом: контекст запроса может быть отменён
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+			defer cancel()
+			if err := auditRepository.AddAuditRecord(ctx, &record); err != nil {
+				log.Error("Failed to save impersonation audit record", "error", err)
+			}
+		})
+	}
+}
+
+// DenyImpersonationMiddleware запрещает выполнять запрос с токеном входа от имени пользователя.
+// Подключается к чувствительным действиям: смена пароля, email, MFA, выдача новых токенов итп.
+//
+// Должен подключаться после AuthMiddleware, так как использует claims из контекста
+func DenyImpersonationMiddleware(log *slog.Logger) func(next http.Handler) http.Handler {
+	const op = "internal/lib/api/middlewares/impersonation.go/DenyImpersonationMiddleware"
+	log = log.With(slog.String("op", op))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := authorization.GetClaims(r.Context())
+			if err == nil {
+				if adminId, impersonated := authorization.GetActorID(claims); impersonated {
+					log.Warn("Sensitive action blocked under impersonation", "admin_id", adminId, "path", r.URL.Path)
+					resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Action is not allowed while impersonating a user"))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,22 +2,33 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
 	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
-	"github.com/go-chi/render"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/i18n"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
 	"github.com/golang-jwt/jwt/v5"
 	"log/slog"
 	"net/http"
 	"strings"
 )
 
-// AuthMiddleware проверяет токен авторизации при выполнении запроса
+// UserStatusSource Источник текущего статуса пользователя (см. users_db.UserRepository)
+type UserStatusSource interface {
+	GetUserFields(ctx context.Context, userId int64, fields []string) (users_db.UserInfo, error)
+}
+
+// AuthMiddleware проверяет токен авторизации при выполнении запроса.
+// Токен выпускается на время, поэтому при каждом запросе проверяется и сам пользователь:
+// удалённый пользователь получает 401, пользователь в неактивном статусе (см. user_status) — 403.
+// Для токена входа от имени пользователя так же проверяется администратор
 //
 // # При успехе передаёт обработку следующему хендлеру
 //
 // При ошибке возвращает статус код 401 и ошибку
-func AuthMiddleware(secretKey string, log *slog.Logger) func(next http.Handler) http.Handler {
+func AuthMiddleware(secretKey string, users UserStatusSource, log *slog.Logger) func(next http.Handler) http.Handler {
 	const op = "internal/lib/api/middlewares/middlewares.go/AuthMiddleware"
 	log = log.With(slog.String("op", op))
 	return func(next http.Handler) http.Handler {
@@ -41,7 +52,13 @@ func AuthMiddleware(secretKey string, log *slog.Logger) func(next http.Handler)
 				return
 			}
 			log.Debug("Authorization token is valid", slog.Any("claims", claims))
-			ctx := context.WithValue(r.Context(), "tokenClaims", claims)
+			if !checkUserActive(w, r, log, users, claims) {
+				return
+			}
+			ctx := context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)
+			if locale, ok := claims["locale"].(string); ok {
+				ctx = i18n.WithUserLocale(ctx, locale)
+			}
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 
@@ -50,25 +67,87 @@ func AuthMiddleware(secretKey string, log *slog.Logger) func(next http.Handler)
 	}
 }
 
+// OptionalAuthMiddleware проверяет токен авторизации, только если он передан.
+// Запрос без заголовка Authorization передаётся дальше без claims в контексте,
+// с невалидным токеном — отклоняется со статус кодом 401
+func OptionalAuthMiddleware(secretKey string, users UserStatusSource, log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		auth := AuthMiddleware(secretKey, users, log)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			auth.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LocaleMiddleware выбирает язык сообщений ответа по заголовку Accept-Language.
+// Если заголовка нет или язык не поддерживается, сообщения будут на языке пользователя из токена (см. AuthMiddleware)
+func LocaleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if locale := i18n.Negotiate(r.Header.Get("Accept-Language")); locale != "" {
+			r = r.WithContext(i18n.WithRequestedLocale(r.Context(), locale))
+		}
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkUserActive проверяет, что владелец токена (и администратор, если это токен входа от имени пользователя)
+// не удалён и может работать с сервисом. Если нет, отвечает ошибкой и возвращает false
+func checkUserActive(w http.ResponseWriter, r *http.Request, log *slog.Logger, users UserStatusSource, claims jwt.MapClaims) bool {
+	userId, err := authorization.GetUserID(claims)
+	if err != nil {
+		renderUnauthorized(w, r, log, fmt.Sprintf("Authorization token is invalid: %v", err))
+		return false
+	}
+	ids := []int64{userId}
+	if actorId, impersonated := authorization.GetActorID(claims); impersonated {
+		ids = append(ids, actorId)
+	}
+	for _, id := range ids {
+		user, err := users.GetUserFields(r.Context(), id, []string{"status"})
+		if err != nil {
+			if errors.Is(err, users_db.ErrUserNotFound) {
+				renderUnauthorized(w, r, log, "User not found")
+				return false
+			}
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
+				return false
+			}
+			log.Error("Failed to get user status", "err", err, "user_id", id)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+			return false
+		}
+		if !user_status.CanLogin(user.Status) {
+			log.Info("Request rejected by account status", "user_id", id, "status", user.Status)
+			resp.RenderResponse(w, r, http.StatusForbidden, resp.ErrorWithCode("account_"+user.Status, "Account is "+user.Status))
+			return false
+		}
+	}
+	return true
+}
+
 func renderUnauthorized(w http.ResponseWriter, r *http.Request, log *slog.Logger, msg string) {
 	log.Error(msg)
-	render.Status(r, http.StatusUnauthorized)
-	render.JSON(w, r, resp.Error(msg))
+	resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error(msg))
 }
 
-func AuthAdminMiddleware(secretKey string, log *slog.Logger) func(next http.Handler) http.Handler {
+func AuthAdminMiddleware(secretKey string, users UserStatusSource, log *slog.Logger) func(next http.Handler) http.Handler {
 	const op = "internal/lib/api/middlewares/middlewares.go/AuthAdminMiddleware"
 	log = log.With(slog.String("op", op))
 
 	return func(next http.Handler) http.Handler {
 		// Используем AuthMiddleware для проверки авторизации
-		return AuthMiddleware(secretKey, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		return AuthMiddleware(secretKey, users, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Извлекаем claims из контекста
-			claims, ok := r.Context().Value("tokenClaims").(jwt.MapClaims)
+			claims, ok := r.Context().Value(authorization.TokenClaimsKey).(jwt.MapClaims)
 			if !ok {
 				log.Error("Failed to retrieve claims from context")
-				render.Status(r, http.StatusInternalServerError)
-				render.JSON(w, r, resp.Error("Internal server error"))
+				resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
 				return
 			}
 
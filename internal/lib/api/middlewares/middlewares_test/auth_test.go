@@ -0,0 +1,103 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/middlewares"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/jwt_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const secretKey = "secret"
+
+// statusSource статусы пользователей по Id, пользователей без статуса нет
+type statusSource map[int64]string
+
+func (s statusSource) GetUserFields(ctx context.Context, userId int64, fields []string) (users_db.UserInfo, error) {
+	if userId == 500 {
+		return users_db.UserInfo{}, errors.New("connection refused")
+	}
+	status, ok := s[userId]
+	if !ok {
+		return users_db.UserInfo{}, users_db.ErrUserNotFound
+	}
+	return users_db.UserInfo{ID: userId, Status: status}, nil
+}
+
+func TestAuthMiddlewareChecksUserStatus(t *testing.T) {
+	users := statusSource{
+		1: user_status.Active,
+		2: user_status.Suspended,
+		3: user_status.Locked,
+		4: user_status.Deactivated,
+		9: user_status.Suspended,
+	}
+	token := func(userId int64) string {
+		token, err := jwt_tokens.CreateToken(userId, "user", "", secretKey, time.Minute)
+		require.NoError(t, err)
+		return token
+	}
+	impersonationToken := func(userId, actorId int64) string {
+		token, err := jwt_tokens.CreateImpersonationToken(userId, "user", actorId, secretKey, time.Minute)
+		require.NoError(t, err)
+		return token
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "active user", token: token(1), expectedCode: http.StatusOK},
+		{name: "suspended user", token: token(2), expectedCode: http.StatusForbidden,
+			expectedBody: `{"status":"ERROR","error":"Account is suspended","code":"account_suspended"}`},
+		{name: "locked user", token: token(3), expectedCode: http.StatusForbidden,
+			expectedBody: `{"status":"ERROR","error":"Account is locked","code":"account_locked"}`},
+		{name: "deactivated user", token: token(4), expectedCode: http.StatusForbidden,
+			expectedBody: `{"status":"ERROR","error":"Account is deactivated","code":"account_deactivated"}`},
+		{name: "deleted user", token: token(5), expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"status":"ERROR","error":"User not found","code":"user_not_found"}`},
+		{name: "impersonation by suspended admin", token: impersonationToken(1, 9), expectedCode: http.StatusForbidden,
+			expectedBody: `{"status":"ERROR","error":"Account is suspended","code":"account_suspended"}`},
+		{name: "status lookup fails", token: token(500), expectedCode: http.StatusInternalServerError},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middlewares.AuthMiddleware(secretKey, users, slog.Default())(next)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOptionalAuthMiddlewareSkipsAnonymousRequests(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	handler := middlewares.OptionalAuthMiddleware(secretKey, statusSource{}, slog.Default())(next)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	require.True(t, called)
+}
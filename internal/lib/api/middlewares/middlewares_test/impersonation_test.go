@@ -0,0 +1,110 @@
+package middlewares_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/middlewares"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/jwt_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/impersonation_audit_db"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockImpersonationAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockImpersonationAuditRepository) AddAuditRecord(ctx context.Context, record *impersonation_audit_db.AuditRecord) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+// impersonationRouter повторяет подключение middleware в app.go: запросы от имени пользователя
+// записываются в журнал, а к смене учётных данных не допускаются
+func impersonationRouter(auditRepository impersonation_audit_db.ImpersonationAuditRepository) http.Handler {
+	users := statusSource{7: user_status.Active, 1: user_status.Active}
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	router := chi.NewRouter()
+	router.Group(func(optionalAuthRouter chi.Router) {
+		optionalAuthRouter.Use(middlewares.OptionalAuthMiddleware(secretKey, users, slog.Default()))
+		optionalAuthRouter.Use(middlewares.ImpersonationAuditMiddleware(auditRepository, slog.Default()))
+		optionalAuthRouter.Get("/users/{id}", ok)
+	})
+	router.Group(func(authRouter chi.Router) {
+		authRouter.Use(middlewares.AuthMiddleware(secretKey, users, slog.Default()))
+		authRouter.Use(middlewares.ImpersonationAuditMiddleware(auditRepository, slog.Default()))
+		authRouter.Group(func(credentialsRouter chi.Router) {
+			credentialsRouter.Use(middlewares.DenyImpersonationMiddleware(slog.Default()))
+			credentialsRouter.Put("/users/{id}", ok)
+			credentialsRouter.Delete("/users/{id}", ok)
+			credentialsRouter.Patch("/users/{id}", ok)
+			credentialsRouter.Post("/users/me/emails", ok)
+		})
+	})
+	return router
+}
+
+func TestImpersonation(t *testing.T) {
+	impersonationToken, err := jwt_tokens.CreateImpersonationToken(7, "user", 1, secretKey, time.Minute)
+	require.NoError(t, err)
+	userToken, err := jwt_tokens.CreateToken(7, "user", "", secretKey, time.Minute)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		expectedCode int
+		audited      bool
+	}{
+		{name: "read under impersonation is audited", method: http.MethodGet, path: "/users/7",
+			token: impersonationToken, expectedCode: http.StatusOK, audited: true},
+		{name: "PUT under impersonation is denied and audited", method: http.MethodPut, path: "/users/7",
+			token: impersonationToken, expectedCode: http.StatusForbidden, audited: true},
+		{name: "PATCH under impersonation is denied and audited", method: http.MethodPatch, path: "/users/7",
+			token: impersonationToken, expectedCode: http.StatusForbidden, audited: true},
+		{name: "DELETE under impersonation is denied and audited", method: http.MethodDelete, path: "/users/7",
+			token: impersonationToken, expectedCode: http.StatusForbidden, audited: true},
+		{name: "adding email under impersonation is denied", method: http.MethodPost, path: "/users/me/emails",
+			token: impersonationToken, expectedCode: http.StatusForbidden, audited: true},
+		{name: "own token is not audited", method: http.MethodPatch, path: "/users/7",
+			token: userToken, expectedCode: http.StatusOK},
+		{name: "anonymous read is not audited", method: http.MethodGet, path: "/users/7",
+			expectedCode: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auditRepository := new(MockImpersonationAuditRepository)
+			if test.audited {
+				auditRepository.On("AddAuditRecord", mock.Anything, mock.MatchedBy(func(record *impersonation_audit_db.AuditRecord) bool {
+					return record.AdminID == 1 && record.TargetUserID == 7 && record.Action == impersonation_audit_db.ActionRequest &&
+						record.Method == test.method && record.Path == test.path && record.Status == test.expectedCode
+				})).Return(nil).Once()
+			}
+
+			req := httptest.NewRequest(test.method, test.path, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			w := httptest.NewRecorder()
+
+			impersonationRouter(auditRepository).ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedCode == http.StatusForbidden {
+				require.Contains(t, w.Body.String(), "Action is not allowed while impersonating a user")
+			}
+			auditRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package query_params
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalidFields = errors.New("invalid fields")
+
+// ParseFieldList разбирает параметр со списком значений через запятую (например fields=id,email или include=last_login)
+// и проверяет каждое значение по списку разрешённых. Повторы отбрасываются, порядок сохраняется.
+// Если параметр не передан, возвращается nil
+func ParseFieldList(query url.Values, param string, validFields []string) ([]string, error) {
+	if !query.Has(param) {
+		return nil, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(query.Get(param), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return nil, fmt.Errorf("%w: %s contains an empty value", ErrInvalidFields, param)
+		}
+		if !containsString(validFields, field) {
+			return nil, fmt.Errorf("%w: %s value %s is not supported", ErrInvalidFields, param, field)
+		}
+		if !containsString(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,212 @@
+package query_params
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Операторы фильтрации
+const (
+	OpEq       = "eq"       // Равно (оператор по умолчанию)
+	OpNe       = "ne"       // Не равно
+	OpGt       = "gt"       // Больше
+	OpGte      = "gte"      // Больше или равно
+	OpLt       = "lt"       // Меньше
+	OpLte      = "lte"      // Меньше или равно
+	OpIn       = "in"       // Одно из значений, перечисленных через запятую
+	OpContains = "contains" // Содержит подстроку (без учёта регистра)
+	OpPrefix   = "prefix"   // Начинается с (без учёта регистра)
+	OpSuffix   = "suffix"   // Заканчивается на (без учёта регистра)
+)
+
+// Типы значений фильтруемых полей
+const (
+	FilterString = "string"
+	FilterInt    = "int"
+	FilterTime   = "time" // RFC3339 или дата в формате 2006-01-02
+	FilterNumber = "number"
+	FilterBool   = "bool"
+)
+
+// Наборы операторов для типовых полей
+var (
+	StringOperators  = []string{OpEq, OpNe, OpIn, OpContains, OpPrefix, OpSuffix}
+	EnumOperators    = []string{OpEq, OpNe, OpIn}
+	OrderedOperators = []string{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn}
+	TimeOperators    = []string{OpGt, OpGte, OpLt, OpLte}
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// FilterField описывает поле, по которому разрешена фильтрация
+type FilterField struct {
+	Type      string   // Тип значения (FilterString, FilterInt, FilterTime, FilterNumber, FilterBool)
+	Operators []string // Разрешённые операторы
+}
+
+// FilterParam представляет одно условие фильтрации
+type FilterParam struct {
+	Field    string      // Поле фильтрации (например, "role")
+	Operator string      // Оператор (например, "eq")
+	Value    interface{} // Значение нужного типа: string, int64, time.Time или срез для оператора in
+}
+
+// FilterParamsParser — интерфейс для кастомной обработки фильтров
+type FilterParamsParser interface {
+	ParseFilterParams(query url.Values, log *slog.Logger) ([]FilterParam, error)
+}
+
+// DefaultFilterParser реализует парсинг фильтров вида filter[field]=value и filter[field][op]=value
+type DefaultFilterParser struct {
+	ValidFilterFields map[string]FilterField // Разрешённые для фильтрации поля и их операторы
+}
+
+// ParseFilterParams ищет в query параметры filter[...] и приводит их значения к типу поля.
+// Фильтры по полям или операторам, которых нет в ValidFilterFields, считаются ошибкой
+func (p *DefaultFilterParser) ParseFilterParams(query url.Values, log *slog.Logger) ([]FilterParam, error) {
+	// Ключи сортируются, что б одинаковые запросы давали одинаковый порядок условий
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if strings.HasPrefix(key, "filter[") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var filters []FilterParam
+	for _, key := range keys {
+		values := query[key]
+		field, operator, err := parseFilterKey(key)
+		if err != nil {
+			log.Warn("Invalid filter key", "key", key)
+			return nil, err
+		}
+		fieldConfig, ok := p.ValidFilterFields[field]
+		if !ok {
+			log.Warn("Filter field is not allowed", "field", field)
+			return nil, fmt.Errorf("%w: filtering by %s is not supported", ErrInvalidFilter, field)
+		}
+		if !containsString(fieldConfig.Operators, operator) {
+			log.Warn("Filter operator is not allowed", "field", field, "operator", operator)
+			return nil, fmt.Errorf("%w: operator %s is not supported for %s", ErrInvalidFilter, operator, field)
+		}
+		for _, rawValue := range values {
+			value, err := parseFilterValue(fieldConfig.Type, operator, rawValue)
+			if err != nil {
+				log.Warn("Invalid filter value", "field", field, "operator", operator, "value", rawValue)
+				return nil, fmt.Errorf("%w: %s[%s]: %v", ErrInvalidFilter, field, operator, err)
+			}
+			filters = append(filters, FilterParam{Field: field, Operator: operator, Value: value})
+		}
+	}
+	return filters, nil
+}
+
+// parseFilterKey разбирает ключ filter[field] или filter[field][op]
+func parseFilterKey(key string) (string, string, error) {
+	rest := strings.TrimPrefix(key, "filter")
+	var parts []string
+	for rest != "" {
+		if rest[0] != '[' {
+			return "", "", fmt.Errorf("%w: malformed key %s", ErrInvalidFilter, key)
+		}
+		end := strings.IndexByte(rest, ']')
+		if end <= 1 {
+			return "", "", fmt.Errorf("%w: malformed key %s", ErrInvalidFilter, key)
+		}
+		parts = append(parts, rest[1:end])
+		rest = rest[end+1:]
+	}
+	switch len(parts) {
+	case 1:
+		return parts[0], OpEq, nil
+	case 2:
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("%w: malformed key %s", ErrInvalidFilter, key)
+	}
+}
+
+// parseFilterValue приводит значение к типу поля, для оператора in — к срезу значений
+func parseFilterValue(fieldType, operator, rawValue string) (interface{}, error) {
+	if operator != OpIn {
+		return parseScalarFilterValue(fieldType, rawValue)
+	}
+	rawValues := strings.Split(rawValue, ",")
+	switch fieldType {
+	case FilterInt:
+		values := make([]int64, 0, len(rawValues))
+		for _, raw := range rawValues {
+			value, err := parseScalarFilterValue(fieldType, raw)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value.(int64))
+		}
+		return values, nil
+	case FilterNumber:
+		values := make([]float64, 0, len(rawValues))
+		for _, raw := range rawValues {
+			value, err := parseScalarFilterValue(fieldType, raw)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value.(float64))
+		}
+		return values, nil
+	case FilterTime:
+		values := make([]time.Time, 0, len(rawValues))
+		for _, raw := range rawValues {
+			value, err := parseScalarFilterValue(fieldType, raw)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value.(time.Time))
+		}
+		return values, nil
+	default:
+		values := make([]string, 0, len(rawValues))
+		for _, raw := range rawValues {
+			values = append(values, strings.TrimSpace(raw))
+		}
+		return values, nil
+	}
+}
+
+func parseScalarFilterValue(fieldType, rawValue string) (interface{}, error) {
+	rawValue = strings.TrimSpace(rawValue)
+	switch fieldType {
+	case FilterInt:
+		return strconv.ParseInt(rawValue, 10, 64)
+	case FilterNumber:
+		return strconv.ParseFloat(rawValue, 64)
+	case FilterBool:
+		return strconv.ParseBool(rawValue)
+	case FilterTime:
+		if value, err := time.Parse(time.RFC3339, rawValue); err == nil {
+			return value, nil
+		}
+		value, err := time.Parse(time.DateOnly, rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("expected RFC3339 time or date, got %q", rawValue)
+		}
+		return value, nil
+	default:
+		return rawValue, nil
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package query_params_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseFilterParams(t *testing.T) {
+	parser := &query_params.DefaultFilterParser{
+		ValidFilterFields: map[string]query_params.FilterField{
+			"id":         {Type: query_params.FilterInt, Operators: query_params.OrderedOperators},
+			"email":      {Type: query_params.FilterString, Operators: query_params.StringOperators},
+			"role":       {Type: query_params.FilterString, Operators: query_params.EnumOperators},
+			"created_at": {Type: query_params.FilterTime, Operators: query_params.TimeOperators},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedFilters []query_params.FilterParam
+		expectedErr     bool
+	}{
+		{
+			name:  "equality by default",
+			query: "filter[role]=admin",
+			expectedFilters: []query_params.FilterParam{
+				{Field: "role", Operator: query_params.OpEq, Value: "admin"},
+			},
+		},
+		{
+			name:  "typed operators",
+			query: "filter[created_at][gte]=2024-01-02&filter[email][suffix]=@corp.com&filter[id][in]=1,2,3",
+			expectedFilters: []query_params.FilterParam{
+				{Field: "created_at", Operator: query_params.OpGte, Value: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+				{Field: "email", Operator: query_params.OpSuffix, Value: "@corp.com"},
+				{Field: "id", Operator: query_params.OpIn, Value: []int64{1, 2, 3}},
+			},
+		},
+		{
+			name:  "other query params are ignored",
+			query: "limit=10&search=ryan",
+		},
+		{
+			name:        "field is not allowed",
+			query:       "filter[password]=secret",
+			expectedErr: true,
+		},
+		{
+			name:        "operator is not allowed for field",
+			query:       "filter[role][contains]=adm",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid value type",
+			query:       "filter[id][gt]=abc",
+			expectedErr: true,
+		},
+		{
+			name:        "malformed key",
+			query:       "filter[id][gt][x]=1",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := url.ParseQuery(test.query)
+			require.NoError(t, err)
+
+			filters, err := parser.ParseFilterParams(query, slog.Default())
+			if test.expectedErr {
+				require.ErrorIs(t, err, query_params.ErrInvalidFilter)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedFilters, filters)
+		})
+	}
+}
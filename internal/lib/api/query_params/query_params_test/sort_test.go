@@ -0,0 +1,106 @@
+package query_params_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/url"
+	"testing"
+)
+
+func TestParseSortParams(t *testing.T) {
+	parser := &query_params.DefaultSortParser{
+		ValidSortFields: []string{"id", "last_name", "created_at"},
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedSort []query_params.SortParam
+		expectedErr  bool
+	}{
+		{
+			name:  "client order is kept and tiebreaker appended",
+			query: "sort=-created_at,last_name",
+			expectedSort: []query_params.SortParam{
+				{Field: "created_at", Order: "desc"},
+				{Field: "last_name", Order: "asc"},
+				{Field: "id", Order: "asc"},
+			},
+		},
+		{
+			name:  "nulls ordering",
+			query: "sort=last_name:nulls_last,-created_at:nulls_first",
+			expectedSort: []query_params.SortParam{
+				{Field: "last_name", Order: "asc", Nulls: query_params.NullsLast},
+				{Field: "created_at", Order: "desc", Nulls: query_params.NullsFirst},
+				{Field: "id", Order: "asc"},
+			},
+		},
+		{
+			name:  "tiebreaker is not duplicated",
+			query: "sort=-id",
+			expectedSort: []query_params.SortParam{
+				{Field: "id", Order: "desc"},
+			},
+		},
+		{
+			name:  "legacy format",
+			query: "last_name=desc",
+			expectedSort: []query_params.SortParam{
+				{Field: "last_name", Order: "desc"},
+				{Field: "id", Order: "asc"},
+			},
+		},
+		{
+			name:  "no sort",
+			query: "limit=10",
+		},
+		{
+			name:        "field is not allowed",
+			query:       "sort=password",
+			expectedErr: true,
+		},
+		{
+			name:        "duplicate field",
+			query:       "sort=last_name,-last_name",
+			expectedErr: true,
+		},
+		{
+			name:        "unknown modifier",
+			query:       "sort=last_name:nulls_middle",
+			expectedErr: true,
+		},
+		{
+			name:        "empty field",
+			query:       "sort=last_name,,id",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid legacy order",
+			query:       "created_at=up",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := url.ParseQuery(test.query)
+			require.NoError(t, err)
+
+			sortParams, err := parser.ParseSortParams(query, slog.Default())
+			if test.expectedErr {
+				require.ErrorIs(t, err, query_params.ErrInvalidSort)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedSort, sortParams)
+		})
+	}
+}
+
+func TestSortParamSQL(t *testing.T) {
+	require.Equal(t, "created_at DESC NULLS LAST",
+		query_params.SortParam{Field: "created_at", Order: "desc", Nulls: query_params.NullsLast}.SQL())
+	require.Equal(t, "id ASC", query_params.SortParam{Field: "id", Order: "asc"}.SQL())
+}
@@ -1,11 +1,13 @@
 package query_params
 
 import (
+	"errors"
 	"fmt"
 	"github.com/go-playground/validator"
 	"log/slog"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
 // BaseQueryParams содержит общие параметры для всех запросов
@@ -20,12 +22,37 @@ type BaseQueryParams struct {
 type SortParam struct {
 	Field string // Поле сортировки (например, "id")
 	Order string // Порядок сортировки ("asc" или "desc")
+	Nulls string // Положение NULL ("first", "last" или пусто — по умолчанию PostgreSQL)
 }
 
-// ListQueryParams объединяет базовые параметры и параметры сортировки
+// Положение NULL при сортировке
+const (
+	NullsFirst = "first"
+	NullsLast  = "last"
+)
+
+// DefaultTiebreaker уникальное поле, которое добавляется в конец сортировки, что б порядок записей был однозначным
+const DefaultTiebreaker = "id"
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SQL возвращает выражение для ORDER BY. Field должен быть проверен по списку разрешённых полей
+func (s SortParam) SQL() string {
+	expression := s.Field + " " + strings.ToUpper(s.Order)
+	switch s.Nulls {
+	case NullsFirst:
+		expression += " NULLS FIRST"
+	case NullsLast:
+		expression += " NULLS LAST"
+	}
+	return expression
+}
+
+// ListQueryParams объединяет базовые параметры, параметры сортировки и фильтры
 type ListQueryParams struct {
 	BaseQueryParams
-	SortParams []SortParam // Список параметров сортировки
+	SortParams []SortParam   // Список параметров сортировки
+	Filters    []FilterParam // Список фильтров
 }
 
 // QueryParamsParser — интерфейс для кастомной обработки сортировки
@@ -36,12 +63,83 @@ type QueryParamsParser interface {
 // DefaultSortParser реализует парсинг сортировки
 type DefaultSortParser struct {
 	ValidSortFields []string // Список разрешённых полей для сортировки
+	Tiebreaker      string   // Уникальное поле для однозначного порядка, по умолчанию DefaultTiebreaker
 }
 
-// ParseSortParams ищет в query параметры, совпадающие с ValidSortFields,
-// и интерпретирует их значения как порядок сортировки (asc/desc)
+// ParseSortParams разбирает параметр sort: поля через запятую в порядке приоритета,
+// "-" перед полем означает desc, суффиксы :nulls_first и :nulls_last задают положение NULL.
+// Например sort=-created_at,last_name:nulls_last.
+// Если sort не передан, поддерживается старый формат: поле=asc|desc для каждого поля из ValidSortFields.
+// К непустой сортировке в конец добавляется Tiebreaker, если его в ней ещё нет
 func (p *DefaultSortParser) ParseSortParams(query url.Values, log *slog.Logger) ([]SortParam, error) {
 	var sortParams []SortParam
+	var err error
+	if query.Has("sort") {
+		sortParams, err = p.parseSortExpression(query.Get("sort"))
+	} else {
+		sortParams, err = p.parseLegacySortParams(query)
+	}
+	if err != nil {
+		log.Warn("Invalid sort params", "error", err)
+		return nil, err
+	}
+	if len(sortParams) == 0 {
+		return nil, nil
+	}
+
+	tiebreaker := p.Tiebreaker
+	if tiebreaker == "" {
+		tiebreaker = DefaultTiebreaker
+	}
+	for _, sortParam := range sortParams {
+		if sortParam.Field == tiebreaker {
+			return sortParams, nil
+		}
+	}
+	return append(sortParams, SortParam{Field: tiebreaker, Order: "asc"}), nil
+}
+
+func (p *DefaultSortParser) parseSortExpression(expression string) ([]SortParam, error) {
+	var sortParams []SortParam
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(expression, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("%w: empty sort field", ErrInvalidSort)
+		}
+		sortParam := SortParam{Order: "asc"}
+		if strings.HasPrefix(item, "-") {
+			sortParam.Order = "desc"
+			item = item[1:]
+		}
+		field, modifier, hasModifier := strings.Cut(item, ":")
+		if hasModifier {
+			switch modifier {
+			case "nulls_first":
+				sortParam.Nulls = NullsFirst
+			case "nulls_last":
+				sortParam.Nulls = NullsLast
+			default:
+				return nil, fmt.Errorf("%w: unknown modifier %s for field %s", ErrInvalidSort, modifier, field)
+			}
+		}
+		if !containsString(p.ValidSortFields, field) {
+			return nil, fmt.Errorf("%w: sorting by %s is not supported", ErrInvalidSort, field)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("%w: field %s is specified more than once", ErrInvalidSort, field)
+		}
+		seen[field] = true
+		sortParam.Field = field
+		sortParams = append(sortParams, sortParam)
+	}
+	return sortParams, nil
+}
+
+// parseLegacySortParams ищет в query параметры, совпадающие с ValidSortFields,
+// и интерпретирует их значения как порядок сортировки (asc/desc)
+func (p *DefaultSortParser) parseLegacySortParams(query url.Values) ([]SortParam, error) {
+	var sortParams []SortParam
 
 	// Проходим по всем разрешённым полям сортировки
 	for _, field := range p.ValidSortFields {
@@ -53,8 +151,7 @@ func (p *DefaultSortParser) ParseSortParams(query url.Values, log *slog.Logger)
 
 		// Проверяем, что порядок сортировки валидный
 		if order != "asc" && order != "desc" {
-			log.Warn("Invalid sort order", "field", field, "order", order)
-			return nil, fmt.Errorf("invalid sort order for field %s: %s", field, order)
+			return nil, fmt.Errorf("%w: invalid sort order for field %s: %s", ErrInvalidSort, field, order)
 		}
 
 		// Добавляем параметр сортировки
@@ -64,7 +161,14 @@ func (p *DefaultSortParser) ParseSortParams(query url.Values, log *slog.Logger)
 	return sortParams, nil
 }
 
-// ParseStandardQueryParams парсит стандартные параметры и делегирует сортировку парсеру
+// ListParser объединяет парсеры сортировки и фильтров для списков с фильтрацией
+type ListParser struct {
+	DefaultSortParser
+	DefaultFilterParser
+}
+
+// ParseStandardQueryParams парсит стандартные параметры и делегирует сортировку парсеру.
+// Если парсер также реализует FilterParamsParser, через него разбираются и фильтры
 func ParseStandardQueryParams(query url.Values, log *slog.Logger, parser QueryParamsParser) (ListQueryParams, error) {
 	log = log.With(
 		slog.String("query", query.Encode()),
@@ -124,6 +228,13 @@ func ParseStandardQueryParams(query url.Values, log *slog.Logger, parser QueryPa
 			return params, err
 		}
 		params.SortParams = sortParams
+		if filterParser, ok := parser.(FilterParamsParser); ok {
+			filters, err := filterParser.ParseFilterParams(query, log)
+			if err != nil {
+				return params, err
+			}
+			params.Filters = filters
+		}
 	}
 
 	return params, nil
@@ -1,18 +1,31 @@
 package response
 
 import (
-	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/i18n"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator"
 	"net/http"
 	"strings"
 )
 
+// Response Ответ со статусом. У ошибок Code — стабильный машиночитаемый код (см. каталоги i18n),
+// Error — сообщение на языке запроса (см. RenderResponse)
 type Response struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Code   string       `json:"code,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"` // Ошибки отдельных полей, только у ошибок валидации
 }
 
+// FieldError Ошибка значения поля запроса
+type FieldError struct {
+	Field string `json:"field"`
+	Code  string `json:"code"` // field_required или field_invalid
+}
+
+// CodeValidationFailed код ошибки валидации тела запроса
+const CodeValidationFailed = "validation_failed"
+
 const (
 	StatusOK    = "OK"
 	StatusError = "ERROR"
@@ -24,39 +37,121 @@ func OK() Response {
 	}
 }
 
+// Error возвращает ошибку с сообщением msg. Если msg есть в каталоге сообщений, ошибка получает его код
 func Error(msg string) Response {
+	code, _ := i18n.CodeOf(msg)
 	return Response{
 		Status: StatusError,
 		Error:  msg,
+		Code:   code,
 	}
 }
 
-func ValidationError(errs validator.ValidationErrors) Response {
-	var errMsgs []string
+// ErrorWithCode возвращает ошибку с машиночитаемым кодом, по которому клиент может отличать причины ошибки
+func ErrorWithCode(code string, msg string) Response {
+	return Response{
+		Status: StatusError,
+		Error:  msg,
+		Code:   code,
+	}
+}
 
+func ValidationError(errs validator.ValidationErrors) Response {
+	fields := make([]FieldError, 0, len(errs))
 	for _, v := range errs {
 		switch v.ActualTag() {
 		case "required":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is required", v.Field()))
+			fields = append(fields, FieldError{Field: v.Field(), Code: "field_required"})
 		default:
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is invalid", v.Field()))
+			fields = append(fields, FieldError{Field: v.Field(), Code: "field_invalid"})
 		}
 
 	}
 	return Response{
 		Status: StatusError,
-		Error:  strings.Join(errMsgs, ", "),
+		Error:  fieldsMessage(i18n.DefaultLocale, fields),
+		Code:   CodeValidationFailed,
+		Fields: fields,
 	}
 }
 
+// Localize возвращает ошибку с сообщением на языке locale. Ошибке без кода назначается код по HTTP статусу.
+// Переводится только сообщение, совпадающее с сообщением кода в каталоге, и ошибки валидации полей:
+// сообщения с подробностями остаются как есть, кроме ошибок сервера (5xx) — их подробности клиенту не нужны
+func (r Response) Localize(locale string, status int) Response {
+	if r.Status != StatusError {
+		return r
+	}
+	if r.Code == "" {
+		r.Code = StatusCode(status)
+	}
+	if locale == i18n.DefaultLocale {
+		return r
+	}
+	switch {
+	case len(r.Fields) > 0:
+		r.Error = fieldsMessage(locale, r.Fields)
+	case i18n.Matches(r.Code, r.Error), status >= http.StatusInternalServerError:
+		if message, ok := i18n.Message(locale, r.Code); ok {
+			r.Error = message
+		}
+	}
+	return r
+}
+
+// StatusCode код ошибки по HTTP статусу для ошибок, которым код не назначен явно
+func StatusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusPreconditionFailed:
+		return "precondition_failed"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusPreconditionRequired:
+		return "precondition_required"
+	case http.StatusTooManyRequests:
+		return "too_many_requests"
+	case http.StatusGatewayTimeout:
+		return "timeout"
+	}
+	if status >= http.StatusInternalServerError {
+		return "internal_error"
+	}
+	return "error"
+}
+
+func fieldsMessage(locale string, fields []FieldError) string {
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		message, _ := i18n.Message(locale, field.Code, field.Field)
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, ", ")
+}
+
 // RenderResponse sets the HTTP status code and renders the provided body as JSON.
 // The status should be a valid HTTP status code (e.g., http.StatusOK).
 // The body is any JSON-serializable object, such as resp.Error or a custom DTO.
+// Errors (Response) are localized to the request language, see i18n.FromContext.
 func RenderResponse(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
 	render.Status(r, status)
 	if body == nil {
 		render.JSON(w, r, struct{}{})
 		return
 	}
+	if response, ok := body.(Response); ok {
+		body = response.Localize(i18n.FromContext(r.Context()), status)
+	}
 	render.JSON(w, r, body)
 }
@@ -0,0 +1,55 @@
+package response_test
+
+import (
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/i18n"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenderLocalizedError(t *testing.T) {
+	validationErr := response.Response{
+		Status: response.StatusError,
+		Error:  "field Email is required",
+		Code:   response.CodeValidationFailed,
+		Fields: []response.FieldError{{Field: "Email", Code: "field_required"}},
+	}
+	tests := []struct {
+		name      string
+		locale    string
+		status    int
+		body      response.Response
+		wantCode  string
+		wantError string
+	}{
+		{name: "Default locale", status: http.StatusNotFound, body: response.Error("User not found"),
+			wantCode: "user_not_found", wantError: "User not found"},
+		{name: "Catalog message", locale: "ru", status: http.StatusNotFound, body: response.Error("User not found"),
+			wantCode: "user_not_found", wantError: "Пользователь не найден"},
+		{name: "Detailed message", locale: "ru", status: http.StatusBadRequest, body: response.Error("invalid sort: field password"),
+			wantCode: "bad_request", wantError: "invalid sort: field password"},
+		{name: "Server error", locale: "ru", status: http.StatusInternalServerError, body: response.Error("Something went wrong, while getting user"),
+			wantCode: "internal_error", wantError: "Внутренняя ошибка сервера"},
+		{name: "Validation", locale: "ru", status: http.StatusBadRequest, body: validationErr,
+			wantCode: response.CodeValidationFailed, wantError: "поле Email обязательно"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.locale != "" {
+				r = r.WithContext(i18n.WithRequestedLocale(r.Context(), tt.locale))
+			}
+			w := httptest.NewRecorder()
+			response.RenderResponse(w, r, tt.status, tt.body)
+
+			require.Equal(t, tt.status, w.Code)
+			var got response.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			require.Equal(t, tt.wantCode, got.Code)
+			require.Equal(t, tt.wantError, got.Error)
+		})
+	}
+}
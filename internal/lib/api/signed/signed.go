@@ -0,0 +1,55 @@
+package signed
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidSignature = errors.New("signature is invalid")
+
+// Signer Упаковывает данные в строку payload.signature (base64url), подписанную HMAC-SHA256,
+// и проверяет такие строки. Используется для непрозрачных значений, которые сервис отдаёт клиенту
+// и принимает обратно: курсоров списков и токенов подтверждения
+type Signer struct {
+	key []byte
+}
+
+// NewSigner создаёт Signer. Ключ подписи выводится из secret и purpose,
+// что б секрет не использовался напрямую, а значение одного назначения не подходило для другого
+func NewSigner(secret, purpose string) Signer {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return Signer{key: mac.Sum(nil)}
+}
+
+// Seal возвращает payload вместе с подписью в виде строки payload.signature
+func (s Signer) Seal(payload []byte) string {
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// Open проверяет подпись строки из Seal и возвращает payload.
+// Для повреждённой строки или чужой подписи возвращает ErrInvalidSignature
+func (s Signer) Open(value string) ([]byte, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.sign(payload)) {
+		return nil, ErrInvalidSignature
+	}
+	return payload, nil
+}
+
+func (s Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,47 @@
+package signed_test
+
+import (
+	"encoding/base64"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/signed"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := signed.NewSigner("secret", "list-cursor")
+	payload := []byte(`{"s":[{"Field":"id","Order":"asc"}],"v":[10]}`)
+
+	value := signer.Seal(payload)
+
+	opened, err := signer.Open(value)
+	require.NoError(t, err)
+	require.Equal(t, payload, opened)
+}
+
+func TestSignerRejects(t *testing.T) {
+	signer := signed.NewSigner("secret", "list-cursor")
+	value := signer.Seal([]byte(`{"n":1}`))
+	encodedPayload, encodedSignature, _ := strings.Cut(value, ".")
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "Changed payload", value: base64.RawURLEncoding.EncodeToString([]byte(`{"n":2}`)) + "." + encodedSignature},
+		{name: "Changed signature", value: encodedPayload + "." + base64.RawURLEncoding.EncodeToString([]byte("signature"))},
+		{name: "Another secret", value: signed.NewSigner("other", "list-cursor").Seal([]byte(`{"n":1}`))},
+		// Значение одного назначения не подходит для другого, даже с тем же секретом
+		{name: "Another purpose", value: signed.NewSigner("secret", "confirmation-token").Seal([]byte(`{"n":1}`))},
+		{name: "Without signature", value: encodedPayload},
+		{name: "Payload is not base64", value: "!!!." + encodedSignature},
+		{name: "Signature is not base64", value: encodedPayload + ".!!!"},
+		{name: "Empty value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := signer.Open(test.value)
+			require.ErrorIs(t, err, signed.ErrInvalidSignature)
+		})
+	}
+}
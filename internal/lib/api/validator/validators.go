@@ -2,7 +2,12 @@ package validators
 
 import (
 	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/i18n"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
 	"github.com/go-playground/validator"
+	"time"
+	// Встроенная база часовых поясов, что б проверка timezone не зависела от tzdata в образе
+	_ "time/tzdata"
 )
 
 // validate Переменная хранящая в себе экземпляр валидатора.
@@ -14,6 +19,30 @@ func InitValidator() error {
 		return errors.New("validator already initialized")
 	}
 	validate = validator.New()
+	// locale — язык, для которого есть каталог сообщений (см. i18n.Supported)
+	if err := validate.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
+		return i18n.IsSupported(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	// timezone — имя часового пояса IANA, например Europe/Moscow
+	if err := validate.RegisterValidation("timezone", func(fl validator.FieldLevel) bool {
+		name := fl.Field().String()
+		if name == "" || name == "Local" {
+			return false
+		}
+		_, err := time.LoadLocation(name)
+		return err == nil
+	}); err != nil {
+		return err
+	}
+	// phone — номер, который приводится к E.164: международный или национальный для региона по умолчанию
+	if err := validate.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		_, err := phone_numbers.Normalize(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,76 @@
+package email_address
+
+import (
+	"strings"
+)
+
+// providerRule Как почтовый сервис трактует локальную часть адреса
+type providerRule struct {
+	Domain     string // Основной домен сервиса, на который заменяются его псевдонимы
+	IgnoreDots bool   // Точки в локальной части не различаются: b.o.b@gmail.com — тот же ящик, что bob@gmail.com
+	PlusTags   bool   // Всё после + в локальной части игнорируется: bob+news@gmail.com — тот же ящик, что bob@gmail.com
+}
+
+// providerRules правила известных почтовых сервисов по домену
+var providerRules = map[string]providerRule{
+	"gmail.com":      {Domain: "gmail.com", IgnoreDots: true, PlusTags: true},
+	"googlemail.com": {Domain: "gmail.com", IgnoreDots: true, PlusTags: true},
+	"outlook.com":    {Domain: "outlook.com", PlusTags: true},
+	"hotmail.com":    {Domain: "hotmail.com", PlusTags: true},
+	"live.com":       {Domain: "live.com", PlusTags: true},
+	"icloud.com":     {Domain: "icloud.com", PlusTags: true},
+	"me.com":         {Domain: "me.com", PlusTags: true},
+	"fastmail.com":   {Domain: "fastmail.com", PlusTags: true},
+	"proton.me":      {Domain: "proton.me", PlusTags: true},
+	"protonmail.com": {Domain: "protonmail.com", PlusTags: true},
+	"yandex.ru":      {Domain: "yandex.ru", PlusTags: true},
+	"yandex.com":     {Domain: "yandex.ru", PlusTags: true},
+	"ya.ru":          {Domain: "yandex.ru", PlusTags: true},
+}
+
+// useProviderRules применять ли правила почтовых сервисов (см. SetProviderRules)
+var useProviderRules = false
+
+// SetProviderRules включает правила почтовых сервисов при приведении адресов к каноническому виду.
+// Без них адрес только очищается от пробелов по краям и получает домен в нижнем регистре
+func SetProviderRules(enabled bool) {
+	useProviderRules = enabled
+}
+
+// Canonicalize приводит email к каноническому виду, в котором он сохраняется: убирает пробелы по краям
+// и переводит домен в нижний регистр. Регистр локальной части сохраняется, но уникальность email
+// в БД проверяется без учёта регистра. При включённых правилах почтовых сервисов у известных сервисов
+// убираются +метки, точки (для Gmail) и псевдонимы домена.
+// Строка без @ возвращается без изменений, кроме пробелов по краям
+func Canonicalize(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email
+	}
+	local, domain := email[:at], strings.ToLower(email[at+1:])
+	if !useProviderRules {
+		return local + "@" + domain
+	}
+	rule, ok := providerRules[domain]
+	if !ok {
+		return local + "@" + domain
+	}
+	if rule.PlusTags {
+		if tagged, _, found := strings.Cut(local, "+"); found && tagged != "" {
+			local = tagged
+		}
+	}
+	if rule.IgnoreDots {
+		if undotted := strings.ReplaceAll(local, ".", ""); undotted != "" {
+			local = undotted
+		}
+	}
+	return local + "@" + rule.Domain
+}
+
+// Key ключ уникальности email: канонический вид в нижнем регистре.
+// Адреса с одинаковым ключом принадлежат одному ящику и не могут быть у двух действующих пользователей
+func Key(email string) string {
+	return strings.ToLower(Canonicalize(email))
+}
@@ -0,0 +1,43 @@
+package email_address_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		email         string
+		providerRules bool
+		want          string
+	}{
+		{name: "Trim and lowercase domain", email: "  John.Doe@Example.COM ", want: "John.Doe@example.com"},
+		{name: "Provider rules disabled", email: "j.doe+news@Gmail.com", want: "j.doe+news@gmail.com"},
+		{name: "Gmail dots and tag", email: "J.Doe+news@Gmail.com", providerRules: true, want: "JDoe@gmail.com"},
+		{name: "Googlemail alias", email: "jdoe@googlemail.com", providerRules: true, want: "jdoe@gmail.com"},
+		{name: "Outlook keeps dots", email: "j.doe+work@outlook.com", providerRules: true, want: "j.doe@outlook.com"},
+		{name: "Yandex alias", email: "jdoe+x@ya.ru", providerRules: true, want: "jdoe@yandex.ru"},
+		{name: "Unknown provider", email: "j.doe+news@example.com", providerRules: true, want: "j.doe+news@example.com"},
+		{name: "Empty tagged part kept", email: "+news@gmail.com", providerRules: true, want: "+news@gmail.com"},
+		{name: "No at sign", email: " not-an-email ", want: "not-an-email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email_address.SetProviderRules(tt.providerRules)
+			defer email_address.SetProviderRules(false)
+
+			assert.Equal(t, tt.want, email_address.Canonicalize(tt.email))
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, email_address.Key("John@Example.com"), email_address.Key(" john@example.COM"))
+	assert.NotEqual(t, email_address.Key("j.ohn@gmail.com"), email_address.Key("john@gmail.com"))
+
+	email_address.SetProviderRules(true)
+	defer email_address.SetProviderRules(false)
+	assert.Equal(t, "john@gmail.com", email_address.Key("J.Ohn+test@GoogleMail.com"))
+}
@@ -0,0 +1,200 @@
+package email_domains
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Машиночитаемые коды ошибок проверки домена email
+const (
+	CodeDomainNotAllowed = "email_domain_not_allowed"
+	CodeDomainDenied     = "email_domain_denied"
+	CodeDisposableEmail  = "email_disposable"
+)
+
+var ErrDomainNotAllowed = &DomainError{Code: CodeDomainNotAllowed, Message: "email domain is not in the list of allowed domains"}
+var ErrDomainDenied = &DomainError{Code: CodeDomainDenied, Message: "email domain is denied"}
+var ErrDisposableEmail = &DomainError{Code: CodeDisposableEmail, Message: "disposable email addresses are not allowed"}
+
+// DomainError Ошибка проверки домена email с машиночитаемым кодом
+type DomainError struct {
+	Code    string
+	Message string
+}
+
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+//go:embed disposable_domains.txt
+var bundledDisposableDomains string
+
+// Config Источники списков доменов.
+// Списки из файлов перечитываются при Reload, встроенные в конфиг списки задаются один раз при старте
+type Config struct {
+	AllowedDomains         []string // Разрешённые домены. Если список пуст, разрешены все домены, кроме запрещённых
+	DeniedDomains          []string // Запрещённые домены
+	AllowedDomainsFile     string   // Файл с дополнительными разрешёнными доменами
+	DeniedDomainsFile      string   // Файл с дополнительными запрещёнными доменами
+	DisposableDomainsFile  string   // Файл со списком одноразовой почты. Если не задан, используется встроенный список
+	BlockDisposableDomains bool     // Блокировать ли одноразовую почту
+}
+
+// Rules Правила проверки доменов email. Безопасны для конкурентного использования
+type Rules struct {
+	cfg        Config
+	mu         sync.RWMutex
+	allowed    map[string]struct{}
+	denied     map[string]struct{}
+	disposable map[string]struct{}
+}
+
+// Stats Количество доменов в загруженных списках
+type Stats struct {
+	Allowed    int `json:"allowed"`
+	Denied     int `json:"denied"`
+	Disposable int `json:"disposable"`
+}
+
+// NewRules создаёт правила и загружает списки доменов
+func NewRules(cfg Config) (*Rules, error) {
+	rules := &Rules{cfg: cfg}
+	if _, err := rules.Reload(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Reload перечитывает списки доменов из файлов. При ошибке продолжают действовать ранее загруженные списки
+func (r *Rules) Reload() (Stats, error) {
+	allowed := toSet(r.cfg.AllowedDomains)
+	if err := loadFile(r.cfg.AllowedDomainsFile, allowed); err != nil {
+		return Stats{}, err
+	}
+	denied := toSet(r.cfg.DeniedDomains)
+	if err := loadFile(r.cfg.DeniedDomainsFile, denied); err != nil {
+		return Stats{}, err
+	}
+	disposable := map[string]struct{}{}
+	if r.cfg.BlockDisposableDomains {
+		if r.cfg.DisposableDomainsFile != "" {
+			if err := loadFile(r.cfg.DisposableDomainsFile, disposable); err != nil {
+				return Stats{}, err
+			}
+		} else if err := readDomains(strings.NewReader(bundledDisposableDomains), disposable); err != nil {
+			return Stats{}, fmt.Errorf("failed to read bundled disposable domains: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	r.allowed, r.denied, r.disposable = allowed, denied, disposable
+	r.mu.Unlock()
+	return r.Stats(), nil
+}
+
+// Stats возвращает размеры загруженных списков
+func (r *Rules) Stats() Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return Stats{Allowed: len(r.allowed), Denied: len(r.denied), Disposable: len(r.disposable)}
+}
+
+// Check проверяет домен email. Запрещённые и одноразовые домены отклоняются всегда,
+// а если задан список разрешённых доменов, то допускаются только они.
+// Поддомены проверяются вместе с родительским доменом.
+// Возвращает nil или одну из ошибок *DomainError
+func (r *Rules) Check(email string) error {
+	if r == nil {
+		return nil
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ErrDomainNotAllowed
+	}
+	domain := normalize(email[at+1:])
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if matches(r.denied, domain) {
+		return ErrDomainDenied
+	}
+	if matches(r.disposable, domain) {
+		return ErrDisposableEmail
+	}
+	if len(r.allowed) > 0 && !matches(r.allowed, domain) {
+		return ErrDomainNotAllowed
+	}
+	return nil
+}
+
+// AsDomainError проверяет, что ошибка является ошибкой проверки домена
+func AsDomainError(err error) (*DomainError, bool) {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
+
+// matches проверяет домен и все его родительские домены (mail.corp.com -> corp.com -> com)
+func matches(set map[string]struct{}, domain string) bool {
+	for domain != "" {
+		if _, ok := set[domain]; ok {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+func toSet(domains []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		if domain = normalize(domain); domain != "" {
+			set[domain] = struct{}{}
+		}
+	}
+	return set
+}
+
+func loadFile(path string, set map[string]struct{}) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open domains file %s: %w", path, err)
+	}
+	defer file.Close()
+	if err = readDomains(file, set); err != nil {
+		return fmt.Errorf("failed to read domains file %s: %w", path, err)
+	}
+	return nil
+}
+
+// readDomains читает домены по одному на строку, пропуская пустые строки и комментарии
+func readDomains(reader io.Reader, set map[string]struct{}) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[normalize(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+func normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
@@ -0,0 +1,100 @@
+package email_domains_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRulesCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     email_domains.Config
+		email   string
+		wantErr error
+	}{
+		{name: "No lists", email: "ivan@example.com"},
+		{name: "Allowed domain", cfg: email_domains.Config{AllowedDomains: []string{"example.com"}},
+			email: "ivan@example.com"},
+		{name: "Domain outside of allow-list", cfg: email_domains.Config{AllowedDomains: []string{"example.com"}},
+			email: "ivan@example.org", wantErr: email_domains.ErrDomainNotAllowed},
+		{name: "Subdomain of allowed domain", cfg: email_domains.Config{AllowedDomains: []string{"example.com"}},
+			email: "ivan@mail.corp.example.com"},
+		{name: "Parent of allowed domain", cfg: email_domains.Config{AllowedDomains: []string{"corp.example.com"}},
+			email: "ivan@example.com", wantErr: email_domains.ErrDomainNotAllowed},
+		{name: "Domain ending with allowed domain", cfg: email_domains.Config{AllowedDomains: []string{"example.com"}},
+			email: "ivan@badexample.com", wantErr: email_domains.ErrDomainNotAllowed},
+		{name: "Denied domain", cfg: email_domains.Config{DeniedDomains: []string{"spam.com"}},
+			email: "ivan@spam.com", wantErr: email_domains.ErrDomainDenied},
+		{name: "Subdomain of denied domain", cfg: email_domains.Config{DeniedDomains: []string{"spam.com"}},
+			email: "ivan@mx.spam.com", wantErr: email_domains.ErrDomainDenied},
+		{name: "Domain outside of deny-list", cfg: email_domains.Config{DeniedDomains: []string{"spam.com"}},
+			email: "ivan@example.com"},
+		// Запрещённый поддомен отклоняется, даже если родительский домен разрешён
+		{name: "Deny-list wins over allow-list",
+			cfg:   email_domains.Config{AllowedDomains: []string{"example.com"}, DeniedDomains: []string{"guest.example.com"}},
+			email: "ivan@guest.example.com", wantErr: email_domains.ErrDomainDenied},
+		{name: "Email domain in upper case", cfg: email_domains.Config{DeniedDomains: []string{"spam.com"}},
+			email: "Ivan@SPAM.Com", wantErr: email_domains.ErrDomainDenied},
+		{name: "List entry in upper case", cfg: email_domains.Config{AllowedDomains: []string{" Example.COM. "}},
+			email: "ivan@example.com"},
+		{name: "Email domain with trailing dot", cfg: email_domains.Config{DeniedDomains: []string{"spam.com"}},
+			email: "ivan@spam.com.", wantErr: email_domains.ErrDomainDenied},
+		{name: "Bundled disposable domain", cfg: email_domains.Config{BlockDisposableDomains: true},
+			email: "ivan@mailinator.com", wantErr: email_domains.ErrDisposableEmail},
+		{name: "Disposable domain is allowed when blocking is off", email: "ivan@mailinator.com"},
+		{name: "Email without domain", cfg: email_domains.Config{DeniedDomains: []string{"spam.com"}},
+			email: "ivan", wantErr: email_domains.ErrDomainNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := email_domains.NewRules(test.cfg)
+			require.NoError(t, err)
+
+			err = rules.Check(test.email)
+
+			if test.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, test.wantErr)
+			domainErr, ok := email_domains.AsDomainError(err)
+			require.True(t, ok)
+			require.Equal(t, test.wantErr.(*email_domains.DomainError).Code, domainErr.Code)
+		})
+	}
+}
+
+func TestNilRulesAllowEverything(t *testing.T) {
+	var rules *email_domains.Rules
+	require.NoError(t, rules.Check("ivan@mailinator.com"))
+}
+
+func TestRulesReload(t *testing.T) {
+	dir := t.TempDir()
+	allowedFile := filepath.Join(dir, "allowed.txt")
+	require.NoError(t, os.WriteFile(allowedFile, []byte("# Домены компании\nexample.com\n\n"), 0o600))
+
+	rules, err := email_domains.NewRules(email_domains.Config{AllowedDomains: []string{"partner.org"}, AllowedDomainsFile: allowedFile})
+	require.NoError(t, err)
+	require.Equal(t, email_domains.Stats{Allowed: 2}, rules.Stats())
+	require.NoError(t, rules.Check("ivan@example.com"))
+	require.ErrorIs(t, rules.Check("ivan@example.net"), email_domains.ErrDomainNotAllowed)
+
+	// Списки из файла перечитываются, список из конфига остаётся
+	require.NoError(t, os.WriteFile(allowedFile, []byte("EXAMPLE.NET\n"), 0o600))
+	stats, err := rules.Reload()
+	require.NoError(t, err)
+	require.Equal(t, email_domains.Stats{Allowed: 2}, stats)
+	require.NoError(t, rules.Check("ivan@example.net"))
+	require.NoError(t, rules.Check("ivan@partner.org"))
+	require.ErrorIs(t, rules.Check("ivan@example.com"), email_domains.ErrDomainNotAllowed)
+
+	// При ошибке чтения продолжают действовать загруженные списки
+	require.NoError(t, os.Remove(allowedFile))
+	_, err = rules.Reload()
+	require.Error(t, err)
+	require.NoError(t, rules.Check("ivan@example.net"))
+}
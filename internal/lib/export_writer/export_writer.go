@@ -0,0 +1,125 @@
+package export_writer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Форматы выгрузки
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var ErrUnknownFormat = errors.New("format must be one of csv, ndjson")
+
+// Writer пишет выгрузку построчно. Каждая запись — JSON представление объекта по именам колонок
+type Writer interface {
+	Write(record map[string]json.RawMessage) error
+	// Flush отправляет накопленные строки клиенту
+	Flush() error
+}
+
+// flusher реализуется http.ResponseWriter, если ответ можно отправлять частями
+type flusher interface {
+	Flush()
+}
+
+// ContentType возвращает Content-Type ответа для формата
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// New создаёт Writer формата format, который пишет колонки columns в out.
+// Для CSV первой строкой сразу пишется заголовок с именами колонок
+func New(format string, out io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		writer := &csvWriter{out: out, csv: csv.NewWriter(out), columns: columns}
+		if err := writer.csv.Write(columns); err != nil {
+			return nil, fmt.Errorf("failed to write csv header: %w", err)
+		}
+		return writer, nil
+	case FormatNDJSON:
+		buffered := bufio.NewWriter(out)
+		return &ndjsonWriter{out: out, buffered: buffered, encoder: json.NewEncoder(buffered), columns: columns}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type csvWriter struct {
+	out     io.Writer
+	csv     *csv.Writer
+	columns []string
+}
+
+func (w *csvWriter) Write(record map[string]json.RawMessage) error {
+	row := make([]string, 0, len(w.columns))
+	for _, column := range w.columns {
+		row = append(row, csvValue(record[column]))
+	}
+	return w.csv.Write(row)
+}
+
+func (w *csvWriter) Flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	flush(w.out)
+	return nil
+}
+
+// csvValue переводит JSON значение в значение ячейки CSV: строки без кавычек, null — пустая ячейка
+func csvValue(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var value string
+	if raw[0] == '"' && json.Unmarshal(raw, &value) == nil {
+		return value
+	}
+	return string(raw)
+}
+
+type ndjsonWriter struct {
+	out      io.Writer
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+	columns  []string
+}
+
+func (w *ndjsonWriter) Write(record map[string]json.RawMessage) error {
+	// Пропущенные колонки пишутся как null, что б у всех строк был одинаковый набор ключей
+	row := make(map[string]json.RawMessage, len(w.columns))
+	for _, column := range w.columns {
+		value, ok := record[column]
+		if !ok {
+			value = json.RawMessage("null")
+		}
+		row[column] = value
+	}
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	if err := w.buffered.Flush(); err != nil {
+		return err
+	}
+	flush(w.out)
+	return nil
+}
+
+func flush(out io.Writer) {
+	if f, ok := out.(flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,51 @@
+package export_writer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/export_writer"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWriters(t *testing.T) {
+	record := map[string]json.RawMessage{
+		"id":         json.RawMessage(`1`),
+		"email":      json.RawMessage(`"a,b@mail.ru"`),
+		"deleted_at": json.RawMessage(`null`),
+	}
+	columns := []string{"id", "email", "phone", "deleted_at"}
+
+	tests := []struct {
+		name     string
+		format   string
+		expected string
+	}{
+		{
+			name:     "csv",
+			format:   export_writer.FormatCSV,
+			expected: "id,email,phone,deleted_at\n1,\"a,b@mail.ru\",,\n",
+		},
+		{
+			name:     "ndjson",
+			format:   export_writer.FormatNDJSON,
+			expected: `{"deleted_at":null,"email":"a,b@mail.ru","id":1,"phone":null}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writer, err := export_writer.New(test.format, &out, columns)
+			require.NoError(t, err)
+			require.NoError(t, writer.Write(record))
+			require.NoError(t, writer.Flush())
+			require.Equal(t, test.expected, out.String())
+		})
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := export_writer.New("xml", &bytes.Buffer{}, []string{"id"})
+	require.ErrorIs(t, err, export_writer.ErrUnknownFormat)
+}
@@ -0,0 +1,156 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale язык сообщений, если ни Accept-Language, ни локаль пользователя не подходят.
+// Каталог этого языка задаёт коды сообщений: в остальных каталогах те же ключи
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs сообщения по кодам для каждого поддерживаемого языка
+var catalogs = map[string]map[string]string{}
+
+// codesByMessage коды сообщений каталога DefaultLocale без параметров по тексту сообщения в нижнем регистре
+var codesByMessage = map[string]string{}
+
+func init() {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read message catalogs: %v", err))
+	}
+	for _, file := range files {
+		data, err := localeFiles.ReadFile("locales/" + file.Name())
+		if err != nil {
+			panic(fmt.Sprintf("failed to read message catalog %s: %v", file.Name(), err))
+		}
+		var messages map[string]string
+		if err = json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("invalid message catalog %s: %v", file.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+	for code, message := range catalogs[DefaultLocale] {
+		if !strings.Contains(message, "%") {
+			codesByMessage[normalize(message)] = code
+		}
+	}
+}
+
+// Supported возвращает поддерживаемые языки в алфавитном порядке
+func Supported() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// IsSupported есть ли каталог сообщений для языка locale
+func IsSupported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// CodeOf возвращает код сообщения каталога по его тексту на DefaultLocale без учёта регистра
+func CodeOf(message string) (string, bool) {
+	code, ok := codesByMessage[normalize(message)]
+	return code, ok
+}
+
+// Message возвращает сообщение code на языке locale, подставляя args.
+// Если в каталоге языка сообщения нет, используется каталог DefaultLocale
+func Message(locale, code string, args ...interface{}) (string, bool) {
+	template, ok := catalogs[locale][code]
+	if !ok {
+		template, ok = catalogs[DefaultLocale][code]
+		if !ok {
+			return "", false
+		}
+	}
+	if len(args) == 0 {
+		return template, true
+	}
+	return fmt.Sprintf(template, args...), true
+}
+
+// Matches является ли message сообщением code каталога DefaultLocale
+func Matches(code, message string) bool {
+	template, ok := catalogs[DefaultLocale][code]
+	return ok && normalize(template) == normalize(message)
+}
+
+// Negotiate выбирает поддерживаемый язык из заголовка Accept-Language с учётом весов q.
+// Регион языка не учитывается: ru-RU соответствует ru. Если подходящего языка нет, возвращается пустая строка
+func Negotiate(acceptLanguage string) string {
+	best, bestWeight := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if weight > bestWeight && IsSupported(language) {
+			best, bestWeight = language, weight
+		}
+	}
+	return best
+}
+
+type contextKey int
+
+const (
+	requestedLocaleKey contextKey = iota
+	userLocaleKey
+)
+
+// WithRequestedLocale сохраняет в контексте язык, выбранный клиентом через Accept-Language
+func WithRequestedLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, requestedLocaleKey, locale)
+}
+
+// WithUserLocale сохраняет в контексте язык из профиля пользователя, выполняющего запрос
+func WithUserLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, userLocaleKey, locale)
+}
+
+// FromContext возвращает язык ответа: выбранный через Accept-Language, иначе язык пользователя, иначе DefaultLocale
+func FromContext(ctx context.Context) string {
+	for _, key := range []contextKey{requestedLocaleKey, userLocaleKey} {
+		if locale, ok := ctx.Value(key).(string); ok && IsSupported(locale) {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// MissingCodes коды каталога DefaultLocale, которых нет в каталоге locale. Нужна для проверки полноты каталогов
+func MissingCodes(locale string) []string {
+	var missing []string
+	for code := range catalogs[DefaultLocale] {
+		if _, ok := catalogs[locale][code]; !ok {
+			missing = append(missing, code)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+func normalize(message string) string {
+	return strings.ToLower(strings.TrimSpace(message))
+}
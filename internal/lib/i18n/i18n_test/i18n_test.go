@@ -0,0 +1,57 @@
+package i18n_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/i18n"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	require.Equal(t, []string{"en", "ru"}, i18n.Supported())
+	for _, locale := range i18n.Supported() {
+		require.Empty(t, i18n.MissingCodes(locale), "catalog %s is incomplete", locale)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "ru", want: "ru"},
+		{header: "ru-RU,ru;q=0.9,en;q=0.8", want: "ru"},
+		{header: "de-DE,en;q=0.5,ru;q=0.7", want: "ru"},
+		{header: "EN-us", want: "en"},
+		{header: "de, fr;q=0.8", want: ""},
+		{header: "ru;q=bad, en;q=0.1", want: "en"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, i18n.Negotiate(tt.header), "header %q", tt.header)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, i18n.DefaultLocale, i18n.FromContext(ctx))
+
+	ctx = i18n.WithUserLocale(ctx, "ru")
+	require.Equal(t, "ru", i18n.FromContext(ctx))
+
+	// Язык из Accept-Language важнее языка пользователя
+	require.Equal(t, "en", i18n.FromContext(i18n.WithRequestedLocale(ctx, "en")))
+}
+
+func TestMessage(t *testing.T) {
+	code, ok := i18n.CodeOf("user not found")
+	require.True(t, ok)
+	require.Equal(t, "user_not_found", code)
+
+	message, ok := i18n.Message("ru", "field_required", "email")
+	require.True(t, ok)
+	require.Equal(t, "поле email обязательно", message)
+
+	_, ok = i18n.Message("ru", "unknown_code")
+	require.False(t, ok)
+}
@@ -0,0 +1,171 @@
+package import_reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/export_writer"
+	"github.com/ShlykovPavel/users-microservice/models/users/import_users"
+	"io"
+	"strings"
+)
+
+// maxLineSize максимальная длина строки NDJSON
+const maxLineSize = 64 * 1024
+
+var ErrEmptyImport = errors.New("import file has no rows")
+var ErrTooManyRows = errors.New("import file has too many rows")
+
+// ErrInvalidFile файл импорта нельзя разобрать целиком: нет заголовка CSV, неизвестная колонка, битая кодировка
+var ErrInvalidFile = errors.New("invalid import file")
+
+// AttributeColumnPrefix префикс колонок CSV с дополнительными атрибутами: attributes.department
+const AttributeColumnPrefix = "attributes."
+
+// Row строка файла импорта. Если строку не удалось разобрать, Err содержит причину, а User пуст
+type Row struct {
+	Line int
+	User import_users.ImportUser
+	Err  error
+	// TextAttributes значения User.Attributes — строки CSV, их нужно привести к типам атрибутов
+	TextAttributes bool
+}
+
+// columnSetters поля import_users.ImportUser по именам колонок CSV
+var columnSetters = map[string]func(user *import_users.ImportUser, value string){
+	"first_name":    func(user *import_users.ImportUser, value string) { user.FirstName = value },
+	"last_name":     func(user *import_users.ImportUser, value string) { user.LastName = value },
+	"email":         func(user *import_users.ImportUser, value string) { user.Email = value },
+	"password":      func(user *import_users.ImportUser, value string) { user.Password = value },
+	"password_hash": func(user *import_users.ImportUser, value string) { user.PasswordHash = value },
+	"phone":         func(user *import_users.ImportUser, value string) { user.Phone = value },
+	"role":          func(user *import_users.ImportUser, value string) { user.Role = value },
+}
+
+// FormatFromContentType определяет формат импорта по Content-Type запроса. Для неизвестного типа возвращает ""
+func FormatFromContentType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "text/csv":
+		return export_writer.FormatCSV
+	case "application/x-ndjson", "application/ndjson":
+		return export_writer.FormatNDJSON
+	default:
+		return ""
+	}
+}
+
+// Read читает строки файла импорта формата format (export_writer.FormatCSV или export_writer.FormatNDJSON).
+// CSV должен начинаться с заголовка с именами колонок, NDJSON — по одному объекту на строку, пустые строки пропускаются.
+// Ошибки отдельных строк возвращаются в Row.Err, ошибка всего файла — вторым значением.
+// Если строк больше maxRows, возвращается ErrTooManyRows
+func Read(format string, in io.Reader, maxRows int) ([]Row, error) {
+	var rows []Row
+	var err error
+	switch format {
+	case export_writer.FormatCSV:
+		rows, err = readCSV(in, maxRows)
+	case export_writer.FormatNDJSON:
+		rows, err = readNDJSON(in, maxRows)
+	default:
+		return nil, export_writer.ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyImport
+	}
+	return rows, nil
+}
+
+func readCSV(in io.Reader, maxRows int) ([]Row, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmptyImport
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+	seen := make(map[string]struct{}, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if _, ok := columnSetters[column]; !ok && !strings.HasPrefix(column, AttributeColumnPrefix) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidFile, column)
+		}
+		if _, ok := seen[column]; ok {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidFile, column)
+		}
+		seen[column] = struct{}{}
+		header[i] = column
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if len(rows) == maxRows {
+			return nil, ErrTooManyRows
+		}
+		row := Row{Line: len(rows) + 1, TextAttributes: true}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			row.Err = parseErr.Err
+		case err != nil:
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+		case len(record) != len(header):
+			row.Err = fmt.Errorf("expected %d columns, got %d", len(header), len(record))
+		default:
+			for i, value := range record {
+				value = strings.TrimSpace(value)
+				if name, ok := strings.CutPrefix(header[i], AttributeColumnPrefix); ok {
+					if value == "" {
+						continue
+					}
+					if row.User.Attributes == nil {
+						row.User.Attributes = make(map[string]interface{})
+					}
+					row.User.Attributes[name] = value
+					continue
+				}
+				columnSetters[header[i]](&row.User, value)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readNDJSON(in io.Reader, maxRows int) ([]Row, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	var rows []Row
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, ErrTooManyRows
+		}
+		row := Row{Line: len(rows) + 1}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row.User); err != nil {
+			row.User = import_users.ImportUser{}
+			row.Err = fmt.Errorf("invalid JSON: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+	}
+	return rows, nil
+}
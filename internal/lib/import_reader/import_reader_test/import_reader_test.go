@@ -0,0 +1,66 @@
+package import_reader_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/export_writer"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/import_reader"
+	"github.com/ShlykovPavel/users-microservice/models/users/import_users"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	input := "email,first_name,last_name,phone,password\n" +
+		"ryan@gmail.com,Ryan,Gosling,79001234567,secret\n" +
+		"broken@gmail.com,Ryan\n"
+
+	rows, err := import_reader.Read(export_writer.FormatCSV, strings.NewReader(input), 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.NoError(t, rows[0].Err)
+	require.Equal(t, import_users.ImportUser{
+		FirstName: "Ryan",
+		LastName:  "Gosling",
+		Email:     "ryan@gmail.com",
+		Password:  "secret",
+		Phone:     "79001234567",
+	}, rows[0].User)
+	require.Equal(t, 2, rows[1].Line)
+	require.Error(t, rows[1].Err)
+}
+
+func TestReadCSVAttributes(t *testing.T) {
+	input := "email,attributes.department,attributes.grade\n" +
+		"ryan@gmail.com,it,3\n" +
+		"emma@gmail.com,sales,\n"
+
+	rows, err := import_reader.Read(export_writer.FormatCSV, strings.NewReader(input), 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.True(t, rows[0].TextAttributes)
+	require.Equal(t, map[string]interface{}{"department": "it", "grade": "3"}, rows[0].User.Attributes)
+	require.Equal(t, map[string]interface{}{"department": "sales"}, rows[1].User.Attributes)
+}
+
+func TestReadNDJSON(t *testing.T) {
+	input := `{"email":"ryan@gmail.com","first_name":"Ryan","role":"admin"}` + "\n\n" +
+		`{"email":"ryan@gmail.com","unknown":1}` + "\n"
+
+	rows, err := import_reader.Read(export_writer.FormatNDJSON, strings.NewReader(input), 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.NoError(t, rows[0].Err)
+	require.Equal(t, "admin", rows[0].User.Role)
+	require.Error(t, rows[1].Err)
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := import_reader.Read(export_writer.FormatCSV, strings.NewReader("email,login\n"), 10)
+	require.ErrorIs(t, err, import_reader.ErrInvalidFile)
+
+	_, err = import_reader.Read(export_writer.FormatCSV, strings.NewReader("email\n"), 10)
+	require.ErrorIs(t, err, import_reader.ErrEmptyImport)
+
+	_, err = import_reader.Read(export_writer.FormatNDJSON, strings.NewReader("{}\n{}\n{}\n"), 2)
+	require.ErrorIs(t, err, import_reader.ErrTooManyRows)
+}
@@ -4,8 +4,53 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"strconv"
+	"time"
 )
 
+// CreateToken создаёт подписанный JWT токен для пользователя.
+// В токен записываются Id пользователя (sub), его роль (user_role), время жизни токена (exp)
+// и язык пользователя (locale), если он задан: на нём API отвечает, когда клиент не передал Accept-Language
+func CreateToken(userId int64, role, locale string, secretKey string, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":       strconv.FormatInt(userId, 10),
+		"user_role": role,
+		"iat":       now.Unix(),
+		"exp":       now.Add(duration).Unix(),
+	}
+	if locale != "" {
+		claims["locale"] = locale
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// CreateImpersonationToken создаёт токен, с которым администратор actorId работает от имени пользователя userId.
+// Кроме обычных claims в токен записывается claim act (RFC 8693) с Id администратора
+func CreateImpersonationToken(userId int64, role string, actorId int64, secretKey string, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":       strconv.FormatInt(userId, 10),
+		"user_role": role,
+		"act": map[string]interface{}{
+			"sub": strconv.FormatInt(actorId, 10),
+		},
+		"iat": now.Unix(),
+		"exp": now.Add(duration).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
 // VerifyToken verifies a JWT token and returns its claims
 func VerifyToken(tokenString string, secretKey string) (jwt.MapClaims, error) {
 	// Парсим токен
@@ -0,0 +1,52 @@
+package notifications
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Типы уведомлений, которые отправляет сервис
+const (
+	TypeNewDeviceLogin = "new_device_login"
+	TypeInvitation     = "invitation"
+	// Смена email: токен подтверждения на новый адрес, уведомления на старый адрес
+	TypeEmailChangeConfirmation = "email_change_confirmation"
+	TypeEmailChangeRequested    = "email_change_requested"
+	TypeEmailChanged            = "email_changed"
+	// Дополнительные адреса: токен подтверждения на добавленный адрес, уведомление на основной адрес
+	TypeEmailVerification = "email_verification"
+	TypeEmailAdded        = "email_added"
+)
+
+// Notification Событие, о котором нужно сообщить пользователю
+type Notification struct {
+	Type      string            // Тип события (например, TypeNewDeviceLogin)
+	UserID    int64             // Id пользователя, которому адресовано уведомление
+	Recipient string            // Адрес получателя
+	Data      map[string]string // Дополнительные данные для шаблона уведомления
+}
+
+// Notifier — интерфейс отправки уведомлений пользователям.
+// Конкретная реализация (email, очередь сообщений итп) подключается при сборке приложения.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier реализация Notifier, которая только пишет уведомления в лог.
+// Используется локально и в тестах, пока не подключен реальный канал доставки.
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.log.Info("Notification emitted",
+		slog.String("type", notification.Type),
+		slog.Int64("user_id", notification.UserID),
+		slog.String("recipient", notification.Recipient),
+		slog.Any("data", notification.Data))
+	return nil
+}
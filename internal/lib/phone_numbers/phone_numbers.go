@@ -0,0 +1,159 @@
+package phone_numbers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPhone номер не удалось привести к E.164: лишние символы или неверная длина
+var ErrInvalidPhone = errors.New("phone number is invalid")
+
+// ErrUnknownCountryCode код страны номера не описан в метаданных
+var ErrUnknownCountryCode = errors.New("phone country code is not supported")
+
+// ErrUnknownRegion регион по умолчанию не описан в метаданных
+var ErrUnknownRegion = errors.New("phone region is not supported")
+
+//go:embed metadata.json
+var metadataFile []byte
+
+// region Правила номеров страны: код страны, допустимые длины национального номера и префикс междугородного набора
+type region struct {
+	Region      string `json:"region"`
+	CountryCode int    `json:"country_code"`
+	Lengths     []int  `json:"lengths"`
+	TrunkPrefix string `json:"trunk_prefix"`
+}
+
+var (
+	regions = map[string]region{}
+	// lengthsByCode допустимые длины национального номера по коду страны (код может быть общим у нескольких регионов)
+	lengthsByCode = map[string][]int{}
+	defaultRegion = "RU"
+)
+
+func init() {
+	var list []region
+	if err := json.Unmarshal(metadataFile, &list); err != nil {
+		panic(fmt.Sprintf("invalid phone metadata: %v", err))
+	}
+	for _, r := range list {
+		regions[r.Region] = r
+		code := strconv.Itoa(r.CountryCode)
+		for _, length := range r.Lengths {
+			if !slices.Contains(lengthsByCode[code], length) {
+				lengthsByCode[code] = append(lengthsByCode[code], length)
+			}
+		}
+	}
+}
+
+// Number Номер телефона в формате E.164
+type Number struct {
+	E164        string // Например +79001234567
+	CountryCode int
+}
+
+// SetDefaultRegion задаёт регион (ISO 3166-1 alpha-2), по правилам которого разбираются номера без кода страны
+func SetDefaultRegion(code string) error {
+	code = strings.ToUpper(code)
+	if _, ok := regions[code]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRegion, code)
+	}
+	defaultRegion = code
+	return nil
+}
+
+// Normalize приводит номер к E.164 по правилам региона по умолчанию (см. SetDefaultRegion)
+func Normalize(raw string) (Number, error) {
+	return Parse(raw, defaultRegion)
+}
+
+// Parse приводит номер к E.164. Пробелы, дефисы, точки и скобки отбрасываются.
+// Номер с + или 00 в начале считается международным. Номер без кода страны разбирается по правилам regionCode:
+// префикс междугородного набора (8 для России) отбрасывается. Номер, который начинается с кода страны
+// региона без +, тоже принимается — так хранились телефоны до нормализации
+func Parse(raw, regionCode string) (Number, error) {
+	digits, international := clean(raw)
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Number{}, ErrInvalidPhone
+	}
+	if international {
+		return parseInternational(digits)
+	}
+
+	r, ok := regions[strings.ToUpper(regionCode)]
+	if !ok {
+		return Number{}, fmt.Errorf("%w: %s", ErrUnknownRegion, regionCode)
+	}
+	code := strconv.Itoa(r.CountryCode)
+	if r.TrunkPrefix != "" {
+		if national, ok := strings.CutPrefix(digits, r.TrunkPrefix); ok && validLength(code, national) {
+			return Number{E164: "+" + code + national, CountryCode: r.CountryCode}, nil
+		}
+	}
+	if validLength(code, digits) {
+		return Number{E164: "+" + code + digits, CountryCode: r.CountryCode}, nil
+	}
+	if national, ok := strings.CutPrefix(digits, code); ok && validLength(code, national) {
+		return Number{E164: "+" + digits, CountryCode: r.CountryCode}, nil
+	}
+	return Number{}, ErrInvalidPhone
+}
+
+// CountryCodeOf возвращает код страны номера в формате E.164, 0 — если номер не в E.164 или код неизвестен
+func CountryCodeOf(e164 string) int {
+	digits, ok := strings.CutPrefix(e164, "+")
+	if !ok {
+		return 0
+	}
+	number, err := parseInternational(digits)
+	if err != nil {
+		return 0
+	}
+	return number.CountryCode
+}
+
+// parseInternational разбирает номер с кодом страны: коды E.164 не являются префиксами друг друга,
+// поэтому подходит первый найденный код длиной от 1 до 3 цифр
+func parseInternational(digits string) (Number, error) {
+	for length := 1; length <= 3 && length < len(digits); length++ {
+		code := digits[:length]
+		if _, ok := lengthsByCode[code]; !ok {
+			continue
+		}
+		if !validLength(code, digits[length:]) {
+			return Number{}, ErrInvalidPhone
+		}
+		countryCode, _ := strconv.Atoi(code)
+		return Number{E164: "+" + digits, CountryCode: countryCode}, nil
+	}
+	return Number{}, ErrUnknownCountryCode
+}
+
+func validLength(code, national string) bool {
+	return slices.Contains(lengthsByCode[code], len(national))
+}
+
+// clean убирает из номера разделители и префикс международного набора (+ или 00)
+func clean(raw string) (string, bool) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.', '\t':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+	if rest, ok := strings.CutPrefix(digits, "+"); ok {
+		return rest, true
+	}
+	if rest, ok := strings.CutPrefix(digits, "00"); ok {
+		return rest, true
+	}
+	return digits, false
+}
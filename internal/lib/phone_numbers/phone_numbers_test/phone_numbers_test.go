@@ -0,0 +1,65 @@
+package phone_numbers_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		region      string
+		want        string
+		countryCode int
+		wantErr     error
+	}{
+		{name: "E.164", raw: "+79001234567", region: "RU", want: "+79001234567", countryCode: 7},
+		{name: "Separators", raw: "+7 (900) 123-45-67", region: "RU", want: "+79001234567", countryCode: 7},
+		{name: "International prefix 00", raw: "00 44 20 7946 0958", region: "RU", want: "+442079460958", countryCode: 44},
+		{name: "Trunk prefix", raw: "8 900 123 45 67", region: "RU", want: "+79001234567", countryCode: 7},
+		{name: "National number", raw: "9001234567", region: "RU", want: "+79001234567", countryCode: 7},
+		{name: "Country code without plus", raw: "79001234567", region: "RU", want: "+79001234567", countryCode: 7},
+		{name: "Other region", raw: "(020) 7946 0958", region: "GB", want: "+442079460958", countryCode: 44},
+		{name: "Three digit country code", raw: "+375 29 123 45 67", region: "RU", want: "+375291234567", countryCode: 375},
+		{name: "Multi character trunk prefix", raw: "80 29 123 45 67", region: "BY", want: "+375291234567", countryCode: 375},
+		{name: "Shared country code", raw: "+7 701 123 45 67", region: "US", want: "+77011234567", countryCode: 7},
+		{name: "Too short", raw: "+7900123456", region: "RU", wantErr: phone_numbers.ErrInvalidPhone},
+		{name: "Too long national", raw: "890012345678", region: "RU", wantErr: phone_numbers.ErrInvalidPhone},
+		{name: "Letters", raw: "+7900123456a", region: "RU", wantErr: phone_numbers.ErrInvalidPhone},
+		{name: "Empty", raw: " ", region: "RU", wantErr: phone_numbers.ErrInvalidPhone},
+		{name: "Unknown country code", raw: "+999123456789", region: "RU", wantErr: phone_numbers.ErrUnknownCountryCode},
+		{name: "Unknown region", raw: "9001234567", region: "XX", wantErr: phone_numbers.ErrUnknownRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := phone_numbers.Parse(tt.raw, tt.region)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, number.E164)
+			assert.Equal(t, tt.countryCode, number.CountryCode)
+		})
+	}
+}
+
+func TestCountryCodeOf(t *testing.T) {
+	assert.Equal(t, 7, phone_numbers.CountryCodeOf("+79001234567"))
+	assert.Equal(t, 44, phone_numbers.CountryCodeOf("+442079460958"))
+	assert.Equal(t, 0, phone_numbers.CountryCodeOf("79001234567"))
+	assert.Equal(t, 0, phone_numbers.CountryCodeOf("+999123456789"))
+}
+
+func TestSetDefaultRegion(t *testing.T) {
+	require.ErrorIs(t, phone_numbers.SetDefaultRegion("XX"), phone_numbers.ErrUnknownRegion)
+
+	require.NoError(t, phone_numbers.SetDefaultRegion("gb"))
+	t.Cleanup(func() { _ = phone_numbers.SetDefaultRegion("RU") })
+	number, err := phone_numbers.Normalize("020 7946 0958")
+	require.NoError(t, err)
+	assert.Equal(t, "+442079460958", number.E164)
+}
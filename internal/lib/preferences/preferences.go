@@ -0,0 +1,197 @@
+package preferences
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// Типы значений настроек
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+var namespacePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+var ErrUnknownNamespace = errors.New("preferences namespace is not defined")
+var ErrNotObject = errors.New("preferences must be a JSON object")
+
+// ErrTooLarge настройки пространства имён больше допустимого размера
+var ErrTooLarge = errors.New("preferences are too large")
+
+//go:embed default_schema.json
+var defaultSchema []byte
+
+// Property Правила значения одной настройки
+type Property struct {
+	Type      string   `json:"type"`
+	Enum      []string `json:"enum,omitempty"`       // Допустимые значения строки
+	MaxLength int      `json:"max_length,omitempty"` // Максимальная длина строки в байтах
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+}
+
+// Namespace Схема настроек пространства имён
+type Namespace struct {
+	Properties map[string]Property `json:"properties"`
+	// AdditionalProperties разрешает настройки, которых нет в Properties, с любыми значениями
+	AdditionalProperties bool `json:"additional_properties"`
+}
+
+// Schema Схемы настроек по пространствам имён. Сохранять можно только пространства имён из схемы
+type Schema struct {
+	namespaces map[string]Namespace
+	maxBytes   int
+}
+
+// Error Ошибка значения настройки
+type Error struct {
+	Key    string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("preference %s %s", e.Key, e.Reason)
+}
+
+// AsError проверяет, является ли ошибка ошибкой значения настройки
+func AsError(err error) (*Error, bool) {
+	var preferenceErr *Error
+	ok := errors.As(err, &preferenceErr)
+	return preferenceErr, ok
+}
+
+// LoadSchema загружает схемы настроек из JSON файла path, если он не задан — встроенные схемы.
+// maxBytes — максимальный размер настроек одного пространства имён
+func LoadSchema(path string, maxBytes int) (*Schema, error) {
+	data := defaultSchema
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read preferences schema %s: %w", path, err)
+		}
+	}
+	return ParseSchema(data, maxBytes)
+}
+
+// ParseSchema разбирает схемы настроек: объект, ключи которого — пространства имён, значения — Namespace
+func ParseSchema(data []byte, maxBytes int) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var namespaces map[string]Namespace
+	if err := decoder.Decode(&namespaces); err != nil {
+		return nil, fmt.Errorf("invalid preferences schema: %w", err)
+	}
+	for name, namespace := range namespaces {
+		if !namespacePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid preferences schema: bad namespace name %q", name)
+		}
+		for key, property := range namespace.Properties {
+			switch property.Type {
+			case TypeString, TypeInteger, TypeNumber, TypeBoolean, TypeArray, TypeObject:
+			default:
+				return nil, fmt.Errorf("invalid preferences schema: %s.%s has unknown type %q", name, key, property.Type)
+			}
+		}
+	}
+	return &Schema{namespaces: namespaces, maxBytes: maxBytes}, nil
+}
+
+// Has есть ли пространство имён в схеме
+func (s *Schema) Has(namespace string) bool {
+	_, ok := s.namespaces[namespace]
+	return ok
+}
+
+// MaxBytes максимальный размер настроек одного пространства имён в компактном виде
+func (s *Schema) MaxBytes() int {
+	return s.maxBytes
+}
+
+// Validate проверяет настройки пространства имён и возвращает их в компактном виде для сохранения
+func (s *Schema) Validate(namespace string, data []byte) (json.RawMessage, error) {
+	schema, ok := s.namespaces[namespace]
+	if !ok {
+		return nil, ErrUnknownNamespace
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, ErrNotObject
+	}
+	if compact.Len() > s.maxBytes {
+		return nil, fmt.Errorf("%w: at most %d bytes allowed", ErrTooLarge, s.maxBytes)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(compact.Bytes(), &values); err != nil || values == nil {
+		return nil, ErrNotObject
+	}
+	for key, value := range values {
+		property, ok := schema.Properties[key]
+		if !ok {
+			if schema.AdditionalProperties {
+				continue
+			}
+			return nil, &Error{Key: key, Reason: "is not defined"}
+		}
+		if err := property.check(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return compact.Bytes(), nil
+}
+
+func (p Property) check(key string, value interface{}) error {
+	switch p.Type {
+	case TypeString:
+		text, ok := value.(string)
+		if !ok {
+			return &Error{Key: key, Reason: "must be a string"}
+		}
+		if p.MaxLength > 0 && len(text) > p.MaxLength {
+			return &Error{Key: key, Reason: fmt.Sprintf("must be at most %d bytes", p.MaxLength)}
+		}
+		if len(p.Enum) > 0 && !slices.Contains(p.Enum, text) {
+			return &Error{Key: key, Reason: "must be one of the allowed values"}
+		}
+	case TypeInteger, TypeNumber:
+		number, ok := value.(float64)
+		if !ok || (p.Type == TypeInteger && number != math.Trunc(number)) {
+			return &Error{Key: key, Reason: "must be " + typeName(p.Type)}
+		}
+		if (p.Minimum != nil && number < *p.Minimum) || (p.Maximum != nil && number > *p.Maximum) {
+			return &Error{Key: key, Reason: "is out of range"}
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return &Error{Key: key, Reason: "must be a boolean"}
+		}
+	case TypeArray:
+		if _, ok := value.([]interface{}); !ok {
+			return &Error{Key: key, Reason: "must be an array"}
+		}
+	case TypeObject:
+		if _, ok := value.(map[string]interface{}); !ok {
+			return &Error{Key: key, Reason: "must be an object"}
+		}
+	}
+	return nil
+}
+
+func typeName(valueType string) string {
+	if valueType == TypeInteger {
+		return "an integer"
+	}
+	return "a number"
+}
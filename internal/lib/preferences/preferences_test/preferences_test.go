@@ -0,0 +1,64 @@
+package preferences_test
+
+import (
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/preferences"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema, err := preferences.LoadSchema("", 64)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		namespace string
+		data      string
+		want      string
+		wantErr   error
+		errKey    string
+	}{
+		{name: "Valid", namespace: "ui", data: "{\n  \"theme\": \"dark\",\n  \"page_size\": 20\n}",
+			want: `{"theme":"dark","page_size":20}`},
+		{name: "Unknown namespace", namespace: "billing", data: `{}`, wantErr: preferences.ErrUnknownNamespace},
+		{name: "Not an object", namespace: "ui", data: `["dark"]`, wantErr: preferences.ErrNotObject},
+		{name: "Invalid JSON", namespace: "ui", data: `{"theme":`, wantErr: preferences.ErrNotObject},
+		{name: "Too large", namespace: "tables", data: `{"users":"` + strings.Repeat("x", 64) + `"}`, wantErr: preferences.ErrTooLarge},
+		{name: "Undefined key", namespace: "ui", data: `{"font":"mono"}`, errKey: "font"},
+		{name: "Not in enum", namespace: "ui", data: `{"theme":"blue"}`, errKey: "theme"},
+		{name: "Not an integer", namespace: "ui", data: `{"page_size":20.5}`, errKey: "page_size"},
+		{name: "Out of range", namespace: "ui", data: `{"page_size":500}`, errKey: "page_size"},
+		{name: "Wrong type", namespace: "notifications", data: `{"email":"yes"}`, errKey: "email"},
+		{name: "Additional properties", namespace: "tables", data: `{"users":{"columns":["id"]}}`,
+			want: `{"users":{"columns":["id"]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := schema.Validate(tt.namespace, []byte(tt.data))
+			switch {
+			case tt.wantErr != nil:
+				require.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+			case tt.errKey != "":
+				preferenceErr, ok := preferences.AsError(err)
+				require.True(t, ok, "got %v", err)
+				require.Equal(t, tt.errKey, preferenceErr.Key)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tt.want, string(value))
+			}
+		})
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	_, err := preferences.ParseSchema([]byte(`{"ui":{"properties":{"theme":{"type":"color"}}}}`), 1024)
+	require.Error(t, err)
+
+	_, err = preferences.ParseSchema([]byte(`{"UI":{"properties":{}}}`), 1024)
+	require.Error(t, err)
+
+	_, err = preferences.ParseSchema([]byte(`{"ui":{"properties":{},"strict":true}}`), 1024)
+	require.Error(t, err)
+}
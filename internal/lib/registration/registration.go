@@ -0,0 +1,91 @@
+package registration
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"strings"
+)
+
+// Режимы регистрации пользователей
+const (
+	ModeOpen            = "open"             // Регистрироваться может кто угодно
+	ModeInviteOnly      = "invite_only"      // Только по приглашению
+	ModeDomainAllowlist = "domain_allowlist" // Только с email из разрешённых доменов (или по приглашению)
+	ModeClosed          = "closed"           // Регистрация выключена
+)
+
+var ErrRegistrationClosed = errors.New("registration is closed")
+var ErrInvitationRequired = errors.New("registration is allowed by invitation only")
+var ErrDomainNotAllowed = errors.New("registration with this email domain is not allowed")
+
+// Policy Настройки регистрации
+type Policy struct {
+	Mode           string
+	AllowedDomains []string
+	// RequireApproval пользователи, зарегистрировавшиеся без приглашения, создаются в статусе pending
+	// и не могут войти, пока администратор их не активирует
+	RequireApproval bool
+}
+
+// NewPolicy создаёт настройки регистрации и проверяет, что режим поддерживается
+func NewPolicy(mode string, allowedDomains []string) (Policy, error) {
+	switch mode {
+	case ModeOpen, ModeInviteOnly, ModeDomainAllowlist, ModeClosed:
+	default:
+		return Policy{}, fmt.Errorf("unknown registration mode: %s", mode)
+	}
+	domains := make([]string, 0, len(allowedDomains))
+	for _, domain := range allowedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if mode == ModeDomainAllowlist && len(domains) == 0 {
+		return Policy{}, errors.New("registration mode domain_allowlist requires at least one allowed domain")
+	}
+	return Policy{Mode: mode, AllowedDomains: domains}, nil
+}
+
+// Check проверяет, можно ли зарегистрироваться с указанным email.
+// hasInvitation — передан ли в запросе токен приглашения (его валидность проверяется отдельно).
+// Приглашение позволяет зарегистрироваться в любом режиме, кроме closed
+func (p Policy) Check(email string, hasInvitation bool) error {
+	switch p.Mode {
+	case ModeClosed:
+		return ErrRegistrationClosed
+	case ModeInviteOnly:
+		if !hasInvitation {
+			return ErrInvitationRequired
+		}
+	case ModeDomainAllowlist:
+		if !hasInvitation && !p.domainAllowed(email) {
+			return ErrDomainNotAllowed
+		}
+	}
+	return nil
+}
+
+// InitialStatus возвращает статус, с которым создаётся зарегистрировавшийся пользователь.
+// Приглашение выдаёт администратор, поэтому приглашённые пользователи активны сразу
+func (p Policy) InitialStatus(hasInvitation bool) string {
+	if p.RequireApproval && !hasInvitation {
+		return user_status.Pending
+	}
+	return user_status.Active
+}
+
+func (p Policy) domainAllowed(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range p.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package secure_tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes длина случайной части токена в байтах
+const tokenBytes = 32
+
+// Generate создаёт случайный одноразовый токен (приглашения, подтверждения итп).
+// Возвращает сам токен, который отдаётся пользователю, и его хеш, который сохраняется в БД
+func Generate() (string, string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, Hash(token), nil
+}
+
+// Hash возвращает sha256 хеш токена в hex. В БД храним только хеш, что б утечка таблицы не давала рабочих токенов
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+package attribute_service
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/attributes_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"log/slog"
+)
+
+// ListDefinitions возвращает все определения атрибутов пользователей
+func ListDefinitions(log *slog.Logger, attributeRepository attributes_db.AttributeRepository, ctx context.Context) (attribute_definition.DefinitionsList, error) {
+	const op = "internal/lib/services/attribute_service/attribute_service.go/ListDefinitions"
+	log = log.With(slog.String("op", op))
+
+	definitions, err := attributeRepository.ListDefinitions(ctx)
+	if err != nil {
+		log.Error("Failed to list attribute definitions", "err", err)
+		return attribute_definition.DefinitionsList{}, err
+	}
+	if definitions == nil {
+		definitions = []attribute_definition.Definition{}
+	}
+	return attribute_definition.DefinitionsList{Definitions: definitions}, nil
+}
+
+// CreateDefinition проверяет имя и правила атрибута и создаёт его определение.
+// Без явной видимости атрибут виден всем
+func CreateDefinition(log *slog.Logger, attributeRepository attributes_db.AttributeRepository, ctx context.Context,
+	dto attribute_definition.CreateDefinitionRequest) (attribute_definition.Definition, error) {
+	const op = "internal/lib/services/attribute_service/attribute_service.go/CreateDefinition"
+	log = log.With(slog.String("op", op), slog.String("name", dto.Name))
+
+	if !user_attributes.ValidName(dto.Name) {
+		return attribute_definition.Definition{}, user_attributes.ErrInvalidName
+	}
+	if err := user_attributes.CheckRules(dto.Type, dto.EnumValues, dto.Pattern); err != nil {
+		return attribute_definition.Definition{}, err
+	}
+	visibility := dto.Visibility
+	if visibility == "" {
+		visibility = user_attributes.VisibilityPublic
+	}
+	definition, err := attributeRepository.CreateDefinition(ctx, attribute_definition.Definition{
+		Name:       dto.Name,
+		Type:       dto.Type,
+		Required:   dto.Required,
+		EnumValues: dto.EnumValues,
+		Pattern:    dto.Pattern,
+		Unique:     dto.Unique,
+		Indexed:    dto.Indexed,
+		Visibility: visibility,
+	})
+	if err != nil {
+		log.Debug("Failed to create attribute definition", "err", err)
+		return attribute_definition.Definition{}, err
+	}
+	log.Info("Attribute definition created", "type", definition.Type)
+	return definition, nil
+}
+
+// UpdateDefinition меняет обязательность, правила значения и видимость атрибута name
+func UpdateDefinition(log *slog.Logger, attributeRepository attributes_db.AttributeRepository, ctx context.Context,
+	name string, dto attribute_definition.UpdateDefinitionRequest) (attribute_definition.Definition, error) {
+	const op = "internal/lib/services/attribute_service/attribute_service.go/UpdateDefinition"
+	log = log.With(slog.String("op", op), slog.String("name", name))
+
+	definitions, err := attributeRepository.ListDefinitions(ctx)
+	if err != nil {
+		log.Error("Failed to list attribute definitions", "err", err)
+		return attribute_definition.Definition{}, err
+	}
+	var current *attribute_definition.Definition
+	for i := range definitions {
+		if definitions[i].Name == name {
+			current = &definitions[i]
+		}
+	}
+	if current == nil {
+		return attribute_definition.Definition{}, attributes_db.ErrDefinitionNotFound
+	}
+	if err = user_attributes.CheckRules(current.Type, dto.EnumValues, dto.Pattern); err != nil {
+		return attribute_definition.Definition{}, err
+	}
+
+	current.Required = dto.Required
+	current.EnumValues = dto.EnumValues
+	current.Pattern = dto.Pattern
+	current.Visibility = dto.Visibility
+	definition, err := attributeRepository.UpdateDefinition(ctx, *current)
+	if err != nil {
+		log.Debug("Failed to update attribute definition", "err", err)
+		return attribute_definition.Definition{}, err
+	}
+	log.Info("Attribute definition updated")
+	return definition, nil
+}
+
+// DeleteDefinition удаляет атрибут name вместе с его значениями у всех пользователей
+func DeleteDefinition(log *slog.Logger, attributeRepository attributes_db.AttributeRepository, ctx context.Context, name string) error {
+	const op = "internal/lib/services/attribute_service/attribute_service.go/DeleteDefinition"
+	log = log.With(slog.String("op", op), slog.String("name", name))
+
+	if err := attributeRepository.DeleteDefinition(ctx, name); err != nil {
+		log.Debug("Failed to delete attribute definition", "err", err)
+		return err
+	}
+	log.Info("Attribute definition deleted")
+	return nil
+}
@@ -0,0 +1,288 @@
+package auth_service
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/jwt_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/impersonation_audit_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
+	"github.com/ShlykovPavel/users-microservice/models/users/login"
+	"github.com/ShlykovPavel/users-microservice/models/users/login_events"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCredentials = errors.New("invalid email or password")
+var ErrImpersonationNotAllowed = errors.New("impersonation of this user is not allowed")
+
+// AccountStatusError вход запрещён из-за статуса учётной записи (см. user_status)
+type AccountStatusError struct {
+	Status string
+	Until  *time.Time // До какого времени действует статус, nil — бессрочно
+}
+
+func (e *AccountStatusError) Error() string {
+	return "account is " + e.Status
+}
+
+// Причины неудачной попытки входа, которые сохраняются в истории
+const (
+	failureUnknownEmail  = "unknown_email"
+	failureWrongPassword = "wrong_password"
+	// Для неактивных учётных записей причина — "account_" + статус (например account_suspended)
+	failureAccountPrefix = "account_"
+)
+
+// ClientInfo Данные о клиенте, с которого выполняется вход
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// TokenConfig Параметры выпуска JWT токена
+type TokenConfig struct {
+	SecretKey string
+	Duration  time.Duration
+}
+
+// LockoutConfig Блокировка учётной записи после неверных паролей.
+// После MaxFailedAttempts неверных паролей за Duration (и после последнего успешного входа)
+// активная учётная запись переводится в статус locked на Duration. MaxFailedAttempts = 0 — не блокировать
+type LockoutConfig struct {
+	MaxFailedAttempts int
+	Duration          time.Duration
+}
+
+// lockoutReason причина блокировки учётной записи после неверных паролей
+const lockoutReason = "too many failed login attempts"
+
+// Login проверяет email и пароль пользователя и выпускает JWT токен.
+// Каждая попытка входа (успешная или нет) записывается в историю входов.
+// Если вход выполнен с нового user agent или из новой IP сети, он помечается как подозрительный
+// и пользователю отправляется уведомление.
+// Войти можно только в активную учётную запись, статус проверяется после пароля, что б не раскрывать его посторонним.
+// После неверных паролей учётная запись может быть заблокирована (см. LockoutConfig)
+func Login(log *slog.Logger, userRepository users_db.UserRepository, loginEventsRepository login_events_db.LoginEventsRepository,
+	notifier notifications.Notifier, ctx context.Context, dto login.LoginRequest, client ClientInfo, tokenConfig TokenConfig,
+	lockout LockoutConfig) (string, error) {
+	const op = "internal/lib/services/auth_service/auth_service.go/Login"
+	log = log.With(slog.String("op", op))
+
+	event := login_events_db.LoginEvent{
+		Email:     dto.Email,
+		IPAddress: client.IPAddress,
+		IPNetwork: IPNetwork(client.IPAddress),
+		UserAgent: client.UserAgent,
+		Method:    login_events_db.MethodPassword,
+	}
+
+	user, err := getUserByLogin(userRepository, ctx, dto.Email)
+	if err != nil {
+		if errors.Is(err, users_db.ErrUserNotFound) {
+			event.FailureReason = failureUnknownEmail
+			recordLoginEvent(log, loginEventsRepository, ctx, &event)
+			return "", ErrInvalidCredentials
+		}
+		log.Error("Failed to get user by email", "err", err)
+		return "", err
+	}
+	event.UserID = &user.ID
+
+	if !users.ComparePassword(user.PasswordHash, dto.Password, log) {
+		event.FailureReason = failureWrongPassword
+		recordLoginEvent(log, loginEventsRepository, ctx, &event)
+		if user_status.CanLogin(user.Status) {
+			lockAfterFailedLogins(log, userRepository, loginEventsRepository, ctx, user.ID, lockout)
+		}
+		return "", ErrInvalidCredentials
+	}
+	if !user_status.CanLogin(user.Status) {
+		log.Info("Login rejected by account status", "user_id", user.ID, "status", user.Status)
+		event.FailureReason = failureAccountPrefix + user.Status
+		recordLoginEvent(log, loginEventsRepository, ctx, &event)
+		return "", &AccountStatusError{Status: user.Status, Until: user.StatusUntil}
+	}
+
+	token, err := jwt_tokens.CreateToken(user.ID, user.Role, user.Locale, tokenConfig.SecretKey, tokenConfig.Duration)
+	if err != nil {
+		log.Error("Failed to create token", "err", err)
+		return "", err
+	}
+
+	event.Success = true
+	device, err := loginEventsRepository.GetKnownDevice(ctx, user.ID, event.UserAgent, event.IPNetwork)
+	if err != nil {
+		// Ошибка проверки устройства не должна мешать пользователю войти
+		log.Error("Failed to check known device", "err", err)
+	} else {
+		event.Suspicious = device.HasHistory && (!device.KnownUserAgent || !device.KnownNetwork)
+	}
+	recordLoginEvent(log, loginEventsRepository, ctx, &event)
+
+	if event.Suspicious {
+		log.Warn("Suspicious login detected", "user_id", user.ID, "ip", event.IPAddress, "user_agent", event.UserAgent)
+		err = notifier.Notify(ctx, notifications.Notification{
+			Type:      notifications.TypeNewDeviceLogin,
+			UserID:    user.ID,
+			Recipient: user.Email,
+			Data: map[string]string{
+				"ip_address": event.IPAddress,
+				"user_agent": event.UserAgent,
+				"time":       time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			log.Error("Failed to send new device login notification", "err", err)
+		}
+	}
+	return token, nil
+}
+
+// Impersonate выпускает администратору adminId короткоживущий токен для работы от имени пользователя targetId.
+// Выдача токена записывается в журнал. Входить от своего имени и от имени других администраторов нельзя.
+// Возвращает токен и время окончания его действия
+func Impersonate(log *slog.Logger, userRepository users_db.UserRepository, auditRepository impersonation_audit_db.ImpersonationAuditRepository,
+	ctx context.Context, adminId, targetId int64, audit impersonation_audit_db.AuditRecord, tokenConfig TokenConfig) (string, time.Time, error) {
+	const op = "internal/lib/services/auth_service/auth_service.go/Impersonate"
+	log = log.With(slog.String("op", op),
+		slog.Int64("admin_id", adminId),
+		slog.Int64("target_user_id", targetId))
+
+	if adminId == targetId {
+		return "", time.Time{}, ErrImpersonationNotAllowed
+	}
+	target, err := userRepository.GetUser(ctx, targetId)
+	if err != nil {
+		log.Error("Failed to get impersonation target", "err", err)
+		return "", time.Time{}, err
+	}
+	if target.Role == "admin" {
+		log.Warn("Attempt to impersonate another admin")
+		return "", time.Time{}, ErrImpersonationNotAllowed
+	}
+
+	expiresAt := time.Now().Add(tokenConfig.Duration)
+	token, err := jwt_tokens.CreateImpersonationToken(targetId, target.Role, adminId, tokenConfig.SecretKey, tokenConfig.Duration)
+	if err != nil {
+		log.Error("Failed to create impersonation token", "err", err)
+		return "", time.Time{}, err
+	}
+
+	// Без записи в журнал токен не выдаём: каждое использование режима должно быть отслеживаемым
+	audit.AdminID = adminId
+	audit.TargetUserID = targetId
+	audit.Action = impersonation_audit_db.ActionTokenIssued
+	if err = auditRepository.AddAuditRecord(ctx, &audit); err != nil {
+		log.Error("Failed to save impersonation audit record", "err", err)
+		return "", time.Time{}, err
+	}
+	log.Info("Impersonation token issued", "expires_at", expiresAt)
+	return token, expiresAt, nil
+}
+
+// lockAfterFailedLogins блокирует учётную запись userId, если неверных паролей стало lockout.MaxFailedAttempts.
+// Ошибки только логируются: ответ на попытку входа от них не меняется
+func lockAfterFailedLogins(log *slog.Logger, userRepository users_db.UserRepository, loginEventsRepository login_events_db.LoginEventsRepository,
+	ctx context.Context, userId int64, lockout LockoutConfig) {
+	if lockout.MaxFailedAttempts <= 0 {
+		return
+	}
+	now := time.Now()
+	failed, err := loginEventsRepository.CountFailedLogins(ctx, userId, now.Add(-lockout.Duration))
+	if err != nil {
+		log.Error("Failed to count failed logins", "err", err, "user_id", userId)
+		return
+	}
+	if failed < lockout.MaxFailedAttempts {
+		return
+	}
+	until := now.Add(lockout.Duration)
+	_, err = userRepository.ChangeStatus(ctx, userId, users_db.AnyVersion, users_db.StatusChange{
+		Status: user_status.Locked,
+		Reason: lockoutReason,
+		Until:  &until,
+		From:   user_status.Active,
+	})
+	if err != nil {
+		log.Error("Failed to lock user after failed logins", "err", err, "user_id", userId)
+		return
+	}
+	log.Warn("User locked after failed logins", "user_id", userId, "failed_attempts", failed, "until", until)
+}
+
+// recordLoginEvent сохраняет попытку входа. Ошибка записи только логируется,
+// так как не должна влиять на результат аутентификации
+func recordLoginEvent(log *slog.Logger, loginEventsRepository login_events_db.LoginEventsRepository, ctx context.Context, event *login_events_db.LoginEvent) {
+	if _, err := loginEventsRepository.AddLoginEvent(ctx, event); err != nil {
+		log.Error("Failed to record login event", "err", err, "email", event.Email, "success", event.Success)
+	}
+}
+
+// IPNetwork возвращает сеть, к которой относится IP адрес: /24 для IPv4 и /64 для IPv6.
+// Сравнение по сети, а не по адресу, позволяет не считать подозрительной смену адреса у одного провайдера
+func IPNetwork(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ipAddress
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return (&net.IPNet{IP: ipv4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+// GetLoginEvents возвращает историю входов пользователя постранично
+func GetLoginEvents(log *slog.Logger, loginEventsRepository login_events_db.LoginEventsRepository, ctx context.Context, userId int64, queryParams query_params.ListQueryParams) (login_events.LoginEventsList, error) {
+	const op = "internal/lib/services/auth_service/auth_service.go/GetLoginEvents"
+	log = log.With(slog.String("op", op),
+		slog.String("UserId", strconv.FormatInt(userId, 10)))
+
+	result, err := loginEventsRepository.GetLoginEvents(ctx, userId, queryParams.Limit, queryParams.Offset, queryParams.SortParams)
+	if err != nil {
+		log.Error("Failed to get login events", "err", err)
+		return login_events.LoginEventsList{}, err
+	}
+	events := make([]login_events.LoginEventInfo, 0, len(result.Events))
+	for _, event := range result.Events {
+		events = append(events, login_events.LoginEventInfo{
+			Id:            event.ID,
+			Success:       event.Success,
+			FailureReason: event.FailureReason,
+			IPAddress:     event.IPAddress,
+			UserAgent:     event.UserAgent,
+			Method:        event.Method,
+			Suspicious:    event.Suspicious,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+	return login_events.LoginEventsList{
+		Events: events,
+		Meta: get_users_list.UsersListMetaData{
+			Page:   queryParams.Page,
+			Total:  &result.Total,
+			Limit:  queryParams.Limit,
+			Offset: queryParams.Offset,
+		},
+	}, nil
+}
+
+// getUserByLogin ищет пользователя по email в каноническом виде. Если не найден, ищет по введённому адресу:
+// адреса, сохранённые до включения правил почтовых сервисов, могут быть ещё не приведены к каноническому виду
+func getUserByLogin(userRepository users_db.UserRepository, ctx context.Context, email string) (users_db.UserInfo, error) {
+	canonical := email_address.Canonicalize(email)
+	user, err := userRepository.GetUserByEmail(ctx, canonical)
+	if errors.Is(err, users_db.ErrUserNotFound) && strings.TrimSpace(email) != canonical {
+		return userRepository.GetUserByEmail(ctx, strings.TrimSpace(email))
+	}
+	return user, err
+}
@@ -0,0 +1,118 @@
+package invitation_service
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
+	"github.com/ShlykovPavel/users-microservice/models/invitations"
+	"github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// CreateInvitation создаёт приглашение от имени администратора adminId и возвращает его вместе с токеном.
+// Если в приглашении указан email, приглашённому отправляется уведомление с токеном
+func CreateInvitation(log *slog.Logger, invitationRepository invitations_db.InvitationRepository, notifier notifications.Notifier,
+	ctx context.Context, dto invitations.CreateInvitationRequest, adminId int64, defaultTTL time.Duration) (invitations.CreateInvitationResponse, error) {
+	const op = "internal/lib/services/invitation_service/invitation_service.go/CreateInvitation"
+	log = log.With(slog.String("op", op), slog.Int64("admin_id", adminId))
+
+	token, tokenHash, err := secure_tokens.Generate()
+	if err != nil {
+		log.Error("Failed to generate invitation token", "err", err)
+		return invitations.CreateInvitationResponse{}, err
+	}
+	ttl := defaultTTL
+	if dto.ExpiresInHours > 0 {
+		ttl = time.Duration(dto.ExpiresInHours) * time.Hour
+	}
+	invitation := invitations_db.Invitation{
+		Email:     email_address.Canonicalize(dto.Email),
+		Role:      dto.Role,
+		TokenHash: tokenHash,
+		CreatedBy: &adminId,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	}
+	id, err := invitationRepository.CreateInvitation(ctx, &invitation)
+	if err != nil {
+		log.Error("Failed to create invitation", "err", err)
+		return invitations.CreateInvitationResponse{}, err
+	}
+	invitation.ID = id
+	invitation.CreatedAt = time.Now().UTC()
+
+	if invitation.Email != "" {
+		err = notifier.Notify(ctx, notifications.Notification{
+			Type:      notifications.TypeInvitation,
+			Recipient: invitation.Email,
+			Data: map[string]string{
+				"token":      token,
+				"role":       invitation.Role,
+				"expires_at": invitation.ExpiresAt.Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			log.Error("Failed to send invitation", "err", err, "invitation_id", id)
+		}
+	}
+	log.Info("Invitation created", "invitation_id", id, "role", invitation.Role)
+	return invitations.CreateInvitationResponse{
+		Invitation: toInvitationInfo(invitation),
+		Token:      token,
+	}, nil
+}
+
+func GetInvitations(log *slog.Logger, invitationRepository invitations_db.InvitationRepository, ctx context.Context, queryParams query_params.ListQueryParams) (invitations.InvitationsList, error) {
+	const op = "internal/lib/services/invitation_service/invitation_service.go/GetInvitations"
+	log = log.With(slog.String("op", op))
+
+	result, err := invitationRepository.GetInvitations(ctx, queryParams.Limit, queryParams.Offset, queryParams.SortParams)
+	if err != nil {
+		log.Error("Failed to get invitations", "err", err)
+		return invitations.InvitationsList{}, err
+	}
+	list := make([]invitations.InvitationInfo, 0, len(result.Invitations))
+	for _, invitation := range result.Invitations {
+		list = append(list, toInvitationInfo(invitation))
+	}
+	return invitations.InvitationsList{
+		Invitations: list,
+		Meta: get_users_list.UsersListMetaData{
+			Page:   queryParams.Page,
+			Total:  &result.Total,
+			Limit:  queryParams.Limit,
+			Offset: queryParams.Offset,
+		},
+	}, nil
+}
+
+func RevokeInvitation(log *slog.Logger, invitationRepository invitations_db.InvitationRepository, ctx context.Context, id int64) error {
+	const op = "internal/lib/services/invitation_service/invitation_service.go/RevokeInvitation"
+	log = log.With(slog.String("op", op),
+		slog.String("InvitationId", strconv.FormatInt(id, 10)))
+
+	err := invitationRepository.RevokeInvitation(ctx, id)
+	if err != nil {
+		log.Error("Failed to revoke invitation", "err", err)
+		return err
+	}
+	return nil
+}
+
+func toInvitationInfo(invitation invitations_db.Invitation) invitations.InvitationInfo {
+	return invitations.InvitationInfo{
+		Id:         invitation.ID,
+		Email:      invitation.Email,
+		Role:       invitation.Role,
+		ExpiresAt:  invitation.ExpiresAt,
+		ConsumedAt: invitation.ConsumedAt,
+		ConsumedBy: invitation.ConsumedBy,
+		RevokedAt:  invitation.RevokedAt,
+		CreatedBy:  invitation.CreatedBy,
+		CreatedAt:  invitation.CreatedAt,
+	}
+}
@@ -0,0 +1,73 @@
+package preferences_service
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/preferences"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/preferences_db"
+	preferences_dto "github.com/ShlykovPavel/users-microservice/models/users/preferences"
+	"log/slog"
+)
+
+// GetPreferences возвращает настройки пользователя в пространстве имён namespace
+func GetPreferences(log *slog.Logger, preferencesRepository preferences_db.PreferencesRepository, schema *preferences.Schema,
+	ctx context.Context, userId int64, namespace string) (preferences_dto.Preferences, error) {
+	const op = "internal/lib/services/preferences_service/preferences_service.go/GetPreferences"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId), slog.String("namespace", namespace))
+
+	if !schema.Has(namespace) {
+		return preferences_dto.Preferences{}, preferences.ErrUnknownNamespace
+	}
+	stored, err := preferencesRepository.GetPreferences(ctx, userId, namespace)
+	if err != nil {
+		log.Debug("Failed to get preferences", "err", err)
+		return preferences_dto.Preferences{}, err
+	}
+	return toDto(stored), nil
+}
+
+// PutPreferences проверяет настройки по схеме пространства имён и заменяет ими прежние.
+// Второе значение — true, если настройки сохранены впервые
+func PutPreferences(log *slog.Logger, preferencesRepository preferences_db.PreferencesRepository, schema *preferences.Schema,
+	ctx context.Context, userId int64, namespace string, data []byte) (preferences_dto.Preferences, bool, error) {
+	const op = "internal/lib/services/preferences_service/preferences_service.go/PutPreferences"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId), slog.String("namespace", namespace))
+
+	value, err := schema.Validate(namespace, data)
+	if err != nil {
+		log.Debug("Invalid preferences", "err", err)
+		return preferences_dto.Preferences{}, false, err
+	}
+	stored, created, err := preferencesRepository.PutPreferences(ctx, userId, namespace, value)
+	if err != nil {
+		log.Error("Failed to save preferences", "err", err)
+		return preferences_dto.Preferences{}, false, err
+	}
+	log.Info("Preferences saved", "created", created)
+	return toDto(stored), created, nil
+}
+
+// DeletePreferences удаляет настройки пользователя в пространстве имён namespace
+func DeletePreferences(log *slog.Logger, preferencesRepository preferences_db.PreferencesRepository, schema *preferences.Schema,
+	ctx context.Context, userId int64, namespace string) error {
+	const op = "internal/lib/services/preferences_service/preferences_service.go/DeletePreferences"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId), slog.String("namespace", namespace))
+
+	if !schema.Has(namespace) {
+		return preferences.ErrUnknownNamespace
+	}
+	if err := preferencesRepository.DeletePreferences(ctx, userId, namespace); err != nil {
+		log.Debug("Failed to delete preferences", "err", err)
+		return err
+	}
+	log.Info("Preferences deleted")
+	return nil
+}
+
+func toDto(stored preferences_db.Preferences) preferences_dto.Preferences {
+	return preferences_dto.Preferences{
+		Namespace: stored.Namespace,
+		Values:    stored.Value,
+		CreatedAt: stored.CreatedAt,
+		UpdatedAt: stored.UpdatedAt,
+	}
+}
@@ -0,0 +1,176 @@
+package user_service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/ShlykovPavel/users-microservice/models/users/batch_users"
+	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
+	"github.com/ShlykovPavel/users-microservice/models/users/update_user"
+	"github.com/ShlykovPavel/users-microservice/models/users/user_resource"
+	"log/slog"
+)
+
+var ErrBatchMissingId = errors.New("field id is required")
+var ErrBatchMissingRole = errors.New("field role is required")
+var ErrBatchInvalidData = errors.New("field data is invalid")
+
+// ErrBatchEmailChange смена email требует подтверждения с нового адреса и в пакете не выполняется
+var ErrBatchEmailChange = errors.New("email cannot be changed in batch, use PATCH /users/{id}")
+
+// ErrBatchRolledBack операция выполнилась, но атомарный пакет отменён из-за ошибки другой операции
+var ErrBatchRolledBack = errors.New("operation rolled back")
+
+// ErrBatchNotExecuted атомарный пакет отменён до этой операции
+var ErrBatchNotExecuted = errors.New("operation not executed")
+
+// BatchResult результат операции пакета: пользователь после операции (для delete — nil) или ошибка
+type BatchResult struct {
+	User *user_resource.User
+	Err  error
+}
+
+// ExecuteBatch выполняет операции пакета и возвращает их результаты в порядке запроса.
+// При request.Atomic операции выполняются в одной транзакции: первая ошибка откатывает пакет,
+// выполненные до неё операции получают ErrBatchRolledBack, остальные — ErrBatchNotExecuted.
+// Атрибуты в create и patch проверяются с правами администратора
+func ExecuteBatch(log *slog.Logger, userRepository users_db.UserRepository, attributeSchema AttributeSchema, ctx context.Context,
+	request batch_users.BatchRequest) []BatchResult {
+	const op = "internal/lib/services/user_service/batch.go/ExecuteBatch"
+	log = log.With(slog.String("op", op), slog.Bool("atomic", request.Atomic))
+
+	results := make([]BatchResult, len(request.Operations))
+	// Определения атрибутов читаются один раз на пакет
+	definitions, err := AttributeDefinitions(ctx, attributeSchema)
+	if err != nil {
+		log.Error("Failed to get attribute definitions", "err", err)
+		for i := range results {
+			results[i] = BatchResult{Err: err}
+		}
+		return results
+	}
+	schema := definitionsSchema(definitions)
+
+	if !request.Atomic {
+		for i, operation := range request.Operations {
+			results[i].User, results[i].Err = executeBatchOperation(log, userRepository, schema, ctx, operation)
+		}
+		return results
+	}
+
+	failed := -1
+	err = userRepository.WithTx(ctx, func(txRepository users_db.UserRepository) error {
+		for i, operation := range request.Operations {
+			user, err := executeBatchOperation(log, txRepository, schema, ctx, operation)
+			if err != nil {
+				failed = i
+				return err
+			}
+			results[i].User = user
+		}
+		return nil
+	})
+	if err == nil {
+		return results
+	}
+	log.Debug("Atomic batch rolled back", "failed_index", failed, "err", err)
+	for i := range results {
+		switch {
+		case failed < 0:
+			// Ошибка транзакции, а не операции: например, не удалось зафиксировать изменения
+			results[i] = BatchResult{Err: err}
+		case i < failed:
+			results[i] = BatchResult{Err: ErrBatchRolledBack}
+		case i == failed:
+			results[i] = BatchResult{Err: err}
+		default:
+			results[i] = BatchResult{Err: ErrBatchNotExecuted}
+		}
+	}
+	return results
+}
+
+// definitionsSchema AttributeSchema с заранее прочитанными определениями
+type definitionsSchema []attribute_definition.Definition
+
+func (s definitionsSchema) ListDefinitions(context.Context) ([]attribute_definition.Definition, error) {
+	return s, nil
+}
+
+func executeBatchOperation(log *slog.Logger, userRepository users_db.UserRepository, schema definitionsSchema, ctx context.Context,
+	operation batch_users.BatchOperation) (*user_resource.User, error) {
+	if operation.Op != batch_users.OpCreate && operation.Id == 0 {
+		return nil, ErrBatchMissingId
+	}
+
+	switch operation.Op {
+	case batch_users.OpCreate:
+		var dto create_user.UserCreate
+		if err := decodeBatchData(operation.Data, &dto); err != nil {
+			return nil, err
+		}
+		if err := user_attributes.Validate(schema, dto.Attributes, user_attributes.AccessAdmin, false); err != nil {
+			return nil, err
+		}
+		phone, err := normalizePhone(dto.Phone)
+		if err != nil {
+			return nil, err
+		}
+		dto.Phone = phone
+		dto.Email = email_address.Canonicalize(dto.Email)
+		passwordHash, err := users.HashUserPassword(dto.Password, log)
+		if err != nil {
+			return nil, err
+		}
+		dto.Password = passwordHash
+		dto.Role = operation.Role
+		user, err := userRepository.CreateUser(ctx, &dto)
+		if err != nil {
+			return nil, err
+		}
+		resource := toUserResourceWithAttributes(user, schema, user_attributes.AccessAdmin)
+		return &resource, nil
+	case batch_users.OpPatch:
+		var dto update_user.PatchUserDto
+		if err := decodeBatchData(operation.Data, &dto); err != nil {
+			return nil, err
+		}
+		if dto.Email != nil {
+			return nil, ErrBatchEmailChange
+		}
+		user, err := PatchUser(log, userRepository, EmailChanger{}, schema, ctx, dto, operation.Id, operation.Version, user_attributes.AccessAdmin)
+		if err != nil {
+			return nil, err
+		}
+		return &user.User, nil
+	case batch_users.OpSetRole:
+		if operation.Role == "" {
+			return nil, ErrBatchMissingRole
+		}
+		user, err := PatchUser(log, userRepository, EmailChanger{}, schema, ctx, update_user.PatchUserDto{Role: &operation.Role}, operation.Id,
+			operation.Version, user_attributes.AccessAdmin)
+		if err != nil {
+			return nil, err
+		}
+		return &user.User, nil
+	default:
+		return nil, DeleteUser(log, userRepository, ctx, operation.Id, operation.Version)
+	}
+}
+
+// decodeBatchData декодирует и валидирует data операции. Неизвестные поля считаются ошибкой
+func decodeBatchData(data json.RawMessage, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return ErrBatchInvalidData
+	}
+	return validators.GetValidator().Struct(v)
+}
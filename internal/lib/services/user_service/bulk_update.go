@@ -0,0 +1,158 @@
+package user_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/confirmation"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/bulk_update"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// bulkUpdateBatchSize сколько пользователей обновляется одной пачкой
+const bulkUpdateBatchSize = 500
+
+var ErrBulkPatchEmpty = errors.New("patch must contain role or status")
+var ErrConfirmationRequired = errors.New("confirmation_token is required, run the request with dry_run=true first")
+
+// ErrBulkPreviewOutdated с момента предпросмотра изменилось количество пользователей, которых затронет обновление
+var ErrBulkPreviewOutdated = errors.New("users matching the filter changed since the preview, run the preview again")
+
+// BulkUpdater Массовое обновление пользователей по фильтрам списка.
+// Сначала клиент получает предпросмотр с количеством пользователей и токеном подтверждения,
+// затем выполняет обновление с этим токеном
+type BulkUpdater struct {
+	UserRepository  users_db.UserRepository
+	Confirmations   *confirmation.Codec
+	AttributeSchema AttributeSchema // Определения атрибутов для фильтров по attributes.имя
+}
+
+// BulkUpdatePlan Подтверждённое массовое обновление, готовое к выполнению
+type BulkUpdatePlan struct {
+	Matched int64
+	params  users_db.UserListParams
+	update  users_db.BulkUpdate
+}
+
+// Preview возвращает количество пользователей, которых изменит patch от имени администратора adminId,
+// и токен подтверждения для тех же filterQuery и patch.
+// filterQuery — параметры запроса, из которых разобраны queryParams и filter
+func (b BulkUpdater) Preview(log *slog.Logger, ctx context.Context, filterQuery url.Values, queryParams query_params.ListQueryParams,
+	filter users_db.UserListFilter, patch bulk_update.BulkPatch, adminId int64) (bulk_update.BulkUpdatePreview, error) {
+	const op = "internal/lib/services/user_service/bulk_update.go/Preview"
+	log = log.With(slog.String("op", op), slog.Int64("admin_id", adminId))
+
+	params, update, err := bulkUpdateParams(queryParams, filter, patch, adminId)
+	if err != nil {
+		return bulk_update.BulkUpdatePreview{}, err
+	}
+	matched, err := b.UserRepository.CountBulkUpdate(ctx, params, update)
+	if err != nil {
+		log.Error("Failed to count users for bulk update", "err", err)
+		return bulk_update.BulkUpdatePreview{}, err
+	}
+	subject, err := bulkUpdateSubject(filterQuery, patch, adminId)
+	if err != nil {
+		return bulk_update.BulkUpdatePreview{}, err
+	}
+	token, expiresAt, err := b.Confirmations.Issue(subject, matched)
+	if err != nil {
+		return bulk_update.BulkUpdatePreview{}, err
+	}
+	log.Info("Bulk update previewed", "matched", matched)
+	return bulk_update.BulkUpdatePreview{Matched: matched, ConfirmationToken: token, ExpiresAt: expiresAt}, nil
+}
+
+// Confirm проверяет токен подтверждения и то, что обновление по-прежнему затронет столько же пользователей, сколько в предпросмотре
+func (b BulkUpdater) Confirm(log *slog.Logger, ctx context.Context, filterQuery url.Values, queryParams query_params.ListQueryParams,
+	filter users_db.UserListFilter, patch bulk_update.BulkPatch, adminId int64, token string) (BulkUpdatePlan, error) {
+	const op = "internal/lib/services/user_service/bulk_update.go/Confirm"
+	log = log.With(slog.String("op", op), slog.Int64("admin_id", adminId))
+
+	if token == "" {
+		return BulkUpdatePlan{}, ErrConfirmationRequired
+	}
+	params, update, err := bulkUpdateParams(queryParams, filter, patch, adminId)
+	if err != nil {
+		return BulkUpdatePlan{}, err
+	}
+	subject, err := bulkUpdateSubject(filterQuery, patch, adminId)
+	if err != nil {
+		return BulkUpdatePlan{}, err
+	}
+	previewed, err := b.Confirmations.Verify(token, subject)
+	if err != nil {
+		log.Debug("Confirmation token rejected", "err", err)
+		return BulkUpdatePlan{}, err
+	}
+	matched, err := b.UserRepository.CountBulkUpdate(ctx, params, update)
+	if err != nil {
+		log.Error("Failed to count users for bulk update", "err", err)
+		return BulkUpdatePlan{}, err
+	}
+	if matched != previewed {
+		log.Debug("Bulk update preview is outdated", "previewed", previewed, "matched", matched)
+		return BulkUpdatePlan{}, ErrBulkPreviewOutdated
+	}
+	return BulkUpdatePlan{Matched: matched, params: params, update: update}, nil
+}
+
+// Run выполняет подтверждённое обновление пачками и после каждой пачки передаёт в progress количество обновлённых пользователей.
+// Уже обновлённые пачки при ошибке не откатываются
+func (b BulkUpdater) Run(log *slog.Logger, ctx context.Context, plan BulkUpdatePlan, progress func(updated int64) error) (int64, error) {
+	const op = "internal/lib/services/user_service/bulk_update.go/Run"
+	log = log.With(slog.String("op", op), slog.Int64("admin_id", plan.update.AdminId))
+
+	updated, err := b.UserRepository.BulkUpdateUsers(ctx, plan.params, plan.update, bulkUpdateBatchSize, progress)
+	if err != nil {
+		log.Error("Bulk update failed", "err", err, "matched", plan.Matched, "updated", updated)
+		return updated, err
+	}
+	log.Info("Bulk update finished", "matched", plan.Matched, "updated", updated)
+	return updated, nil
+}
+
+// bulkUpdateParams проверяет patch по тем же правилам, что и ChangeStatus, и строит параметры выборки и обновления.
+// Удалённые пользователи массовым обновлением не меняются
+func bulkUpdateParams(queryParams query_params.ListQueryParams, filter users_db.UserListFilter, patch bulk_update.BulkPatch,
+	adminId int64) (users_db.UserListParams, users_db.BulkUpdate, error) {
+	if patch.Role == nil && patch.Status == nil {
+		return users_db.UserListParams{}, users_db.BulkUpdate{}, ErrBulkPatchEmpty
+	}
+	update := users_db.BulkUpdate{Role: patch.Role, AdminId: adminId}
+	if patch.Status != nil {
+		status := *patch.Status
+		reason := strings.TrimSpace(patch.StatusChange.Reason)
+		if status == user_status.Suspended && reason == "" {
+			return users_db.UserListParams{}, users_db.BulkUpdate{}, ErrStatusReasonRequired
+		}
+		if until := patch.StatusChange.Until; until != nil {
+			if !user_status.Expires(status) {
+				return users_db.UserListParams{}, users_db.BulkUpdate{}, ErrStatusUntilNotAllowed
+			}
+			if !until.After(time.Now()) {
+				return users_db.UserListParams{}, users_db.BulkUpdate{}, ErrStatusUntilInPast
+			}
+		}
+		update.Status = &users_db.StatusChange{Status: status, Reason: reason, Until: patch.StatusChange.Until, ChangedBy: adminId}
+	}
+	filter.IncludeDeleted = false
+	return users_db.UserListParams{Search: queryParams.Search, UserListFilter: filter}, update, nil
+}
+
+// bulkUpdateSubject возвращает то, что подтверждает токен: администратора, фильтры и изменения обновления.
+// Срок действия подписывается в токене вместе с subject (см. confirmation.Codec), поэтому токен,
+// выданный одному администратору, другой администратор использовать не может
+func bulkUpdateSubject(filterQuery url.Values, patch bulk_update.BulkPatch, adminId int64) ([]byte, error) {
+	return json.Marshal(struct {
+		AdminId int64                 `json:"admin_id"`
+		Query   string                `json:"query"`
+		Patch   bulk_update.BulkPatch `json:"patch"`
+	}{AdminId: adminId, Query: filterQuery.Encode(), Patch: patch})
+}
@@ -0,0 +1,131 @@
+package user_service
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/email_changes_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log/slog"
+	"time"
+)
+
+// EmailChanger Зависимости и настройки смены email с подтверждением нового адреса
+type EmailChanger struct {
+	Repository  email_changes_db.EmailChangeRepository
+	DomainRules *email_domains.Rules
+	Notifier    notifications.Notifier
+	TTL         time.Duration // Сколько действует ссылка подтверждения
+}
+
+// CheckNewEmail проверяет, что на новый email можно переключиться: домен разрешён и адрес не занят другим пользователем
+func (ec EmailChanger) CheckNewEmail(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, user users_db.UserInfo, newEmail string) error {
+	if err := ec.DomainRules.Check(newEmail); err != nil {
+		log.Debug("Email change rejected by email domain rules", "err", err)
+		return err
+	}
+	owner, err := userRepository.GetUserByEmail(ctx, newEmail)
+	if err == nil && owner.ID != user.ID {
+		return users_db.ErrEmailAlreadyExists
+	}
+	if err != nil && !errors.Is(err, users_db.ErrUserNotFound) {
+		log.Error("Failed to check new email", "err", err)
+		return err
+	}
+	return nil
+}
+
+// EmailChangeRequest Созданный запрос на смену email, о котором ещё не сообщено пользователю
+type EmailChangeRequest struct {
+	UserID    int64
+	OldEmail  string
+	NewEmail  string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// RequestEmailChange создаёт запрос на смену email пользователя. Уведомления не отправляются:
+// их отправляет SendEmailChangeRequest, когда остальные изменения пользователя сохранены.
+// Пока токен не отправлен, запрос никто не может подтвердить
+func (ec EmailChanger) RequestEmailChange(log *slog.Logger, ctx context.Context, user users_db.UserInfo, newEmail string) (EmailChangeRequest, error) {
+	const op = "internal/lib/services/user_service/email_change.go/RequestEmailChange"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", user.ID))
+
+	token, tokenHash, err := secure_tokens.Generate()
+	if err != nil {
+		log.Error("Failed to generate email change token", "err", err)
+		return EmailChangeRequest{}, err
+	}
+	request := EmailChangeRequest{
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		Token:     token,
+		ExpiresAt: time.Now().Add(ec.TTL).UTC(),
+	}
+	_, err = ec.Repository.CreateEmailChange(ctx, &email_changes_db.EmailChange{
+		UserID:    request.UserID,
+		OldEmail:  request.OldEmail,
+		NewEmail:  request.NewEmail,
+		TokenHash: tokenHash,
+		ExpiresAt: request.ExpiresAt,
+	})
+	if err != nil {
+		log.Error("Failed to create email change request", "err", err)
+		return EmailChangeRequest{}, err
+	}
+	log.Info("Email change requested")
+	return request, nil
+}
+
+// SendEmailChangeRequest отправляет на новый адрес токен подтверждения, на старый — уведомление о запрошенной смене
+func (ec EmailChanger) SendEmailChangeRequest(log *slog.Logger, ctx context.Context, request EmailChangeRequest) {
+	ec.notify(log, ctx, notifications.Notification{
+		Type:      notifications.TypeEmailChangeConfirmation,
+		UserID:    request.UserID,
+		Recipient: request.NewEmail,
+		Data: map[string]string{
+			"token":      request.Token,
+			"expires_at": request.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+	ec.notify(log, ctx, notifications.Notification{
+		Type:      notifications.TypeEmailChangeRequested,
+		UserID:    request.UserID,
+		Recipient: request.OldEmail,
+		Data: map[string]string{
+			"new_email": request.NewEmail,
+		},
+	})
+}
+
+// ConfirmEmailChange подтверждает смену email по токену из письма и сообщает об этом на старый адрес.
+// Уникальность email проверяется повторно: адрес мог быть занят, пока запрос ждал подтверждения
+func (ec EmailChanger) ConfirmEmailChange(log *slog.Logger, ctx context.Context, token string) (email_changes_db.EmailChange, error) {
+	const op = "internal/lib/services/user_service/email_change.go/ConfirmEmailChange"
+	log = log.With(slog.String("op", op))
+
+	change, err := ec.Repository.ConfirmEmailChange(ctx, secure_tokens.Hash(token))
+	if err != nil {
+		log.Debug("Failed to confirm email change", "err", err)
+		return email_changes_db.EmailChange{}, err
+	}
+	ec.notify(log, ctx, notifications.Notification{
+		Type:      notifications.TypeEmailChanged,
+		UserID:    change.UserID,
+		Recipient: change.OldEmail,
+		Data: map[string]string{
+			"new_email": change.NewEmail,
+		},
+	})
+	log.Info("Email changed", "user_id", change.UserID)
+	return change, nil
+}
+
+func (ec EmailChanger) notify(log *slog.Logger, ctx context.Context, notification notifications.Notification) {
+	if err := ec.Notifier.Notify(ctx, notification); err != nil {
+		log.Error("Failed to send notification", "err", err, "type", notification.Type)
+	}
+}
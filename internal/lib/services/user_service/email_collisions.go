@@ -0,0 +1,120 @@
+package user_service
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// EmailOwner Действующий пользователь и его email
+type EmailOwner struct {
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	Canonical string    `json:"canonical"` // Email в каноническом виде (см. email_address.Canonicalize)
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EmailCollision Пользователи, у которых email относятся к одному ящику (совпадает email_address.Key)
+type EmailCollision struct {
+	Key   string       `json:"key"`
+	Users []EmailOwner `json:"users"` // В порядке регистрации
+}
+
+// EmailCollisionReport Результат проверки email действующих пользователей
+type EmailCollisionReport struct {
+	Checked    int64            `json:"checked"`
+	Collisions []EmailCollision `json:"collisions"`
+	// NotCanonical пользователи, email которых сохранён не в каноническом виде.
+	// Такие адреса находятся при входе без учёта регистра, но не с правилами почтовых сервисов
+	NotCanonical []EmailOwner `json:"not_canonical"`
+}
+
+// FindEmailCollisions проверяет email всех действующих пользователей: находит адреса одного ящика
+// у разных пользователей, из-за которых не получится создать уникальный индекс email без учёта регистра,
+// и адреса, сохранённые не в каноническом виде. Пользователи читаются из БД потоком
+func FindEmailCollisions(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context) (EmailCollisionReport, error) {
+	const op = "internal/lib/services/user_service/email_collisions.go/FindEmailCollisions"
+	log = log.With(slog.String("op", op))
+
+	report := EmailCollisionReport{Collisions: []EmailCollision{}, NotCanonical: []EmailOwner{}}
+	owners := make(map[string][]EmailOwner)
+	var keys []string
+	err := userRepository.ExportUsers(ctx, users_db.UserListParams{
+		Fields: []string{"id", "email", "created_at"},
+	}, func(user users_db.UserInfo) error {
+		report.Checked++
+		owner := EmailOwner{
+			UserID:    user.ID,
+			Email:     user.Email,
+			Canonical: email_address.Canonicalize(user.Email),
+			CreatedAt: user.CreatedAt,
+		}
+		if owner.Canonical != owner.Email {
+			report.NotCanonical = append(report.NotCanonical, owner)
+		}
+		key := email_address.Key(user.Email)
+		if _, ok := owners[key]; !ok {
+			keys = append(keys, key)
+		}
+		owners[key] = append(owners[key], owner)
+		return nil
+	})
+	if err != nil {
+		log.Error("Failed to read user emails", "err", err)
+		return EmailCollisionReport{}, err
+	}
+
+	slices.Sort(keys)
+	for _, key := range keys {
+		if len(owners[key]) < 2 {
+			continue
+		}
+		users := owners[key]
+		slices.SortFunc(users, func(a, b EmailOwner) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+		report.Collisions = append(report.Collisions, EmailCollision{Key: key, Users: users})
+	}
+	log.Info("Email collisions checked", "checked", report.Checked, "collisions", len(report.Collisions),
+		"not_canonical", len(report.NotCanonical))
+	return report, nil
+}
+
+// ErrEmailCollisionsFound адреса нельзя привести к каноническому виду, пока у разных пользователей есть адреса одного ящика
+var ErrEmailCollisionsFound = errors.New("email collisions found, resolve them before canonicalizing emails")
+
+// EmailRewriter Хранилище, в котором можно переписать email пользователей
+type EmailRewriter interface {
+	RewriteEmails(ctx context.Context, emails map[int64]string) error
+}
+
+// CanonicalizeStoredEmails переписывает в канонический вид email из report.NotCanonical.
+// Нужен после включения правил почтовых сервисов: вход и проверка уникальности сравнивают адреса
+// в каноническом виде, поэтому адреса, сохранённые до этого, должны быть в нём же.
+// Если в отчёте есть дубликаты, ничего не меняется и возвращается ErrEmailCollisionsFound.
+// Возвращает число изменённых адресов
+func CanonicalizeStoredEmails(log *slog.Logger, rewriter EmailRewriter, ctx context.Context, report EmailCollisionReport) (int, error) {
+	const op = "internal/lib/services/user_service/email_collisions.go/CanonicalizeStoredEmails"
+	log = log.With(slog.String("op", op))
+
+	if len(report.Collisions) > 0 {
+		return 0, ErrEmailCollisionsFound
+	}
+	if len(report.NotCanonical) == 0 {
+		return 0, nil
+	}
+	emails := make(map[int64]string, len(report.NotCanonical))
+	for _, owner := range report.NotCanonical {
+		emails[owner.UserID] = owner.Canonical
+	}
+	if err := rewriter.RewriteEmails(ctx, emails); err != nil {
+		log.Error("Failed to canonicalize stored emails", "err", err)
+		return 0, err
+	}
+	log.Info("Stored emails canonicalized", "count", len(emails))
+	return len(emails), nil
+}
@@ -0,0 +1,58 @@
+package user_service
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/fieldset"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/export_writer"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log/slog"
+)
+
+// exportFlushRows через сколько строк выгрузка отправляется клиенту
+const exportFlushRows = 500
+
+// ExportColumns колонки выгрузки пользователей: UserFields и время удаления
+var ExportColumns = append(append([]string{}, UserFields...), "deleted_at")
+
+// ExportUsers выгружает в writer всех пользователей, подходящих под поиск, фильтры и сортировку списка.
+// columns — колонки из ExportColumns. Строки пишутся по мере чтения из БД и периодически отправляются клиенту.
+// Возвращает число выгруженных строк, в том числе если выгрузка прервалась с ошибкой
+func ExportUsers(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context,
+	queryParams query_params.ListQueryParams, filter users_db.UserListFilter, columns []string, writer export_writer.Writer) (int64, error) {
+	const op = "internal/lib/services/user_service/export.go/ExportUsers"
+	log = log.With(slog.String("op", op))
+
+	var exported int64
+	err := userRepository.ExportUsers(ctx, users_db.UserListParams{
+		Search:         queryParams.Search,
+		SortParams:     queryParams.SortParams,
+		Fields:         columns,
+		UserListFilter: filter,
+	}, func(user users_db.UserInfo) error {
+		resource := toUserResource(user)
+		resource.DeletedAt = user.DeletedAt
+		record, err := fieldset.Select(resource, columns)
+		if err != nil {
+			return err
+		}
+		if err = writer.Write(record); err != nil {
+			return err
+		}
+		exported++
+		if exported%exportFlushRows == 0 {
+			return writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("Users export interrupted", "err", err, "exported", exported)
+		return exported, err
+	}
+	if err = writer.Flush(); err != nil {
+		log.Error("Failed to flush users export", "err", err)
+		return exported, err
+	}
+	log.Info("Users exported", "exported", exported)
+	return exported, nil
+}
@@ -0,0 +1,172 @@
+package user_service
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/import_reader"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/ShlykovPavel/users-microservice/models/users/import_users"
+	"github.com/go-playground/validator"
+	"log/slog"
+)
+
+// dryRunPasswordHash подставляется вместо хеша пароля при проверке импорта:
+// транзакция всё равно откатывается, а bcrypt для сотен строк занимает слишком много времени
+const dryRunPasswordHash = "dry-run"
+
+var errInvalidPasswordHash = errors.New("field password_hash is not a bcrypt hash")
+var errPasswordAndHash = errors.New("only one of password and password_hash can be set")
+var errDuplicateEmail = errors.New("email is duplicated in import file")
+
+// ImportUsers проверяет строки импорта, хеширует пароли и записывает пользователей одной транзакцией.
+// Дополнительные атрибуты проверяются по определениям из attributeSchema с правами администратора,
+// как при создании пользователя: обязательные атрибуты должны быть в каждой строке.
+// Строки с ошибкой разбора или валидации, повторы email внутри файла, email, подтверждённые у другого пользователя
+// как дополнительный адрес, и занятые телефоны (при включённой уникальности) отмечаются import_users.RowFailed и не записываются.
+// Если при on_conflict=fail email уже занят, возвращается отчёт вместе с users_db.ErrImportConflict:
+// строки с занятым email отмечены import_users.RowFailed, остальные — import_users.RowRolledBack
+func ImportUsers(log *slog.Logger, userRepository users_db.UserRepository, attributeSchema AttributeSchema, ctx context.Context,
+	rows []import_reader.Row, onConflict string, dryRun bool) (import_users.ImportUsersResponse, error) {
+	const op = "internal/lib/services/user_service/import.go/ImportUsers"
+	log = log.With(slog.String("op", op))
+
+	report := import_users.ImportUsersResponse{
+		DryRun:     dryRun,
+		OnConflict: onConflict,
+		Total:      len(rows),
+		Rows:       make([]import_users.ImportRowResult, len(rows)),
+	}
+	definitions, err := AttributeDefinitions(ctx, attributeSchema)
+	if err != nil {
+		log.Error("Failed to get attribute definitions", "err", err)
+		return import_users.ImportUsersResponse{}, err
+	}
+
+	// toWrite индексы строк отчёта, которые отправляются в БД, в порядке toImport
+	toWrite := make([]int, 0, len(rows))
+	toImport := make([]import_users.ImportUser, 0, len(rows))
+	emails := make(map[string]struct{}, len(rows))
+	for i, row := range rows {
+		report.Rows[i] = import_users.ImportRowResult{Row: row.Line, Email: row.User.Email}
+		user, err := prepareImportUser(log, row, definitions, emails, dryRun)
+		if err != nil {
+			report.Rows[i].Status = import_users.RowFailed
+			report.Rows[i].Error = err.Error()
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return import_users.ImportUsersResponse{}, err
+		}
+		toWrite = append(toWrite, i)
+		toImport = append(toImport, user)
+	}
+
+	var results []users_db.ImportResult
+	var importErr error
+	if len(toImport) > 0 {
+		results, importErr = userRepository.ImportUsers(ctx, toImport, onConflict, dryRun)
+		if importErr != nil && !errors.Is(importErr, users_db.ErrImportConflict) {
+			return import_users.ImportUsersResponse{}, importErr
+		}
+	}
+	for j, result := range results {
+		row := &report.Rows[toWrite[j]]
+		row.Status = result.Status
+		if result.Err != nil {
+			row.Error = result.Err.Error()
+		}
+		if !dryRun && importErr == nil {
+			row.UserId = result.ID
+		}
+		if importErr == nil {
+			continue
+		}
+		if result.Status == import_users.RowSkipped {
+			row.Status = import_users.RowFailed
+			row.Error = users_db.ErrEmailAlreadyExists.Error()
+		} else if result.Status != import_users.RowFailed {
+			row.Status = import_users.RowRolledBack
+		}
+	}
+
+	for _, row := range report.Rows {
+		switch row.Status {
+		case import_users.RowCreated:
+			report.Created++
+		case import_users.RowUpdated:
+			report.Updated++
+		case import_users.RowSkipped:
+			report.Skipped++
+		case import_users.RowFailed:
+			report.Failed++
+		}
+	}
+	log.Info("Users import finished", "dry_run", dryRun, "on_conflict", onConflict, "total", report.Total,
+		"created", report.Created, "updated", report.Updated, "skipped", report.Skipped, "failed", report.Failed)
+	return report, importErr
+}
+
+// prepareImportUser валидирует строку импорта и возвращает пользователя, у которого Password содержит хеш пароля
+func prepareImportUser(log *slog.Logger, row import_reader.Row, definitions []attribute_definition.Definition,
+	emails map[string]struct{}, dryRun bool) (import_users.ImportUser, error) {
+	if row.Err != nil {
+		return import_users.ImportUser{}, row.Err
+	}
+	user := row.User
+	if err := validators.GetValidator().Struct(&user); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			return import_users.ImportUser{}, errors.New(response.ValidationError(validationErrors).Error)
+		}
+		return import_users.ImportUser{}, err
+	}
+	if user.Password != "" && user.PasswordHash != "" {
+		return import_users.ImportUser{}, errPasswordAndHash
+	}
+	if row.TextAttributes {
+		attributes, err := user_attributes.FromText(definitions, user.Attributes)
+		if err != nil {
+			return import_users.ImportUser{}, err
+		}
+		user.Attributes = attributes
+	}
+	if err := user_attributes.Validate(definitions, user.Attributes, user_attributes.AccessAdmin, false); err != nil {
+		return import_users.ImportUser{}, err
+	}
+	user.Email = email_address.Canonicalize(user.Email)
+	// Адреса одного ящика, записанные по-разному, тоже считаются повтором
+	emailKey := email_address.Key(user.Email)
+	if _, ok := emails[emailKey]; ok {
+		return import_users.ImportUser{}, errDuplicateEmail
+	}
+	emails[emailKey] = struct{}{}
+	phone, err := normalizePhone(user.Phone)
+	if err != nil {
+		return import_users.ImportUser{}, err
+	}
+	user.Phone = phone
+
+	switch {
+	case user.PasswordHash != "":
+		if !users.IsPasswordHash(user.PasswordHash) {
+			return import_users.ImportUser{}, errInvalidPasswordHash
+		}
+		user.Password = user.PasswordHash
+	case dryRun:
+		user.Password = dryRunPasswordHash
+	default:
+		passwordHash, err := users.HashUserPassword(user.Password, log)
+		if err != nil {
+			return import_users.ImportUser{}, err
+		}
+		user.Password = passwordHash
+	}
+	user.PasswordHash = ""
+	return user, nil
+}
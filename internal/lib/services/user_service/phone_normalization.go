@@ -0,0 +1,113 @@
+package user_service
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// PhoneOwner Действующий пользователь и его телефон
+type PhoneOwner struct {
+	UserID     int64     `json:"user_id"`
+	Phone      string    `json:"phone"`
+	Normalized string    `json:"normalized,omitempty"` // Телефон в формате E.164, пусто — телефон не разбирается
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PhoneDuplicate Пользователи, у которых телефон в формате E.164 совпадает
+type PhoneDuplicate struct {
+	Phone string       `json:"phone"`
+	Users []PhoneOwner `json:"users"` // В порядке регистрации
+}
+
+// PhoneNormalizationReport Результат проверки телефонов действующих пользователей
+type PhoneNormalizationReport struct {
+	Checked int64 `json:"checked"`
+	// NotNormalized пользователи, телефон которых сохранён не в формате E.164 (до миграции 000013)
+	NotNormalized []PhoneOwner `json:"not_normalized"`
+	// Invalid пользователи, телефон которых нельзя привести к E.164. Его нужно исправить вручную
+	Invalid []PhoneOwner `json:"invalid"`
+	// Duplicates телефоны, которые после нормализации есть у нескольких пользователей.
+	// Пока они есть, уникальность телефонов (PHONE_UNIQUE) не включится
+	Duplicates []PhoneDuplicate `json:"duplicates"`
+}
+
+// FindPhonesToNormalize проверяет телефоны всех действующих пользователей: находит телефоны не в формате E.164,
+// телефоны, которые нельзя разобрать, и одинаковые после нормализации телефоны. Пользователи читаются из БД потоком
+func FindPhonesToNormalize(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context) (PhoneNormalizationReport, error) {
+	const op = "internal/lib/services/user_service/phone_normalization.go/FindPhonesToNormalize"
+	log = log.With(slog.String("op", op))
+
+	report := PhoneNormalizationReport{NotNormalized: []PhoneOwner{}, Invalid: []PhoneOwner{}, Duplicates: []PhoneDuplicate{}}
+	owners := make(map[string][]PhoneOwner)
+	var phones []string
+	err := userRepository.ExportUsers(ctx, users_db.UserListParams{
+		Fields: []string{"id", "phone", "created_at"},
+	}, func(user users_db.UserInfo) error {
+		report.Checked++
+		owner := PhoneOwner{UserID: user.ID, Phone: user.Phone, CreatedAt: user.CreatedAt}
+		normalized, err := normalizePhone(user.Phone)
+		if err != nil {
+			report.Invalid = append(report.Invalid, owner)
+			return nil
+		}
+		owner.Normalized = normalized
+		if normalized != user.Phone {
+			report.NotNormalized = append(report.NotNormalized, owner)
+		}
+		if _, ok := owners[normalized]; !ok {
+			phones = append(phones, normalized)
+		}
+		owners[normalized] = append(owners[normalized], owner)
+		return nil
+	})
+	if err != nil {
+		log.Error("Failed to read user phones", "err", err)
+		return PhoneNormalizationReport{}, err
+	}
+
+	slices.Sort(phones)
+	for _, phone := range phones {
+		if len(owners[phone]) < 2 {
+			continue
+		}
+		users := owners[phone]
+		slices.SortFunc(users, func(a, b PhoneOwner) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+		report.Duplicates = append(report.Duplicates, PhoneDuplicate{Phone: phone, Users: users})
+	}
+	log.Info("Phones checked", "checked", report.Checked, "not_normalized", len(report.NotNormalized),
+		"invalid", len(report.Invalid), "duplicates", len(report.Duplicates))
+	return report, nil
+}
+
+// PhoneRewriter Хранилище, в котором можно переписать телефоны пользователей
+type PhoneRewriter interface {
+	RewritePhones(ctx context.Context, phones map[int64]string) error
+}
+
+// NormalizeStoredPhones переписывает в формат E.164 телефоны из report.NotNormalized.
+// Телефоны, сохранённые до миграции 000013, не находятся по номеру в E.164 и не сравниваются
+// при проверке уникальности. Если уникальность телефонов включена и нормализованный телефон занят,
+// ничего не меняется и возвращается users_db.ErrPhoneAlreadyExists. Возвращает число изменённых телефонов
+func NormalizeStoredPhones(log *slog.Logger, rewriter PhoneRewriter, ctx context.Context, report PhoneNormalizationReport) (int, error) {
+	const op = "internal/lib/services/user_service/phone_normalization.go/NormalizeStoredPhones"
+	log = log.With(slog.String("op", op))
+
+	if len(report.NotNormalized) == 0 {
+		return 0, nil
+	}
+	phones := make(map[int64]string, len(report.NotNormalized))
+	for _, owner := range report.NotNormalized {
+		phones[owner.UserID] = owner.Normalized
+	}
+	if err := rewriter.RewritePhones(ctx, phones); err != nil {
+		log.Error("Failed to normalize stored phones", "err", err)
+		return 0, err
+	}
+	log.Info("Stored phones normalized", "count", len(phones))
+	return len(phones), nil
+}
@@ -0,0 +1,131 @@
+package user_service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/i18n"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/sms"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/phone_verifications_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log/slog"
+	"math/big"
+	"time"
+)
+
+// ErrPhoneAlreadyVerified текущий телефон пользователя уже подтверждён
+var ErrPhoneAlreadyVerified = errors.New("phone is already verified")
+
+// ErrVerificationTooSoon новый код запрошен раньше, чем истёк интервал повторной отправки
+var ErrVerificationTooSoon = errors.New("verification code was sent recently, try again later")
+
+// phoneVerificationCodeDigits число цифр в коде подтверждения телефона
+const phoneVerificationCodeDigits = 6
+
+// PhoneVerifier Зависимости и настройки подтверждения телефона кодом из SMS
+type PhoneVerifier struct {
+	Repository     phone_verifications_db.PhoneVerificationRepository
+	Sender         sms.Sender
+	TTL            time.Duration // Сколько действует код
+	ResendInterval time.Duration // Через сколько после отправки можно запросить новый код
+	MaxAttempts    int           // Сколько раз можно ввести код неверно
+}
+
+// SendCode отправляет на текущий телефон пользователя новый код подтверждения.
+// Телефон должен быть в формате E.164, иначе возвращается phone_numbers.ErrInvalidPhone.
+// Текст SMS на языке пользователя. Возвращает отправленный код подтверждения (без самого кода).
+// Если предыдущий код отправлен меньше ResendInterval назад, возвращается ErrVerificationTooSoon
+// вместе с предыдущим кодом
+func (pv PhoneVerifier) SendCode(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, userId int64) (phone_verifications_db.Verification, error) {
+	const op = "internal/lib/services/user_service/phone_verification.go/SendCode"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId))
+
+	user, err := userRepository.GetUser(ctx, userId)
+	if err != nil {
+		log.Debug("Failed to get user", "err", err)
+		return phone_verifications_db.Verification{}, err
+	}
+	if user.PhoneVerified {
+		return phone_verifications_db.Verification{}, ErrPhoneAlreadyVerified
+	}
+	// Телефоны, сохранённые до нормализации, нужно сначала обновить: код отправляется только на номер в E.164
+	if user.PhoneCountryCode == 0 {
+		return phone_verifications_db.Verification{}, phone_numbers.ErrInvalidPhone
+	}
+
+	pending, err := pv.Repository.GetPendingVerification(ctx, userId)
+	if err != nil && !errors.Is(err, phone_verifications_db.ErrVerificationNotFound) {
+		log.Error("Failed to get pending phone verification", "err", err)
+		return phone_verifications_db.Verification{}, err
+	}
+	if err == nil {
+		if time.Now().Before(pv.RetryAt(pending)) {
+			return pending, ErrVerificationTooSoon
+		}
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		log.Error("Failed to generate phone verification code", "err", err)
+		return phone_verifications_db.Verification{}, err
+	}
+	verification := phone_verifications_db.Verification{
+		UserID:    userId,
+		Phone:     user.Phone,
+		CodeHash:  secure_tokens.Hash(code),
+		ExpiresAt: time.Now().Add(pv.TTL).UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+	verification.ID, err = pv.Repository.CreateVerification(ctx, &verification)
+	if err != nil {
+		log.Error("Failed to create phone verification", "err", err)
+		return phone_verifications_db.Verification{}, err
+	}
+
+	locale := user.Locale
+	if !i18n.IsSupported(locale) {
+		locale = i18n.DefaultLocale
+	}
+	text, _ := i18n.Message(locale, "sms_phone_verification", code)
+	if err = pv.Sender.Send(ctx, sms.Message{UserID: userId, To: user.Phone, Text: text}); err != nil {
+		log.Error("Failed to send phone verification SMS", "err", err)
+		return phone_verifications_db.Verification{}, err
+	}
+	log.Info("Phone verification code sent")
+	return verification, nil
+}
+
+// RetryAt время, после которого можно запросить код взамен verification
+func (pv PhoneVerifier) RetryAt(verification phone_verifications_db.Verification) time.Time {
+	return verification.CreatedAt.Add(pv.ResendInterval)
+}
+
+// ConfirmCode подтверждает телефон пользователя кодом из SMS
+func (pv PhoneVerifier) ConfirmCode(log *slog.Logger, ctx context.Context, userId int64, code string) (phone_verifications_db.Verification, error) {
+	const op = "internal/lib/services/user_service/phone_verification.go/ConfirmCode"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId))
+
+	verification, err := pv.Repository.ConfirmVerification(ctx, userId, secure_tokens.Hash(code), pv.MaxAttempts)
+	if err != nil {
+		log.Debug("Failed to confirm phone verification", "err", err)
+		return phone_verifications_db.Verification{}, err
+	}
+	log.Info("Phone verified")
+	return verification, nil
+}
+
+// generateVerificationCode возвращает случайный код из phoneVerificationCodeDigits цифр
+func generateVerificationCode() (string, error) {
+	limit := big.NewInt(1)
+	for range phoneVerificationCodeDigits {
+		limit.Mul(limit, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", phoneVerificationCodeDigits, n), nil
+}
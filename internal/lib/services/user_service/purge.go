@@ -0,0 +1,40 @@
+package user_service
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log/slog"
+	"time"
+)
+
+// RunPurge раз в interval окончательно удаляет пользователей, мягко удалённых больше retention назад.
+// Блокирует выполнение до отмены ctx, поэтому запускается в отдельной горутине
+func RunPurge(ctx context.Context, log *slog.Logger, userRepository users_db.UserRepository, retention, interval time.Duration) {
+	const op = "internal/lib/services/user_service/purge.go/RunPurge"
+	log = log.With(slog.String("op", op))
+	log.Info("Deleted users purge started", "retention", retention, "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		PurgeDeletedUsers(ctx, log, userRepository, retention)
+		select {
+		case <-ctx.Done():
+			log.Info("Deleted users purge stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDeletedUsers окончательно удаляет пользователей, мягко удалённых больше retention назад
+func PurgeDeletedUsers(ctx context.Context, log *slog.Logger, userRepository users_db.UserRepository, retention time.Duration) {
+	purged, err := userRepository.PurgeDeletedUsers(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Error("Failed to purge deleted users", "err", err)
+		return
+	}
+	if purged > 0 {
+		log.Info("Purged deleted users", "count", purged)
+	}
+}
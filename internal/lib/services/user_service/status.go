@@ -0,0 +1,72 @@
+package user_service
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/status_change"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrStatusReasonRequired = errors.New("reason is required to suspend a user")
+var ErrStatusUntilNotAllowed = errors.New("until can be set only for suspended or locked status")
+var ErrStatusUntilInPast = errors.New("until must be in the future")
+var ErrOwnStatusChange = errors.New("administrator cannot change own status")
+
+// ChangeStatus переводит пользователя id в статус status от имени администратора adminId.
+// Переход должен быть допустим (см. user_status), приостановка требует причину и может быть ограничена сроком
+func ChangeStatus(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, id, adminId int64,
+	status string, dto status_change.ChangeStatusRequest) (status_change.ChangeStatusResponse, error) {
+	const op = "internal/lib/services/user_service/status.go/ChangeStatus"
+	log = log.With(slog.String("op", op),
+		slog.String("UserId", strconv.FormatInt(id, 10)),
+		slog.Int64("admin_id", adminId))
+
+	if id == adminId {
+		return status_change.ChangeStatusResponse{}, ErrOwnStatusChange
+	}
+	reason := strings.TrimSpace(dto.Reason)
+	if status == user_status.Suspended && reason == "" {
+		return status_change.ChangeStatusResponse{}, ErrStatusReasonRequired
+	}
+	if dto.Until != nil {
+		if !user_status.Expires(status) {
+			return status_change.ChangeStatusResponse{}, ErrStatusUntilNotAllowed
+		}
+		if !dto.Until.After(time.Now()) {
+			return status_change.ChangeStatusResponse{}, ErrStatusUntilInPast
+		}
+	}
+
+	user, err := userRepository.GetUser(ctx, id)
+	if err != nil {
+		log.Error("Failed to get user", "err", err)
+		return status_change.ChangeStatusResponse{}, err
+	}
+	if err = user_status.CheckTransition(user.Status, status); err != nil {
+		log.Debug("Status transition rejected", "err", err)
+		return status_change.ChangeStatusResponse{}, err
+	}
+
+	version, err := userRepository.ChangeStatus(ctx, id, user.Version, users_db.StatusChange{
+		Status:    status,
+		Reason:    reason,
+		Until:     dto.Until,
+		ChangedBy: adminId,
+	})
+	if err != nil {
+		log.Error("Failed to change user status", "err", err)
+		return status_change.ChangeStatusResponse{}, err
+	}
+	log.Info("User status changed", "from", user.Status, "to", status, "reason", reason)
+	return status_change.ChangeStatusResponse{
+		UserID:      id,
+		Status:      status,
+		StatusUntil: dto.Until,
+		Version:     version,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package user_service
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/user_emails_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"log/slog"
+	"time"
+)
+
+// ErrTooManyEmails у пользователя уже максимальное число адресов
+var ErrTooManyEmails = errors.New("too many email addresses, remove one before adding another")
+
+// UserEmails Зависимости и настройки дополнительных адресов email пользователя
+type UserEmails struct {
+	Repository  user_emails_db.UserEmailRepository
+	DomainRules *email_domains.Rules
+	Notifier    notifications.Notifier
+	TTL         time.Duration // Сколько действует ссылка подтверждения адреса
+	MaxEmails   int           // Сколько адресов, включая основной, может быть у пользователя
+}
+
+// GetEmails возвращает адреса пользователя: сначала основной, остальные в порядке добавления
+func (ue UserEmails) GetEmails(log *slog.Logger, ctx context.Context, userId int64) ([]user_emails_db.UserEmail, error) {
+	emails, err := ue.Repository.GetEmails(ctx, userId)
+	if err != nil {
+		log.Error("Failed to get user emails", "err", err)
+		return nil, err
+	}
+	return emails, nil
+}
+
+// AddEmail добавляет пользователю адрес, который нужно подтвердить: на адрес отправляется токен подтверждения,
+// на основной адрес — уведомление о добавленном адресе. Повторное добавление неподтверждённого адреса
+// отправляет новый токен. Адрес, который использует другой пользователь, добавить нельзя
+func (ue UserEmails) AddEmail(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, userId int64, email string) (user_emails_db.UserEmail, error) {
+	const op = "internal/lib/services/user_service/user_emails.go/AddEmail"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId))
+
+	email = email_address.Canonicalize(email)
+	if err := ue.DomainRules.Check(email); err != nil {
+		log.Debug("Email rejected by email domain rules", "err", err)
+		return user_emails_db.UserEmail{}, err
+	}
+
+	emails, err := ue.Repository.GetEmails(ctx, userId)
+	if err != nil {
+		log.Error("Failed to get user emails", "err", err)
+		return user_emails_db.UserEmail{}, err
+	}
+	if len(emails) == 0 {
+		return user_emails_db.UserEmail{}, users_db.ErrUserNotFound
+	}
+	var primary string
+	added := false
+	for _, existing := range emails {
+		if existing.Primary {
+			primary = existing.Email
+		}
+		if email_address.Key(existing.Email) == email_address.Key(email) {
+			added = true
+		}
+	}
+	if !added && len(emails) >= ue.MaxEmails {
+		return user_emails_db.UserEmail{}, ErrTooManyEmails
+	}
+
+	owner, err := userRepository.GetUserByEmail(ctx, email)
+	if err == nil && owner.ID != userId {
+		return user_emails_db.UserEmail{}, users_db.ErrEmailAlreadyExists
+	}
+	if err != nil && !errors.Is(err, users_db.ErrUserNotFound) {
+		log.Error("Failed to check email owner", "err", err)
+		return user_emails_db.UserEmail{}, err
+	}
+
+	token, tokenHash, err := secure_tokens.Generate()
+	if err != nil {
+		log.Error("Failed to generate email verification token", "err", err)
+		return user_emails_db.UserEmail{}, err
+	}
+	userEmail := user_emails_db.UserEmail{
+		UserID:         userId,
+		Email:          email,
+		TokenHash:      tokenHash,
+		TokenExpiresAt: time.Now().Add(ue.TTL).UTC(),
+	}
+	userEmail.ID, err = ue.Repository.AddEmail(ctx, &userEmail)
+	if err != nil {
+		log.Debug("Failed to add user email", "err", err)
+		return user_emails_db.UserEmail{}, err
+	}
+
+	ue.notify(log, ctx, notifications.Notification{
+		Type:      notifications.TypeEmailVerification,
+		UserID:    userId,
+		Recipient: email,
+		Data: map[string]string{
+			"token":      token,
+			"expires_at": userEmail.TokenExpiresAt.Format(time.RFC3339),
+		},
+	})
+	ue.notify(log, ctx, notifications.Notification{
+		Type:      notifications.TypeEmailAdded,
+		UserID:    userId,
+		Recipient: primary,
+		Data: map[string]string{
+			"email": email,
+		},
+	})
+	log.Info("User email added", "email_id", userEmail.ID)
+	return userEmail, nil
+}
+
+// VerifyEmail подтверждает адрес по токену из письма. После подтверждения по адресу можно войти
+func (ue UserEmails) VerifyEmail(log *slog.Logger, ctx context.Context, token string) (user_emails_db.UserEmail, error) {
+	const op = "internal/lib/services/user_service/user_emails.go/VerifyEmail"
+	log = log.With(slog.String("op", op))
+
+	email, err := ue.Repository.VerifyEmail(ctx, secure_tokens.Hash(token))
+	if err != nil {
+		log.Debug("Failed to verify user email", "err", err)
+		return user_emails_db.UserEmail{}, err
+	}
+	log.Info("User email verified", "user_id", email.UserID, "email_id", email.ID)
+	return email, nil
+}
+
+// DeleteEmail удаляет адрес пользователя. Основной адрес удалить нельзя
+func (ue UserEmails) DeleteEmail(log *slog.Logger, ctx context.Context, userId, emailId int64) error {
+	const op = "internal/lib/services/user_service/user_emails.go/DeleteEmail"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId))
+
+	if err := ue.Repository.DeleteEmail(ctx, userId, emailId); err != nil {
+		log.Debug("Failed to delete user email", "err", err)
+		return err
+	}
+	log.Info("User email deleted", "email_id", emailId)
+	return nil
+}
+
+// SetPrimaryEmail делает подтверждённый адрес основным, он становится email пользователя.
+// На прежний основной адрес отправляется уведомление о смене email
+func (ue UserEmails) SetPrimaryEmail(log *slog.Logger, ctx context.Context, userId, emailId int64) (user_emails_db.UserEmail, error) {
+	const op = "internal/lib/services/user_service/user_emails.go/SetPrimaryEmail"
+	log = log.With(slog.String("op", op), slog.Int64("user_id", userId))
+
+	email, previous, err := ue.Repository.SetPrimaryEmail(ctx, userId, emailId)
+	if err != nil {
+		log.Debug("Failed to set primary email", "err", err)
+		return user_emails_db.UserEmail{}, err
+	}
+	if email_address.Key(previous) != email_address.Key(email.Email) {
+		ue.notify(log, ctx, notifications.Notification{
+			Type:      notifications.TypeEmailChanged,
+			UserID:    userId,
+			Recipient: previous,
+			Data: map[string]string{
+				"new_email": email.Email,
+			},
+		})
+		log.Info("Primary email changed", "email_id", emailId)
+	}
+	return email, nil
+}
+
+func (ue UserEmails) notify(log *slog.Logger, ctx context.Context, notification notifications.Notification) {
+	if err := ue.Notifier.Notify(ctx, notification); err != nil {
+		log.Error("Failed to send notification", "err", err, "type", notification.Type)
+	}
+}
@@ -3,67 +3,245 @@ package user_service
 import (
 	"context"
 	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/cursor"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_address"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/registration"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
-	"github.com/ShlykovPavel/users-microservice/models/users/get_user_by_id"
+	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
 	"github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
 	"github.com/ShlykovPavel/users-microservice/models/users/update_user"
+	"github.com/ShlykovPavel/users-microservice/models/users/user_resource"
 	"log/slog"
 	"strconv"
+	"strings"
 )
 
-func GetUser(log *slog.Logger, userRepository users_db.UserRepository, userId int64, ctx context.Context) (get_user_by_id.UserInfo, error) {
+// ErrRoleChangeForbidden роль пользователя может менять только администратор
+var ErrRoleChangeForbidden = errors.New("only administrator can change user role")
+
+// RegisterUser регистрирует пользователя с учётом режима регистрации.
+// Если передан токен приглашения, приглашение помечается использованным, а пользователь получает роль из приглашения.
+// Без приглашения при policy.RequireApproval пользователь создаётся в статусе pending и ждёт активации администратором.
+// Пароль в dto передаётся в открытом виде и хешируется перед сохранением, email сохраняется в каноническом виде.
+// Атрибуты проверяются по определениям из attributeSchema с правами самого пользователя.
+// Возвращает созданного пользователя
+func RegisterUser(log *slog.Logger, userRepository users_db.UserRepository, invitationRepository invitations_db.InvitationRepository,
+	attributeSchema AttributeSchema, policy registration.Policy, domainRules *email_domains.Rules, ctx context.Context,
+	dto *create_user.UserCreate) (user_resource.User, error) {
+	const op = "internal/lib/services/user_service/user_service.go/RegisterUser"
+	log = log.With(slog.String("op", op))
+
+	dto.Email = email_address.Canonicalize(dto.Email)
+	hasInvitation := dto.InvitationToken != ""
+	if err := policy.Check(dto.Email, hasInvitation); err != nil {
+		log.Debug("Registration rejected by policy", "mode", policy.Mode, "err", err)
+		return user_resource.User{}, err
+	}
+	if err := domainRules.Check(dto.Email); err != nil {
+		log.Debug("Registration rejected by email domain rules", "err", err)
+		return user_resource.User{}, err
+	}
+	definitions, err := AttributeDefinitions(ctx, attributeSchema)
+	if err != nil {
+		log.Error("Failed to get attribute definitions", "err", err)
+		return user_resource.User{}, err
+	}
+	if err = user_attributes.Validate(definitions, dto.Attributes, user_attributes.AccessSelf, false); err != nil {
+		log.Debug("Registration rejected by attribute rules", "err", err)
+		return user_resource.User{}, err
+	}
+
+	if dto.Phone, err = normalizePhone(dto.Phone); err != nil {
+		return user_resource.User{}, err
+	}
+
+	passwordHash, err := users.HashUserPassword(dto.Password, log)
+	if err != nil {
+		return user_resource.User{}, err
+	}
+	dto.Password = passwordHash
+	dto.Status = policy.InitialStatus(hasInvitation)
+
+	if !hasInvitation {
+		dto.Role = ""
+		user, err := userRepository.CreateUser(ctx, dto)
+		if err != nil {
+			return user_resource.User{}, err
+		}
+		if user.Status == user_status.Pending {
+			log.Info("User registered and awaits approval", "user_id", user.ID)
+		}
+		return toUserResourceWithAttributes(user, definitions, user_attributes.AccessSelf), nil
+	}
+
+	invitation, err := invitationRepository.ConsumeInvitation(ctx, secure_tokens.Hash(dto.InvitationToken), dto.Email)
+	if err != nil {
+		log.Debug("Failed to consume invitation", "err", err)
+		return user_resource.User{}, err
+	}
+	dto.Role = invitation.Role
+
+	user, err := userRepository.CreateUser(ctx, dto)
+	if err != nil {
+		// Пользователь не создан, поэтому приглашение должно остаться действующим
+		if releaseErr := invitationRepository.ReleaseInvitation(context.WithoutCancel(ctx), invitation.ID); releaseErr != nil {
+			log.Error("Failed to release invitation", "err", releaseErr, "invitation_id", invitation.ID)
+		}
+		return user_resource.User{}, err
+	}
+	if err = invitationRepository.SetConsumedBy(ctx, invitation.ID, user.ID); err != nil {
+		log.Error("Failed to link invitation with user", "err", err, "invitation_id", invitation.ID, "user_id", user.ID)
+	}
+	log.Info("User registered by invitation", "invitation_id", invitation.ID, "user_id", user.ID)
+	return toUserResourceWithAttributes(user, definitions, user_attributes.AccessSelf), nil
+}
+
+func GetUser(log *slog.Logger, userRepository users_db.UserRepository, loginEventsRepository login_events_db.LoginEventsRepository,
+	userId int64, ctx context.Context, view UserView) (user_resource.User, error) {
 	const op = "internal/lib/services/user_service/user_service.go/GetUser"
 	log = log.With(slog.String("op", op),
 		slog.String("UserId", strconv.FormatInt(userId, 10)))
 
-	userInfo, err := userRepository.GetUser(ctx, userId)
+	var userInfo users_db.UserInfo
+	var err error
+	if view.Fields == nil && len(view.Include) == 0 {
+		userInfo, err = userRepository.GetUser(ctx, userId)
+	} else {
+		userInfo, err = userRepository.GetUserFields(ctx, userId, view.repositoryFields())
+	}
 	if err != nil {
 		if errors.Is(err, users_db.ErrUserNotFound) {
 			log.Debug("Пользователь не найден", "err", err)
-			return get_user_by_id.UserInfo{}, err
+			return user_resource.User{}, err
 		}
 		log.Error("Ошибка поиска пользователя в БД", "err", err)
-		return get_user_by_id.UserInfo{}, err
+		return user_resource.User{}, err
 	}
-	return get_user_by_id.UserInfo{
-		Email:     userInfo.Email,
-		Phone:     userInfo.Phone,
-		LastName:  userInfo.LastName,
-		FirstName: userInfo.FirstName,
-	}, nil
+	user := toUserResource(userInfo)
+	user.Attributes = view.visibleAttributes(userInfo)
+	if view.includes(IncludeStatusDetails) {
+		user.StatusDetails = statusDetails(userInfo)
+	}
+	if view.includes(IncludeLastLogin) {
+		lastLogins, err := getLastLogins(ctx, loginEventsRepository, []int64{userId})
+		if err != nil {
+			log.Error("Ошибка получения последнего входа пользователя", "err", err)
+			return user_resource.User{}, err
+		}
+		user.LastLogin = lastLogins[userId]
+	}
+	return user, nil
 
 }
 
+// UserListPage Параметры keyset пагинации и подсчёта total для списка пользователей
+type UserListPage struct {
+	Cursor    string // Курсор из next_cursor или prev_cursor предыдущего ответа, пустой для первой страницы
+	CountMode string // users_db.CountExact, users_db.CountEstimated или users_db.CountNone
+}
+
 // GetUserList retrieves a list of users from the repository and converts them to DTOs.
 // It takes a logger, user repository, and context as input.
-// Returns a slice of UserInfoList DTOs or an error if the operation fails.
-func GetUserList(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, queryParams query_params.ListQueryParams) (get_users_list.UsersList, error) {
+// The filter narrows the list down (deleted users, statuses).
+// The view limits selected fields and adds related data (last login, status details).
+// When page.Cursor is set, the list continues from the cursor position and its sorting overrides queryParams.SortParams.
+// Returns a page of user resources or an error if the operation fails.
+func GetUserList(log *slog.Logger, userRepository users_db.UserRepository, loginEventsRepository login_events_db.LoginEventsRepository,
+	cursorCodec *cursor.Codec, ctx context.Context, queryParams query_params.ListQueryParams, filter users_db.UserListFilter,
+	page UserListPage, view UserView) (get_users_list.UsersList, error) {
 	const op = "internal/lib/services/user_service/user_service.go/GetUserList"
 	log = log.With(slog.String("op", op))
 
-	result, err := userRepository.GetUserList(ctx, queryParams.Search, queryParams.Limit, queryParams.Offset, queryParams.SortParams)
+	sortParams := queryParams.SortParams
+	var keyset *users_db.Keyset
+	if page.Cursor != "" {
+		pageCursor, err := cursorCodec.Decode(page.Cursor)
+		if err != nil {
+			log.Debug("Failed to decode cursor", "err", err)
+			return get_users_list.UsersList{}, err
+		}
+		sortParams = pageCursor.Sort
+		keyset = &users_db.Keyset{Values: pageCursor.Values, Backward: pageCursor.Backward}
+	}
+
+	var fields []string
+	if view.Fields != nil || len(view.Include) > 0 {
+		fields = view.repositoryFields()
+		if filter.IncludeDeleted {
+			fields = append(fields, "deleted_at")
+		}
+	}
+	result, err := userRepository.GetUserList(ctx, users_db.UserListParams{
+		Search:         queryParams.Search,
+		Limit:          queryParams.Limit,
+		Offset:         queryParams.Offset,
+		SortParams:     sortParams,
+		Keyset:         keyset,
+		CountMode:      page.CountMode,
+		Fields:         fields,
+		UserListFilter: filter,
+	})
 	if err != nil {
 		log.Error("Failed to get users list", "err", err)
 		return get_users_list.UsersList{}, err
 	}
-	userList := make([]get_users_list.UserInfoList, 0, len(result.Users))
+	var lastLogins map[int64]*user_resource.LastLogin
+	if view.includes(IncludeLastLogin) && len(result.Users) > 0 {
+		userIds := make([]int64, 0, len(result.Users))
+		for _, user := range result.Users {
+			userIds = append(userIds, user.ID)
+		}
+		lastLogins, err = getLastLogins(ctx, loginEventsRepository, userIds)
+		if err != nil {
+			log.Error("Failed to get last logins", "err", err)
+			return get_users_list.UsersList{}, err
+		}
+	}
+	userList := make([]user_resource.User, 0, len(result.Users))
 	for _, user := range result.Users {
-		userInfo := get_users_list.UserInfoList{
-			Id:        user.ID,
-			Email:     user.Email,
-			Phone:     user.Phone,
-			LastName:  user.LastName,
-			FirstName: user.FirstName,
-			Role:      user.Role,
+		userInfo := toUserResource(user)
+		userInfo.DeletedAt = user.DeletedAt
+		userInfo.Score = user.Score
+		userInfo.LastLogin = lastLogins[user.ID]
+		userInfo.Attributes = view.visibleAttributes(user)
+		if view.includes(IncludeStatusDetails) {
+			userInfo.StatusDetails = statusDetails(user)
 		}
 		userList = append(userList, userInfo)
 	}
 	metaData := get_users_list.UsersListMetaData{
-		Page:   queryParams.Page,
-		Total:  result.Total,
-		Limit:  queryParams.Limit,
-		Offset: queryParams.Offset,
+		Page:           queryParams.Page,
+		Total:          result.Total,
+		TotalEstimated: result.TotalEstimated,
+		Limit:          queryParams.Limit,
+		Offset:         queryParams.Offset,
+	}
+	// Курсоры не выдаются для порядка по релевантности и по атрибутам: их значения не хранятся в курсоре
+	if len(result.Users) > 0 && !result.RankedByScore && !sortedByAttribute(sortParams) {
+		backward := keyset != nil && keyset.Backward
+		sortKey := users_db.KeysetSort(sortParams)
+		// Следующая страница есть, если выбрали лишнюю запись или пришли на эту страницу с конца списка
+		if backward || result.HasMore {
+			metaData.NextCursor, err = encodeUserCursor(cursorCodec, sortKey, result.Users[len(result.Users)-1], false)
+			if err != nil {
+				return get_users_list.UsersList{}, err
+			}
+		}
+		if (backward && result.HasMore) || (!backward && (keyset != nil || queryParams.Offset > 0)) {
+			metaData.PrevCursor, err = encodeUserCursor(cursorCodec, sortKey, result.Users[0], true)
+			if err != nil {
+				return get_users_list.UsersList{}, err
+			}
+		}
 	}
 	userDto := get_users_list.UsersList{
 		Users: userList,
@@ -73,28 +251,232 @@ func GetUserList(log *slog.Logger, userRepository users_db.UserRepository, ctx c
 
 }
 
-func UpdateUser(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, dto update_user.UpdateUserDto, id int64) error {
+// sortedByAttribute есть ли в сортировке поля атрибутов
+func sortedByAttribute(sortParams []query_params.SortParam) bool {
+	for _, sortParam := range sortParams {
+		if strings.HasPrefix(sortParam.Field, user_attributes.FieldPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeUserCursor строит курсор, указывающий на записи после (или перед, если backward) пользователя user
+func encodeUserCursor(cursorCodec *cursor.Codec, sortKey []query_params.SortParam, user users_db.UserInfo, backward bool) (string, error) {
+	values := make([]interface{}, 0, len(sortKey))
+	for _, sortParam := range sortKey {
+		values = append(values, user.SortValue(sortParam.Field))
+	}
+	return cursorCodec.Encode(cursor.Cursor{Sort: sortKey, Values: values, Backward: backward})
+}
+
+// UpdateUser обновляет данные пользователя.
+// Email сразу не меняется: если он отличается от текущего, создаётся запрос на смену email,
+// который нужно подтвердить с нового адреса. В этом случае в ответе выставляется EmailChangePending.
+// Если в dto переданы атрибуты, они заменяют все атрибуты, которые access может менять.
+// Роль, отличную от текущей, может передать только администратор, иначе возвращается ErrRoleChangeForbidden.
+// version — версия пользователя, которую видел клиент (users_db.AnyVersion, если проверять не нужно).
+// Возвращает пользователя после обновления
+func UpdateUser(log *slog.Logger, userRepository users_db.UserRepository, emailChanger EmailChanger, attributeSchema AttributeSchema,
+	ctx context.Context, dto update_user.UpdateUserDto, id, version int64, access user_attributes.Access) (update_user.UpdateUserResponse, error) {
 	const op = "internal/lib/services/user_service/user_service.go/UpdateUser"
 	log = log.With(slog.String("op", op),
 		slog.String("UserId", strconv.FormatInt(id, 10)))
 
-	err := userRepository.UpdateUser(ctx, id, dto.FirstName, dto.LastName, dto.Email, dto.Phone, dto.Role)
+	current, err := userRepository.GetUser(ctx, id)
+	if err != nil {
+		log.Error("Failed to get user", "err", err)
+		return update_user.UpdateUserResponse{}, err
+	}
+	current.ID = id
+	if version != users_db.AnyVersion && current.Version != version {
+		log.Debug("User version mismatch", "expected", version, "current", current.Version)
+		return update_user.UpdateUserResponse{}, users_db.ErrVersionMismatch
+	}
+	if dto.Role != current.Role && access != user_attributes.AccessAdmin {
+		log.Debug("Role change denied", "role", dto.Role)
+		return update_user.UpdateUserResponse{}, ErrRoleChangeForbidden
+	}
+	definitions, err := AttributeDefinitions(ctx, attributeSchema)
+	if err != nil {
+		log.Error("Failed to get attribute definitions", "err", err)
+		return update_user.UpdateUserResponse{}, err
+	}
+	var attributes map[string]interface{}
+	if dto.Attributes != nil {
+		if err = user_attributes.Validate(definitions, dto.Attributes, access, false); err != nil {
+			log.Debug("Update rejected by attribute rules", "err", err)
+			return update_user.UpdateUserResponse{}, err
+		}
+		attributes = user_attributes.Replacement(definitions, dto.Attributes, access)
+	}
+	phone, err := normalizePhone(dto.Phone)
+	if err != nil {
+		return update_user.UpdateUserResponse{}, err
+	}
+
+	// Запрос на смену email создаётся до обновления остальных полей, что б не применять запрос частично.
+	// Токен отправляется только после обновления
+	newEmail := email_address.Canonicalize(dto.Email)
+	emailChanged := email_address.Key(current.Email) != email_address.Key(newEmail)
+	var emailChange EmailChangeRequest
+	if emailChanged {
+		if err = emailChanger.CheckNewEmail(log, userRepository, ctx, current, newEmail); err != nil {
+			return update_user.UpdateUserResponse{}, err
+		}
+		if emailChange, err = emailChanger.RequestEmailChange(log, ctx, current, newEmail); err != nil {
+			return update_user.UpdateUserResponse{}, err
+		}
+	}
+
+	profile := users_db.Profile{DisplayName: dto.DisplayName, Locale: dto.Locale, Timezone: dto.Timezone}
+	user, err := userRepository.UpdateUser(ctx, id, version, dto.FirstName, dto.LastName, phone, dto.Role, profile, attributes)
 	if err != nil {
 		log.Error("Failed to update user", "err", err)
-		return err
+		return update_user.UpdateUserResponse{}, err
 	}
-	return nil
+
+	if emailChanged {
+		emailChanger.SendEmailChangeRequest(log, ctx, emailChange)
+	}
+	return update_user.UpdateUserResponse{
+		User:               toUserResourceWithAttributes(user, definitions, access),
+		EmailChangePending: emailChanged,
+	}, nil
+}
+
+// PatchUser частично обновляет пользователя: меняются только переданные в dto поля.
+// Смена email, как и в UpdateUser, требует подтверждения с нового адреса.
+// Атрибуты применяются как JSON Merge Patch и проверяются с правами access.
+// version — версия пользователя, которую видел клиент (users_db.AnyVersion, если проверять не нужно).
+// Возвращает пользователя после обновления
+func PatchUser(log *slog.Logger, userRepository users_db.UserRepository, emailChanger EmailChanger, attributeSchema AttributeSchema,
+	ctx context.Context, dto update_user.PatchUserDto, id, version int64, access user_attributes.Access) (update_user.UpdateUserResponse, error) {
+	const op = "internal/lib/services/user_service/user_service.go/PatchUser"
+	log = log.With(slog.String("op", op),
+		slog.String("UserId", strconv.FormatInt(id, 10)))
+
+	definitions, err := AttributeDefinitions(ctx, attributeSchema)
+	if err != nil {
+		log.Error("Failed to get attribute definitions", "err", err)
+		return update_user.UpdateUserResponse{}, err
+	}
+	if dto.RemoveAttributes {
+		dto.Attributes = user_attributes.Replacement(definitions, nil, access)
+	}
+	if err = user_attributes.Validate(definitions, dto.Attributes, access, true); err != nil {
+		log.Debug("Patch rejected by attribute rules", "err", err)
+		return update_user.UpdateUserResponse{}, err
+	}
+
+	fields := make(map[string]interface{})
+	if dto.FirstName != nil {
+		fields["first_name"] = *dto.FirstName
+	}
+	if dto.LastName != nil {
+		fields["last_name"] = *dto.LastName
+	}
+	if dto.Phone != nil {
+		phone, err := normalizePhone(*dto.Phone)
+		if err != nil {
+			return update_user.UpdateUserResponse{}, err
+		}
+		fields["phone"] = phone
+	}
+	if dto.Role != nil {
+		fields["role"] = *dto.Role
+	}
+	if dto.DisplayName != nil {
+		fields["display_name"] = *dto.DisplayName
+	}
+	if dto.Locale != nil {
+		fields["locale"] = *dto.Locale
+	}
+	if dto.Timezone != nil {
+		fields["timezone"] = *dto.Timezone
+	}
+	if dto.Attributes != nil {
+		fields["attributes"] = dto.Attributes
+	}
+
+	// Запрос на смену email создаётся до обновления остальных полей, что б не применять запрос частично.
+	// Токен отправляется только после обновления
+	emailChanged := false
+	var emailChange EmailChangeRequest
+	if dto.Email != nil {
+		current, err := userRepository.GetUser(ctx, id)
+		if err != nil {
+			log.Error("Failed to get user", "err", err)
+			return update_user.UpdateUserResponse{}, err
+		}
+		current.ID = id
+		if version != users_db.AnyVersion && current.Version != version {
+			log.Debug("User version mismatch", "expected", version, "current", current.Version)
+			return update_user.UpdateUserResponse{}, users_db.ErrVersionMismatch
+		}
+		newEmail := email_address.Canonicalize(*dto.Email)
+		emailChanged = email_address.Key(current.Email) != email_address.Key(newEmail)
+		if emailChanged {
+			if err = emailChanger.CheckNewEmail(log, userRepository, ctx, current, newEmail); err != nil {
+				return update_user.UpdateUserResponse{}, err
+			}
+			if emailChange, err = emailChanger.RequestEmailChange(log, ctx, current, newEmail); err != nil {
+				return update_user.UpdateUserResponse{}, err
+			}
+		}
+	}
+
+	user, err := userRepository.PatchUser(ctx, id, version, fields)
+	if err != nil {
+		log.Error("Failed to patch user", "err", err)
+		return update_user.UpdateUserResponse{}, err
+	}
+
+	if emailChanged {
+		emailChanger.SendEmailChangeRequest(log, ctx, emailChange)
+	}
+	return update_user.UpdateUserResponse{
+		User:               toUserResourceWithAttributes(user, definitions, access),
+		EmailChangePending: emailChanged,
+	}, nil
 }
 
-func DeleteUser(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, id int64) error {
+// DeleteUser удаляет пользователя.
+// version — версия пользователя, которую видел клиент (users_db.AnyVersion, если проверять не нужно)
+func DeleteUser(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, id, version int64) error {
 	const op = "internal/lib/services/user_service/user_service.go/DeleteUser"
 	log = log.With(slog.String("op", op),
 		slog.String("UserId", strconv.FormatInt(id, 10)))
 
-	err := userRepository.DeleteUser(ctx, id)
+	err := userRepository.DeleteUser(ctx, id, version)
 	if err != nil {
 		log.Error("Failed to delete user", "err", err)
 		return err
 	}
 	return nil
 }
+
+// RestoreUser восстанавливает мягко удалённого пользователя и возвращает его новую версию
+func RestoreUser(log *slog.Logger, userRepository users_db.UserRepository, ctx context.Context, id int64) (int64, error) {
+	const op = "internal/lib/services/user_service/user_service.go/RestoreUser"
+	log = log.With(slog.String("op", op),
+		slog.String("UserId", strconv.FormatInt(id, 10)))
+
+	version, err := userRepository.RestoreUser(ctx, id)
+	if err != nil {
+		log.Debug("Failed to restore user", "err", err)
+		return 0, err
+	}
+	log.Info("User restored")
+	return version, nil
+}
+
+// normalizePhone приводит телефон к формату E.164 (см. phone_numbers.Normalize).
+// Тег валидации phone проверяет номер заранее, поэтому ошибка здесь означает номер, не прошедший валидацию DTO
+func normalizePhone(phone string) (string, error) {
+	number, err := phone_numbers.Normalize(phone)
+	if err != nil {
+		return "", err
+	}
+	return number.E164, nil
+}
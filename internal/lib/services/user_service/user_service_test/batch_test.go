@@ -0,0 +1,90 @@
+package user_service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/batch_users"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+var errCommit = errors.New("commit failed")
+
+// commitFailingRepository выполняет операции транзакции, но не может её зафиксировать
+type commitFailingRepository struct {
+	*users_db_mock.MockUserRepository
+}
+
+func (r commitFailingRepository) WithTx(ctx context.Context, fn func(txRepository users_db.UserRepository) error) error {
+	if err := fn(r.MockUserRepository); err != nil {
+		return err
+	}
+	return errCommit
+}
+
+// batchOperations повышает роль пользователя 1, удаляет пользователей 2 и 3
+var batchOperations = []batch_users.BatchOperation{
+	{Op: batch_users.OpSetRole, Id: 1, Role: "admin"},
+	{Op: batch_users.OpDelete, Id: 2, Version: 5},
+	{Op: batch_users.OpDelete, Id: 3},
+}
+
+func TestExecuteBatchAtomicRollback(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("PatchUser", mock.Anything, int64(1), users_db.AnyVersion, map[string]interface{}{"role": "admin"}).
+		Return(users_db.UserInfo{ID: 1, Role: "admin", Version: 2}, nil).Once()
+	userRepository.On("DeleteUser", mock.Anything, int64(2), int64(5)).Return(users_db.ErrVersionMismatch).Once()
+
+	results := user_service.ExecuteBatch(slog.Default(), userRepository, nil, context.Background(),
+		batch_users.BatchRequest{Atomic: true, Operations: batchOperations})
+
+	require.Len(t, results, 3)
+	require.Nil(t, results[0].User)
+	require.ErrorIs(t, results[0].Err, user_service.ErrBatchRolledBack)
+	require.ErrorIs(t, results[1].Err, users_db.ErrVersionMismatch)
+	require.ErrorIs(t, results[2].Err, user_service.ErrBatchNotExecuted)
+	// Пользователь 3 не удаляется: пакет отменён на предыдущей операции
+	userRepository.AssertExpectations(t)
+	userRepository.AssertNotCalled(t, "DeleteUser", mock.Anything, int64(3), mock.Anything)
+}
+
+func TestExecuteBatchAtomicCommitFailure(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("PatchUser", mock.Anything, int64(1), users_db.AnyVersion, map[string]interface{}{"role": "admin"}).
+		Return(users_db.UserInfo{ID: 1, Role: "admin", Version: 2}, nil).Once()
+	userRepository.On("DeleteUser", mock.Anything, int64(2), int64(5)).Return(nil).Once()
+	userRepository.On("DeleteUser", mock.Anything, int64(3), users_db.AnyVersion).Return(nil).Once()
+
+	results := user_service.ExecuteBatch(slog.Default(), commitFailingRepository{userRepository}, nil, context.Background(),
+		batch_users.BatchRequest{Atomic: true, Operations: batchOperations})
+
+	require.Len(t, results, 3)
+	for _, result := range results {
+		require.Nil(t, result.User)
+		require.ErrorIs(t, result.Err, errCommit)
+	}
+	userRepository.AssertExpectations(t)
+}
+
+func TestExecuteBatchIndependentOperations(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("PatchUser", mock.Anything, int64(1), users_db.AnyVersion, map[string]interface{}{"role": "admin"}).
+		Return(users_db.UserInfo{ID: 1, Role: "admin", Version: 2}, nil).Once()
+	userRepository.On("DeleteUser", mock.Anything, int64(2), int64(5)).Return(users_db.ErrVersionMismatch).Once()
+	userRepository.On("DeleteUser", mock.Anything, int64(3), users_db.AnyVersion).Return(nil).Once()
+
+	results := user_service.ExecuteBatch(slog.Default(), userRepository, nil, context.Background(),
+		batch_users.BatchRequest{Operations: batchOperations})
+
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "admin", results[0].User.Role)
+	require.ErrorIs(t, results[1].Err, users_db.ErrVersionMismatch)
+	require.NoError(t, results[2].Err)
+	userRepository.AssertExpectations(t)
+}
@@ -0,0 +1,219 @@
+package user_service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/confirmation"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/bulk_update"
+	"github.com/ShlykovPavel/users-microservice/models/users/status_change"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const bulkAdminId = int64(1)
+
+var bulkQuery = url.Values{"status": {user_status.Active}}
+var bulkFilter = users_db.UserListFilter{Statuses: []string{user_status.Active}}
+
+// bulkRolePatch повышает роль пользователей до admin
+func bulkRolePatch() bulk_update.BulkPatch {
+	role := "admin"
+	return bulk_update.BulkPatch{Role: &role}
+}
+
+func bulkParams() users_db.UserListParams {
+	return users_db.UserListParams{UserListFilter: bulkFilter}
+}
+
+func bulkRoleUpdate() users_db.BulkUpdate {
+	return users_db.BulkUpdate{Role: bulkRolePatch().Role, AdminId: bulkAdminId}
+}
+
+func newBulkUpdater(userRepository users_db.UserRepository, ttl time.Duration) user_service.BulkUpdater {
+	return user_service.BulkUpdater{UserRepository: userRepository, Confirmations: confirmation.NewCodec("secret", ttl)}
+}
+
+// previewToken выдаёт токен подтверждения для bulkRolePatch, под который подходят matched пользователей
+func previewToken(t *testing.T, bulkUpdater user_service.BulkUpdater, userRepository *users_db_mock.MockUserRepository, matched int64) string {
+	userRepository.On("CountBulkUpdate", mock.Anything, bulkParams(), bulkRoleUpdate()).Return(matched, nil).Once()
+	preview, err := bulkUpdater.Preview(slog.Default(), context.Background(), bulkQuery, query_params.ListQueryParams{},
+		bulkFilter, bulkRolePatch(), bulkAdminId)
+	require.NoError(t, err)
+	return preview.ConfirmationToken
+}
+
+func TestBulkUpdatePreview(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("CountBulkUpdate", mock.Anything, bulkParams(), bulkRoleUpdate()).Return(int64(42), nil).Once()
+	bulkUpdater := newBulkUpdater(userRepository, time.Minute)
+
+	preview, err := bulkUpdater.Preview(slog.Default(), context.Background(), bulkQuery, query_params.ListQueryParams{},
+		bulkFilter, bulkRolePatch(), bulkAdminId)
+
+	require.NoError(t, err)
+	require.Equal(t, int64(42), preview.Matched)
+	require.NotEmpty(t, preview.ConfirmationToken)
+	require.WithinDuration(t, time.Now().Add(time.Minute), preview.ExpiresAt, 2*time.Second)
+	userRepository.AssertExpectations(t)
+}
+
+func TestBulkUpdatePreviewRejectsPatch(t *testing.T) {
+	suspended := user_status.Suspended
+	active := user_status.Active
+	until := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		patch   bulk_update.BulkPatch
+		wantErr error
+	}{
+		{name: "Empty patch", wantErr: user_service.ErrBulkPatchEmpty},
+		{name: "Suspend without reason", patch: bulk_update.BulkPatch{Status: &suspended},
+			wantErr: user_service.ErrStatusReasonRequired},
+		{name: "Activate until time", patch: bulk_update.BulkPatch{Status: &active, StatusChange: status_change.ChangeStatusRequest{Until: &until}},
+			wantErr: user_service.ErrStatusUntilNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			bulkUpdater := newBulkUpdater(userRepository, time.Minute)
+
+			_, err := bulkUpdater.Preview(slog.Default(), context.Background(), bulkQuery, query_params.ListQueryParams{},
+				bulkFilter, test.patch, bulkAdminId)
+
+			require.ErrorIs(t, err, test.wantErr)
+			userRepository.AssertNotCalled(t, "CountBulkUpdate", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestBulkUpdateConfirm(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	bulkUpdater := newBulkUpdater(userRepository, time.Minute)
+	token := previewToken(t, bulkUpdater, userRepository, 42)
+	userRepository.On("CountBulkUpdate", mock.Anything, bulkParams(), bulkRoleUpdate()).Return(int64(42), nil).Once()
+
+	plan, err := bulkUpdater.Confirm(slog.Default(), context.Background(), bulkQuery, query_params.ListQueryParams{},
+		bulkFilter, bulkRolePatch(), bulkAdminId, token)
+
+	require.NoError(t, err)
+	require.Equal(t, int64(42), plan.Matched)
+	userRepository.AssertExpectations(t)
+}
+
+func TestBulkUpdateConfirmRejectsToken(t *testing.T) {
+	otherRole := "user"
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		query   url.Values
+		patch   *bulk_update.BulkPatch
+		adminId int64
+		noToken bool   // Подтверждение без токена
+		token   string // Если задан, передаётся вместо токена из предпросмотра
+		wantErr error
+	}{
+		{name: "Missing token", noToken: true, wantErr: user_service.ErrConfirmationRequired},
+		{name: "Forged token", token: "eyJoIjoiIn0.c2lnbmF0dXJl", wantErr: confirmation.ErrInvalidToken},
+		{name: "Expired token", ttl: -time.Second, wantErr: confirmation.ErrTokenExpired},
+		{name: "Token of another admin", adminId: 2, wantErr: confirmation.ErrTokenMismatch},
+		{name: "Token for another filter", query: url.Values{"status": {user_status.Suspended}}, wantErr: confirmation.ErrTokenMismatch},
+		{name: "Token for another patch", patch: &bulk_update.BulkPatch{Role: &otherRole}, wantErr: confirmation.ErrTokenMismatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			ttl := test.ttl
+			if ttl == 0 {
+				ttl = time.Minute
+			}
+			bulkUpdater := newBulkUpdater(userRepository, ttl)
+			token := previewToken(t, bulkUpdater, userRepository, 42)
+			if test.noToken {
+				token = ""
+			}
+			if test.token != "" {
+				token = test.token
+			}
+			query, patch, adminId := bulkQuery, bulkRolePatch(), bulkAdminId
+			if test.query != nil {
+				query = test.query
+			}
+			if test.patch != nil {
+				patch = *test.patch
+			}
+			if test.adminId != 0 {
+				adminId = test.adminId
+			}
+
+			_, err := bulkUpdater.Confirm(slog.Default(), context.Background(), query, query_params.ListQueryParams{},
+				bulkFilter, patch, adminId, token)
+
+			require.ErrorIs(t, err, test.wantErr)
+			// Токен проверяется до подсчёта пользователей: CountBulkUpdate вызывался только в предпросмотре
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBulkUpdateConfirmOutdatedPreview(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	bulkUpdater := newBulkUpdater(userRepository, time.Minute)
+	token := previewToken(t, bulkUpdater, userRepository, 42)
+	userRepository.On("CountBulkUpdate", mock.Anything, bulkParams(), bulkRoleUpdate()).Return(int64(43), nil).Once()
+
+	_, err := bulkUpdater.Confirm(slog.Default(), context.Background(), bulkQuery, query_params.ListQueryParams{},
+		bulkFilter, bulkRolePatch(), bulkAdminId, token)
+
+	require.ErrorIs(t, err, user_service.ErrBulkPreviewOutdated)
+	userRepository.AssertExpectations(t)
+}
+
+func TestBulkUpdateRun(t *testing.T) {
+	errBatch := errors.New("batch failed")
+
+	tests := []struct {
+		name            string
+		batches         []int64
+		updated         int64
+		err             error
+		expectedUpdated int64
+	}{
+		{name: "All batches", batches: []int64{500, 1000, 1200}, updated: 1200, expectedUpdated: 1200},
+		{name: "Failed batch", batches: []int64{500}, updated: 500, err: errBatch, expectedUpdated: 500},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			bulkUpdater := newBulkUpdater(userRepository, time.Minute)
+			token := previewToken(t, bulkUpdater, userRepository, 1200)
+			userRepository.On("CountBulkUpdate", mock.Anything, bulkParams(), bulkRoleUpdate()).Return(int64(1200), nil).Once()
+			plan, err := bulkUpdater.Confirm(slog.Default(), context.Background(), bulkQuery, query_params.ListQueryParams{},
+				bulkFilter, bulkRolePatch(), bulkAdminId, token)
+			require.NoError(t, err)
+			userRepository.On("BulkUpdateUsers", mock.Anything, bulkParams(), bulkRoleUpdate(), 500).
+				Return(test.updated, test.err, test.batches).Once()
+
+			var progress []int64
+			updated, err := bulkUpdater.Run(slog.Default(), context.Background(), plan, func(updated int64) error {
+				progress = append(progress, updated)
+				return nil
+			})
+
+			require.ErrorIs(t, err, test.err)
+			require.Equal(t, test.expectedUpdated, updated)
+			require.Equal(t, test.batches, progress)
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package user_service_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/email_changes_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/update_user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type MockEmailChangeRepository struct {
+	mock.Mock
+}
+
+func (m *MockEmailChangeRepository) CreateEmailChange(ctx context.Context, change *email_changes_db.EmailChange) (int64, error) {
+	args := m.Called(ctx, change)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockEmailChangeRepository) ConfirmEmailChange(ctx context.Context, tokenHash string) (email_changes_db.EmailChange, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(email_changes_db.EmailChange), args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	args := m.Called(ctx, notification)
+	return args.Error(0)
+}
+
+const (
+	emailChangeUserId = int64(7)
+	oldEmail          = "ivan@example.com"
+	newEmail          = "ivan@work.example.com"
+	emailChangeTTL    = time.Hour
+)
+
+func newEmailChanger(t *testing.T, repository *MockEmailChangeRepository, notifier *MockNotifier) user_service.EmailChanger {
+	domainRules, err := email_domains.NewRules(email_domains.Config{})
+	require.NoError(t, err)
+	return user_service.EmailChanger{Repository: repository, DomainRules: domainRules, Notifier: notifier, TTL: emailChangeTTL}
+}
+
+func patchEmail(emailChanger user_service.EmailChanger, userRepository users_db.UserRepository, email string) (update_user.UpdateUserResponse, error) {
+	return user_service.PatchUser(slog.Default(), userRepository, emailChanger, nil, context.Background(),
+		update_user.PatchUserDto{Email: &email}, emailChangeUserId, 3, user_attributes.AccessSelf)
+}
+
+func TestPatchUserRequestsEmailChange(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("GetUser", mock.Anything, emailChangeUserId).
+		Return(users_db.UserInfo{ID: emailChangeUserId, Email: oldEmail, Version: 3}, nil).Once()
+	userRepository.On("GetUserByEmail", mock.Anything, newEmail).Return(users_db.UserInfo{}, users_db.ErrUserNotFound).Once()
+	// Email пользователя не меняется до подтверждения: в PatchUser его нет
+	userRepository.On("PatchUser", mock.Anything, emailChangeUserId, int64(3), map[string]interface{}{}).
+		Return(users_db.UserInfo{ID: emailChangeUserId, Email: oldEmail, Version: 4}, nil).Once()
+
+	var created *email_changes_db.EmailChange
+	repository := new(MockEmailChangeRepository)
+	repository.On("CreateEmailChange", mock.Anything, mock.AnythingOfType("*email_changes_db.EmailChange")).
+		Run(func(args mock.Arguments) { created = args.Get(1).(*email_changes_db.EmailChange) }).
+		Return(int64(1), nil).Once()
+
+	var sent []notifications.Notification
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, mock.AnythingOfType("notifications.Notification")).
+		Run(func(args mock.Arguments) { sent = append(sent, args.Get(1).(notifications.Notification)) }).
+		Return(nil).Twice()
+
+	response, err := patchEmail(newEmailChanger(t, repository, notifier), userRepository, "ivan@Work.Example.COM")
+
+	require.NoError(t, err)
+	require.True(t, response.EmailChangePending)
+	require.Equal(t, oldEmail, response.User.Email)
+
+	require.NotNil(t, created)
+	require.Equal(t, emailChangeUserId, created.UserID)
+	require.Equal(t, oldEmail, created.OldEmail)
+	require.Equal(t, newEmail, created.NewEmail)
+	require.WithinDuration(t, time.Now().Add(emailChangeTTL), created.ExpiresAt, 2*time.Second)
+
+	require.Len(t, sent, 2)
+	// Токен уходит только на новый адрес, в базе хранится его хеш
+	require.Equal(t, notifications.TypeEmailChangeConfirmation, sent[0].Type)
+	require.Equal(t, newEmail, sent[0].Recipient)
+	require.Equal(t, created.TokenHash, secure_tokens.Hash(sent[0].Data["token"]))
+	require.Equal(t, created.ExpiresAt.Format(time.RFC3339), sent[0].Data["expires_at"])
+	// На старый адрес — уведомление без токена
+	require.Equal(t, notifications.TypeEmailChangeRequested, sent[1].Type)
+	require.Equal(t, oldEmail, sent[1].Recipient)
+	require.Equal(t, map[string]string{"new_email": newEmail}, sent[1].Data)
+
+	userRepository.AssertExpectations(t)
+	repository.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestPatchUserEmailChangeRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		setupMock func(*users_db_mock.MockUserRepository, *MockEmailChangeRepository)
+		wantErr   error
+	}{
+		{
+			name:  "Same email in another case",
+			email: "IVAN@example.com",
+			setupMock: func(userRepository *users_db_mock.MockUserRepository, repository *MockEmailChangeRepository) {
+				userRepository.On("PatchUser", mock.Anything, emailChangeUserId, int64(3), map[string]interface{}{}).
+					Return(users_db.UserInfo{ID: emailChangeUserId, Email: oldEmail, Version: 4}, nil).Once()
+			},
+		},
+		{
+			name:  "Email of another user",
+			email: newEmail,
+			setupMock: func(userRepository *users_db_mock.MockUserRepository, repository *MockEmailChangeRepository) {
+				userRepository.On("GetUserByEmail", mock.Anything, newEmail).Return(users_db.UserInfo{ID: 8, Email: newEmail}, nil).Once()
+			},
+			wantErr: users_db.ErrEmailAlreadyExists,
+		},
+		{
+			name:  "User changed by another request",
+			email: newEmail,
+			setupMock: func(userRepository *users_db_mock.MockUserRepository, repository *MockEmailChangeRepository) {
+				userRepository.On("GetUserByEmail", mock.Anything, newEmail).Return(users_db.UserInfo{}, users_db.ErrUserNotFound).Once()
+				repository.On("CreateEmailChange", mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+				userRepository.On("PatchUser", mock.Anything, emailChangeUserId, int64(3), map[string]interface{}{}).
+					Return(users_db.UserInfo{}, users_db.ErrVersionMismatch).Once()
+			},
+			wantErr: users_db.ErrVersionMismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			userRepository.On("GetUser", mock.Anything, emailChangeUserId).
+				Return(users_db.UserInfo{ID: emailChangeUserId, Email: oldEmail, Version: 3}, nil).Once()
+			repository := new(MockEmailChangeRepository)
+			test.setupMock(userRepository, repository)
+			notifier := new(MockNotifier)
+
+			response, err := patchEmail(newEmailChanger(t, repository, notifier), userRepository, test.email)
+
+			require.ErrorIs(t, err, test.wantErr)
+			require.False(t, response.EmailChangePending)
+			// Токен не отправляется, если запрос не создан или остальные изменения не сохранены
+			notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+			userRepository.AssertExpectations(t)
+			repository.AssertExpectations(t)
+		})
+	}
+}
+
+// TestPatchUserEmailChangeDomainRules новый email проверяется теми же правилами доменов, что и при регистрации
+func TestPatchUserEmailChangeDomainRules(t *testing.T) {
+	domainRules, err := email_domains.NewRules(email_domains.Config{
+		AllowedDomains:         []string{"example.com"},
+		DeniedDomains:          []string{"guest.example.com"},
+		BlockDisposableDomains: true,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{name: "Subdomain of allowed domain", email: "ivan@Work.Example.COM"},
+		{name: "Domain outside of allow-list", email: "ivan@example.org", wantErr: email_domains.ErrDomainNotAllowed},
+		{name: "Denied subdomain", email: "ivan@GUEST.example.com", wantErr: email_domains.ErrDomainDenied},
+		{name: "Disposable domain", email: "ivan@mailinator.com", wantErr: email_domains.ErrDisposableEmail},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			userRepository.On("GetUser", mock.Anything, emailChangeUserId).
+				Return(users_db.UserInfo{ID: emailChangeUserId, Email: oldEmail, Version: 3}, nil).Once()
+			repository := new(MockEmailChangeRepository)
+			notifier := new(MockNotifier)
+			if test.wantErr == nil {
+				userRepository.On("GetUserByEmail", mock.Anything, newEmail).Return(users_db.UserInfo{}, users_db.ErrUserNotFound).Once()
+				repository.On("CreateEmailChange", mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+				userRepository.On("PatchUser", mock.Anything, emailChangeUserId, int64(3), map[string]interface{}{}).
+					Return(users_db.UserInfo{ID: emailChangeUserId, Email: oldEmail, Version: 4}, nil).Once()
+				notifier.On("Notify", mock.Anything, mock.Anything).Return(nil).Twice()
+			}
+			emailChanger := user_service.EmailChanger{Repository: repository, DomainRules: domainRules, Notifier: notifier, TTL: emailChangeTTL}
+
+			response, err := patchEmail(emailChanger, userRepository, test.email)
+
+			require.ErrorIs(t, err, test.wantErr)
+			require.Equal(t, test.wantErr == nil, response.EmailChangePending)
+			// Запрещённый адрес отклоняется до проверки занятости и создания запроса
+			userRepository.AssertExpectations(t)
+			repository.AssertExpectations(t)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestEmailChangeReplacesToken(t *testing.T) {
+	var hashes []string
+	repository := new(MockEmailChangeRepository)
+	repository.On("CreateEmailChange", mock.Anything, mock.AnythingOfType("*email_changes_db.EmailChange")).
+		Run(func(args mock.Arguments) {
+			hashes = append(hashes, args.Get(1).(*email_changes_db.EmailChange).TokenHash)
+		}).
+		Return(int64(1), nil).Twice()
+	emailChanger := newEmailChanger(t, repository, new(MockNotifier))
+	user := users_db.UserInfo{ID: emailChangeUserId, Email: oldEmail}
+
+	first, err := emailChanger.RequestEmailChange(slog.Default(), context.Background(), user, newEmail)
+	require.NoError(t, err)
+	second, err := emailChanger.RequestEmailChange(slog.Default(), context.Background(), user, newEmail)
+	require.NoError(t, err)
+
+	// Каждый запрос получает новый токен, предыдущий запрос репозиторий удаляет (см. CreateEmailChange)
+	require.NotEqual(t, first.Token, second.Token)
+	require.Equal(t, []string{secure_tokens.Hash(first.Token), secure_tokens.Hash(second.Token)}, hashes)
+	require.WithinDuration(t, time.Now().Add(emailChangeTTL), second.ExpiresAt, 2*time.Second)
+	repository.AssertExpectations(t)
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	const token = "confirmation-token"
+	change := email_changes_db.EmailChange{ID: 1, UserID: emailChangeUserId, OldEmail: oldEmail, NewEmail: newEmail}
+	repository := new(MockEmailChangeRepository)
+	repository.On("ConfirmEmailChange", mock.Anything, secure_tokens.Hash(token)).Return(change, nil).Once()
+	// Повторное подтверждение тем же токеном: запрос уже подтверждён
+	repository.On("ConfirmEmailChange", mock.Anything, secure_tokens.Hash(token)).
+		Return(email_changes_db.EmailChange{}, email_changes_db.ErrEmailChangeInvalid).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", mock.Anything, notifications.Notification{
+		Type:      notifications.TypeEmailChanged,
+		UserID:    emailChangeUserId,
+		Recipient: oldEmail,
+		Data:      map[string]string{"new_email": newEmail},
+	}).Return(nil).Once()
+	emailChanger := newEmailChanger(t, repository, notifier)
+
+	confirmed, err := emailChanger.ConfirmEmailChange(slog.Default(), context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, change, confirmed)
+
+	_, err = emailChanger.ConfirmEmailChange(slog.Default(), context.Background(), token)
+	require.ErrorIs(t, err, email_changes_db.ErrEmailChangeInvalid)
+
+	repository.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestConfirmEmailChangeRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		// Истёкший, уже подтверждённый и несуществующий токен репозиторий не различает
+		{name: "Expired or used token", err: email_changes_db.ErrEmailChangeInvalid, wantErr: email_changes_db.ErrEmailChangeInvalid},
+		// Транзакция подтверждения откатывается, если адрес занят, пока запрос ждал подтверждения
+		{name: "Email taken before confirmation", err: users_db.ErrEmailAlreadyExists, wantErr: users_db.ErrEmailAlreadyExists},
+		{name: "User deleted before confirmation", err: users_db.ErrUserNotFound, wantErr: users_db.ErrUserNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository := new(MockEmailChangeRepository)
+			repository.On("ConfirmEmailChange", mock.Anything, secure_tokens.Hash("token")).
+				Return(email_changes_db.EmailChange{}, test.err).Once()
+			notifier := new(MockNotifier)
+
+			_, err := newEmailChanger(t, repository, notifier).ConfirmEmailChange(slog.Default(), context.Background(), "token")
+
+			require.ErrorIs(t, err, test.wantErr)
+			notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+			repository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package user_service_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/sms"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/phone_verifications_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type MockPhoneVerificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockPhoneVerificationRepository) CreateVerification(ctx context.Context, verification *phone_verifications_db.Verification) (int64, error) {
+	args := m.Called(ctx, verification)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPhoneVerificationRepository) GetPendingVerification(ctx context.Context, userId int64) (phone_verifications_db.Verification, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).(phone_verifications_db.Verification), args.Error(1)
+}
+
+func (m *MockPhoneVerificationRepository) ConfirmVerification(ctx context.Context, userId int64, codeHash string, maxAttempts int) (phone_verifications_db.Verification, error) {
+	args := m.Called(ctx, userId, codeHash, maxAttempts)
+	return args.Get(0).(phone_verifications_db.Verification), args.Error(1)
+}
+
+type MockSMSSender struct {
+	mock.Mock
+}
+
+func (m *MockSMSSender) Send(ctx context.Context, message sms.Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+const (
+	phoneUserId    = int64(7)
+	userPhone      = "+79512345678"
+	phoneCodeTTL   = 10 * time.Minute
+	resendInterval = time.Minute
+	maxAttempts    = 5
+)
+
+// smsCode код подтверждения из текста SMS
+var smsCode = regexp.MustCompile(`\b\d{6}\b`)
+
+func newPhoneVerifier(repository *MockPhoneVerificationRepository, sender *MockSMSSender) user_service.PhoneVerifier {
+	return user_service.PhoneVerifier{Repository: repository, Sender: sender, TTL: phoneCodeTTL,
+		ResendInterval: resendInterval, MaxAttempts: maxAttempts}
+}
+
+func phoneUser() users_db.UserInfo {
+	return users_db.UserInfo{ID: phoneUserId, Phone: userPhone, PhoneCountryCode: 7, Locale: "en"}
+}
+
+func TestSendPhoneVerificationCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending *phone_verifications_db.Verification // Предыдущий код, nil — кода нет
+	}{
+		{name: "First code"},
+		{name: "Code after resend interval", pending: &phone_verifications_db.Verification{ID: 1, UserID: phoneUserId,
+			Phone: userPhone, CreatedAt: time.Now().Add(-2 * resendInterval)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			userRepository.On("GetUser", mock.Anything, phoneUserId).Return(phoneUser(), nil).Once()
+			repository := new(MockPhoneVerificationRepository)
+			if test.pending != nil {
+				repository.On("GetPendingVerification", mock.Anything, phoneUserId).Return(*test.pending, nil).Once()
+			} else {
+				repository.On("GetPendingVerification", mock.Anything, phoneUserId).
+					Return(phone_verifications_db.Verification{}, phone_verifications_db.ErrVerificationNotFound).Once()
+			}
+			var created *phone_verifications_db.Verification
+			repository.On("CreateVerification", mock.Anything, mock.AnythingOfType("*phone_verifications_db.Verification")).
+				Run(func(args mock.Arguments) { created = args.Get(1).(*phone_verifications_db.Verification) }).
+				Return(int64(2), nil).Once()
+			var message sms.Message
+			sender := new(MockSMSSender)
+			sender.On("Send", mock.Anything, mock.AnythingOfType("sms.Message")).
+				Run(func(args mock.Arguments) { message = args.Get(1).(sms.Message) }).
+				Return(nil).Once()
+
+			verification, err := newPhoneVerifier(repository, sender).SendCode(slog.Default(), userRepository, context.Background(), phoneUserId)
+
+			require.NoError(t, err)
+			require.Equal(t, int64(2), verification.ID)
+			require.Equal(t, userPhone, verification.Phone)
+			require.WithinDuration(t, time.Now().Add(phoneCodeTTL), verification.ExpiresAt, 2*time.Second)
+			// Код уходит только в SMS, в базе хранится его хеш
+			require.Equal(t, userPhone, message.To)
+			code := smsCode.FindString(message.Text)
+			require.NotEmpty(t, code, message.Text)
+			require.Equal(t, secure_tokens.Hash(code), created.CodeHash)
+			userRepository.AssertExpectations(t)
+			repository.AssertExpectations(t)
+			sender.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSendPhoneVerificationCodeRejected(t *testing.T) {
+	pending := phone_verifications_db.Verification{ID: 1, UserID: phoneUserId, Phone: userPhone, CreatedAt: time.Now().Add(-10 * time.Second)}
+	verifiedUser := phoneUser()
+	verifiedUser.PhoneVerified = true
+	legacyUser := phoneUser()
+	legacyUser.Phone, legacyUser.PhoneCountryCode = "89512345678", 0
+
+	tests := []struct {
+		name    string
+		user    users_db.UserInfo
+		pending bool
+		wantErr error
+	}{
+		{name: "Phone already verified", user: verifiedUser, wantErr: user_service.ErrPhoneAlreadyVerified},
+		{name: "Phone is not in E.164", user: legacyUser, wantErr: phone_numbers.ErrInvalidPhone},
+		{name: "Code sent recently", user: phoneUser(), pending: true, wantErr: user_service.ErrVerificationTooSoon},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			userRepository.On("GetUser", mock.Anything, phoneUserId).Return(test.user, nil).Once()
+			repository := new(MockPhoneVerificationRepository)
+			if test.pending {
+				repository.On("GetPendingVerification", mock.Anything, phoneUserId).Return(pending, nil).Once()
+			}
+			sender := new(MockSMSSender)
+			verifier := newPhoneVerifier(repository, sender)
+
+			verification, err := verifier.SendCode(slog.Default(), userRepository, context.Background(), phoneUserId)
+
+			require.ErrorIs(t, err, test.wantErr)
+			if test.pending {
+				// Вместе с ошибкой возвращается предыдущий код: по нему считается Retry-After
+				require.Equal(t, pending.ID, verification.ID)
+				require.Equal(t, pending.CreatedAt.Add(resendInterval), verifier.RetryAt(verification))
+			}
+			repository.AssertNotCalled(t, "CreateVerification", mock.Anything, mock.Anything)
+			sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+			userRepository.AssertExpectations(t)
+			repository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfirmPhoneVerificationCode(t *testing.T) {
+	verifiedAt := time.Now().UTC()
+
+	tests := []struct {
+		name    string
+		result  phone_verifications_db.Verification
+		err     error
+		wantErr error
+	}{
+		{name: "Correct code", result: phone_verifications_db.Verification{ID: 2, UserID: phoneUserId, Phone: userPhone, VerifiedAt: &verifiedAt}},
+		// Неверный код, истёкший код и код для прежнего телефона репозиторий не различает
+		{name: "Wrong or expired code", err: phone_verifications_db.ErrVerificationInvalid, wantErr: phone_verifications_db.ErrVerificationInvalid},
+		{name: "Attempt limit reached", err: phone_verifications_db.ErrTooManyAttempts, wantErr: phone_verifications_db.ErrTooManyAttempts},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository := new(MockPhoneVerificationRepository)
+			repository.On("ConfirmVerification", mock.Anything, phoneUserId, secure_tokens.Hash("123456"), maxAttempts).
+				Return(test.result, test.err).Once()
+
+			verification, err := newPhoneVerifier(repository, new(MockSMSSender)).ConfirmCode(slog.Default(), context.Background(), phoneUserId, "123456")
+
+			require.ErrorIs(t, err, test.wantErr)
+			require.Equal(t, test.result, verification)
+			repository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package user_service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/stretchr/testify/mock"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	const retention = 30 * 24 * time.Hour
+
+	for _, purgeErr := range []error{nil, errors.New("connection refused")} {
+		userRepository := new(users_db_mock.MockUserRepository)
+		before := time.Now().Add(-retention)
+		userRepository.On("PurgeDeletedUsers", mock.Anything, mock.MatchedBy(func(deletedBefore time.Time) bool {
+			return !deletedBefore.Before(before) && !deletedBefore.After(time.Now().Add(-retention))
+		})).Return(int64(2), purgeErr).Once()
+
+		user_service.PurgeDeletedUsers(context.Background(), slog.Default(), userRepository, retention)
+
+		userRepository.AssertExpectations(t)
+	}
+}
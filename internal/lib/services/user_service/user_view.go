@@ -0,0 +1,154 @@
+package user_service
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/ShlykovPavel/users-microservice/models/users/user_resource"
+	"github.com/golang-jwt/jwt/v5"
+	"net/url"
+	"slices"
+)
+
+// Связанные данные, которые можно запросить через ?include=
+const (
+	IncludeLastLogin     = "last_login"     // Последний успешный вход пользователя
+	IncludeStatusDetails = "status_details" // Причина, срок и автор последней смены статуса
+)
+
+// UserFields поля пользователя, которые можно запросить через ?fields=
+var UserFields = []string{"id", "first_name", "last_name", "email", "phone", "phone_country_code", "phone_verified", "role", "display_name", "locale", "timezone",
+	"status", "version", "created_at", "updated_at"}
+
+// FieldAttributes поле дополнительных атрибутов пользователя. Не входит в UserFields, так как не выгружается в экспорт
+const FieldAttributes = "attributes"
+
+// UserIncludes связанные данные, которые можно запросить через ?include=
+var UserIncludes = []string{IncludeLastLogin, IncludeStatusDetails}
+
+// UserView Какие поля и связанные данные пользователя нужны клиенту
+type UserView struct {
+	Fields  []string // Поля из UserFields или FieldAttributes, nil — все поля
+	Include []string // Связанные данные из UserIncludes
+	// Viewer claims токена читающего, nil для запроса без токена. Определяет, какие атрибуты ему видны
+	Viewer jwt.MapClaims
+	// Attributes определения атрибутов. Атрибуты без определения не отдаются
+	Attributes []attribute_definition.Definition
+}
+
+// AttributeSchema Источник определений дополнительных атрибутов пользователей
+type AttributeSchema interface {
+	ListDefinitions(ctx context.Context) ([]attribute_definition.Definition, error)
+}
+
+// AttributeDefinitions возвращает определения атрибутов из schema. Если schema не задана, атрибутов нет
+func AttributeDefinitions(ctx context.Context, schema AttributeSchema) ([]attribute_definition.Definition, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	return schema.ListDefinitions(ctx)
+}
+
+// ParseUserView разбирает параметры запроса fields и include.
+// id возвращается всегда, поэтому добавляется в начало fields, если его там нет.
+// Неизвестные значения возвращают ошибку query_params.ErrInvalidFields
+func ParseUserView(query url.Values) (UserView, error) {
+	fields, err := query_params.ParseFieldList(query, "fields", append(append([]string{}, UserFields...), FieldAttributes))
+	if err != nil {
+		return UserView{}, err
+	}
+	if fields != nil && !slices.Contains(fields, "id") {
+		fields = append([]string{"id"}, fields...)
+	}
+	include, err := query_params.ParseFieldList(query, "include", UserIncludes)
+	if err != nil {
+		return UserView{}, err
+	}
+	return UserView{Fields: fields, Include: include}, nil
+}
+
+func (v UserView) includes(relation string) bool {
+	for _, include := range v.Include {
+		if include == relation {
+			return true
+		}
+	}
+	return false
+}
+
+// repositoryFields поля users_db, которые нужно выбрать для представления
+func (v UserView) repositoryFields() []string {
+	fields := v.Fields
+	if fields == nil {
+		fields = append(append([]string{}, UserFields...), FieldAttributes)
+	}
+	fields = append([]string{}, fields...)
+	if v.includes(IncludeStatusDetails) {
+		fields = append(fields, "status_reason", "status_until", "status_changed_at", "status_changed_by")
+	}
+	return fields
+}
+
+// toUserResource переводит пользователя из БД в представление, которое отдаётся клиентам
+func toUserResource(user users_db.UserInfo) user_resource.User {
+	return user_resource.User{
+		Id:               user.ID,
+		Email:            user.Email,
+		Phone:            user.Phone,
+		PhoneCountryCode: user.PhoneCountryCode,
+		PhoneVerified:    user.PhoneVerified,
+		LastName:         user.LastName,
+		FirstName:        user.FirstName,
+		Role:             user.Role,
+		DisplayName:      user.DisplayName,
+		Locale:           user.Locale,
+		Timezone:         user.Timezone,
+		Status:           user.Status,
+		Version:          user.Version,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
+
+// toUserResourceWithAttributes переводит пользователя в представление вместе с атрибутами, которые видны access
+func toUserResourceWithAttributes(user users_db.UserInfo, definitions []attribute_definition.Definition, access user_attributes.Access) user_resource.User {
+	resource := toUserResource(user)
+	resource.Attributes = user_attributes.Visible(definitions, user.Attributes, access)
+	return resource
+}
+
+// visibleAttributes возвращает атрибуты пользователя, которые видны читающему
+func (v UserView) visibleAttributes(user users_db.UserInfo) map[string]interface{} {
+	return user_attributes.Visible(v.Attributes, user.Attributes, user_attributes.AccessFor(v.Viewer, user.ID))
+}
+
+// statusDetails собирает подробности статуса пользователя
+func statusDetails(user users_db.UserInfo) *user_resource.StatusDetails {
+	return &user_resource.StatusDetails{
+		Reason:    user.StatusReason,
+		Until:     user.StatusUntil,
+		ChangedAt: user.StatusChangedAt,
+		ChangedBy: user.StatusChangedBy,
+	}
+}
+
+// getLastLogins возвращает последние успешные входы пользователей в виде DTO
+func getLastLogins(ctx context.Context, loginEventsRepository login_events_db.LoginEventsRepository, userIds []int64) (map[int64]*user_resource.LastLogin, error) {
+	events, err := loginEventsRepository.GetLastLogins(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+	lastLogins := make(map[int64]*user_resource.LastLogin, len(events))
+	for userId, event := range events {
+		lastLogins[userId] = &user_resource.LastLogin{
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			Method:    event.Method,
+			CreatedAt: event.CreatedAt,
+		}
+	}
+	return lastLogins, nil
+}
@@ -0,0 +1,37 @@
+package sms
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Message SMS сообщение
+type Message struct {
+	UserID int64  // Id пользователя, которому адресовано сообщение
+	To     string // Номер получателя в формате E.164
+	Text   string
+}
+
+// Sender — интерфейс отправки SMS.
+// Конкретная реализация (SMS шлюз, очередь сообщений итп) подключается при сборке приложения.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// LogSender реализация Sender, которая только пишет сообщения в лог.
+// Используется локально и в тестах, пока не подключен реальный SMS шлюз.
+type LogSender struct {
+	log *slog.Logger
+}
+
+func NewLogSender(log *slog.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(ctx context.Context, message Message) error {
+	s.log.Info("SMS sent",
+		slog.Int64("user_id", message.UserID),
+		slog.String("to", message.To),
+		slog.String("text", message.Text))
+	return nil
+}
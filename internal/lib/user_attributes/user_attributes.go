@@ -0,0 +1,333 @@
+package user_attributes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/golang-jwt/jwt/v5"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Типы значений атрибутов
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date" // Строка в формате 2006-01-02
+)
+
+// Видимость атрибутов
+const (
+	VisibilityPublic  = "public"  // Видят все, кому виден пользователь
+	VisibilityPrivate = "private" // Видят сам пользователь и администратор
+	VisibilityAdmin   = "admin"   // Видит и меняет только администратор
+)
+
+// FieldPrefix префикс атрибутов в фильтрах и сортировке списка: filter[attributes.department]=...
+const FieldPrefix = "attributes."
+
+// maxStringLength максимальная длина строкового значения атрибута
+const maxStringLength = 1024
+
+// maxSafeInteger наибольшее целое, которое JSON число передаёт без потери точности
+const maxSafeInteger = 1 << 53
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+var ErrInvalidName = errors.New("attribute name must start with a lowercase letter and contain only lowercase letters, digits and underscores")
+var ErrInvalidPattern = errors.New("pattern is not a valid regular expression")
+var ErrRulesNotAllowed = errors.New("enum_values and pattern are allowed only for string attributes")
+
+// Access Права читающего или записывающего атрибуты
+type Access int
+
+const (
+	AccessPublic Access = iota // Любой, кому виден пользователь
+	AccessSelf                 // Сам пользователь
+	AccessAdmin                // Администратор
+)
+
+// Error Ошибка значения атрибута
+type Error struct {
+	Name   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("attribute %s %s", e.Name, e.Reason)
+}
+
+// AsError проверяет, является ли ошибка ошибкой значения атрибута
+func AsError(err error) (*Error, bool) {
+	var attributeErr *Error
+	ok := errors.As(err, &attributeErr)
+	return attributeErr, ok
+}
+
+// ValidName проверяет имя атрибута. Имя попадает в имена индексов, поэтому набор символов ограничен
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// CheckRules проверяет правила значения атрибута типа attributeType
+func CheckRules(attributeType string, enumValues []string, pattern string) error {
+	if attributeType != TypeString && (len(enumValues) > 0 || pattern != "") {
+		return ErrRulesNotAllowed
+	}
+	if pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return ErrInvalidPattern
+		}
+	}
+	return nil
+}
+
+// AccessFor права владельца токена claims на атрибуты пользователя userId.
+// Для запроса без токена (claims nil) возвращает AccessPublic
+func AccessFor(claims jwt.MapClaims, userId int64) Access {
+	switch {
+	case claims == nil:
+		return AccessPublic
+	case authorization.IsAdmin(claims):
+		return AccessAdmin
+	case authorization.CanAccessUser(claims, userId):
+		return AccessSelf
+	default:
+		return AccessPublic
+	}
+}
+
+// ReadableBy возвращает определения атрибутов, которые access может читать
+func ReadableBy(definitions []attribute_definition.Definition, access Access) []attribute_definition.Definition {
+	var readable []attribute_definition.Definition
+	for _, definition := range definitions {
+		if Readable(definition, access) {
+			readable = append(readable, definition)
+		}
+	}
+	return readable
+}
+
+// Readable может ли access читать атрибут
+func Readable(definition attribute_definition.Definition, access Access) bool {
+	switch definition.Visibility {
+	case VisibilityPublic:
+		return true
+	case VisibilityPrivate:
+		return access >= AccessSelf
+	default:
+		return access == AccessAdmin
+	}
+}
+
+// Writable может ли access менять атрибут
+func Writable(definition attribute_definition.Definition, access Access) bool {
+	if definition.Visibility == VisibilityAdmin {
+		return access == AccessAdmin
+	}
+	return access >= AccessSelf
+}
+
+// Validate проверяет значения атрибутов по определениям definitions.
+// Неизвестные атрибуты и атрибуты, которые access не может менять, считаются ошибкой.
+// При partial (JSON Merge Patch) nil удаляет атрибут, а обязательность проверяется только для удаляемых атрибутов,
+// иначе все обязательные атрибуты, которые access может менять, должны быть переданы.
+// Уникальность значений проверяет БД при записи
+func Validate(definitions []attribute_definition.Definition, values map[string]interface{}, access Access, partial bool) error {
+	byName := make(map[string]attribute_definition.Definition, len(definitions))
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+	for name, value := range values {
+		definition, ok := byName[name]
+		if !ok {
+			return &Error{Name: name, Reason: "is not defined"}
+		}
+		if !Writable(definition, access) {
+			return &Error{Name: name, Reason: "cannot be changed"}
+		}
+		if value == nil {
+			if !partial || definition.Required {
+				return &Error{Name: name, Reason: "cannot be removed"}
+			}
+			continue
+		}
+		if err := checkValue(definition, value); err != nil {
+			return err
+		}
+	}
+	if partial {
+		return nil
+	}
+	for _, definition := range definitions {
+		if _, ok := values[definition.Name]; !ok && definition.Required && Writable(definition, access) {
+			return &Error{Name: definition.Name, Reason: "is required"}
+		}
+	}
+	return nil
+}
+
+func checkValue(definition attribute_definition.Definition, value interface{}) error {
+	switch definition.Type {
+	case TypeString:
+		text, ok := value.(string)
+		if !ok {
+			return &Error{Name: definition.Name, Reason: "must be a string"}
+		}
+		if len(text) > maxStringLength {
+			return &Error{Name: definition.Name, Reason: fmt.Sprintf("must be at most %d bytes", maxStringLength)}
+		}
+		if len(definition.EnumValues) > 0 && !slices.Contains(definition.EnumValues, text) {
+			return &Error{Name: definition.Name, Reason: "must be one of the allowed values"}
+		}
+		if definition.Pattern != "" {
+			pattern, err := regexp.Compile(definition.Pattern)
+			if err != nil || !pattern.MatchString(text) {
+				return &Error{Name: definition.Name, Reason: "does not match the pattern"}
+			}
+		}
+	case TypeInteger:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) || math.Abs(number) > maxSafeInteger {
+			return &Error{Name: definition.Name, Reason: "must be an integer"}
+		}
+	case TypeNumber:
+		if _, ok := value.(float64); !ok {
+			return &Error{Name: definition.Name, Reason: "must be a number"}
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return &Error{Name: definition.Name, Reason: "must be a boolean"}
+		}
+	case TypeDate:
+		text, ok := value.(string)
+		if !ok {
+			return &Error{Name: definition.Name, Reason: "must be a date (2006-01-02)"}
+		}
+		if _, err := time.Parse(time.DateOnly, text); err != nil {
+			return &Error{Name: definition.Name, Reason: "must be a date (2006-01-02)"}
+		}
+	}
+	return nil
+}
+
+// FromText приводит строковые значения атрибутов (например, из колонок CSV) к типам их определений:
+// integer и number — к числу, boolean — к true/false. Атрибуты без определения остаются строками, их отклонит Validate
+func FromText(definitions []attribute_definition.Definition, values map[string]interface{}) (map[string]interface{}, error) {
+	byName := make(map[string]attribute_definition.Definition, len(definitions))
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+	converted := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		converted[name] = value
+		text, ok := value.(string)
+		definition, defined := byName[name]
+		if !ok || !defined {
+			continue
+		}
+		switch definition.Type {
+		case TypeInteger:
+			number, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, &Error{Name: name, Reason: "must be an integer"}
+			}
+			converted[name] = number
+		case TypeNumber:
+			number, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, &Error{Name: name, Reason: "must be a number"}
+			}
+			converted[name] = number
+		case TypeBoolean:
+			flag, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, &Error{Name: name, Reason: "must be a boolean"}
+			}
+			converted[name] = flag
+		}
+	}
+	return converted, nil
+}
+
+// Replacement превращает полный набор атрибутов values в JSON Merge Patch: атрибуты, которые access может менять,
+// но которых нет в values, удаляются. Атрибуты, недоступные access, остаются без изменений
+func Replacement(definitions []attribute_definition.Definition, values map[string]interface{}, access Access) map[string]interface{} {
+	patch := make(map[string]interface{}, len(definitions))
+	for _, definition := range definitions {
+		if Writable(definition, access) {
+			patch[definition.Name] = nil
+		}
+	}
+	for name, value := range values {
+		patch[name] = value
+	}
+	return patch
+}
+
+// Visible возвращает атрибуты, определённые в definitions и доступные access для чтения. Если таких нет, возвращает nil
+func Visible(definitions []attribute_definition.Definition, values map[string]interface{}, access Access) map[string]interface{} {
+	var visible map[string]interface{}
+	for _, definition := range definitions {
+		value, ok := values[definition.Name]
+		if !ok || !Readable(definition, access) {
+			continue
+		}
+		if visible == nil {
+			visible = make(map[string]interface{})
+		}
+		visible[definition.Name] = value
+	}
+	return visible
+}
+
+// Indexed возвращает типы индексируемых атрибутов по именам
+func Indexed(definitions []attribute_definition.Definition) map[string]string {
+	indexed := make(map[string]string)
+	for _, definition := range definitions {
+		if definition.Indexed {
+			indexed[definition.Name] = definition.Type
+		}
+	}
+	return indexed
+}
+
+// FilterFields возвращает поля фильтрации списка (FieldPrefix + имя) для индексируемых атрибутов
+func FilterFields(definitions []attribute_definition.Definition) map[string]query_params.FilterField {
+	fields := make(map[string]query_params.FilterField)
+	for name, attributeType := range Indexed(definitions) {
+		var field query_params.FilterField
+		switch attributeType {
+		case TypeInteger:
+			field = query_params.FilterField{Type: query_params.FilterInt, Operators: query_params.OrderedOperators}
+		case TypeNumber:
+			field = query_params.FilterField{Type: query_params.FilterNumber, Operators: query_params.OrderedOperators}
+		case TypeBoolean:
+			field = query_params.FilterField{Type: query_params.FilterBool, Operators: []string{query_params.OpEq, query_params.OpNe}}
+		case TypeDate:
+			// Даты хранятся в формате 2006-01-02, поэтому сравниваются как строки
+			field = query_params.FilterField{Type: query_params.FilterString, Operators: query_params.OrderedOperators}
+		default:
+			field = query_params.FilterField{Type: query_params.FilterString, Operators: query_params.StringOperators}
+		}
+		fields[FieldPrefix+name] = field
+	}
+	return fields
+}
+
+// SortFields возвращает поля сортировки списка (FieldPrefix + имя) для индексируемых атрибутов
+func SortFields(definitions []attribute_definition.Definition) []string {
+	var fields []string
+	for name := range Indexed(definitions) {
+		fields = append(fields, FieldPrefix+name)
+	}
+	slices.Sort(fields)
+	return fields
+}
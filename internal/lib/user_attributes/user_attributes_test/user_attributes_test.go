@@ -0,0 +1,96 @@
+package user_attributes_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var definitions = []attribute_definition.Definition{
+	{Name: "department", Type: user_attributes.TypeString, Required: true, EnumValues: []string{"sales", "it"},
+		Visibility: user_attributes.VisibilityPublic, Indexed: true},
+	{Name: "employee_id", Type: user_attributes.TypeString, Pattern: `^E\d{4}$`, Visibility: user_attributes.VisibilityPrivate},
+	{Name: "grade", Type: user_attributes.TypeInteger, Visibility: user_attributes.VisibilityAdmin},
+	{Name: "hired_on", Type: user_attributes.TypeDate, Visibility: user_attributes.VisibilityPublic},
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		access  user_attributes.Access
+		partial bool
+		wantErr string
+	}{
+		{name: "Valid", values: map[string]interface{}{"department": "it", "employee_id": "E0001", "hired_on": "2024-02-01"},
+			access: user_attributes.AccessSelf},
+		{name: "Required missing", values: map[string]interface{}{"employee_id": "E0001"},
+			access: user_attributes.AccessSelf, wantErr: "attribute department is required"},
+		{name: "Required missing in patch", values: map[string]interface{}{"employee_id": "E0001"},
+			access: user_attributes.AccessSelf, partial: true},
+		{name: "Unknown attribute", values: map[string]interface{}{"department": "it", "team": "core"},
+			access: user_attributes.AccessSelf, wantErr: "attribute team is not defined"},
+		{name: "Not in enum", values: map[string]interface{}{"department": "hr"},
+			access: user_attributes.AccessSelf, wantErr: "attribute department must be one of the allowed values"},
+		{name: "Pattern mismatch", values: map[string]interface{}{"department": "it", "employee_id": "42"},
+			access: user_attributes.AccessSelf, wantErr: "attribute employee_id does not match the pattern"},
+		{name: "Admin attribute by user", values: map[string]interface{}{"department": "it", "grade": float64(3)},
+			access: user_attributes.AccessSelf, wantErr: "attribute grade cannot be changed"},
+		{name: "Admin attribute by admin", values: map[string]interface{}{"department": "it", "grade": float64(3)},
+			access: user_attributes.AccessAdmin},
+		{name: "Not an integer", values: map[string]interface{}{"grade": 2.5},
+			access: user_attributes.AccessAdmin, partial: true, wantErr: "attribute grade must be an integer"},
+		{name: "Invalid date", values: map[string]interface{}{"hired_on": "01.02.2024"},
+			access: user_attributes.AccessSelf, partial: true, wantErr: "attribute hired_on must be a date (2006-01-02)"},
+		{name: "Remove optional in patch", values: map[string]interface{}{"employee_id": nil},
+			access: user_attributes.AccessSelf, partial: true},
+		{name: "Remove required in patch", values: map[string]interface{}{"department": nil},
+			access: user_attributes.AccessSelf, partial: true, wantErr: "attribute department cannot be removed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := user_attributes.Validate(definitions, tt.values, tt.access, tt.partial)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+			_, ok := user_attributes.AsError(err)
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestVisible(t *testing.T) {
+	values := map[string]interface{}{"department": "it", "employee_id": "E0001", "grade": float64(3), "removed": "x"}
+
+	require.Equal(t, map[string]interface{}{"department": "it"},
+		user_attributes.Visible(definitions, values, user_attributes.AccessPublic))
+	require.Equal(t, map[string]interface{}{"department": "it", "employee_id": "E0001"},
+		user_attributes.Visible(definitions, values, user_attributes.AccessSelf))
+	require.Equal(t, map[string]interface{}{"department": "it", "employee_id": "E0001", "grade": float64(3)},
+		user_attributes.Visible(definitions, values, user_attributes.AccessAdmin))
+}
+
+func TestReplacement(t *testing.T) {
+	patch := user_attributes.Replacement(definitions, map[string]interface{}{"department": "sales"}, user_attributes.AccessSelf)
+
+	require.Equal(t, map[string]interface{}{"department": "sales", "employee_id": nil, "hired_on": nil}, patch)
+}
+
+func TestFromText(t *testing.T) {
+	values, err := user_attributes.FromText(definitions, map[string]interface{}{"department": "it", "grade": "3", "unknown": "x"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"department": "it", "grade": float64(3), "unknown": "x"}, values)
+
+	_, err = user_attributes.FromText(definitions, map[string]interface{}{"grade": "three"})
+	require.EqualError(t, err, "attribute grade must be an integer")
+}
+
+func TestListFields(t *testing.T) {
+	require.Equal(t, []string{"attributes.department"}, user_attributes.SortFields(definitions))
+	require.Contains(t, user_attributes.FilterFields(definitions), "attributes.department")
+	require.True(t, user_attributes.ValidName("employee_id"))
+	require.False(t, user_attributes.ValidName("Employee-Id"))
+}
@@ -0,0 +1,71 @@
+package user_status
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Статусы учётной записи пользователя
+const (
+	Pending     = "pending"     // Зарегистрировался без приглашения, ждёт активации администратором (REGISTRATION_REQUIRE_APPROVAL)
+	Active      = "active"      // Обычное состояние, вход разрешён
+	Suspended   = "suspended"   // Приостановлена администратором, возможно до указанного времени
+	Locked      = "locked"      // Заблокирована после неверных паролей (см. auth_service.LockoutConfig) или администратором, возможно до указанного времени
+	Deactivated = "deactivated" // Отключена, вернуть её может только администратор
+)
+
+var ErrUnknownStatus = errors.New("unknown user status")
+var ErrInvalidTransition = errors.New("user status transition is not allowed")
+
+// transitions Допустимые переходы между статусами
+var transitions = map[string][]string{
+	Pending:     {Active, Deactivated},
+	Active:      {Suspended, Locked, Deactivated},
+	Suspended:   {Active, Deactivated},
+	Locked:      {Active, Deactivated},
+	Deactivated: {Active},
+}
+
+// Valid проверяет, что статус существует
+func Valid(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CheckTransition проверяет, можно ли перевести пользователя из статуса from в статус to
+func CheckTransition(from, to string) error {
+	if !Valid(to) {
+		return fmt.Errorf("%w: %s", ErrUnknownStatus, to)
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+}
+
+// AllowedFrom возвращает статусы, из которых можно перейти в статус to
+func AllowedFrom(to string) []string {
+	var from []string
+	for status, allowed := range transitions {
+		for _, target := range allowed {
+			if target == to {
+				from = append(from, status)
+			}
+		}
+	}
+	sort.Strings(from)
+	return from
+}
+
+// Expires может ли статус действовать ограниченное время
+func Expires(status string) bool {
+	return status == Suspended || status == Locked
+}
+
+// CanLogin разрешён ли вход пользователю в этом статусе
+func CanLogin(status string) bool {
+	return status == Active
+}
@@ -0,0 +1,61 @@
+package user_status_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		wantErr error
+	}{
+		{from: user_status.Active, to: user_status.Suspended},
+		{from: user_status.Active, to: user_status.Locked},
+		{from: user_status.Active, to: user_status.Deactivated},
+		{from: user_status.Active, to: user_status.Active, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Suspended, to: user_status.Active},
+		{from: user_status.Suspended, to: user_status.Deactivated},
+		{from: user_status.Suspended, to: user_status.Locked, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Suspended, to: user_status.Suspended, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Locked, to: user_status.Active},
+		{from: user_status.Locked, to: user_status.Deactivated},
+		{from: user_status.Locked, to: user_status.Suspended, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Deactivated, to: user_status.Active},
+		{from: user_status.Deactivated, to: user_status.Suspended, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Deactivated, to: user_status.Locked, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Pending, to: user_status.Active},
+		{from: user_status.Pending, to: user_status.Deactivated},
+		{from: user_status.Pending, to: user_status.Suspended, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Pending, to: user_status.Locked, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Active, to: user_status.Pending, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Deactivated, to: user_status.Pending, wantErr: user_status.ErrInvalidTransition},
+		{from: user_status.Active, to: "archived", wantErr: user_status.ErrUnknownStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" -> "+tt.to, func(t *testing.T) {
+			err := user_status.CheckTransition(tt.from, tt.to)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAllowedFrom(t *testing.T) {
+	assert.Equal(t, []string{user_status.Deactivated, user_status.Locked, user_status.Pending, user_status.Suspended}, user_status.AllowedFrom(user_status.Active))
+	assert.Equal(t, []string{user_status.Active}, user_status.AllowedFrom(user_status.Suspended))
+	assert.Equal(t, []string{user_status.Active, user_status.Locked, user_status.Pending, user_status.Suspended}, user_status.AllowedFrom(user_status.Deactivated))
+	assert.Empty(t, user_status.AllowedFrom(user_status.Pending))
+}
+
+func TestCanLogin(t *testing.T) {
+	assert.True(t, user_status.CanLogin(user_status.Active))
+	for _, status := range []string{user_status.Pending, user_status.Suspended, user_status.Locked, user_status.Deactivated} {
+		assert.False(t, user_status.CanLogin(status), status)
+	}
+}
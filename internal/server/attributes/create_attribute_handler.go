@@ -0,0 +1,71 @@
+package attributes
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/attribute_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/attributes_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// CreateAttributeHandler godoc
+// @Summary Создать атрибут пользователей
+// @Description Добавляет дополнительный атрибут пользователей. Имя: латиница в нижнем регистре, цифры и _, до 40 символов.
+// @Description Типы: string, integer, number, boolean, date (2006-01-02). enum_values и pattern допустимы только для string.
+// @Description Видимость: public — всем, private — самому пользователю и администратору, admin — только администратору (он же единственный, кто может менять атрибут).
+// @Description unique запрещает одинаковые значения у действующих пользователей, indexed разрешает фильтр и сортировку
+// @Description по атрибуту в списке пользователей (filter[attributes.имя], sort=attributes.имя).
+// @Description Имя, тип, unique и indexed после создания не меняются
+// @Tags Attributes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param input body attribute_definition.CreateDefinitionRequest true "Определение атрибута"
+// @Success 201 {object} attribute_definition.Definition
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /admin/attributes [post]
+func CreateAttributeHandler(logger *slog.Logger, attributeRepository attributes_db.AttributeRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/attributes/create_attribute_handler.go/CreateAttributeHandler"
+		log := logger.With(slog.String("op", op))
+
+		var request attribute_definition.CreateDefinitionRequest
+		err := body.DecodeAndValidateJson(r, &request)
+		if err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		definition, err := attribute_service.CreateDefinition(log, attributeRepository, ctx, request)
+		if err != nil {
+			switch {
+			case errors.Is(err, user_attributes.ErrInvalidName), errors.Is(err, user_attributes.ErrInvalidPattern),
+				errors.Is(err, user_attributes.ErrRulesNotAllowed):
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			case errors.Is(err, attributes_db.ErrDefinitionExists), errors.Is(err, attributes_db.ErrValuesNotUnique):
+				resp.RenderResponse(w, r, http.StatusConflict, resp.Error(err.Error()))
+			default:
+				log.Error("Failed to create attribute definition", "err", err)
+				resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while creating attribute"))
+			}
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusCreated, definition)
+	}
+}
@@ -0,0 +1,47 @@
+package attributes
+
+import (
+	"context"
+	"errors"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/attribute_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/attributes_db"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DeleteAttributeHandler godoc
+// @Summary Удалить атрибут пользователей
+// @Description Удаляет атрибут и его значения у всех пользователей
+// @Tags Attributes
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Имя атрибута"
+// @Success 204
+// @Failure 404 {object} response.Response
+// @Router /admin/attributes/{name} [delete]
+func DeleteAttributeHandler(logger *slog.Logger, attributeRepository attributes_db.AttributeRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/attributes/delete_attribute_handler.go/DeleteAttributeHandler"
+		log := logger.With(slog.String("op", op))
+
+		name := chi.URLParam(r, "name")
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		err := attribute_service.DeleteDefinition(log, attributeRepository, ctx, name)
+		if err != nil {
+			if errors.Is(err, attributes_db.ErrDefinitionNotFound) {
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("Attribute not found"))
+				return
+			}
+			log.Error("Failed to delete attribute definition", "err", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while deleting attribute"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusNoContent, nil)
+	}
+}
@@ -0,0 +1,37 @@
+package attributes
+
+import (
+	"context"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/attribute_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/attributes_db"
+	_ "github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// GetAttributesHandler godoc
+// @Summary Получить определения атрибутов пользователей
+// @Description Возвращает все дополнительные атрибуты пользователей с их типами, правилами и видимостью
+// @Tags Attributes
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} attribute_definition.DefinitionsList
+// @Router /admin/attributes [get]
+func GetAttributesHandler(logger *slog.Logger, attributeRepository attributes_db.AttributeRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/attributes/get_attributes_handler.go/GetAttributesHandler"
+		log := logger.With(slog.String("op", op))
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		definitions, err := attribute_service.ListDefinitions(log, attributeRepository, ctx)
+		if err != nil {
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting attributes"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, definitions)
+	}
+}
@@ -0,0 +1,69 @@
+package attributes
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/attribute_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/attributes_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// UpdateAttributeHandler godoc
+// @Summary Изменить атрибут пользователей
+// @Description Меняет обязательность, допустимые значения, шаблон и видимость атрибута.
+// @Description Новые правила проверяются при следующих изменениях пользователей, сохранённые значения не перепроверяются
+// @Tags Attributes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Имя атрибута"
+// @Param input body attribute_definition.UpdateDefinitionRequest true "Правила атрибута"
+// @Success 200 {object} attribute_definition.Definition
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /admin/attributes/{name} [put]
+func UpdateAttributeHandler(logger *slog.Logger, attributeRepository attributes_db.AttributeRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/attributes/update_attribute_handler.go/UpdateAttributeHandler"
+		log := logger.With(slog.String("op", op))
+
+		name := chi.URLParam(r, "name")
+		var request attribute_definition.UpdateDefinitionRequest
+		err := body.DecodeAndValidateJson(r, &request)
+		if err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		definition, err := attribute_service.UpdateDefinition(log, attributeRepository, ctx, name, request)
+		if err != nil {
+			switch {
+			case errors.Is(err, attributes_db.ErrDefinitionNotFound):
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("Attribute not found"))
+			case errors.Is(err, user_attributes.ErrInvalidPattern), errors.Is(err, user_attributes.ErrRulesNotAllowed):
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			default:
+				log.Error("Failed to update attribute definition", "err", err)
+				resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while updating attribute"))
+			}
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, definition)
+	}
+}
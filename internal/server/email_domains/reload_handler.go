@@ -0,0 +1,37 @@
+package email_domains
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	email_domains_dto "github.com/ShlykovPavel/users-microservice/models/email_domains"
+	"log/slog"
+	"net/http"
+)
+
+// ReloadHandler godoc
+// @Summary Перезагрузить списки доменов email
+// @Description Перечитывает из файлов списки разрешённых, запрещённых доменов и доменов одноразовой почты.
+// @Description Если файл прочитать не удалось, продолжают действовать ранее загруженные списки
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} email_domains.ReloadResponse
+// @Router /admin/email-domains/reload [post]
+func ReloadHandler(logger *slog.Logger, domainRules *email_domains.Rules) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/email_domains/reload_handler.go/ReloadHandler"
+		log := logger.With(slog.String("op", op))
+
+		stats, err := domainRules.Reload()
+		if err != nil {
+			log.Error("Failed to reload email domain lists", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Failed to reload email domain lists"))
+			return
+		}
+		log.Info("Email domain lists reloaded", "allowed", stats.Allowed, "denied", stats.Denied, "disposable", stats.Disposable)
+		resp.RenderResponse(w, r, http.StatusOK, email_domains_dto.ReloadResponse{
+			Response: resp.OK(),
+			Domains:  stats,
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package invitations
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/invitation_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
+	"github.com/ShlykovPavel/users-microservice/models/invitations"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// CreateInvitationHandler godoc
+// @Summary Создать приглашение
+// @Description Создаёт приглашение на регистрацию с заданной ролью и сроком действия. Токен приглашения возвращается только в этом ответе
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param input body invitations.CreateInvitationRequest true "Данные приглашения"
+// @Success 201 {object} invitations.CreateInvitationResponse
+// @Router /admin/invitations [post]
+func CreateInvitationHandler(logger *slog.Logger, invitationRepository invitations_db.InvitationRepository, notifier notifications.Notifier,
+	defaultTTL time.Duration, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/invitations/create_invitation_handler.go/CreateInvitationHandler"
+		log := logger.With(slog.String("op", op))
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil {
+			log.Error("Failed to retrieve claims from context", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+			return
+		}
+		adminId, err := authorization.GetUserID(claims)
+		if err != nil {
+			log.Error("Failed to retrieve admin id from token", "error", err)
+			resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var request invitations.CreateInvitationRequest
+		err = body.DecodeAndValidateJson(r, &request)
+		if err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		result, err := invitation_service.CreateInvitation(log, invitationRepository, notifier, ctx, request, adminId, defaultTTL)
+		if err != nil {
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while creating invitation"))
+			return
+		}
+		result.Response = resp.OK()
+		resp.RenderResponse(w, r, http.StatusCreated, result)
+	}
+}
@@ -0,0 +1,58 @@
+package invitations
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/invitation_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
+	_ "github.com/ShlykovPavel/users-microservice/models/invitations"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// GetInvitationsHandler godoc
+// @Summary Получить список приглашений
+// @Description Возвращает постранично все приглашения, включая использованные и отозванные
+// @Tags Invitations
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Номер страницы"
+// @Param limit query int false "Количество записей на странице (1-100)"
+// @Param sort query string false "Сортировка: поля через запятую, - для desc, суффиксы :nulls_first/:nulls_last (id, created_at, expires_at)" example(expires_at,-created_at)
+// @Success 200 {object} invitations.InvitationsList
+// @Failure 400 {object} response.Response
+// @Router /admin/invitations [get]
+func GetInvitationsHandler(logger *slog.Logger, invitationRepository invitations_db.InvitationRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/invitations/get_invitations_handler.go/GetInvitationsHandler"
+		log := logger.With(slog.String("op", op))
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		requestQuery := r.URL.Query()
+
+		queryParser := &query_params.DefaultSortParser{
+			ValidSortFields: []string{"id", "created_at", "expires_at"},
+		}
+		parsedQuery, err := query_params.ParseStandardQueryParams(requestQuery, log, queryParser)
+		if errors.Is(err, query_params.ErrInvalidSort) {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка парсинга параметров", "error", err, "request", requestQuery)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid request parameters"))
+			return
+		}
+
+		list, err := invitation_service.GetInvitations(log, invitationRepository, ctx, parsedQuery)
+		if err != nil {
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting invitations"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, list)
+	}
+}
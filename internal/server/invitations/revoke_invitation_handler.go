@@ -0,0 +1,53 @@
+package invitations
+
+import (
+	"context"
+	"errors"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/invitation_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RevokeInvitationHandler godoc
+// @Summary Отозвать приглашение
+// @Description Отзывает ещё не использованное приглашение
+// @Tags Invitations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID приглашения"
+// @Success 204
+// @Router /admin/invitations/{id} [delete]
+func RevokeInvitationHandler(logger *slog.Logger, invitationRepository invitations_db.InvitationRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/invitations/revoke_invitation_handler.go/RevokeInvitationHandler"
+		log := logger.With(slog.String("op", op))
+
+		invitationID := chi.URLParam(r, "id")
+		id, err := strconv.ParseInt(invitationID, 10, 64)
+		if err != nil {
+			log.Error("Invitation ID is invalid", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid invitation ID"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		err = invitation_service.RevokeInvitation(log, invitationRepository, ctx, id)
+		if err != nil {
+			if errors.Is(err, invitations_db.ErrInvitationNotFound) {
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("Invitation not found or already used"))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while revoking invitation"))
+			return
+		}
+		log.Info("Revoked invitation", "invitation_id", id)
+		resp.RenderResponse(w, r, http.StatusNoContent, nil)
+	}
+}
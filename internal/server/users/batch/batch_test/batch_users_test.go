@@ -0,0 +1,84 @@
+package batch_test
+
+import (
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/batch"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	if err := validators.InitValidator(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestBatchUsersHandlerRole(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		setupMock    func(*users_db_mock.MockUserRepository)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Unknown role in set_role",
+			body:         `{"operations":[{"op":"set_role","id":1,"role":"superuser"}]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"code":"validation_failed"`,
+		},
+		{
+			name:         "Unknown role in create",
+			body:         `{"operations":[{"op":"create","role":"root","data":{"first_name":"Ivan","last_name":"Petrov","email":"ivan@example.com","password":"secret","phone":"+79512345678"}}]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"code":"validation_failed"`,
+		},
+		{
+			name:         "Unknown role rejects the whole batch",
+			body:         `{"operations":[{"op":"set_role","id":1,"role":"admin"},{"op":"set_role","id":2,"role":"owner"}]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"code":"validation_failed"`,
+		},
+		{
+			name: "Known role",
+			body: `{"operations":[{"op":"set_role","id":1,"role":"admin"}]}`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("PatchUser", mock.Anything, int64(1), users_db.AnyVersion, map[string]interface{}{"role": "admin"}).
+					Return(users_db.UserInfo{ID: 1, Role: "admin", Version: 2}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"succeeded":1`,
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			if test.setupMock != nil {
+				test.setupMock(userRepository)
+			}
+			handler := batch.BatchUsersHandler(logger, userRepository, nil, time.Second)
+
+			req := httptest.NewRequest(http.MethodPost, "/users/batch", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			require.Contains(t, w.Body.String(), test.expectedBody)
+			// При неизвестной роли ни одна операция пакета не выполняется
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
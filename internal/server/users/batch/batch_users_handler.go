@@ -0,0 +1,120 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/batch_users"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// successStatuses HTTP статусы успешных операций
+var successStatuses = map[string]int{
+	batch_users.OpCreate:  http.StatusCreated,
+	batch_users.OpPatch:   http.StatusOK,
+	batch_users.OpSetRole: http.StatusOK,
+	batch_users.OpDelete:  http.StatusNoContent,
+}
+
+// BatchUsersHandler godoc
+// @Summary Пакетные операции над пользователями
+// @Description Выполняет до 200 операций create, patch, delete и set_role одним запросом. Доступно только администратору.
+// @Description У каждой операции свой результат с HTTP статусом, который вернул бы отдельный запрос.
+// @Description При atomic=true операции выполняются в одной транзакции: первая ошибка отменяет весь пакет,
+// @Description ответ 422 (или 5xx при ошибке сервера), выполненные операции получают статус 424 и ошибку "operation rolled back",
+// @Description невыполненные — 424 и "operation not executed". Без atomic ответ всегда 200.
+// @Description email в patch не меняется: смена email требует подтверждения и выполняется через PATCH /users/{id}
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param input body batch_users.BatchRequest true "Операции"
+// @Success 200 {object} batch_users.BatchResponse
+// @Failure 400 {object} response.Response
+// @Failure 422 {object} batch_users.BatchResponse
+// @Router /users/batch [post]
+func BatchUsersHandler(logger *slog.Logger, userDbRepository users_db.UserRepository, attributeSchema user_service.AttributeSchema,
+	timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/batch/batch_users_handler.go/BatchUsersHandler"
+		log := logger.With(slog.String("op", op))
+
+		var request batch_users.BatchRequest
+		if err := body.DecodeAndValidateJson(r, &request); err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		// Создание пользователей хеширует пароли, поэтому пакет может выполняться дольше обычного запроса
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			log.Warn("Failed to extend write deadline", "error", err)
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		results := user_service.ExecuteBatch(log, userDbRepository, attributeSchema, ctx, request)
+		response := batch_users.BatchResponse{
+			Atomic:  request.Atomic,
+			Results: make([]batch_users.BatchOperationResult, len(results)),
+		}
+		status := http.StatusOK
+		for i, result := range results {
+			operationResult := batch_users.BatchOperationResult{Index: i, Op: request.Operations[i].Op, User: result.User}
+			if result.Err == nil {
+				operationResult.Status = successStatuses[operationResult.Op]
+				response.Succeeded++
+			} else {
+				operationResult.Status, operationResult.Error = operationError(log, result.Err)
+				response.Failed++
+				if request.Atomic && (status == http.StatusOK || operationResult.Status >= http.StatusInternalServerError) {
+					status = http.StatusUnprocessableEntity
+					if operationResult.Status >= http.StatusInternalServerError {
+						status = operationResult.Status
+					}
+				}
+			}
+			response.Results[i] = operationResult
+		}
+		log.Info("Batch executed", "atomic", request.Atomic, "succeeded", response.Succeeded, "failed", response.Failed)
+		resp.RenderResponse(w, r, status, response)
+	}
+}
+
+// operationError возвращает HTTP статус и текст ошибки операции
+func operationError(log *slog.Logger, err error) (int, string) {
+	var validationErrors validator.ValidationErrors
+	var attributeErr *user_attributes.Error
+	switch {
+	case errors.As(err, &validationErrors):
+		return http.StatusBadRequest, resp.ValidationError(validationErrors).Error
+	case errors.As(err, &attributeErr):
+		return http.StatusBadRequest, attributeErr.Error()
+	case errors.Is(err, user_service.ErrBatchRolledBack), errors.Is(err, user_service.ErrBatchNotExecuted):
+		return http.StatusFailedDependency, err.Error()
+	case errors.Is(err, user_service.ErrBatchMissingId), errors.Is(err, user_service.ErrBatchMissingRole),
+		errors.Is(err, user_service.ErrBatchInvalidData), errors.Is(err, user_service.ErrBatchEmailChange),
+		errors.Is(err, users_db.ErrEmailAlreadyExists), errors.Is(err, users_db.ErrPhoneAlreadyExists), errors.Is(err, users_db.ErrAttributeNotUnique):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, users_db.ErrUserNotFound):
+		return http.StatusNotFound, "User not found"
+	case errors.Is(err, users_db.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, "User was modified by another request"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "Request timed out or canceled"
+	default:
+		log.Error("Batch operation failed", "err", err)
+		return http.StatusInternalServerError, "Something went wrong"
+	}
+}
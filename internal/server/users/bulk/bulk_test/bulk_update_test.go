@@ -0,0 +1,207 @@
+package bulk_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/confirmation"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/bulk"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/bulk_update"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const bulkPath = "/users/bulk-update?status=active"
+
+func TestMain(m *testing.M) {
+	if err := validators.InitValidator(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newRouter подключает массовое обновление от имени администратора adminId
+func newRouter(userRepository users_db.UserRepository, confirmationTTL time.Duration, adminId string) http.Handler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	bulkUpdater := user_service.BulkUpdater{UserRepository: userRepository, Confirmations: confirmation.NewCodec("secret", confirmationTTL)}
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.MapClaims{"sub": adminId, "user_role": "admin"}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)))
+		})
+	})
+	router.Post("/users/bulk-update", bulk.BulkUpdateHandler(logger, bulkUpdater, time.Second))
+	return router
+}
+
+// activeUsers параметры выборки пользователей по фильтру status=active
+var activeUsers = mock.MatchedBy(func(params users_db.UserListParams) bool {
+	return len(params.Statuses) == 1 && params.Statuses[0] == "active" && !params.IncludeDeleted
+})
+
+// roleUpdate изменение роли на role от имени администратора 1
+func roleUpdate(role string) interface{} {
+	return mock.MatchedBy(func(update users_db.BulkUpdate) bool {
+		return update.Role != nil && *update.Role == role && update.Status == nil && update.AdminId == 1
+	})
+}
+
+func post(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, bulkPath, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// preview выполняет предпросмотр повышения до admin и возвращает токен подтверждения
+func preview(t *testing.T, router http.Handler, userRepository *users_db_mock.MockUserRepository, matched int64) string {
+	userRepository.On("CountBulkUpdate", mock.Anything, activeUsers, roleUpdate("admin")).Return(matched, nil).Once()
+	w := post(router, `{"patch":{"role":"admin"},"dry_run":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var result bulk_update.BulkUpdatePreview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Equal(t, matched, result.Matched)
+	require.NotEmpty(t, result.ConfirmationToken)
+	return result.ConfirmationToken
+}
+
+// readProgress разбирает строки NDJSON прогресса
+func readProgress(t *testing.T, w *httptest.ResponseRecorder) []bulk_update.BulkUpdateProgress {
+	var lines []bulk_update.BulkUpdateProgress
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var line bulk_update.BulkUpdateProgress
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestBulkUpdateHandlerRejectsRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "Unknown role", body: `{"patch":{"role":"superuser"},"dry_run":true}`,
+			expectedCode: http.StatusBadRequest, expectedBody: `"code":"validation_failed"`},
+		{name: "Unknown status", body: `{"patch":{"status":"archived"},"dry_run":true}`,
+			expectedCode: http.StatusBadRequest, expectedBody: `"code":"validation_failed"`},
+		{name: "Empty patch", body: `{"patch":{},"dry_run":true}`,
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"patch must contain role or status"`},
+		{name: "Missing confirmation token", body: `{"patch":{"role":"admin"}}`,
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"confirmation_token is required, run the request with dry_run=true first"`},
+		{name: "Forged confirmation token", body: `{"patch":{"role":"admin"},"confirmation_token":"eyJoIjoiIn0.c2lnbmF0dXJl"}`,
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"confirmation token is invalid"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+
+			w := post(newRouter(userRepository, time.Minute, "1"), test.body)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			require.Contains(t, w.Body.String(), test.expectedBody)
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBulkUpdateHandlerStreamsProgress(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	router := newRouter(userRepository, time.Minute, "1")
+	token := preview(t, router, userRepository, 1200)
+	userRepository.On("CountBulkUpdate", mock.Anything, activeUsers, roleUpdate("admin")).Return(int64(1200), nil).Once()
+	userRepository.On("BulkUpdateUsers", mock.Anything, activeUsers, roleUpdate("admin"), 500).
+		Return(int64(1200), nil, []int64{500, 1000, 1200}).Once()
+
+	w := post(router, `{"patch":{"role":"admin"},"confirmation_token":"`+token+`"}`)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	require.Equal(t, []bulk_update.BulkUpdateProgress{
+		{Matched: 1200},
+		{Matched: 1200, Updated: 500},
+		{Matched: 1200, Updated: 1000},
+		{Matched: 1200, Updated: 1200},
+		{Matched: 1200, Updated: 1200, Done: true},
+	}, readProgress(t, w))
+	userRepository.AssertExpectations(t)
+}
+
+func TestBulkUpdateHandlerReportsFailedBatch(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	router := newRouter(userRepository, time.Minute, "1")
+	token := preview(t, router, userRepository, 1200)
+	userRepository.On("CountBulkUpdate", mock.Anything, activeUsers, roleUpdate("admin")).Return(int64(1200), nil).Once()
+	userRepository.On("BulkUpdateUsers", mock.Anything, activeUsers, roleUpdate("admin"), 500).
+		Return(int64(500), errors.New("connection lost"), []int64{500}).Once()
+
+	w := post(router, `{"patch":{"role":"admin"},"confirmation_token":"`+token+`"}`)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Equal(t, []bulk_update.BulkUpdateProgress{
+		{Matched: 1200},
+		{Matched: 1200, Updated: 500},
+		{Matched: 1200, Updated: 500, Error: "bulk update stopped: connection lost"},
+	}, readProgress(t, w))
+	userRepository.AssertExpectations(t)
+}
+
+func TestBulkUpdateHandlerRejectsConfirmation(t *testing.T) {
+	tests := []struct {
+		name            string
+		confirmationTTL time.Duration
+		confirmAdminId  string
+		confirmBody     string // %s заменяется токеном из предпросмотра
+		matched         int64  // Количество пользователей при подтверждении, 0 — подсчёт не выполняется
+		expectedCode    int
+		expectedBody    string
+	}{
+		{name: "Expired token", confirmationTTL: -time.Second, confirmAdminId: "1",
+			confirmBody:  `{"patch":{"role":"admin"},"confirmation_token":"%s"}`,
+			expectedCode: http.StatusConflict, expectedBody: `"error":"confirmation token has expired, run the preview again"`},
+		{name: "Token of another admin", confirmationTTL: time.Minute, confirmAdminId: "2",
+			confirmBody:  `{"patch":{"role":"admin"},"confirmation_token":"%s"}`,
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"confirmation token was issued for another request"`},
+		{name: "Token for another patch", confirmationTTL: time.Minute, confirmAdminId: "1",
+			confirmBody:  `{"patch":{"role":"user"},"confirmation_token":"%s"}`,
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"confirmation token was issued for another request"`},
+		{name: "Outdated preview", confirmationTTL: time.Minute, confirmAdminId: "1",
+			confirmBody: `{"patch":{"role":"admin"},"confirmation_token":"%s"}`, matched: 1201,
+			expectedCode: http.StatusConflict, expectedBody: `"error":"users matching the filter changed since the preview, run the preview again"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			token := preview(t, newRouter(userRepository, test.confirmationTTL, "1"), userRepository, 1200)
+			if test.matched != 0 {
+				userRepository.On("CountBulkUpdate", mock.Anything, activeUsers, roleUpdate("admin")).Return(test.matched, nil).Once()
+			}
+
+			w := post(newRouter(userRepository, test.confirmationTTL, test.confirmAdminId), strings.Replace(test.confirmBody, "%s", token, 1))
+
+			require.Equal(t, test.expectedCode, w.Code)
+			require.Contains(t, w.Body.String(), test.expectedBody)
+			userRepository.AssertExpectations(t)
+			userRepository.AssertNotCalled(t, "BulkUpdateUsers", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
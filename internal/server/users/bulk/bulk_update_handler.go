@@ -0,0 +1,155 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/confirmation"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/export_writer"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user/get_user_list"
+	"github.com/ShlykovPavel/users-microservice/models/users/bulk_update"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// BulkUpdateHandler godoc
+// @Summary Массово обновить пользователей по фильтру
+// @Description Меняет роль и/или статус всех пользователей, подходящих под search, ids, status и filter[...] из query (как в GET /users).
+// @Description Доступно только администратору. Удалённые пользователи и сам администратор не меняются,
+// @Description при смене статуса меняются только пользователи, для которых переход допустим.
+// @Description С dry_run=true возвращает количество пользователей и confirmation_token.
+// @Description Без dry_run требуется confirmation_token, выданный этому же администратору для тех же query и patch; если количество пользователей
+// @Description изменилось с момента предпросмотра, возвращается 409.
+// @Description Обновление выполняется пачками, прогресс отдаётся в формате NDJSON (строка после каждой пачки),
+// @Description последняя строка содержит done=true или error. Уже обновлённые пачки при ошибке не откатываются
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param input body bulk_update.BulkUpdateRequest true "Изменения"
+// @Param search query string false "Поисковый запрос"
+// @Param status query string false "Фильтр по статусам через запятую"
+// @Param filter[email][suffix] query string false "Пример: пользователи с email на домене (@contractor.com)"
+// @Param filter[created_at][lt] query string false "Пример: созданные раньше даты (RFC3339 или 2006-01-02)"
+// @Success 200 {object} bulk_update.BulkUpdatePreview "Предпросмотр (dry_run)"
+// @Success 202 {object} bulk_update.BulkUpdateProgress "Строки прогресса"
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /users/bulk-update [post]
+func BulkUpdateHandler(logger *slog.Logger, bulkUpdater user_service.BulkUpdater, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/bulk/bulk_update_handler.go/BulkUpdateHandler"
+		log := logger.With(slog.String("op", op))
+		requestQuery := r.URL.Query()
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil {
+			log.Error("Failed to retrieve claims from context", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+			return
+		}
+		adminId, err := authorization.GetUserID(claims)
+		if err != nil {
+			log.Error("Failed to retrieve admin id from token", "error", err)
+			resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+			return
+		}
+
+		definitions, err := user_service.AttributeDefinitions(r.Context(), bulkUpdater.AttributeSchema)
+		if err != nil {
+			log.Error("Failed to get attribute definitions", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+			return
+		}
+		parsedQuery, filter, err := get_user_list.ParseListQuery(requestQuery, log, definitions)
+		if err != nil {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		var request bulk_update.BulkUpdateRequest
+		if err = body.DecodeAndValidateJson(r, &request); err != nil {
+			log.Error("Failed decoding body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		if request.DryRun {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			preview, err := bulkUpdater.Preview(log, ctx, requestQuery, parsedQuery, filter, request.Patch, adminId)
+			if err != nil {
+				renderError(w, r, log, err)
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusOK, preview)
+			return
+		}
+
+		// Обновление больших выборок идёт дольше обычного запроса, поэтому дедлайн записи продлевается до таймаута обновления
+		controller := http.NewResponseController(w)
+		if err = controller.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			log.Warn("Failed to extend write deadline", "error", err)
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		plan, err := bulkUpdater.Confirm(log, ctx, requestQuery, parsedQuery, filter, request.Patch, adminId, request.ConfirmationToken)
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", export_writer.ContentType(export_writer.FormatNDJSON))
+		w.WriteHeader(http.StatusAccepted)
+		encoder := json.NewEncoder(w)
+		writeProgress := func(progress bulk_update.BulkUpdateProgress) error {
+			if err := encoder.Encode(progress); err != nil {
+				return err
+			}
+			return controller.Flush()
+		}
+		if err = writeProgress(bulk_update.BulkUpdateProgress{Matched: plan.Matched}); err != nil {
+			log.Warn("Failed to write bulk update progress", "error", err)
+			return
+		}
+		updated, err := bulkUpdater.Run(log, ctx, plan, func(updated int64) error {
+			return writeProgress(bulk_update.BulkUpdateProgress{Matched: plan.Matched, Updated: updated})
+		})
+		result := bulk_update.BulkUpdateProgress{Matched: plan.Matched, Updated: updated, Done: err == nil}
+		if err != nil {
+			result.Error = "bulk update stopped: " + err.Error()
+		}
+		if err = writeProgress(result); err != nil {
+			log.Warn("Failed to write bulk update result", "error", err)
+		}
+	}
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, user_service.ErrBulkPatchEmpty), errors.Is(err, user_service.ErrStatusReasonRequired),
+		errors.Is(err, user_service.ErrStatusUntilNotAllowed), errors.Is(err, user_service.ErrStatusUntilInPast),
+		errors.Is(err, user_service.ErrConfirmationRequired), errors.Is(err, query_params.ErrInvalidFilter),
+		errors.Is(err, confirmation.ErrInvalidToken), errors.Is(err, confirmation.ErrTokenMismatch):
+		resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+	case errors.Is(err, confirmation.ErrTokenExpired), errors.Is(err, user_service.ErrBulkPreviewOutdated):
+		resp.RenderResponse(w, r, http.StatusConflict, resp.Error(err.Error()))
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
+	default:
+		log.Error("Bulk update failed", "err", err)
+		resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while updating users"))
+	}
+}
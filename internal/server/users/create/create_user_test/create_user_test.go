@@ -7,8 +7,13 @@ import (
 	"fmt"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
 	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/registration"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
 	users "github.com/ShlykovPavel/users-microservice/internal/server/users/create"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
 	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/mock"
@@ -20,49 +25,49 @@ import (
 	"time"
 )
 
-type MockUserRepository struct {
+type MockInvitationRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) CreateUser(ctx context.Context, userinfo *create_user.UserCreate) (int64, error) {
-	args := m.Called(ctx, userinfo)
+func (m *MockInvitationRepository) CreateInvitation(ctx context.Context, invitation *invitations_db.Invitation) (int64, error) {
+	args := m.Called(ctx, invitation)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockUserRepository) GetUser(ctx context.Context, userId int64) (users_db.UserInfo, error) {
-	args := m.Called(ctx, userId)
-	return args.Get(0).(users_db.UserInfo), args.Error(1)
+func (m *MockInvitationRepository) GetInvitations(ctx context.Context, limit, offset int, sortParams []query_params.SortParam) (invitations_db.InvitationListResult, error) {
+	args := m.Called(ctx, limit, offset, sortParams)
+	return args.Get(0).(invitations_db.InvitationListResult), args.Error(1)
 }
 
-func (m *MockUserRepository) CheckAdminInDB(ctx context.Context) (users_db.UserInfo, error) {
-	args := m.Called(ctx)
-	return args.Get(0).(users_db.UserInfo), args.Error(1)
-}
-func (m *MockUserRepository) AddFirstAdmin(ctx context.Context, passwordHash string) error {
-	args := m.Called(ctx, passwordHash)
-	return args.Error(1)
+func (m *MockInvitationRepository) RevokeInvitation(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
-func (m *MockUserRepository) GetUserList(ctx context.Context, search string, limit, offset int, sortParams []query_params.SortParam) (users_db.UserListResult, error) {
-	args := m.Called(ctx)
-	return args.Get(0).(users_db.UserListResult), args.Error(1)
+
+func (m *MockInvitationRepository) ConsumeInvitation(ctx context.Context, tokenHash, email string) (invitations_db.Invitation, error) {
+	args := m.Called(ctx, tokenHash, email)
+	return args.Get(0).(invitations_db.Invitation), args.Error(1)
 }
 
-func (m *MockUserRepository) UpdateUser(ctx context.Context, id int64, firstName, lastName, email, phone, role string) error {
-	args := m.Called(ctx, id, firstName, lastName, email, phone, role)
+func (m *MockInvitationRepository) ReleaseInvitation(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
-func (m *MockUserRepository) DeleteUser(ctx context.Context, id int64) error {
-	args := m.Called(ctx, id)
+
+func (m *MockInvitationRepository) SetConsumedBy(ctx context.Context, id, userId int64) error {
+	args := m.Called(ctx, id, userId)
 	return args.Error(0)
 }
 
 func TestCreateUser(t *testing.T) {
 	tests := []struct {
-		testName       string
-		input          create_user.UserCreate
-		setupMock      func(*MockUserRepository)
-		expectedStatus int
-		expectedBody   string
+		testName        string
+		mode            string
+		requireApproval bool
+		input           create_user.UserCreate
+		setupMock       func(*users_db_mock.MockUserRepository, *MockInvitationRepository)
+		expectedStatus  int
+		expectedBody    string
 	}{
 		{
 			testName: "success creating user",
@@ -73,13 +78,13 @@ func TestCreateUser(t *testing.T) {
 				Password:  "password",
 				Phone:     "+78951235678",
 			},
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
 				mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *create_user.UserCreate) bool {
-					return u.Email == "ryanGosling@gmail.com" && u.FirstName == "Ryan"
-				})).Return(int64(123), nil).Once()
+					return u.Email == "ryanGosling@gmail.com" && u.FirstName == "Ryan" && u.Status == user_status.Active
+				})).Return(users_db.UserInfo{ID: 123, Email: "ryanGosling@gmail.com", FirstName: "Ryan", Role: "user", Version: 1}, nil).Once()
 			},
 			expectedStatus: http.StatusCreated,
-			expectedBody:   `{"status":"OK","id":123}`,
+			expectedBody:   `{"id":123,"email":"ryanGosling@gmail.com",`,
 		},
 		{
 			testName: "wrong type in field",
@@ -90,11 +95,11 @@ func TestCreateUser(t *testing.T) {
 				Password:  "password",
 				Phone:     "+78951235678",
 			},
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
 				//	Не настраиваем мок так как будет ошибка
 			},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"status":"ERROR","error":"field FirstName is required"}`,
+			expectedBody:   `{"status":"ERROR","error":"field FirstName is required","code":"validation_failed","fields":[{"field":"FirstName","code":"field_required"}]}`,
 		},
 		{
 			testName: "email already exists",
@@ -105,12 +110,12 @@ func TestCreateUser(t *testing.T) {
 				Password:  "password",
 				Phone:     "+78951235678",
 			},
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
 				mockRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*create_user.UserCreate")).
-					Return(int64(0), users_db.ErrEmailAlreadyExists).Once()
+					Return(users_db.UserInfo{}, users_db.ErrEmailAlreadyExists).Once()
 			},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"status":"ERROR","error":"Пользователь с email уже существует. "}`,
+			expectedBody:   `{"status":"ERROR","error":"user with this email already exists","code":"email_exists"}`,
 		},
 		{
 			testName: "timeout error",
@@ -121,31 +126,168 @@ func TestCreateUser(t *testing.T) {
 				Password:  "password",
 				Phone:     "+78951235678",
 			},
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
 				mockRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*create_user.UserCreate")).
 					Run(func(args mock.Arguments) {
 						time.Sleep(6 * time.Second) // Задержка больше таймаута (5 секунд)
 					}).
-					Return(int64(0), context.DeadlineExceeded).Once()
+					Return(users_db.UserInfo{}, context.DeadlineExceeded).Once()
 			},
 			expectedStatus: http.StatusGatewayTimeout,
-			expectedBody:   `{"status":"ERROR","error":"Request timed out or canceled"}`,
+			expectedBody:   `{"status":"ERROR","error":"Request timed out or canceled","code":"timeout"}`,
+		},
+		{
+			testName: "registration closed",
+			mode:     registration.ModeClosed,
+			input: create_user.UserCreate{
+				FirstName: "Ryan",
+				LastName:  "Gosling",
+				Email:     "ryanGosling@gmail.com",
+				Password:  "password",
+				Phone:     "78951235678",
+			},
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
+				// Регистрация выключена, до репозитория не доходим
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"ERROR","error":"registration is closed","code":"registration_closed"}`,
+		},
+		{
+			testName: "invite only without invitation",
+			mode:     registration.ModeInviteOnly,
+			input: create_user.UserCreate{
+				FirstName: "Ryan",
+				LastName:  "Gosling",
+				Email:     "ryanGosling@gmail.com",
+				Password:  "password",
+				Phone:     "78951235678",
+			},
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
+				// Без приглашения до репозитория не доходим
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"ERROR","error":"registration is allowed by invitation only","code":"registration_invitation_required"}`,
+		},
+		{
+			testName: "invite only with invitation",
+			mode:     registration.ModeInviteOnly,
+			input: create_user.UserCreate{
+				FirstName:       "Ryan",
+				LastName:        "Gosling",
+				Email:           "ryanGosling@gmail.com",
+				Password:        "password",
+				Phone:           "78951235678",
+				InvitationToken: "invitation-token",
+			},
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
+				invitationRepo.On("ConsumeInvitation", mock.Anything, mock.AnythingOfType("string"), "ryanGosling@gmail.com").
+					Return(invitations_db.Invitation{ID: 5, Role: "admin"}, nil).Once()
+				mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *create_user.UserCreate) bool {
+					return u.Role == "admin"
+				})).Return(users_db.UserInfo{ID: 124, Role: "admin", Version: 1}, nil).Once()
+				invitationRepo.On("SetConsumedBy", mock.Anything, int64(5), int64(124)).Return(nil).Once()
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `"role":"admin"`,
+		},
+		{
+			testName:        "registration awaits approval",
+			requireApproval: true,
+			input: create_user.UserCreate{
+				FirstName: "Ryan",
+				LastName:  "Gosling",
+				Email:     "ryanGosling@gmail.com",
+				Password:  "password",
+				Phone:     "78951235678",
+			},
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
+				mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *create_user.UserCreate) bool {
+					return u.Status == user_status.Pending
+				})).Return(users_db.UserInfo{ID: 125, Role: "user", Status: user_status.Pending, Version: 1}, nil).Once()
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `"status":"pending"`,
+		},
+		{
+			testName:        "invited user skips approval",
+			requireApproval: true,
+			input: create_user.UserCreate{
+				FirstName:       "Ryan",
+				LastName:        "Gosling",
+				Email:           "ryanGosling@gmail.com",
+				Password:        "password",
+				Phone:           "78951235678",
+				InvitationToken: "invitation-token",
+			},
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
+				invitationRepo.On("ConsumeInvitation", mock.Anything, mock.AnythingOfType("string"), "ryanGosling@gmail.com").
+					Return(invitations_db.Invitation{ID: 5, Role: "user"}, nil).Once()
+				mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *create_user.UserCreate) bool {
+					return u.Status == user_status.Active
+				})).Return(users_db.UserInfo{ID: 126, Role: "user", Status: user_status.Active, Version: 1}, nil).Once()
+				invitationRepo.On("SetConsumedBy", mock.Anything, int64(5), int64(126)).Return(nil).Once()
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `"status":"active"`,
+		},
+		{
+			testName: "invalid invitation",
+			mode:     registration.ModeInviteOnly,
+			input: create_user.UserCreate{
+				FirstName:       "Ryan",
+				LastName:        "Gosling",
+				Email:           "ryanGosling@gmail.com",
+				Password:        "password",
+				Phone:           "78951235678",
+				InvitationToken: "expired-token",
+			},
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
+				invitationRepo.On("ConsumeInvitation", mock.Anything, mock.AnythingOfType("string"), "ryanGosling@gmail.com").
+					Return(invitations_db.Invitation{}, invitations_db.ErrInvitationInvalid).Once()
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"ERROR","error":"invitation is invalid or expired","code":"invitation_invalid"}`,
+		},
+		{
+			testName: "disposable email",
+			input: create_user.UserCreate{
+				FirstName: "Ryan",
+				LastName:  "Gosling",
+				Email:     "ryan@mailinator.com",
+				Password:  "password",
+				Phone:     "78951235678",
+			},
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository, invitationRepo *MockInvitationRepository) {
+				// Домен отклоняется до обращения к репозиторию
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"ERROR","error":"disposable email addresses are not allowed","code":"email_disposable"}`,
 		},
 	}
 
 	if err := validators.InitValidator(); err != nil {
 		fmt.Println("Failed to initialize validator")
 	}
+	domainRules, err := email_domains.NewRules(email_domains.Config{BlockDisposableDomains: true})
+	require.NoError(t, err)
 	for _, test := range tests {
 		t.Run(test.testName, func(t *testing.T) {
 			logger := slog.Default()
 			timeout := 5 * time.Second
-			mockRepo := new(MockUserRepository)
+			mockRepo := new(users_db_mock.MockUserRepository)
+			invitationRepo := new(MockInvitationRepository)
+			mode := test.mode
+			if mode == "" {
+				mode = registration.ModeOpen
+			}
+			policy, err := registration.NewPolicy(mode, nil)
+			require.NoError(t, err)
+			policy.RequireApproval = test.requireApproval
 
-			handler := users.CreateUser(logger, mockRepo, timeout)
+			handler := users.CreateUser(logger, mockRepo, invitationRepo, nil, policy, domainRules, timeout)
 
 			// Настраиваем мок
-			test.setupMock(mockRepo)
+			test.setupMock(mockRepo, invitationRepo)
 
 			// Создаём запрос
 			body, _ := json.Marshal(test.input)
@@ -161,12 +303,13 @@ func TestCreateUser(t *testing.T) {
 
 			// Проверяем тело ответа
 			var respBody map[string]interface{}
-			err := json.Unmarshal(w.Body.Bytes(), &respBody)
+			err = json.Unmarshal(w.Body.Bytes(), &respBody)
 			require.NoError(t, err, "response should be valid JSON")
 			require.Contains(t, w.Body.String(), test.expectedBody, "unexpected response body")
 
 			// Проверяем, что все ожидаемые вызовы мока выполнены
 			mockRepo.AssertExpectations(t)
+			invitationRepo.AssertExpectations(t)
 		})
 	}
 
@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
 	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
-	users "github.com/ShlykovPavel/users-microservice/internal/server/users"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/registration"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/invitations_db"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
 	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
+	_ "github.com/ShlykovPavel/users-microservice/models/users/user_resource"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator"
 	"log/slog"
@@ -17,12 +23,15 @@ import (
 
 // CreateUser godoc
 // @Summary Создать пользователя
-// @Description Регистрирует пользователя в системе
+// @Description Регистрирует пользователя в системе. В зависимости от режима регистрации может потребоваться токен приглашения.
+// @Description attributes проверяются по определениям атрибутов, атрибуты с видимостью admin при регистрации не принимаются
 // @Tags Users
 // @Param input body create_user.UserCreate true "Данные пользователя"
-// @Success 201 {object} create_user.CreateUserResponse
+// @Success 201 {object} user_resource.User
+// @Failure 403 {object} response.Response
 // @Router /register [post]
-func CreateUser(log *slog.Logger, userRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
+func CreateUser(log *slog.Logger, userRepository users_db.UserRepository, invitationRepository invitations_db.InvitationRepository,
+	attributeSchema user_service.AttributeSchema, policy registration.Policy, domainRules *email_domains.Rules, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "server/users.CreateUser"
 		log = log.With(
@@ -45,24 +54,34 @@ func CreateUser(log *slog.Logger, userRepository users_db.UserRepository, timeou
 			return
 		}
 
-		//	Хешируем пароль
-		passwordHash, err := users.HashUserPassword(user.Password, log)
-		if err != nil {
-			log.Error("Error while hashing password", "err", err)
-			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error(err.Error()))
-			return
-		}
-
-		user.Password = passwordHash
 		//Записываем в бд
-		userId, err := userRepository.CreateUser(ctx, &user)
+		createdUser, err := user_service.RegisterUser(log, userRepository, invitationRepository, attributeSchema, policy, domainRules, ctx, &user)
 		if err != nil {
 			log.Error("Error while creating user", "err", err)
-			if errors.Is(err, users_db.ErrEmailAlreadyExists) {
+			if errors.Is(err, users_db.ErrEmailAlreadyExists) || errors.Is(err, users_db.ErrPhoneAlreadyExists) ||
+				errors.Is(err, users_db.ErrAttributeNotUnique) {
 				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(
 					err.Error()))
 				return
 			}
+			if attributeErr, ok := user_attributes.AsError(err); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(attributeErr.Error()))
+				return
+			}
+			if errors.Is(err, registration.ErrRegistrationClosed) ||
+				errors.Is(err, registration.ErrInvitationRequired) ||
+				errors.Is(err, registration.ErrDomainNotAllowed) {
+				resp.RenderResponse(w, r, http.StatusForbidden, resp.Error(err.Error()))
+				return
+			}
+			if domainErr, ok := email_domains.AsDomainError(err); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ErrorWithCode(domainErr.Code, domainErr.Message))
+				return
+			}
+			if errors.Is(err, invitations_db.ErrInvitationInvalid) {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+				return
+			}
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				log.Warn("Request canceled or timed out", slog.Any("err", err))
 				resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
@@ -72,10 +91,8 @@ func CreateUser(log *slog.Logger, userRepository users_db.UserRepository, timeou
 			return
 		}
 
-		log.Info("Created user", "user id", userId)
-		resp.RenderResponse(w, r, http.StatusCreated, create_user.CreateUserResponse{
-			resp.OK(),
-			userId,
-		})
+		log.Info("Created user", "user id", createdUser.Id)
+		w.Header().Set("ETag", etag.Format(createdUser.Version))
+		resp.RenderResponse(w, r, http.StatusCreated, createdUser)
 	}
 }
@@ -3,6 +3,8 @@ package users_delete
 import (
 	"context"
 	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
 	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
@@ -15,13 +17,20 @@ import (
 
 // DeleteUserHandler godoc
 // @Summary Удалить пользователя
-// @Description Удалить пользователя по ID
+// @Description Удалить пользователя по ID. Пользователь удаляется мягко: его можно восстановить,
+// @Description пока не истёк срок хранения удалённых пользователей. Требует заголовок If-Match с ETag, полученным из GET /users/{id}.
+// @Description Доступно самому пользователю и администратору
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "ID пользователя"
+// @Param If-Match header string true "ETag пользователя"
 // @Success 204
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 428 {object} response.Response
 // @Router /users/{id} [delete]
 func DeleteUserHandler(logger *slog.Logger, userDbRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -39,11 +48,38 @@ func DeleteUserHandler(logger *slog.Logger, userDbRepository users_db.UserReposi
 			return
 		}
 
+		version, err := etag.ParseIfMatch(r)
+		if err != nil {
+			log.Debug("Precondition is missing or invalid", "err", err)
+			if errors.Is(err, etag.ErrPreconditionRequired) {
+				resp.RenderResponse(w, r, http.StatusPreconditionRequired, resp.Error(err.Error()))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil {
+			log.Error("Failed to retrieve claims from context", "error", err)
+			resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+			return
+		}
+		if !authorization.CanAccessUser(claims, id) {
+			log.Debug("Access to delete user denied", "user_id", id)
+			resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Forbidden"))
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
 
-		err = user_service.DeleteUser(logger, userDbRepository, ctx, id)
+		err = user_service.DeleteUser(logger, userDbRepository, ctx, id, version)
 		if err != nil {
+			if errors.Is(err, users_db.ErrVersionMismatch) {
+				resp.RenderResponse(w, r, http.StatusPreconditionFailed, resp.Error("User was modified by another request"))
+				return
+			}
 			if errors.Is(err, users_db.ErrUserNotFound) {
 				log.Error("User not found", "error", err)
 				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
@@ -54,7 +90,7 @@ func DeleteUserHandler(logger *slog.Logger, userDbRepository users_db.UserReposi
 			return
 		}
 		log.Info("Deleted user", "userID", userID)
-		resp.RenderResponse(w, r, http.StatusNoContent, nil)
+		w.WriteHeader(http.StatusNoContent)
 	}
 
 }
@@ -0,0 +1,112 @@
+package delete_user_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	users_delete "github.com/ShlykovPavel/users-microservice/internal/server/users/delete"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+const userId = int64(7)
+
+func TestDeleteUserHandler(t *testing.T) {
+	admin := jwt.MapClaims{"sub": "1", "user_role": "admin"}
+
+	tests := []struct {
+		name         string
+		claims       jwt.MapClaims // nil — запрос без токена
+		ifMatch      string
+		setupMock    func(*users_db_mock.MockUserRepository)
+		expectedCode int
+	}{
+		{
+			name:    "Delete own account",
+			claims:  jwt.MapClaims{"sub": "7", "user_role": "user"},
+			ifMatch: `"3"`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("DeleteUser", mock.Anything, userId, int64(3)).Return(nil).Once()
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:    "Admin deletes any version",
+			claims:  admin,
+			ifMatch: "*",
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("DeleteUser", mock.Anything, userId, users_db.AnyVersion).Return(nil).Once()
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Anonymous",
+			ifMatch:      `"3"`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Other user",
+			claims:       jwt.MapClaims{"sub": "8", "user_role": "user"},
+			ifMatch:      `"3"`,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Without If-Match",
+			claims:       admin,
+			expectedCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "Invalid If-Match",
+			claims:       admin,
+			ifMatch:      "3",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:    "Stale version",
+			claims:  admin,
+			ifMatch: `"3"`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("DeleteUser", mock.Anything, userId, int64(3)).Return(users_db.ErrVersionMismatch).Once()
+			},
+			expectedCode: http.StatusPreconditionFailed,
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			if test.setupMock != nil {
+				test.setupMock(userRepository)
+			}
+			router := chi.NewRouter()
+			router.Delete("/users/{id}", users_delete.DeleteUserHandler(logger, userRepository, time.Second))
+
+			req := httptest.NewRequest(http.MethodDelete, "/users/7", nil)
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+			if test.claims != nil {
+				req = req.WithContext(context.WithValue(req.Context(), authorization.TokenClaimsKey, test.claims))
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedCode == http.StatusNoContent {
+				require.Empty(t, w.Body.String())
+			}
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
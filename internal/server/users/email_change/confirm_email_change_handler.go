@@ -0,0 +1,68 @@
+package email_change
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/email_changes_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
+	"github.com/ShlykovPavel/users-microservice/models/users/email_change"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ConfirmEmailChangeHandler godoc
+// @Summary Подтвердить смену email
+// @Description Подтверждает смену email по токену, отправленному на новый адрес
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param input body email_change.ConfirmEmailChangeRequest true "Токен подтверждения"
+// @Success 200 {object} create_user.CreateUserResponse
+// @Failure 400 {object} response.Response
+// @Router /users/email-change/confirm [post]
+func ConfirmEmailChangeHandler(logger *slog.Logger, emailChanger user_service.EmailChanger, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/email_change/confirm_email_change_handler.go/ConfirmEmailChangeHandler"
+		log := logger.With(slog.String("op", op))
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var request email_change.ConfirmEmailChangeRequest
+		err := body.DecodeAndValidateJson(r, &request)
+		if err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		change, err := emailChanger.ConfirmEmailChange(log, ctx, request.Token)
+		if err != nil {
+			if errors.Is(err, email_changes_db.ErrEmailChangeInvalid) || errors.Is(err, users_db.ErrEmailAlreadyExists) {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+				return
+			}
+			if errors.Is(err, users_db.ErrUserNotFound) {
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+				return
+			}
+			log.Error("Failed to confirm email change", "err", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while confirming email change"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, create_user.CreateUserResponse{
+			Response: resp.OK(),
+			UserID:   change.UserID,
+		})
+	}
+}
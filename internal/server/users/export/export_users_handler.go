@@ -0,0 +1,93 @@
+package export
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/export_writer"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user/get_user_list"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/metrics"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ExportUsersHandler godoc
+// @Summary Выгрузить пользователей
+// @Description Выгружает всех пользователей без ограничения limit в CSV или NDJSON. Доступно только администратору.
+// @Description Поддерживает те же search, sort, filter[...], status и include_deleted, что и GET /users.
+// @Description columns задаёт колонки выгрузки (id, first_name, last_name, email, phone, role, status, version, created_at, updated_at, deleted_at), по умолчанию все, кроме deleted_at.
+// @Description Строки отправляются по мере чтения из БД, поэтому ошибка в середине выгрузки обрывает ответ
+// @Tags Users
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param format query string false "Формат выгрузки" Enums(csv, ndjson) default(csv)
+// @Param columns query string false "Колонки через запятую" example(id,email,status)
+// @Param search query string false "Поисковый запрос"
+// @Param sort query string false "Сортировка" example(-created_at)
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response
+// @Router /users/export [get]
+func ExportUsersHandler(logger *slog.Logger, userDbRepository users_db.UserRepository, attributeSchema user_service.AttributeSchema,
+	metrics *metrics.Metrics, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/export/export_users_handler.go/ExportUsersHandler"
+		log := logger.With(slog.String("op", op))
+		requestQuery := r.URL.Query()
+
+		format := requestQuery.Get("format")
+		if format == "" {
+			format = export_writer.FormatCSV
+		}
+		if format != export_writer.FormatCSV && format != export_writer.FormatNDJSON {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(export_writer.ErrUnknownFormat.Error()))
+			return
+		}
+		columns, err := query_params.ParseFieldList(requestQuery, "columns", user_service.ExportColumns)
+		if err != nil {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		if columns == nil {
+			columns = user_service.UserFields
+		}
+		definitions, err := user_service.AttributeDefinitions(r.Context(), attributeSchema)
+		if err != nil {
+			log.Error("Failed to get attribute definitions", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while exporting users"))
+			return
+		}
+		parsedQuery, filter, err := get_user_list.ParseListQuery(requestQuery, log, definitions)
+		if err != nil {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		// Выгрузка дольше обычного запроса, поэтому дедлайн записи ответа продлевается до её таймаута
+		if err = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			log.Warn("Failed to extend write deadline", "error", err)
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		w.Header().Set("Content-Type", export_writer.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="users.`+format+`"`)
+		writer, err := export_writer.New(format, w, columns)
+		if err != nil {
+			log.Error("Failed to start users export", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while exporting users"))
+			return
+		}
+
+		exported, err := user_service.ExportUsers(log, userDbRepository, ctx, parsedQuery, filter, columns, writer)
+		metrics.UsersExportedTotal.WithLabelValues(format).Add(float64(exported))
+		if err != nil {
+			// Заголовки и часть строк уже отправлены, сообщить об ошибке можно только оборвав ответ
+			log.Error("Users export failed", "error", err, "exported", exported)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
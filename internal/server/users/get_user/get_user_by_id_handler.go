@@ -2,11 +2,16 @@ package get_user
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/fieldset"
 	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
-	"github.com/ShlykovPavel/users-microservice/models/users/get_user_by_id"
+	_ "github.com/ShlykovPavel/users-microservice/models/users/user_resource"
 	"github.com/go-chi/chi/v5"
 	"log/slog"
 	"net/http"
@@ -16,14 +21,26 @@ import (
 
 // GetUserById godoc
 // @Summary Получить пользователя по ID
-// @Description Получить детальную информацию о пользователе
+// @Description Получить детальную информацию о пользователе.
+// @Description ETag зависит от версии пользователя и от ответа (fields, include, видимые запрашивающему атрибуты),
+// @Description при совпадении с If-None-Match возвращается 304. ETag подходит для If-Match в PUT и PATCH.
+// @Description fields ограничивает набор полей ответа (id, first_name, last_name, email, phone, role, status, version, created_at, updated_at, attributes), id возвращается всегда.
+// @Description attributes содержит только атрибуты, видимые запрашивающему: private — самому пользователю и администратору, admin — администратору.
+// @Description include добавляет связанные данные (last_login, status_details), доступно самому пользователю и администратору
 // @Tags Users
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "ID пользователя"
-// @Success 200 {object} get_user_by_id.UserInfo
+// @Param If-None-Match header string false "ETag, полученный ранее"
+// @Param fields query string false "Поля через запятую" example(id,first_name,email)
+// @Param include query string false "Связанные данные через запятую" example(last_login)
+// @Success 200 {object} user_resource.User
+// @Success 304
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Router /users/{id} [get]
-func GetUserById(logger *slog.Logger, userDbRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
+func GetUserById(logger *slog.Logger, userDbRepository users_db.UserRepository, loginEventsRepository login_events_db.LoginEventsRepository,
+	attributeSchema user_service.AttributeSchema, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "server/users/get_user/get_user_by_id_handler.go./GetUserById"
 		log := logger.With(slog.String("op", op))
@@ -40,10 +57,33 @@ func GetUserById(logger *slog.Logger, userDbRepository users_db.UserRepository,
 			return
 		}
 
+		view, err := user_service.ParseUserView(r.URL.Query())
+		if err != nil {
+			log.Debug("Invalid fields or include", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		claims, _ := authorization.GetClaims(r.Context())
+		if len(view.Include) > 0 {
+			if claims == nil || !authorization.CanAccessUser(claims, id) {
+				log.Debug("Related user data requested without access", "id", id)
+				resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Forbidden"))
+				return
+			}
+		}
+
 		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
 
-		userInfo, err := user_service.GetUser(logger, userDbRepository, id, ctx)
+		view.Viewer = claims
+		view.Attributes, err = user_service.AttributeDefinitions(ctx, attributeSchema)
+		if err != nil {
+			log.Error("Error while getting attribute definitions", "err", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting user"))
+			return
+		}
+
+		userInfo, err := user_service.GetUser(logger, userDbRepository, loginEventsRepository, id, ctx, view)
 		if err != nil {
 			if errors.Is(err, users_db.ErrUserNotFound) {
 				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
@@ -53,14 +93,33 @@ func GetUserById(logger *slog.Logger, userDbRepository users_db.UserRepository,
 			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting user"))
 			return
 		}
+		var body interface{} = userInfo
+		if view.Fields != nil {
+			body, err = fieldset.Select(userInfo, append(view.Fields, view.Include...))
+			if err != nil {
+				log.Error("Error while selecting user fields", "err", err)
+				resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting user"))
+				return
+			}
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			log.Error("Error while encoding user", "err", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting user"))
+			return
+		}
+
+		// Видимые атрибуты и include зависят от токена
+		w.Header().Add("Vary", "Authorization")
+		tag := etag.ForRepresentation(userInfo.Version, data)
+		w.Header().Set("ETag", tag)
+		if etag.NoneMatch(r, tag) {
+			log.Debug("User not modified", "id", id)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 		log.Debug("Successful get user by id", "user", userInfo)
-		resp.RenderResponse(w, r, http.StatusOK, get_user_by_id.UserInfo{
-			Email:     userInfo.Email,
-			Phone:     userInfo.Phone,
-			LastName:  userInfo.LastName,
-			FirstName: userInfo.FirstName,
-		})
-		return
+		resp.RenderResponse(w, r, http.StatusOK, body)
 
 	}
 }
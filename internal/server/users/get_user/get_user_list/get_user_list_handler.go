@@ -2,11 +2,18 @@ package get_user_list
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/cursor"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/fieldset"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
 	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
-	_ "github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
+	"github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
 	"log/slog"
 	"net/http"
 	"time"
@@ -14,13 +21,50 @@ import (
 
 // GetUserList godoc
 // @Summary Получить список пользователей
-// @Description Получить список пользователей
+// @Description Получить список пользователей.
+// @Description Удалённые пользователи возвращаются только администратору с include_deleted=true.
+// @Description ids=1,2,3 выбирает пользователей по списку id (до 100), без limit и page все они возвращаются одной страницей.
+// @Description status принимает список статусов через запятую (pending, active, suspended, locked, deactivated).
+// @Description Фильтры: filter[поле]=значение (равенство) или filter[поле][оператор]=значение.
+// @Description Операторы: eq, ne, gt, gte, lt, lte, in (значения через запятую), contains, prefix, suffix.
+// @Description Поля: id (eq, ne, gt, gte, lt, lte, in); first_name, last_name, email, phone (eq, ne, in, contains, prefix, suffix);
+// @Description role, status (eq, ne, in); created_at, updated_at (gt, gte, lt, lte).
+// @Description По индексируемым атрибутам (indexed) доступны фильтры filter[attributes.имя] и сортировка sort=attributes.имя:
+// @Description всем — по атрибутам с видимостью public, администратору — по всем. При сортировке по атрибуту курсоры не выдаются.
+// @Description Для больших списков вместо page/offset используйте cursor из meta.next_cursor/meta.prev_cursor.
+// @Description count управляет подсчётом total: exact (по умолчанию), estimated (оценка по статистике таблицы), none.
+// @Description sort задаёт сортировку полями через запятую в порядке приоритета: "-" перед полем — по убыванию,
+// @Description суффиксы :nulls_first/:nulls_last — положение пустых значений. Поля: id, first_name, last_name, email, created_at.
+// @Description К сортировке всегда добавляется id, что б порядок был однозначным.
+// @Description search ищет по имени, фамилии, email и телефону с учётом опечаток, каждое слово запроса должно совпасть.
+// @Description Без sort результаты поиска упорядочены по релевантности (поле score), курсоры для такого порядка не выдаются.
+// @Description fields ограничивает набор полей пользователей (id, first_name, last_name, email, phone, role, status, version, created_at, updated_at, attributes), id возвращается всегда,
+// @Description include добавляет связанные данные (last_login, status_details), доступно только администратору
 // @Tags Users
 // @Produce json
 // @Security BearerAuth
+// @Param limit query int false "Размер страницы (1-100)"
+// @Param page query int false "Номер страницы"
+// @Param offset query int false "Смещение"
+// @Param cursor query string false "Курсор страницы (нельзя совмещать с page и offset)"
+// @Param search query string false "Поисковый запрос"
+// @Param fields query string false "Поля через запятую" example(id,first_name,last_name)
+// @Param include query string false "Связанные данные через запятую" example(last_login)
+// @Param count query string false "Режим подсчёта total" Enums(exact, estimated, none)
+// @Param sort query string false "Сортировка" example(-created_at,last_name)
+// @Param include_deleted query bool false "Включить удалённых пользователей (только для администратора)"
+// @Param ids query string false "Список id через запятую" example(1,2,3)
+// @Param status query string false "Фильтр по статусам через запятую"
+// @Param filter[role] query string false "Фильтр по полю: filter[поле]=значение или filter[поле][оператор]=значение"
+// @Param filter[email][suffix] query string false "Пример: пользователи с email на домене (@corp.com)"
+// @Param filter[created_at][gte] query string false "Пример: созданные не раньше даты (RFC3339 или 2006-01-02)"
+// @Param filter[id][in] query string false "Пример: значения через запятую"
+// @Failure 400 {object} response.Response
 // @Success 200 {object} get_users_list.UsersList
+// @Failure 403 {object} response.Response
 // @Router /users [get]
-func GetUserList(logger *slog.Logger, userDbRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
+func GetUserList(logger *slog.Logger, userDbRepository users_db.UserRepository, loginEventsRepository login_events_db.LoginEventsRepository,
+	attributeSchema user_service.AttributeSchema, cursorCodec *cursor.Codec, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal/server/users/get_user/get_user_list/get_user_list_handler.go/get_user_list"
 		log := logger.With(slog.String("op", op))
@@ -29,24 +73,88 @@ func GetUserList(logger *slog.Logger, userDbRepository users_db.UserRepository,
 		defer cancel()
 		requestQuery := r.URL.Query()
 
-		queryParser := &query_params.DefaultSortParser{
-			ValidSortFields: []string{"id", "first_name", "last_name", "email"},
+		claims, _ := authorization.GetClaims(r.Context())
+		definitions, err := user_service.AttributeDefinitions(ctx, attributeSchema)
+		if err != nil {
+			log.Error("Error while getting attribute definitions", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting user list"))
+			return
+		}
+		// Фильтровать и сортировать можно только по атрибутам, которые видны всем пользователям списка
+		listAccess := user_attributes.AccessPublic
+		if claims != nil && authorization.IsAdmin(claims) {
+			listAccess = user_attributes.AccessAdmin
+		}
+		parsedQuery, filter, err := ParseListQuery(requestQuery, log, user_attributes.ReadableBy(definitions, listAccess))
+		if err != nil {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		page := user_service.UserListPage{
+			Cursor:    requestQuery.Get("cursor"),
+			CountMode: requestQuery.Get("count"),
+		}
+		if page.Cursor != "" && (requestQuery.Has("page") || requestQuery.Has("offset")) {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("cursor cannot be combined with page or offset"))
+			return
 		}
-		parsedQuery, err := query_params.ParseStandardQueryParams(requestQuery, log, queryParser)
+		switch page.CountMode {
+		case "", users_db.CountExact, users_db.CountEstimated, users_db.CountNone:
+		default:
+			log.Error("Invalid count mode", "count", page.CountMode)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("count must be one of exact, estimated, none"))
+			return
+		}
+
+		view, err := user_service.ParseUserView(requestQuery)
 		if err != nil {
-			log.Error("Ошибка парсинга параметров", "error", err, "request", requestQuery)
-			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Ошибка параметров запроса"))
+			log.Debug("Invalid fields or include", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
 			return
 		}
+		if filter.IncludeDeleted || len(view.Include) > 0 {
+			if listAccess != user_attributes.AccessAdmin {
+				log.Debug("Deleted users or related data requested without admin privileges")
+				resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Forbidden"))
+				return
+			}
+		}
 
-		userList, err := user_service.GetUserList(log, userDbRepository, ctx, parsedQuery)
+		view.Viewer = claims
+		view.Attributes = definitions
+		userList, err := user_service.GetUserList(log, userDbRepository, loginEventsRepository, cursorCodec, ctx, parsedQuery, filter, page, view)
+		if errors.Is(err, query_params.ErrInvalidFilter) {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		if errors.Is(err, cursor.ErrInvalidCursor) || errors.Is(err, users_db.ErrInvalidKeyset) {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(cursor.ErrInvalidCursor.Error()))
+			return
+		}
 		if err != nil {
 			log.Error("Error while getting user list", "error", err)
 			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting user list"))
 			return
 		}
 		log.Debug("Successful get users list")
-		resp.RenderResponse(w, r, http.StatusOK, userList)
+		if view.Fields == nil {
+			resp.RenderResponse(w, r, http.StatusOK, userList)
+			return
+		}
+		// Служебные поля score и deleted_at возвращаются всегда, когда заполнены
+		keys := append(append(view.Fields, view.Include...), "score", "deleted_at")
+		users := make([]map[string]json.RawMessage, 0, len(userList.Users))
+		for _, user := range userList.Users {
+			selected, err := fieldset.Select(user, keys)
+			if err != nil {
+				log.Error("Error while selecting user fields", "error", err)
+				resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting user list"))
+				return
+			}
+			users = append(users, selected)
+		}
+		resp.RenderResponse(w, r, http.StatusOK, get_users_list.SparseUsersList{Users: users, Meta: userList.Meta})
 		return
 
 	}
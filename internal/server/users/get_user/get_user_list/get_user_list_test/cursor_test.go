@@ -0,0 +1,56 @@
+package get_user_list_test
+
+import (
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/cursor"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user/get_user_list"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestGetUserListCursorWithNullCreatedAt граница страницы с пустым created_at попадает в курсор как NULL,
+// и следующая страница продолжается от неё, а не от нулевой даты
+func TestGetUserListCursorWithNullCreatedAt(t *testing.T) {
+	codec := cursor.NewCodec("secret")
+	createdAtSort := []query_params.SortParam{{Field: "created_at", Order: "asc"}, {Field: "id", Order: "asc"}}
+
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+		return params.Keyset == nil
+	})).Return(users_db.UserListResult{
+		Users: []users_db.UserInfo{
+			{ID: 4, FirstName: "Ivan", CreatedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+			{ID: 8, FirstName: "Maria"},
+		},
+		HasMore: true,
+	}, nil).Once()
+	handler := get_user_list.GetUserList(slog.New(slog.NewTextHandler(os.Stdout, nil)), userRepository, nil, nil, codec, time.Second)
+
+	w := listRequest(handler, url.Values{"sort": {"created_at"}, "limit": {"2"}})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	firstPage := decodeList(t, w)
+	nextCursor, err := codec.Decode(firstPage.Meta.NextCursor)
+	require.NoError(t, err)
+	require.Equal(t, createdAtSort, nextCursor.Sort)
+	require.Equal(t, []interface{}{nil, json.Number("8")}, nextCursor.Values)
+
+	userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+		return params.Keyset != nil && params.Keyset.Values[0] == nil && params.Keyset.Values[1] == json.Number("8")
+	})).Return(users_db.UserListResult{Users: []users_db.UserInfo{{ID: 9, FirstName: "Petr"}}}, nil).Once()
+
+	w = listRequest(handler, url.Values{"cursor": {firstPage.Meta.NextCursor}, "limit": {"2"}})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, int64(9), decodeList(t, w).Users[0].Id)
+	userRepository.AssertExpectations(t)
+}
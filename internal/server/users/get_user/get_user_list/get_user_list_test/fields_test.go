@@ -0,0 +1,151 @@
+package get_user_list_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user/get_user_list"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+type MockLoginEventsRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginEventsRepository) AddLoginEvent(ctx context.Context, event *login_events_db.LoginEvent) (int64, error) {
+	args := m.Called(ctx, event)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetKnownDevice(ctx context.Context, userId int64, userAgent, ipNetwork string) (login_events_db.KnownDevice, error) {
+	args := m.Called(ctx, userId, userAgent, ipNetwork)
+	return args.Get(0).(login_events_db.KnownDevice), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetLoginEvents(ctx context.Context, userId int64, limit, offset int, sortParams []query_params.SortParam) (login_events_db.LoginEventsListResult, error) {
+	args := m.Called(ctx, userId, limit, offset, sortParams)
+	return args.Get(0).(login_events_db.LoginEventsListResult), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetLastLogins(ctx context.Context, userIds []int64) (map[int64]login_events_db.LoginEvent, error) {
+	args := m.Called(ctx, userIds)
+	return args.Get(0).(map[int64]login_events_db.LoginEvent), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) CountFailedLogins(ctx context.Context, userId int64, since time.Time) (int, error) {
+	args := m.Called(ctx, userId, since)
+	return args.Int(0), args.Error(1)
+}
+
+// listUsers возвращает пользователей из ответа в виде JSON объектов, что б проверить набор полей
+func listUsers(t *testing.T, w *httptest.ResponseRecorder) []map[string]json.RawMessage {
+	var list struct {
+		Users []map[string]json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	return list.Users
+}
+
+func TestGetUserListFields(t *testing.T) {
+	loginAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	users := []users_db.UserInfo{
+		{ID: 3, FirstName: "Ivan", LastName: "Petrov", Email: "ivan@example.com", Role: "user"},
+		{ID: 4, FirstName: "Maria", LastName: "Ivanova", Email: "maria@example.com", Role: "admin"},
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		role         string
+		fields       []string // Поля, которые хендлер запрашивает у репозитория
+		lastLogin    bool
+		expectedCode int
+		expectedKeys []string // Поля каждого пользователя в ответе
+		expectedBody string
+	}{
+		{name: "Sparse fieldset", query: "?fields=id,first_name,email", role: "user",
+			fields: []string{"id", "first_name", "email"}, expectedCode: http.StatusOK,
+			expectedKeys: []string{"id", "first_name", "email"}},
+		{name: "id is always returned", query: "?fields=last_name", role: "user",
+			fields: []string{"id", "last_name"}, expectedCode: http.StatusOK,
+			expectedKeys: []string{"id", "last_name"}},
+		{name: "Include last login", query: "?fields=email&include=last_login", role: "admin",
+			fields: []string{"id", "email"}, lastLogin: true, expectedCode: http.StatusOK,
+			expectedKeys: []string{"id", "email", "last_login"}},
+		{name: "Unknown field", query: "?fields=id,password", role: "admin",
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"invalid fields: fields value password is not supported"`},
+		{name: "Empty field", query: "?fields=id,,email", role: "admin",
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"invalid fields: fields contains an empty value"`},
+		{name: "Unknown include", query: "?include=roles", role: "admin",
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"invalid fields: include value roles is not supported"`},
+		{name: "Include without admin privileges", query: "?include=last_login", role: "user",
+			expectedCode: http.StatusForbidden},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			loginEventsRepository := new(MockLoginEventsRepository)
+			if test.fields != nil {
+				userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+					return slices.Equal(test.fields, params.Fields)
+				})).Return(users_db.UserListResult{Users: users}, nil).Once()
+			}
+			if test.lastLogin {
+				// Последний вход запрашивается одним запросом для всей страницы, у пользователя 4 входов нет
+				loginEventsRepository.On("GetLastLogins", mock.Anything, []int64{3, 4}).Return(map[int64]login_events_db.LoginEvent{
+					3: {IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0", Method: "password", CreatedAt: loginAt},
+				}, nil).Once()
+			}
+			handler := get_user_list.GetUserList(logger, userRepository, loginEventsRepository, nil, nil, time.Second)
+
+			req := httptest.NewRequest(http.MethodGet, "/users"+test.query, nil)
+			claims := jwt.MapClaims{"sub": "1", "user_role": test.role}
+			req = req.WithContext(context.WithValue(req.Context(), authorization.TokenClaimsKey, claims))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.Contains(t, w.Body.String(), test.expectedBody)
+			}
+			if test.expectedKeys != nil {
+				listed := listUsers(t, w)
+				require.Len(t, listed, len(users))
+				require.Equal(t, json.RawMessage("3"), listed[0]["id"])
+				for i, user := range listed {
+					keys := make([]string, 0, len(user))
+					for key := range user {
+						keys = append(keys, key)
+					}
+					// Пользователь без входов не получает last_login
+					expectedKeys := test.expectedKeys
+					if test.lastLogin && i == 1 {
+						expectedKeys = expectedKeys[:len(expectedKeys)-1]
+					}
+					require.ElementsMatch(t, expectedKeys, keys)
+				}
+				if test.lastLogin {
+					require.JSONEq(t, `{"ip_address":"203.0.113.7","user_agent":"Mozilla/5.0","method":"password","created_at":"2026-10-01T12:00:00Z"}`,
+						string(listed[0]["last_login"]))
+				}
+			}
+			userRepository.AssertExpectations(t)
+			loginEventsRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package get_user_list_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user/get_user_list"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetUserListIncludeDeleted(t *testing.T) {
+	deletedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		role           string
+		includeDeleted bool
+		expectedCode   int
+		expectedBody   string
+	}{
+		{name: "Deleted users are hidden by default", role: "admin",
+			expectedCode: http.StatusOK},
+		{name: "Admin lists deleted users", query: "?include_deleted=true", role: "admin", includeDeleted: true,
+			expectedCode: http.StatusOK, expectedBody: `"deleted_at":"2026-10-01T12:00:00Z"`},
+		{name: "User cannot list deleted users", query: "?include_deleted=true", role: "user",
+			expectedCode: http.StatusForbidden},
+		{name: "Invalid include_deleted", query: "?include_deleted=maybe", role: "admin",
+			expectedCode: http.StatusBadRequest},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			if test.expectedCode == http.StatusOK {
+				user := users_db.UserInfo{ID: 3, Email: "ivan@example.com"}
+				if test.includeDeleted {
+					user.DeletedAt = &deletedAt
+				}
+				userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+					return params.IncludeDeleted == test.includeDeleted
+				})).Return(users_db.UserListResult{Users: []users_db.UserInfo{user}}, nil).Once()
+			}
+			handler := get_user_list.GetUserList(logger, userRepository, nil, nil, nil, time.Second)
+
+			req := httptest.NewRequest(http.MethodGet, "/users"+test.query, nil)
+			claims := jwt.MapClaims{"sub": "1", "user_role": test.role}
+			req = req.WithContext(context.WithValue(req.Context(), authorization.TokenClaimsKey, claims))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.Contains(t, w.Body.String(), test.expectedBody)
+			}
+			if test.expectedCode == http.StatusOK && !test.includeDeleted {
+				require.NotContains(t, w.Body.String(), "deleted_at")
+			}
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
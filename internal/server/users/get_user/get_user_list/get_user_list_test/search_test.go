@@ -0,0 +1,179 @@
+package get_user_list_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/cursor"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user/get_user_list"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func score(value float64) *float64 {
+	return &value
+}
+
+func listRequest(handler http.Handler, query url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/users?"+query.Encode(), nil)
+	claims := jwt.MapClaims{"sub": "1", "user_role": "admin"}
+	req = req.WithContext(context.WithValue(req.Context(), authorization.TokenClaimsKey, claims))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func decodeList(t *testing.T, w *httptest.ResponseRecorder) get_users_list.UsersList {
+	var list get_users_list.UsersList
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	return list
+}
+
+func TestParseListQuerySearch(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	tests := []struct {
+		name           string
+		query          url.Values
+		expectedSearch string
+		expectedSort   []query_params.SortParam
+	}{
+		{name: "No search", query: url.Values{}},
+		// Регистр и лишние пробелы нормализует репозиторий, запрос передаётся как есть
+		{name: "Several words", query: url.Values{"search": {" Ivan  PETROV "}}, expectedSearch: " Ivan  PETROV "},
+		{name: "Non-latin and special characters", query: url.Values{"search": {"Иван 100%_"}}, expectedSearch: "Иван 100%_"},
+		{name: "Search with sort", query: url.Values{"search": {"ivan"}, "sort": {"-created_at"}}, expectedSearch: "ivan",
+			expectedSort: []query_params.SortParam{{Field: "created_at", Order: "desc"}, {Field: "id", Order: "asc"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsedQuery, _, err := get_user_list.ParseListQuery(test.query, logger, nil)
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectedSearch, parsedQuery.Search)
+			require.Equal(t, test.expectedSort, parsedQuery.SortParams)
+		})
+	}
+}
+
+// TestGetUserListSearchRankedByScore без sort результаты поиска упорядочены репозиторием по релевантности:
+// порядок и score сохраняются в ответе, а курсоры не выдаются, даже если есть следующая страница
+func TestGetUserListSearchRankedByScore(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+		return params.Search == "ivan petrov" && params.SortParams == nil && params.Keyset == nil
+	})).Return(users_db.UserListResult{
+		Users: []users_db.UserInfo{
+			{ID: 9, FirstName: "Ivan", LastName: "Petrov", Score: score(1.5)},
+			{ID: 2, FirstName: "Ivan", LastName: "Petrova", Score: score(0.75)},
+			{ID: 5, FirstName: "Ivanna", LastName: "Petrenko", Score: score(0.25)},
+		},
+		HasMore:       true,
+		RankedByScore: true,
+	}, nil).Once()
+	handler := get_user_list.GetUserList(slog.New(slog.NewTextHandler(os.Stdout, nil)), userRepository, nil, nil,
+		cursor.NewCodec("secret"), time.Second)
+
+	w := listRequest(handler, url.Values{"search": {"ivan petrov"}, "limit": {"3"}})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	list := decodeList(t, w)
+	require.Len(t, list.Users, 3)
+	ids := make([]int64, 0, len(list.Users))
+	scores := make([]float64, 0, len(list.Users))
+	for _, user := range list.Users {
+		ids = append(ids, user.Id)
+		require.NotNil(t, user.Score)
+		scores = append(scores, *user.Score)
+	}
+	require.Equal(t, []int64{9, 2, 5}, ids)
+	require.Equal(t, []float64{1.5, 0.75, 0.25}, scores)
+	require.Empty(t, list.Meta.NextCursor)
+	require.Empty(t, list.Meta.PrevCursor)
+	userRepository.AssertExpectations(t)
+}
+
+// TestGetUserListSearchWithSortAndCursor с явной сортировкой поиск поддерживает keyset пагинацию:
+// курсор следующей страницы сохраняет сортировку, а поисковый запрос передаётся заново вместе с курсором
+func TestGetUserListSearchWithSortAndCursor(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	codec := cursor.NewCodec("secret")
+	lastNameSort := []query_params.SortParam{{Field: "last_name", Order: "asc"}, {Field: "id", Order: "asc"}}
+
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+		return params.Search == "ivan" && params.Keyset == nil
+	})).Return(users_db.UserListResult{
+		Users: []users_db.UserInfo{
+			{ID: 4, FirstName: "Ivan", LastName: "Alekseev", Score: score(0.5)},
+			{ID: 8, FirstName: "Ivan", LastName: "Borisov", Score: score(1)},
+		},
+		HasMore: true,
+	}, nil).Once()
+	handler := get_user_list.GetUserList(logger, userRepository, nil, nil, codec, time.Second)
+
+	w := listRequest(handler, url.Values{"search": {"ivan"}, "sort": {"last_name"}, "limit": {"2"}})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	firstPage := decodeList(t, w)
+	// Порядок задаёт сортировка, score только показывает релевантность
+	require.Equal(t, int64(4), firstPage.Users[0].Id)
+	require.Equal(t, int64(8), firstPage.Users[1].Id)
+	require.NotEmpty(t, firstPage.Meta.NextCursor)
+	nextCursor, err := codec.Decode(firstPage.Meta.NextCursor)
+	require.NoError(t, err)
+	require.Equal(t, lastNameSort, nextCursor.Sort)
+	require.Equal(t, []interface{}{"Borisov", json.Number("8")}, nextCursor.Values)
+
+	userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+		return params.Search == "ivan" && params.Keyset != nil && !params.Keyset.Backward &&
+			len(params.SortParams) == len(lastNameSort) && params.SortParams[0] == lastNameSort[0]
+	})).Return(users_db.UserListResult{
+		Users: []users_db.UserInfo{{ID: 6, FirstName: "Ivan", LastName: "Sokolov", Score: score(0.5)}},
+	}, nil).Once()
+
+	w = listRequest(handler, url.Values{"search": {"ivan"}, "cursor": {firstPage.Meta.NextCursor}, "limit": {"2"}})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	secondPage := decodeList(t, w)
+	require.Len(t, secondPage.Users, 1)
+	require.Equal(t, int64(6), secondPage.Users[0].Id)
+	require.Empty(t, secondPage.Meta.NextCursor)
+	require.NotEmpty(t, secondPage.Meta.PrevCursor)
+	userRepository.AssertExpectations(t)
+}
+
+// TestGetUserListSearchCursorOverridesSort курсор всегда содержит явную сортировку,
+// поэтому страница по курсору не упорядочивается по релевантности, даже если sort не передан
+func TestGetUserListSearchCursorOverridesSort(t *testing.T) {
+	codec := cursor.NewCodec("secret")
+	pageCursor, err := codec.Encode(cursor.Cursor{
+		Sort:   []query_params.SortParam{{Field: "id", Order: "asc"}},
+		Values: []interface{}{int64(10)},
+	})
+	require.NoError(t, err)
+
+	userRepository := new(users_db_mock.MockUserRepository)
+	userRepository.On("GetUserList", mock.Anything, mock.MatchedBy(func(params users_db.UserListParams) bool {
+		return params.Search == "ivan" && params.Keyset != nil && len(params.SortParams) == 1 && params.SortParams[0].Field == "id"
+	})).Return(users_db.UserListResult{Users: []users_db.UserInfo{{ID: 11, FirstName: "Ivan", Score: score(0.5)}}}, nil).Once()
+	handler := get_user_list.GetUserList(slog.New(slog.NewTextHandler(os.Stdout, nil)), userRepository, nil, nil, codec, time.Second)
+
+	w := listRequest(handler, url.Values{"search": {"ivan"}, "cursor": {pageCursor}})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotEmpty(t, decodeList(t, w).Meta.PrevCursor)
+	userRepository.AssertExpectations(t)
+}
@@ -0,0 +1,100 @@
+package get_user_list
+
+import (
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidListQuery параметры списка пользователей не удалось разобрать
+var ErrInvalidListQuery = errors.New("invalid request parameters")
+
+// MaxLookupIds сколько пользователей можно запросить одним ?ids=
+const MaxLookupIds = 100
+
+// userFilterFields поля, по которым можно фильтровать список пользователей
+var userFilterFields = map[string]query_params.FilterField{
+	"id":                 {Type: query_params.FilterInt, Operators: query_params.OrderedOperators},
+	"first_name":         {Type: query_params.FilterString, Operators: query_params.StringOperators},
+	"last_name":          {Type: query_params.FilterString, Operators: query_params.StringOperators},
+	"email":              {Type: query_params.FilterString, Operators: query_params.StringOperators},
+	"phone":              {Type: query_params.FilterString, Operators: query_params.StringOperators},
+	"phone_country_code": {Type: query_params.FilterInt, Operators: query_params.EnumOperators},
+	"phone_verified":     {Type: query_params.FilterBool, Operators: []string{query_params.OpEq}},
+	"role":               {Type: query_params.FilterString, Operators: query_params.EnumOperators},
+	"status":             {Type: query_params.FilterString, Operators: query_params.EnumOperators},
+	"created_at":         {Type: query_params.FilterTime, Operators: query_params.TimeOperators},
+	"updated_at":         {Type: query_params.FilterTime, Operators: query_params.TimeOperators},
+}
+
+// ParseListQuery разбирает параметры поиска, сортировки, фильтров, ids, status и include_deleted списка пользователей.
+// ids=1,2,3 выбирает пользователей по списку id (не больше MaxLookupIds), без limit и page они возвращаются одной страницей.
+// Индексируемые атрибуты из definitions доступны для фильтров и сортировки как attributes.имя.
+// Используется списком и выгрузкой пользователей, что б они выбирали одни и те же записи.
+// Ошибки сортировки и фильтров содержат подробности для клиента, остальные ошибки разбора возвращаются как ErrInvalidListQuery
+func ParseListQuery(requestQuery url.Values, log *slog.Logger, definitions []attribute_definition.Definition) (query_params.ListQueryParams, users_db.UserListFilter, error) {
+	filterFields := user_attributes.FilterFields(definitions)
+	for field, config := range userFilterFields {
+		filterFields[field] = config
+	}
+	queryParser := &query_params.ListParser{
+		DefaultSortParser: query_params.DefaultSortParser{
+			ValidSortFields: append([]string{"id", "first_name", "last_name", "email", "created_at"}, user_attributes.SortFields(definitions)...),
+		},
+		DefaultFilterParser: query_params.DefaultFilterParser{
+			ValidFilterFields: filterFields,
+		},
+	}
+	parsedQuery, err := query_params.ParseStandardQueryParams(requestQuery, log, queryParser)
+	if errors.Is(err, query_params.ErrInvalidFilter) || errors.Is(err, query_params.ErrInvalidSort) {
+		return query_params.ListQueryParams{}, users_db.UserListFilter{}, err
+	}
+	if err != nil {
+		log.Error("Ошибка парсинга параметров", "error", err, "request", requestQuery)
+		return query_params.ListQueryParams{}, users_db.UserListFilter{}, ErrInvalidListQuery
+	}
+
+	filter := users_db.UserListFilter{Filters: parsedQuery.Filters, Attributes: user_attributes.Indexed(definitions)}
+	if includeDeletedStr := requestQuery.Get("include_deleted"); includeDeletedStr != "" {
+		filter.IncludeDeleted, err = strconv.ParseBool(includeDeletedStr)
+		if err != nil {
+			log.Error("Invalid include_deleted", "error", err)
+			return query_params.ListQueryParams{}, users_db.UserListFilter{}, ErrInvalidListQuery
+		}
+	}
+	if idsStr := requestQuery.Get("ids"); idsStr != "" {
+		for _, idStr := range strings.Split(idsStr, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+			if err != nil || id <= 0 {
+				log.Error("Invalid ids filter", "id", idStr)
+				return query_params.ListQueryParams{}, users_db.UserListFilter{}, ErrInvalidListQuery
+			}
+			filter.IDs = append(filter.IDs, id)
+		}
+		if len(filter.IDs) > MaxLookupIds {
+			log.Error("Too many ids in filter", "count", len(filter.IDs))
+			return query_params.ListQueryParams{}, users_db.UserListFilter{}, ErrInvalidListQuery
+		}
+		if !requestQuery.Has("limit") && !requestQuery.Has("page") {
+			parsedQuery.Limit = len(filter.IDs)
+		}
+	}
+	if statusStr := requestQuery.Get("status"); statusStr != "" {
+		for _, status := range strings.Split(statusStr, ",") {
+			status = strings.TrimSpace(status)
+			if !user_status.Valid(status) {
+				log.Error("Invalid status filter", "status", status)
+				return query_params.ListQueryParams{}, users_db.UserListFilter{}, ErrInvalidListQuery
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	return parsedQuery, filter, nil
+}
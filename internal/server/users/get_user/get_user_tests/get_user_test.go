@@ -1,15 +1,16 @@
 package get_user_tests
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
 	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
-	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
-	"github.com/ShlykovPavel/users-microservice/models/users/get_user_by_id"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/user_resource"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/mock"
@@ -21,75 +22,166 @@ import (
 	"time"
 )
 
-type MockUserRepository struct {
-	mock.Mock
-}
-
-func (m *MockUserRepository) CreateUser(ctx context.Context, userinfo *create_user.UserCreate) (int64, error) {
-	args := m.Called(ctx, userinfo)
-	return args.Get(0).(int64), args.Error(1)
-}
-
-func (m *MockUserRepository) GetUser(ctx context.Context, userId int64) (users_db.UserInfo, error) {
-	args := m.Called(ctx, userId)
-	return args.Get(0).(users_db.UserInfo), args.Error(1)
-}
-
-func (m *MockUserRepository) CheckAdminInDB(ctx context.Context) (users_db.UserInfo, error) {
-	args := m.Called(ctx)
-	return args.Get(0).(users_db.UserInfo), args.Error(1)
-}
-
-func (m *MockUserRepository) AddFirstAdmin(ctx context.Context, passwordHash string) error {
-	args := m.Called(ctx, passwordHash)
-	return args.Error(0)
-}
-
-func (m *MockUserRepository) GetUserList(ctx context.Context, search string, limit, offset int, sortParams []query_params.SortParam) (users_db.UserListResult, error) {
-	args := m.Called(ctx)
-	return args.Get(0).(users_db.UserListResult), args.Error(1)
-}
-func (m *MockUserRepository) UpdateUser(ctx context.Context, id int64, firstName, lastName, email, phone, role string) error {
-	args := m.Called(ctx, id, firstName, lastName, email, phone, role)
-	return args.Error(0)
-}
-
-func (m *MockUserRepository) DeleteUser(ctx context.Context, id int64) error {
-	args := m.Called(ctx, id)
-	return args.Error(0)
-}
-
 func TestGetUser(t *testing.T) {
 	tests := []struct {
 		name           string
 		userId         string
-		setupMock      func(*MockUserRepository)
+		query          string
+		ifNoneMatch    string
+		setupMock      func(*users_db_mock.MockUserRepository)
 		expectedStatus int
+		expectedETag   bool // ETag представления из тела ответа (см. etag.ForRepresentation)
 		expectedBody   interface{}
 	}{
 		{
 			name:   "success get user",
 			userId: "1",
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
 				mockRepo.On("GetUser", mock.Anything, int64(1)).Return(users_db.UserInfo{
+					ID:        1,
 					Email:     "ryanGosling@gmail.com",
 					FirstName: "Ryan",
 					LastName:  "Gosling",
 					Phone:     "+1234567890",
+					Version:   3,
 				}, nil).Once()
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody: get_user_by_id.UserInfo{
+			expectedETag:   true,
+			expectedBody: user_resource.User{
+				Id:        1,
 				Email:     "ryanGosling@gmail.com",
 				FirstName: "Ryan",
 				LastName:  "Gosling",
 				Phone:     "+1234567890",
+				Version:   3,
+			},
+		},
+		{
+			name:        "not modified",
+			userId:      "1",
+			ifNoneMatch: representationETag(3, user_resource.User{Id: 1, Email: "ryanGosling@gmail.com", Version: 3}),
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				mockRepo.On("GetUser", mock.Anything, int64(1)).Return(users_db.UserInfo{
+					ID:      1,
+					Email:   "ryanGosling@gmail.com",
+					Version: 3,
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   true,
+		},
+		{
+			name:        "modified since etag",
+			userId:      "1",
+			ifNoneMatch: representationETag(2, user_resource.User{Id: 1, Email: "ryanGosling@gmail.com", Version: 2}),
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				mockRepo.On("GetUser", mock.Anything, int64(1)).Return(users_db.UserInfo{
+					ID:      1,
+					Email:   "ryanGosling@gmail.com",
+					Version: 3,
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   true,
+			expectedBody: user_resource.User{
+				Id:      1,
+				Email:   "ryanGosling@gmail.com",
+				Version: 3,
+			},
+		},
+		{
+			name:   "sparse fieldset",
+			userId: "1",
+			query:  "?fields=id,email",
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				mockRepo.On("GetUserFields", mock.Anything, int64(1), []string{"id", "email"}).Return(users_db.UserInfo{
+					ID:      1,
+					Email:   "ryanGosling@gmail.com",
+					Version: 3,
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   true,
+			expectedBody: map[string]interface{}{
+				"id":    1,
+				"email": "ryanGosling@gmail.com",
 			},
 		},
+		{
+			name:   "sparse fieldset without id",
+			userId: "1",
+			query:  "?fields=email,first_name",
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				// id возвращается всегда, даже если его нет в fields
+				mockRepo.On("GetUserFields", mock.Anything, int64(1), []string{"id", "email", "first_name"}).Return(users_db.UserInfo{
+					ID:        1,
+					Email:     "ryanGosling@gmail.com",
+					FirstName: "Ryan",
+					Version:   3,
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   true,
+			expectedBody: map[string]interface{}{
+				"id":         1,
+				"email":      "ryanGosling@gmail.com",
+				"first_name": "Ryan",
+			},
+		},
+		{
+			name:   "unknown include",
+			userId: "1",
+			query:  "?include=roles",
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				// Нет вызова мока, так как связанные данные не проходят проверку
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   response.ErrorWithCode("bad_request", "invalid fields: include value roles is not supported"),
+		},
+		{
+			name:        "etag of another representation",
+			userId:      "1",
+			query:       "?fields=id,email",
+			ifNoneMatch: representationETag(3, user_resource.User{Id: 1, Email: "ryanGosling@gmail.com", Version: 3}),
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				mockRepo.On("GetUserFields", mock.Anything, int64(1), []string{"id", "email"}).Return(users_db.UserInfo{
+					ID:      1,
+					Email:   "ryanGosling@gmail.com",
+					Version: 3,
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   true,
+			expectedBody: map[string]interface{}{
+				"id":    1,
+				"email": "ryanGosling@gmail.com",
+			},
+		},
+		{
+			name:   "unknown field",
+			userId: "1",
+			query:  "?fields=id,password",
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				// Нет вызова мока, так как поле не проходит проверку
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   response.ErrorWithCode("bad_request", "invalid fields: fields value password is not supported"),
+		},
+		{
+			name:   "include without authorization",
+			userId: "1",
+			query:  "?include=last_login",
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
+				// Нет вызова мока, так как связанные данные доступны только авторизованным пользователям
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   response.Error("Forbidden"),
+		},
 		{
 			name:   "empty user id",
 			userId: "",
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
 				// Нет вызова мока, так как хендлер не доходит до обращения к репозиторию
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -98,7 +190,7 @@ func TestGetUser(t *testing.T) {
 		{
 			name:   "invalid user id",
 			userId: "invalid",
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
 				// Нет вызова мока, так как хендлер не доходит до обращения к репозиторию
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -107,7 +199,7 @@ func TestGetUser(t *testing.T) {
 		{
 			name:   "user not found",
 			userId: "1",
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
 				mockRepo.On("GetUser", mock.Anything, int64(1)).Return(users_db.UserInfo{}, users_db.ErrUserNotFound).Once()
 			},
 			expectedStatus: http.StatusNotFound,
@@ -116,11 +208,11 @@ func TestGetUser(t *testing.T) {
 		{
 			name:   "internal server error",
 			userId: "1",
-			setupMock: func(mockRepo *MockUserRepository) {
+			setupMock: func(mockRepo *users_db_mock.MockUserRepository) {
 				mockRepo.On("GetUser", mock.Anything, int64(1)).Return(users_db.UserInfo{}, errors.New("database error")).Once()
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   response.Error("Something went wrong, while getting user"),
+			expectedBody:   response.ErrorWithCode("internal_error", "Something went wrong, while getting user"),
 		},
 	}
 
@@ -128,18 +220,21 @@ func TestGetUser(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			logger := slog.Default()
 			timeout := 5 * time.Second
-			mockRepo := new(MockUserRepository)
-			handler := get_user.GetUserById(logger, mockRepo, timeout)
+			mockRepo := new(users_db_mock.MockUserRepository)
+			handler := get_user.GetUserById(logger, mockRepo, nil, nil, timeout)
 
 			// Настраиваем мок
 			test.setupMock(mockRepo)
 
 			// Создаем запрос
-			req := httptest.NewRequest(http.MethodGet, "/users/"+test.userId, nil)
+			req := httptest.NewRequest(http.MethodGet, "/users/"+test.userId+test.query, nil)
 			rctx := chi.NewRouteContext()
 			rctx.URLParams.Add("id", test.userId)
 			req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, rctx))
 			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "test-id"))
+			if test.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", test.ifNoneMatch)
+			}
 			w := httptest.NewRecorder()
 
 			// Вызываем хендлер
@@ -147,6 +242,18 @@ func TestGetUser(t *testing.T) {
 
 			// Проверяем статус
 			require.Equal(t, test.expectedStatus, w.Code, "unexpected HTTP status")
+			if test.expectedStatus == http.StatusNotModified {
+				require.Equal(t, test.ifNoneMatch, w.Header().Get("ETag"), "unexpected ETag")
+				require.Empty(t, w.Body.String(), "304 response must not have a body")
+				mockRepo.AssertExpectations(t)
+				return
+			}
+			if test.expectedETag {
+				require.Equal(t, etag.ForRepresentation(3, bytes.TrimSpace(w.Body.Bytes())), w.Header().Get("ETag"), "unexpected ETag")
+				require.Contains(t, w.Header().Values("Vary"), "Authorization")
+			} else {
+				require.Empty(t, w.Header().Get("ETag"), "unexpected ETag")
+			}
 
 			expectedJSON, err := json.Marshal(test.expectedBody)
 			require.NoError(t, err, "failed to marshal expected body to JSON")
@@ -159,3 +266,12 @@ func TestGetUser(t *testing.T) {
 		})
 	}
 }
+
+// representationETag ETag, который хендлер вернёт для тела body версии version
+func representationETag(version int64, body interface{}) string {
+	data, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+	return etag.ForRepresentation(version, data)
+}
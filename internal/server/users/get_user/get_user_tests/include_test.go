@@ -0,0 +1,129 @@
+package get_user_tests
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/get_user"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockLoginEventsRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginEventsRepository) AddLoginEvent(ctx context.Context, event *login_events_db.LoginEvent) (int64, error) {
+	args := m.Called(ctx, event)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetKnownDevice(ctx context.Context, userId int64, userAgent, ipNetwork string) (login_events_db.KnownDevice, error) {
+	args := m.Called(ctx, userId, userAgent, ipNetwork)
+	return args.Get(0).(login_events_db.KnownDevice), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetLoginEvents(ctx context.Context, userId int64, limit, offset int, sortParams []query_params.SortParam) (login_events_db.LoginEventsListResult, error) {
+	args := m.Called(ctx, userId, limit, offset, sortParams)
+	return args.Get(0).(login_events_db.LoginEventsListResult), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetLastLogins(ctx context.Context, userIds []int64) (map[int64]login_events_db.LoginEvent, error) {
+	args := m.Called(ctx, userIds)
+	return args.Get(0).(map[int64]login_events_db.LoginEvent), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) CountFailedLogins(ctx context.Context, userId int64, since time.Time) (int, error) {
+	args := m.Called(ctx, userId, since)
+	return args.Int(0), args.Error(1)
+}
+
+func TestGetUserInclude(t *testing.T) {
+	loginAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	changedAt := time.Date(2026, 9, 30, 8, 0, 0, 0, time.UTC)
+	changedBy := int64(2)
+	user := users_db.UserInfo{ID: 1, Email: "ryanGosling@gmail.com", FirstName: "Ryan", Status: "suspended", Version: 3,
+		StatusReason: "spam", StatusChangedAt: &changedAt, StatusChangedBy: &changedBy}
+
+	tests := []struct {
+		name         string
+		query        string
+		claims       jwt.MapClaims
+		fields       []string // Поля, которые хендлер запрашивает у репозитория, nil — запроса нет
+		lastLogin    bool
+		expectedCode int
+		expectedBody string
+		exactBody    bool // expectedBody — весь ответ, а не его часть
+	}{
+		{
+			name:         "Last login for the user himself",
+			query:        "?include=last_login",
+			claims:       jwt.MapClaims{"sub": "1", "user_role": "user"},
+			fields:       append(append([]string{}, user_service.UserFields...), user_service.FieldAttributes),
+			lastLogin:    true,
+			expectedCode: http.StatusOK,
+			expectedBody: `"last_login":{"ip_address":"203.0.113.7","user_agent":"Mozilla/5.0","method":"password","created_at":"2026-10-01T12:00:00Z"}`,
+		},
+		{
+			name:         "Include with sparse fieldset",
+			query:        "?fields=first_name&include=status_details,last_login",
+			claims:       jwt.MapClaims{"sub": "2", "user_role": "admin"},
+			fields:       []string{"id", "first_name", "status_reason", "status_until", "status_changed_at", "status_changed_by"},
+			lastLogin:    true,
+			expectedCode: http.StatusOK,
+			exactBody:    true,
+			expectedBody: `{"id":1,"first_name":"Ryan","status_details":{"reason":"spam","changed_at":"2026-09-30T08:00:00Z","changed_by":2},` +
+				`"last_login":{"ip_address":"203.0.113.7","user_agent":"Mozilla/5.0","method":"password","created_at":"2026-10-01T12:00:00Z"}}`,
+		},
+		{
+			name:         "Include for another user",
+			query:        "?include=last_login",
+			claims:       jwt.MapClaims{"sub": "5", "user_role": "user"},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `"error":"Forbidden"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			loginEventsRepository := new(MockLoginEventsRepository)
+			if test.fields != nil {
+				userRepository.On("GetUserFields", mock.Anything, int64(1), test.fields).Return(user, nil).Once()
+			}
+			if test.lastLogin {
+				loginEventsRepository.On("GetLastLogins", mock.Anything, []int64{1}).Return(map[int64]login_events_db.LoginEvent{
+					1: {IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0", Method: "password", CreatedAt: loginAt},
+				}, nil).Once()
+			}
+			handler := get_user.GetUserById(slog.Default(), userRepository, loginEventsRepository, nil, time.Second)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1"+test.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(context.WithValue(ctx, authorization.TokenClaimsKey, test.claims))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.exactBody {
+				require.JSONEq(t, test.expectedBody, w.Body.String())
+			} else {
+				require.Contains(t, w.Body.String(), test.expectedBody)
+			}
+			userRepository.AssertExpectations(t)
+			loginEventsRepository.AssertExpectations(t)
+		})
+	}
+}
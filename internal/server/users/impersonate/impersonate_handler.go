@@ -0,0 +1,95 @@
+package impersonate
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/client_info"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/auth_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/impersonation_audit_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/impersonate"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ImpersonateHandler godoc
+// @Summary Войти от имени пользователя
+// @Description Выдаёт администратору короткоживущий токен для работы от имени пользователя.
+// @Description Токен содержит claims sub (пользователь) и act (администратор). Все запросы с таким токеном записываются в журнал,
+// @Description а смена пароля и другие чувствительные действия запрещены
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} impersonate.ImpersonateResponse
+// @Failure 403 {object} response.Response
+// @Router /admin/users/{id}/impersonate [post]
+func ImpersonateHandler(logger *slog.Logger, userRepository users_db.UserRepository, auditRepository impersonation_audit_db.ImpersonationAuditRepository,
+	tokenConfig auth_service.TokenConfig, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/impersonate/impersonate_handler.go/ImpersonateHandler"
+		log := logger.With(slog.String("op", op))
+
+		userID := chi.URLParam(r, "id")
+		if userID == "" {
+			log.Error("User ID is empty")
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("User ID is required"))
+			return
+		}
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			log.Error("User ID is invalid", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid user ID"))
+			return
+		}
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil {
+			log.Error("Failed to retrieve claims from context", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+			return
+		}
+		adminId, err := authorization.GetUserID(claims)
+		if err != nil {
+			log.Error("Failed to retrieve admin id from token", "error", err)
+			resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		audit := impersonation_audit_db.AuditRecord{
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			RequestID: middleware.GetReqID(r.Context()),
+			IPAddress: client_info.ClientIP(r),
+		}
+		token, expiresAt, err := auth_service.Impersonate(log, userRepository, auditRepository, ctx, adminId, id, audit, tokenConfig)
+		if err != nil {
+			if errors.Is(err, users_db.ErrUserNotFound) {
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+				return
+			}
+			if errors.Is(err, auth_service.ErrImpersonationNotAllowed) {
+				resp.RenderResponse(w, r, http.StatusForbidden, resp.Error(err.Error()))
+				return
+			}
+			log.Error("Failed to impersonate user", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while impersonating user"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, impersonate.ImpersonateResponse{
+			Response:  resp.OK(),
+			Token:     token,
+			UserID:    id,
+			ExpiresAt: expiresAt,
+		})
+	}
+}
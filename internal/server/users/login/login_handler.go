@@ -0,0 +1,84 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/client_info"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/auth_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/login"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LoginHandler godoc
+// @Summary Аутентификация пользователя
+// @Description Проверяет email и пароль и выдаёт JWT токен. Каждая попытка входа сохраняется в истории входов.
+// @Description После LOGIN_MAX_FAILED_ATTEMPTS неверных паролей подряд учётная запись блокируется на LOGIN_LOCK_DURATION
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body login.LoginRequest true "Email и пароль"
+// @Success 200 {object} login.LoginResponse
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Router /login [post]
+func LoginHandler(log *slog.Logger, userRepository users_db.UserRepository, loginEventsRepository login_events_db.LoginEventsRepository,
+	notifier notifications.Notifier, tokenConfig auth_service.TokenConfig, lockout auth_service.LockoutConfig, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "server/users/login.LoginHandler"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var loginRequest login.LoginRequest
+		err := body.DecodeAndValidateJson(r, &loginRequest)
+		if err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		client := auth_service.ClientInfo{
+			IPAddress: client_info.ClientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+		token, err := auth_service.Login(log, userRepository, loginEventsRepository, notifier, ctx, loginRequest, client, tokenConfig, lockout)
+		if err != nil {
+			if errors.Is(err, auth_service.ErrInvalidCredentials) {
+				resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Invalid email or password"))
+				return
+			}
+			var statusErr *auth_service.AccountStatusError
+			if errors.As(err, &statusErr) {
+				resp.RenderResponse(w, r, http.StatusForbidden, resp.ErrorWithCode("account_"+statusErr.Status, "Account is "+statusErr.Status))
+				return
+			}
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
+				return
+			}
+			log.Error("Failed to login", "err", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while logging in"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, login.LoginResponse{
+			Response: resp.OK(),
+			Token:    token,
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package login_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/auth_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/login"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	login_dto "github.com/ShlykovPavel/users-microservice/models/users/login"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockLoginEventsRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginEventsRepository) AddLoginEvent(ctx context.Context, event *login_events_db.LoginEvent) (int64, error) {
+	args := m.Called(ctx, event)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetKnownDevice(ctx context.Context, userId int64, userAgent, ipNetwork string) (login_events_db.KnownDevice, error) {
+	args := m.Called(ctx, userId, userAgent, ipNetwork)
+	return args.Get(0).(login_events_db.KnownDevice), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetLoginEvents(ctx context.Context, userId int64, limit, offset int, sortParams []query_params.SortParam) (login_events_db.LoginEventsListResult, error) {
+	args := m.Called(ctx, userId, limit, offset, sortParams)
+	return args.Get(0).(login_events_db.LoginEventsListResult), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) GetLastLogins(ctx context.Context, userIds []int64) (map[int64]login_events_db.LoginEvent, error) {
+	args := m.Called(ctx, userIds)
+	return args.Get(0).(map[int64]login_events_db.LoginEvent), args.Error(1)
+}
+
+func (m *MockLoginEventsRepository) CountFailedLogins(ctx context.Context, userId int64, since time.Time) (int, error) {
+	args := m.Called(ctx, userId, since)
+	return args.Int(0), args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	args := m.Called(ctx, notification)
+	return args.Error(0)
+}
+
+func TestLogin(t *testing.T) {
+	logger := slog.Default()
+	passwordHash, err := users.HashUserPassword("password", logger)
+	require.NoError(t, err)
+	user := users_db.UserInfo{
+		ID:           7,
+		Email:        "ryanGosling@gmail.com",
+		PasswordHash: passwordHash,
+		Role:         "user",
+		Status:       user_status.Active,
+	}
+	suspendedUser := user
+	suspendedUser.Status = user_status.Suspended
+	pendingUser := user
+	pendingUser.Status = user_status.Pending
+
+	tests := []struct {
+		testName       string
+		input          login_dto.LoginRequest
+		setupMock      func(*users_db_mock.MockUserRepository, *MockLoginEventsRepository, *MockNotifier)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			testName: "success login from known device",
+			input:    login_dto.LoginRequest{Email: user.Email, Password: "password"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil).Once()
+				eventsRepo.On("GetKnownDevice", mock.Anything, user.ID, "test-agent", "192.0.2.0/24").
+					Return(login_events_db.KnownDevice{HasHistory: true, KnownUserAgent: true, KnownNetwork: true}, nil).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.MatchedBy(func(e *login_events_db.LoginEvent) bool {
+					return e.Success && !e.Suspicious && *e.UserID == user.ID
+				})).Return(int64(1), nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":"OK"`,
+		},
+		{
+			testName: "login from new device is suspicious",
+			input:    login_dto.LoginRequest{Email: user.Email, Password: "password"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil).Once()
+				eventsRepo.On("GetKnownDevice", mock.Anything, user.ID, "test-agent", "192.0.2.0/24").
+					Return(login_events_db.KnownDevice{HasHistory: true, KnownUserAgent: false, KnownNetwork: true}, nil).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.MatchedBy(func(e *login_events_db.LoginEvent) bool {
+					return e.Success && e.Suspicious
+				})).Return(int64(2), nil).Once()
+				notifier.On("Notify", mock.Anything, mock.MatchedBy(func(n notifications.Notification) bool {
+					return n.Type == notifications.TypeNewDeviceLogin && n.UserID == user.ID
+				})).Return(nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":"OK"`,
+		},
+		{
+			testName: "wrong password",
+			input:    login_dto.LoginRequest{Email: user.Email, Password: "wrong"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.MatchedBy(func(e *login_events_db.LoginEvent) bool {
+					return !e.Success && e.FailureReason == "wrong_password"
+				})).Return(int64(3), nil).Once()
+				eventsRepo.On("CountFailedLogins", mock.Anything, user.ID, mock.Anything).Return(2, nil).Once()
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":"ERROR","error":"Invalid email or password","code":"invalid_credentials"}`,
+		},
+		{
+			testName: "wrong password locks account after max failed attempts",
+			input:    login_dto.LoginRequest{Email: user.Email, Password: "wrong"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.Anything).Return(int64(6), nil).Once()
+				eventsRepo.On("CountFailedLogins", mock.Anything, user.ID, mock.Anything).Return(3, nil).Once()
+				userRepo.On("ChangeStatus", mock.Anything, user.ID, users_db.AnyVersion, mock.MatchedBy(func(change users_db.StatusChange) bool {
+					return change.Status == user_status.Locked && change.From == user_status.Active && change.ChangedBy == 0 &&
+						change.Until != nil && change.Until.After(time.Now())
+				})).Return(int64(4), nil).Once()
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":"ERROR","error":"Invalid email or password","code":"invalid_credentials"}`,
+		},
+		{
+			testName: "wrong password for suspended account does not lock it",
+			input:    login_dto.LoginRequest{Email: user.Email, Password: "wrong"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(suspendedUser, nil).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.Anything).Return(int64(7), nil).Once()
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":"ERROR","error":"Invalid email or password","code":"invalid_credentials"}`,
+		},
+		{
+			testName: "suspended account",
+			input:    login_dto.LoginRequest{Email: user.Email, Password: "password"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(suspendedUser, nil).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.MatchedBy(func(e *login_events_db.LoginEvent) bool {
+					return !e.Success && e.FailureReason == "account_suspended"
+				})).Return(int64(5), nil).Once()
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"ERROR","error":"Account is suspended","code":"account_suspended"}`,
+		},
+		{
+			testName: "account awaiting approval",
+			input:    login_dto.LoginRequest{Email: user.Email, Password: "password"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(pendingUser, nil).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.MatchedBy(func(e *login_events_db.LoginEvent) bool {
+					return !e.Success && e.FailureReason == "account_pending"
+				})).Return(int64(5), nil).Once()
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"ERROR","error":"Account is pending","code":"account_pending"}`,
+		},
+		{
+			testName: "unknown email",
+			input:    login_dto.LoginRequest{Email: "unknown@gmail.com", Password: "password"},
+			setupMock: func(userRepo *users_db_mock.MockUserRepository, eventsRepo *MockLoginEventsRepository, notifier *MockNotifier) {
+				userRepo.On("GetUserByEmail", mock.Anything, "unknown@gmail.com").Return(users_db.UserInfo{}, users_db.ErrUserNotFound).Once()
+				eventsRepo.On("AddLoginEvent", mock.Anything, mock.MatchedBy(func(e *login_events_db.LoginEvent) bool {
+					return !e.Success && e.UserID == nil && e.FailureReason == "unknown_email"
+				})).Return(int64(4), nil).Once()
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":"ERROR","error":"Invalid email or password","code":"invalid_credentials"}`,
+		},
+	}
+
+	if err := validators.InitValidator(); err != nil {
+		fmt.Println("Failed to initialize validator")
+	}
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			userRepo := new(users_db_mock.MockUserRepository)
+			eventsRepo := new(MockLoginEventsRepository)
+			notifier := new(MockNotifier)
+			tokenConfig := auth_service.TokenConfig{SecretKey: "secret", Duration: time.Minute}
+			lockout := auth_service.LockoutConfig{MaxFailedAttempts: 3, Duration: time.Minute}
+
+			handler := login.LoginHandler(logger, userRepo, eventsRepo, notifier, tokenConfig, lockout, 5*time.Second)
+
+			// Настраиваем моки
+			test.setupMock(userRepo, eventsRepo, notifier)
+
+			body, _ := json.Marshal(test.input)
+			req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+			req = req.WithContext(context.WithValue(context.Background(), middleware.RequestIDKey, "test-id"))
+			req.RemoteAddr = "192.0.2.15:54321"
+			req.Header.Set("User-Agent", "test-agent")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatus, w.Code, "unexpected HTTP status")
+			require.Contains(t, w.Body.String(), test.expectedBody, "unexpected response body")
+
+			userRepo.AssertExpectations(t)
+			eventsRepo.AssertExpectations(t)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
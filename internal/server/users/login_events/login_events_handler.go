@@ -0,0 +1,84 @@
+package login_events
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/auth_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/login_events_db"
+	_ "github.com/ShlykovPavel/users-microservice/models/users/login_events"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetLoginEvents godoc
+// @Summary Получить историю входов пользователя
+// @Description Возвращает постранично все попытки аутентификации пользователя. Доступно самому пользователю и администратору
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Param page query int false "Номер страницы"
+// @Param limit query int false "Количество записей на странице (1-100)"
+// @Param sort query string false "Сортировка: поля через запятую, - для desc, суффиксы :nulls_first/:nulls_last (id, created_at)" example(-created_at)
+// @Param created_at query string false "Сортировка по дате (asc/desc), устаревший формат"
+// @Failure 400 {object} response.Response
+// @Success 200 {object} login_events.LoginEventsList
+// @Router /users/{id}/login-events [get]
+func GetLoginEvents(logger *slog.Logger, loginEventsRepository login_events_db.LoginEventsRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/login_events/login_events_handler.go/GetLoginEvents"
+		log := logger.With(slog.String("op", op))
+
+		userID := chi.URLParam(r, "id")
+		if userID == "" {
+			log.Error("User ID is empty")
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("User ID is required"))
+			return
+		}
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			log.Error("User ID is invalid", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid user ID"))
+			return
+		}
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil || !authorization.CanAccessUser(claims, id) {
+			log.Debug("Access to login events denied", "user_id", id)
+			resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Forbidden"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		requestQuery := r.URL.Query()
+
+		queryParser := &query_params.DefaultSortParser{
+			ValidSortFields: []string{"id", "created_at"},
+		}
+		parsedQuery, err := query_params.ParseStandardQueryParams(requestQuery, log, queryParser)
+		if errors.Is(err, query_params.ErrInvalidSort) {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка парсинга параметров", "error", err, "request", requestQuery)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid request parameters"))
+			return
+		}
+
+		events, err := auth_service.GetLoginEvents(log, loginEventsRepository, ctx, id, parsedQuery)
+		if err != nil {
+			log.Error("Error while getting login events", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while getting login events"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, events)
+	}
+}
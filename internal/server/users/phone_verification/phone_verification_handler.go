@@ -0,0 +1,148 @@
+package phone_verification
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/phone_verifications_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/phone_verification"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SendPhoneVerificationHandler godoc
+// @Summary Отправить код подтверждения телефона
+// @Description Отправляет на телефон текущего пользователя SMS с кодом подтверждения. Действует только последний отправленный код.
+// @Description Новый код можно запросить не чаще PHONE_VERIFICATION_RESEND_INTERVAL, иначе возвращается 429 с заголовком Retry-After
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} phone_verification.SendPhoneVerificationResponse
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Router /users/me/phone/verification [post]
+func SendPhoneVerificationHandler(logger *slog.Logger, userRepository users_db.UserRepository, verifier user_service.PhoneVerifier, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/phone_verification/phone_verification_handler.go/SendPhoneVerificationHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		verification, err := verifier.SendCode(log, userRepository, ctx, userId)
+		if err != nil {
+			if errors.Is(err, user_service.ErrVerificationTooSoon) {
+				retryAfter := math.Ceil(time.Until(verifier.RetryAt(verification)).Seconds())
+				w.Header().Set("Retry-After", strconv.Itoa(max(int(retryAfter), 1)))
+				resp.RenderResponse(w, r, http.StatusTooManyRequests, resp.Error(err.Error()))
+				return
+			}
+			renderError(w, r, log, err)
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusAccepted, phone_verification.SendPhoneVerificationResponse{
+			Response:  resp.OK(),
+			Phone:     verification.Phone,
+			ExpiresAt: verification.ExpiresAt,
+		})
+	}
+}
+
+// ConfirmPhoneVerificationHandler godoc
+// @Summary Подтвердить телефон
+// @Description Подтверждает телефон текущего пользователя кодом из SMS. Код действует PHONE_VERIFICATION_TTL,
+// @Description после PHONE_VERIFICATION_MAX_ATTEMPTS неверных попыток нужно запросить новый код.
+// @Description Если телефон сменился после отправки кода, код не действует
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param input body phone_verification.ConfirmPhoneVerificationRequest true "Код из SMS"
+// @Success 200 {object} phone_verification.ConfirmPhoneVerificationResponse
+// @Failure 400 {object} response.Response
+// @Failure 429 {object} response.Response
+// @Router /users/me/phone/verification/confirm [post]
+func ConfirmPhoneVerificationHandler(logger *slog.Logger, verifier user_service.PhoneVerifier, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/phone_verification/phone_verification_handler.go/ConfirmPhoneVerificationHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+
+		var request phone_verification.ConfirmPhoneVerificationRequest
+		if err := body.DecodeAndValidateJson(r, &request); err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		verification, err := verifier.ConfirmCode(log, ctx, userId, request.Code)
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, phone_verification.ConfirmPhoneVerificationResponse{
+			Response:   resp.OK(),
+			Phone:      verification.Phone,
+			VerifiedAt: *verification.VerifiedAt,
+		})
+	}
+}
+
+func currentUserID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	claims, err := authorization.GetClaims(r.Context())
+	if err != nil {
+		log.Error("Failed to retrieve claims from context", "error", err)
+		resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+		return 0, false
+	}
+	userId, err := authorization.GetUserID(claims)
+	if err != nil {
+		log.Error("Failed to retrieve user id from token", "error", err)
+		resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+		return 0, false
+	}
+	return userId, true
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, phone_verifications_db.ErrVerificationInvalid), errors.Is(err, phone_numbers.ErrInvalidPhone):
+		resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+	case errors.Is(err, phone_verifications_db.ErrTooManyAttempts):
+		resp.RenderResponse(w, r, http.StatusTooManyRequests, resp.Error(err.Error()))
+	case errors.Is(err, user_service.ErrPhoneAlreadyVerified):
+		resp.RenderResponse(w, r, http.StatusConflict, resp.Error(err.Error()))
+	case errors.Is(err, users_db.ErrUserNotFound):
+		resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
+	default:
+		log.Error("Failed to process phone verification", "error", err)
+		resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while verifying phone"))
+	}
+}
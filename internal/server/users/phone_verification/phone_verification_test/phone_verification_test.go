@@ -0,0 +1,215 @@
+package phone_verification_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/secure_tokens"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/sms"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/phone_verification"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/phone_verifications_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockPhoneVerificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockPhoneVerificationRepository) CreateVerification(ctx context.Context, verification *phone_verifications_db.Verification) (int64, error) {
+	args := m.Called(ctx, verification)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPhoneVerificationRepository) GetPendingVerification(ctx context.Context, userId int64) (phone_verifications_db.Verification, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).(phone_verifications_db.Verification), args.Error(1)
+}
+
+func (m *MockPhoneVerificationRepository) ConfirmVerification(ctx context.Context, userId int64, codeHash string, maxAttempts int) (phone_verifications_db.Verification, error) {
+	args := m.Called(ctx, userId, codeHash, maxAttempts)
+	return args.Get(0).(phone_verifications_db.Verification), args.Error(1)
+}
+
+type MockSMSSender struct {
+	mock.Mock
+}
+
+func (m *MockSMSSender) Send(ctx context.Context, message sms.Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+const (
+	userId    = int64(7)
+	userPhone = "+79512345678"
+)
+
+func TestMain(m *testing.M) {
+	if err := validators.InitValidator(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newRouter подключает отправку и подтверждение кода от имени пользователя 7
+func newRouter(userRepository users_db.UserRepository, repository *MockPhoneVerificationRepository, sender *MockSMSSender) http.Handler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	verifier := user_service.PhoneVerifier{Repository: repository, Sender: sender, TTL: 10 * time.Minute,
+		ResendInterval: time.Minute, MaxAttempts: 5}
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.MapClaims{"sub": "7", "user_role": "user"}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)))
+		})
+	})
+	router.Post("/users/me/phone/verification", phone_verification.SendPhoneVerificationHandler(logger, userRepository, verifier, time.Second))
+	router.Post("/users/me/phone/verification/confirm", phone_verification.ConfirmPhoneVerificationHandler(logger, verifier, time.Second))
+	return router
+}
+
+func TestSendPhoneVerificationHandler(t *testing.T) {
+	user := users_db.UserInfo{ID: userId, Phone: userPhone, PhoneCountryCode: 7}
+	verifiedUser := user
+	verifiedUser.PhoneVerified = true
+
+	tests := []struct {
+		name               string
+		setupMock          func(*users_db_mock.MockUserRepository, *MockPhoneVerificationRepository, *MockSMSSender)
+		expectedCode       int
+		expectedBody       string
+		expectedRetryAfter string
+	}{
+		{
+			name: "Code sent",
+			setupMock: func(userRepository *users_db_mock.MockUserRepository, repository *MockPhoneVerificationRepository, sender *MockSMSSender) {
+				userRepository.On("GetUser", mock.Anything, userId).Return(user, nil).Once()
+				repository.On("GetPendingVerification", mock.Anything, userId).
+					Return(phone_verifications_db.Verification{}, phone_verifications_db.ErrVerificationNotFound).Once()
+				repository.On("CreateVerification", mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+				sender.On("Send", mock.Anything, mock.MatchedBy(func(message sms.Message) bool {
+					return message.To == userPhone && message.UserID == userId
+				})).Return(nil).Once()
+			},
+			expectedCode: http.StatusAccepted,
+			expectedBody: `"phone":"+79512345678"`,
+		},
+		{
+			name: "Code sent recently",
+			setupMock: func(userRepository *users_db_mock.MockUserRepository, repository *MockPhoneVerificationRepository, sender *MockSMSSender) {
+				userRepository.On("GetUser", mock.Anything, userId).Return(user, nil).Once()
+				repository.On("GetPendingVerification", mock.Anything, userId).
+					Return(phone_verifications_db.Verification{ID: 1, CreatedAt: time.Now().Add(-30 * time.Second)}, nil).Once()
+			},
+			expectedCode:       http.StatusTooManyRequests,
+			expectedBody:       `"error":"verification code was sent recently, try again later"`,
+			expectedRetryAfter: "30",
+		},
+		{
+			name: "Phone already verified",
+			setupMock: func(userRepository *users_db_mock.MockUserRepository, repository *MockPhoneVerificationRepository, sender *MockSMSSender) {
+				userRepository.On("GetUser", mock.Anything, userId).Return(verifiedUser, nil).Once()
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: `"error":"phone is already verified"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			repository := new(MockPhoneVerificationRepository)
+			sender := new(MockSMSSender)
+			test.setupMock(userRepository, repository, sender)
+
+			req := httptest.NewRequest(http.MethodPost, "/users/me/phone/verification", nil)
+			w := httptest.NewRecorder()
+			newRouter(userRepository, repository, sender).ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			require.Contains(t, w.Body.String(), test.expectedBody)
+			if test.expectedRetryAfter != "" {
+				require.Equal(t, test.expectedRetryAfter, w.Header().Get("Retry-After"))
+			}
+			userRepository.AssertExpectations(t)
+			repository.AssertExpectations(t)
+			sender.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfirmPhoneVerificationHandler(t *testing.T) {
+	verifiedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		body         string
+		result       phone_verifications_db.Verification
+		err          error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Correct code",
+			body:         `{"code":"123456"}`,
+			result:       phone_verifications_db.Verification{ID: 1, UserID: userId, Phone: userPhone, VerifiedAt: &verifiedAt},
+			expectedCode: http.StatusOK,
+			expectedBody: `"verified_at":"2026-10-01T12:00:00Z"`,
+		},
+		{
+			name:         "Wrong code",
+			body:         `{"code":"123456"}`,
+			err:          phone_verifications_db.ErrVerificationInvalid,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"error":"phone verification code is invalid or expired"`,
+		},
+		{
+			name:         "Attempt limit reached",
+			body:         `{"code":"123456"}`,
+			err:          phone_verifications_db.ErrTooManyAttempts,
+			expectedCode: http.StatusTooManyRequests,
+			expectedBody: `"error":"too many attempts, request a new verification code"`,
+		},
+		{
+			name:         "Code of wrong length",
+			body:         `{"code":"12345"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"code":"validation_failed"`,
+		},
+		{
+			name:         "Code with letters",
+			body:         `{"code":"12a456"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"code":"validation_failed"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository := new(MockPhoneVerificationRepository)
+			if test.result.ID != 0 || test.err != nil {
+				repository.On("ConfirmVerification", mock.Anything, userId, secure_tokens.Hash("123456"), 5).
+					Return(test.result, test.err).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/users/me/phone/verification/confirm", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			newRouter(new(users_db_mock.MockUserRepository), repository, new(MockSMSSender)).ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			require.Contains(t, w.Body.String(), test.expectedBody)
+			repository.AssertExpectations(t)
+		})
+	}
+}
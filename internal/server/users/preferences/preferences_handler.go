@@ -0,0 +1,171 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/preferences"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/preferences_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/preferences_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	_ "github.com/ShlykovPavel/users-microservice/models/users/preferences"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetPreferencesHandler godoc
+// @Summary Получить настройки текущего пользователя
+// @Description Возвращает настройки пространства имён namespace вместе с датами создания и последнего изменения
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param namespace path string true "Пространство имён настроек" example(ui)
+// @Success 200 {object} preferences.Preferences
+// @Failure 404 {object} response.Response
+// @Router /users/me/preferences/{namespace} [get]
+func GetPreferencesHandler(logger *slog.Logger, preferencesRepository preferences_db.PreferencesRepository, schema *preferences.Schema, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/preferences/preferences_handler.go/GetPreferencesHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		result, err := preferences_service.GetPreferences(log, preferencesRepository, schema, ctx, userId, chi.URLParam(r, "namespace"))
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, result)
+	}
+}
+
+// PutPreferencesHandler godoc
+// @Summary Сохранить настройки текущего пользователя
+// @Description Заменяет настройки пространства имён namespace JSON объектом из тела запроса.
+// @Description Значения проверяются по схеме пространства имён, размер ограничен PREFERENCES_MAX_BYTES
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param namespace path string true "Пространство имён настроек" example(ui)
+// @Param input body object true "Настройки"
+// @Success 200 {object} preferences.Preferences
+// @Success 201 {object} preferences.Preferences
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Router /users/me/preferences/{namespace} [put]
+func PutPreferencesHandler(logger *slog.Logger, preferencesRepository preferences_db.PreferencesRepository, schema *preferences.Schema, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/preferences/preferences_handler.go/PutPreferencesHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+		// Лимит проверяется по компактному JSON, поэтому для пробелов и переносов в теле оставлен запас
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(schema.MaxBytes())*2))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				resp.RenderResponse(w, r, http.StatusRequestEntityTooLarge, resp.Error(
+					"preferences must be at most "+strconv.Itoa(schema.MaxBytes())+" bytes"))
+				return
+			}
+			log.Error("Failed to read request body", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Failed to read request body"))
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		result, created, err := preferences_service.PutPreferences(log, preferencesRepository, schema, ctx, userId, chi.URLParam(r, "namespace"), data)
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		resp.RenderResponse(w, r, status, result)
+	}
+}
+
+// DeletePreferencesHandler godoc
+// @Summary Удалить настройки текущего пользователя
+// @Description Удаляет настройки пространства имён namespace, после чего действуют значения по умолчанию клиента
+// @Tags Users
+// @Security BearerAuth
+// @Param namespace path string true "Пространство имён настроек" example(ui)
+// @Success 204
+// @Failure 404 {object} response.Response
+// @Router /users/me/preferences/{namespace} [delete]
+func DeletePreferencesHandler(logger *slog.Logger, preferencesRepository preferences_db.PreferencesRepository, schema *preferences.Schema, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/preferences/preferences_handler.go/DeletePreferencesHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if err := preferences_service.DeletePreferences(log, preferencesRepository, schema, ctx, userId, chi.URLParam(r, "namespace")); err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func currentUserID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	claims, err := authorization.GetClaims(r.Context())
+	if err != nil {
+		log.Error("Failed to retrieve claims from context", "error", err)
+		resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+		return 0, false
+	}
+	userId, err := authorization.GetUserID(claims)
+	if err != nil {
+		log.Error("Failed to retrieve user id from token", "error", err)
+		resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+		return 0, false
+	}
+	return userId, true
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	if preferenceErr, ok := preferences.AsError(err); ok {
+		resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(preferenceErr.Error()))
+		return
+	}
+	switch {
+	case errors.Is(err, preferences.ErrUnknownNamespace), errors.Is(err, preferences_db.ErrPreferencesNotFound):
+		resp.RenderResponse(w, r, http.StatusNotFound, resp.Error(err.Error()))
+	case errors.Is(err, preferences.ErrNotObject):
+		resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+	case errors.Is(err, preferences.ErrTooLarge):
+		resp.RenderResponse(w, r, http.StatusRequestEntityTooLarge, resp.Error(err.Error()))
+	case errors.Is(err, users_db.ErrUserNotFound):
+		resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
+	default:
+		log.Error("Failed to process preferences", "error", err)
+		resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while processing preferences"))
+	}
+}
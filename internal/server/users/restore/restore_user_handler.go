@@ -0,0 +1,78 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/restore_user"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RestoreUserHandler godoc
+// @Summary Восстановить удалённого пользователя
+// @Description Восстановить мягко удалённого пользователя по ID. Доступно только администратору
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} restore_user.RestoreUserResponse
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /users/{id}/restore [post]
+func RestoreUserHandler(logger *slog.Logger, userDbRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.With(slog.String("op", "internal/server/users/restore/restore_user_handler.go/RestoreUserHandler"))
+		userID := chi.URLParam(r, "id")
+		if userID == "" {
+			log.Error("User ID is empty")
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("User ID is required"))
+			return
+		}
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			log.Error("User ID is invalid", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid user ID"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		version, err := user_service.RestoreUser(logger, userDbRepository, ctx, id)
+		if err != nil {
+			if errors.Is(err, users_db.ErrUserNotFound) {
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+				return
+			}
+			if errors.Is(err, users_db.ErrUserNotDeleted) {
+				resp.RenderResponse(w, r, http.StatusConflict, resp.Error("User is not deleted"))
+				return
+			}
+			if errors.Is(err, users_db.ErrEmailAlreadyExists) {
+				resp.RenderResponse(w, r, http.StatusConflict, resp.Error("User email is already taken by another user"))
+				return
+			}
+			if errors.Is(err, users_db.ErrPhoneAlreadyExists) {
+				resp.RenderResponse(w, r, http.StatusConflict, resp.Error("User phone is already taken by another user"))
+				return
+			}
+			log.Error("Error restoring user", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Error restoring user"))
+			return
+		}
+		log.Info("Restored user", "userID", userID)
+		w.Header().Set("ETag", etag.Format(version))
+		resp.RenderResponse(w, r, http.StatusOK, restore_user.RestoreUserResponse{
+			Response: resp.OK(),
+			UserID:   id,
+			Version:  version,
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package restore_user_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/restore"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+const userId = int64(7)
+
+func TestRestoreUserHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		restoreErr   error
+		expectedCode int
+		expectedBody string
+		expectedETag string
+	}{
+		{
+			name:         "Restore deleted user",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status":"OK","id":7,"version":5}`,
+			expectedETag: `"5"`,
+		},
+		{
+			name:         "User not found",
+			restoreErr:   users_db.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "User is not deleted",
+			restoreErr:   users_db.ErrUserNotDeleted,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"status":"ERROR","error":"User is not deleted","code":"user_not_deleted"}`,
+		},
+		{
+			name:         "Email taken while user was deleted",
+			restoreErr:   users_db.ErrEmailAlreadyExists,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"status":"ERROR","error":"User email is already taken by another user","code":"email_taken"}`,
+		},
+		{
+			name:         "Phone taken while user was deleted",
+			restoreErr:   users_db.ErrPhoneAlreadyExists,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"status":"ERROR","error":"User phone is already taken by another user","code":"phone_taken"}`,
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			version := int64(0)
+			if test.restoreErr == nil {
+				version = 5
+			}
+			userRepository.On("RestoreUser", mock.Anything, userId).Return(version, test.restoreErr).Once()
+			router := chi.NewRouter()
+			router.Post("/users/{id}/restore", restore.RestoreUserHandler(logger, userRepository, time.Second))
+
+			req := httptest.NewRequest(http.MethodPost, "/users/7/restore", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+			require.Equal(t, test.expectedETag, w.Header().Get("ETag"))
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/status_change"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SuspendUserHandler godoc
+// @Summary Приостановить пользователя
+// @Description Приостанавливает учётную запись: пользователь не сможет войти, пока его не активируют снова или не наступит until.
+// @Description Причина обязательна
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Param input body status_change.ChangeStatusRequest true "Причина и срок приостановки"
+// @Success 200 {object} status_change.ChangeStatusResponse
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /admin/users/{id}/suspend [post]
+func SuspendUserHandler(logger *slog.Logger, userRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
+	return changeStatusHandler(logger, userRepository, user_status.Suspended, timeout)
+}
+
+// ReactivateUserHandler godoc
+// @Summary Активировать пользователя
+// @Description Возвращает ожидающую активации, приостановленную, заблокированную или отключённую учётную запись в статус active
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Param input body status_change.ChangeStatusRequest false "Причина активации"
+// @Success 200 {object} status_change.ChangeStatusResponse
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /admin/users/{id}/reactivate [post]
+func ReactivateUserHandler(logger *slog.Logger, userRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
+	return changeStatusHandler(logger, userRepository, user_status.Active, timeout)
+}
+
+func changeStatusHandler(logger *slog.Logger, userRepository users_db.UserRepository, status string, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/status/change_status_handler.go/changeStatusHandler"
+		log := logger.With(slog.String("op", op), slog.String("status", status))
+
+		userID := chi.URLParam(r, "id")
+		if userID == "" {
+			log.Error("User ID is empty")
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("User ID is required"))
+			return
+		}
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			log.Error("User ID is invalid", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid user ID"))
+			return
+		}
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil {
+			log.Error("Failed to retrieve claims from context", "error", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+			return
+		}
+		adminId, err := authorization.GetUserID(claims)
+		if err != nil {
+			log.Error("Failed to retrieve admin id from token", "error", err)
+			resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+			return
+		}
+
+		// Тело запроса необязательно: активировать пользователя можно и без причины
+		var dto status_change.ChangeStatusRequest
+		if r.Body != http.NoBody && r.ContentLength != 0 {
+			if err = body.DecodeAndValidateJson(r, &dto); err != nil {
+				log.Error("Failed decoding body", "err", err)
+				if validationErrors, ok := err.(validator.ValidationErrors); ok {
+					resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+					return
+				}
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		result, err := user_service.ChangeStatus(log, userRepository, ctx, id, adminId, status, dto)
+		if err != nil {
+			switch {
+			case errors.Is(err, users_db.ErrUserNotFound):
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+			case errors.Is(err, user_status.ErrInvalidTransition), errors.Is(err, users_db.ErrVersionMismatch):
+				resp.RenderResponse(w, r, http.StatusConflict, resp.Error(err.Error()))
+			case errors.Is(err, user_service.ErrOwnStatusChange):
+				resp.RenderResponse(w, r, http.StatusForbidden, resp.Error(err.Error()))
+			case errors.Is(err, user_service.ErrStatusReasonRequired), errors.Is(err, user_service.ErrStatusUntilNotAllowed),
+				errors.Is(err, user_service.ErrStatusUntilInPast):
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			default:
+				log.Error("Failed to change user status", "err", err)
+				resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Failed changing user status"))
+			}
+			return
+		}
+		log.Info("User status changed", "id", id)
+		result.Response = resp.OK()
+		w.Header().Set("ETag", etag.Format(result.Version))
+		resp.RenderResponse(w, r, http.StatusOK, result)
+	}
+}
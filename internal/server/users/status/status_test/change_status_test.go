@@ -0,0 +1,198 @@
+package status_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/status"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	userId  = int64(7)
+	adminId = int64(1)
+)
+
+func TestMain(m *testing.M) {
+	if err := validators.InitValidator(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newRouter подключает смену статуса от имени администратора 1
+func newRouter(userRepository users_db.UserRepository) http.Handler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.MapClaims{"sub": "1", "user_role": "admin"}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)))
+		})
+	})
+	router.Post("/users/{id}/suspend", status.SuspendUserHandler(logger, userRepository, time.Second))
+	router.Post("/users/{id}/reactivate", status.ReactivateUserHandler(logger, userRepository, time.Second))
+	return router
+}
+
+func TestChangeStatusHandler(t *testing.T) {
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		currentStatus  string // Статус пользователя до запроса, пусто — пользователь не читается
+		getErr         error
+		expectedChange *users_db.StatusChange
+		changeErr      error
+		expectedCode   int
+		expectedBody   string
+	}{
+		{
+			name:           "Suspend active user",
+			path:           "/users/7/suspend",
+			body:           `{"reason":"spam","until":"` + until.Format(time.RFC3339) + `"}`,
+			currentStatus:  user_status.Active,
+			expectedChange: &users_db.StatusChange{Status: user_status.Suspended, Reason: "spam", Until: &until, ChangedBy: adminId},
+			expectedCode:   http.StatusOK,
+			expectedBody:   `"status":"suspended"`,
+		},
+		{
+			name:           "Reactivate locked user",
+			path:           "/users/7/reactivate",
+			currentStatus:  user_status.Locked,
+			expectedChange: &users_db.StatusChange{Status: user_status.Active, ChangedBy: adminId},
+			expectedCode:   http.StatusOK,
+			expectedBody:   `"status":"active"`,
+		},
+		{
+			name:           "Reactivate deactivated user",
+			path:           "/users/7/reactivate",
+			currentStatus:  user_status.Deactivated,
+			expectedChange: &users_db.StatusChange{Status: user_status.Active, ChangedBy: adminId},
+			expectedCode:   http.StatusOK,
+			expectedBody:   `"status":"active"`,
+		},
+		{
+			name:           "Activate pending user",
+			path:           "/users/7/reactivate",
+			currentStatus:  user_status.Pending,
+			expectedChange: &users_db.StatusChange{Status: user_status.Active, ChangedBy: adminId},
+			expectedCode:   http.StatusOK,
+			expectedBody:   `"status":"active"`,
+		},
+		{
+			name:          "Suspend pending user",
+			path:          "/users/7/suspend",
+			body:          `{"reason":"spam"}`,
+			currentStatus: user_status.Pending,
+			expectedCode:  http.StatusConflict,
+		},
+		{
+			name:         "Suspend without reason",
+			path:         "/users/7/suspend",
+			body:         `{"reason":"  "}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Suspend until past time",
+			path:         "/users/7/suspend",
+			body:         `{"reason":"spam","until":"2020-01-01T00:00:00Z"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Reactivate until time",
+			path:         "/users/7/reactivate",
+			body:         `{"until":"` + until.Format(time.RFC3339) + `"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:          "Suspend suspended user",
+			path:          "/users/7/suspend",
+			body:          `{"reason":"spam"}`,
+			currentStatus: user_status.Suspended,
+			expectedCode:  http.StatusConflict,
+		},
+		{
+			name:          "Suspend locked user",
+			path:          "/users/7/suspend",
+			body:          `{"reason":"spam"}`,
+			currentStatus: user_status.Locked,
+			expectedCode:  http.StatusConflict,
+		},
+		{
+			name:          "Reactivate active user",
+			path:          "/users/7/reactivate",
+			currentStatus: user_status.Active,
+			expectedCode:  http.StatusConflict,
+		},
+		{
+			name:           "Status changed by another request",
+			path:           "/users/7/reactivate",
+			currentStatus:  user_status.Suspended,
+			expectedChange: &users_db.StatusChange{Status: user_status.Active, ChangedBy: adminId},
+			changeErr:      users_db.ErrVersionMismatch,
+			expectedCode:   http.StatusConflict,
+		},
+		{
+			name:          "User not found",
+			path:          "/users/7/suspend",
+			body:          `{"reason":"spam"}`,
+			currentStatus: user_status.Active,
+			getErr:        users_db.ErrUserNotFound,
+			expectedCode:  http.StatusNotFound,
+		},
+		{
+			name:         "Own status",
+			path:         "/users/1/suspend",
+			body:         `{"reason":"spam"}`,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			if test.currentStatus != "" {
+				userRepository.On("GetUser", mock.Anything, userId).
+					Return(users_db.UserInfo{ID: userId, Status: test.currentStatus, Version: 3}, test.getErr).Once()
+			}
+			if test.expectedChange != nil {
+				userRepository.On("ChangeStatus", mock.Anything, userId, int64(3), mock.MatchedBy(func(change users_db.StatusChange) bool {
+					return change.Status == test.expectedChange.Status && change.Reason == test.expectedChange.Reason &&
+						change.ChangedBy == test.expectedChange.ChangedBy && change.From == "" &&
+						(change.Until == nil) == (test.expectedChange.Until == nil) &&
+						(change.Until == nil || change.Until.Equal(*test.expectedChange.Until))
+				})).Return(int64(4), test.changeErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+
+			newRouter(userRepository).ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.Contains(t, w.Body.String(), test.expectedBody)
+			}
+			if test.expectedCode == http.StatusOK {
+				require.Equal(t, `"4"`, w.Header().Get("ETag"))
+			}
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
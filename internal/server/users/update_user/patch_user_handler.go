@@ -0,0 +1,165 @@
+package update_user
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/update_user"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PatchUserHandler godoc
+// @Summary Частично обновить пользователя по ID
+// @Description Принимает JSON Merge Patch (RFC 7396): меняются только переданные поля, валидируются тоже только они.
+// @Description null очищает display_name, locale и timezone, для остальных полей профиля null недопустим. Смена email требует подтверждения с нового адреса.
+// @Description attributes тоже применяются как JSON Merge Patch (null удаляет необязательный атрибут, "attributes": null — все изменяемые),
+// @Description Доступно самому пользователю и администратору, role может менять только администратор.
+// @Description Требует заголовок If-Match с ETag, полученным из GET /users/{id}
+// @Tags Users
+// @Accept application/merge-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID пользователя"
+// @Param If-Match header string true "ETag пользователя"
+// @Param input body update_user.PatchUserDto true "Изменяемые поля пользователя"
+// @Success 200 {object} update_user.UpdateUserResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Failure 415 {object} response.Response
+// @Failure 428 {object} response.Response
+// @Router /users/{id} [patch]
+func PatchUserHandler(logger *slog.Logger, userRepository users_db.UserRepository, emailChanger user_service.EmailChanger,
+	attributeSchema user_service.AttributeSchema, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "server/users.PatchUser"
+		log := logger.With(slog.String("op", op))
+
+		userID := chi.URLParam(r, "id")
+		if userID == "" {
+			log.Error("User ID is empty")
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("User ID is required"))
+			return
+		}
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			log.Error("User ID is invalid", "error", err)
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid user ID"))
+			return
+		}
+
+		version, err := etag.ParseIfMatch(r)
+		if err != nil {
+			log.Debug("Precondition is missing or invalid", "err", err)
+			if errors.Is(err, etag.ErrPreconditionRequired) {
+				resp.RenderResponse(w, r, http.StatusPreconditionRequired, resp.Error(err.Error()))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil {
+			log.Error("Failed to retrieve claims from context", "error", err)
+			resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+			return
+		}
+		if !authorization.CanAccessUser(claims, id) {
+			log.Debug("Access to patch user denied", "user_id", id)
+			resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Forbidden"))
+			return
+		}
+		access := user_attributes.AccessFor(claims, id)
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var patchUserDto update_user.PatchUserDto
+		fields, err := body.DecodeMergePatch(w, r, &patchUserDto, update_user.PatchUserFields, update_user.PatchUserRemovableFields)
+		if err != nil {
+			log.Error("Failed decoding patch", "err", err)
+			if errors.Is(err, body.ErrUnsupportedMediaType) {
+				resp.RenderResponse(w, r, http.StatusUnsupportedMediaType, resp.Error(err.Error()))
+				return
+			}
+			if errors.Is(err, body.ErrPatchTooLarge) {
+				resp.RenderResponse(w, r, http.StatusRequestEntityTooLarge, resp.Error(err.Error()))
+				return
+			}
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		// null для полей профиля очищает их так же, как пустая строка
+		cleared := ""
+		for field, removed := range fields {
+			if !removed {
+				continue
+			}
+			switch field {
+			case "display_name":
+				patchUserDto.DisplayName = &cleared
+			case "locale":
+				patchUserDto.Locale = &cleared
+			case "timezone":
+				patchUserDto.Timezone = &cleared
+			case "attributes":
+				patchUserDto.RemoveAttributes = true
+			}
+		}
+		if patchUserDto.Role != nil && !authorization.IsAdmin(claims) {
+			log.Debug("Role change denied", "user_id", id)
+			resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Only administrator can change user role"))
+			return
+		}
+
+		user, err := user_service.PatchUser(log, userRepository, emailChanger, attributeSchema, ctx, patchUserDto, id, version, access)
+		if err != nil {
+			if errors.Is(err, users_db.ErrVersionMismatch) {
+				resp.RenderResponse(w, r, http.StatusPreconditionFailed, resp.Error("User was modified by another request"))
+				return
+			}
+			if errors.Is(err, users_db.ErrUserNotFound) {
+				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+				return
+			}
+			if errors.Is(err, users_db.ErrEmailAlreadyExists) || errors.Is(err, users_db.ErrPhoneAlreadyExists) ||
+				errors.Is(err, users_db.ErrAttributeNotUnique) {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+				return
+			}
+			if attributeErr, ok := user_attributes.AsError(err); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(attributeErr.Error()))
+				return
+			}
+			if domainErr, ok := email_domains.AsDomainError(err); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ErrorWithCode(domainErr.Code, domainErr.Message))
+				return
+			}
+			log.Error("Failed to patch user", "err", err)
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Failed updating user"))
+			return
+		}
+		log.Debug("Successfully patched user", "id", id)
+		w.Header().Set("ETag", etag.Format(user.Version))
+		resp.RenderResponse(w, r, http.StatusOK, user)
+	}
+}
@@ -3,11 +3,14 @@ package update_user
 import (
 	"context"
 	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/etag"
 	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
-	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
 	"github.com/ShlykovPavel/users-microservice/models/users/update_user"
 	"github.com/go-chi/chi/v5"
 	"log/slog"
@@ -18,16 +21,26 @@ import (
 
 // UpdateUserHandler godoc
 // @Summary Обновить пользователя по ID
-// @Description Обновить детальную информацию о пользователе
+// @Description Обновить детальную информацию о пользователе.
+// @Description Если email отличается от текущего, он меняется только после подтверждения по ссылке, отправленной на новый адрес.
+// @Description Доступно самому пользователю и администратору, role может менять только администратор.
+// @Description attributes заменяют все атрибуты, которые может менять владелец токена: сам пользователь или администратор.
+// @Description Требует заголовок If-Match с ETag, полученным из GET /users/{id}
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "ID пользователя"
+// @Param If-Match header string true "ETag пользователя"
 // @Param input body update_user.UpdateUserDto true "Данные пользователя"
-// @Success 200 {object} create_user.CreateUserResponse
+// @Success 200 {object} update_user.UpdateUserResponse
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 428 {object} response.Response
 // @Router /users/{id} [put]
-func UpdateUserHandler(log *slog.Logger, userRepository users_db.UserRepository, timeout time.Duration) http.HandlerFunc {
+func UpdateUserHandler(log *slog.Logger, userRepository users_db.UserRepository, emailChanger user_service.EmailChanger,
+	attributeSchema user_service.AttributeSchema, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "server/users.UpdateUser"
 		log = log.With(slog.String("op", op))
@@ -45,6 +58,30 @@ func UpdateUserHandler(log *slog.Logger, userRepository users_db.UserRepository,
 			return
 		}
 
+		version, err := etag.ParseIfMatch(r)
+		if err != nil {
+			log.Debug("Precondition is missing or invalid", "err", err)
+			if errors.Is(err, etag.ErrPreconditionRequired) {
+				resp.RenderResponse(w, r, http.StatusPreconditionRequired, resp.Error(err.Error()))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		claims, err := authorization.GetClaims(r.Context())
+		if err != nil {
+			log.Error("Failed to retrieve claims from context", "error", err)
+			resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+			return
+		}
+		if !authorization.CanAccessUser(claims, id) {
+			log.Debug("Access to update user denied", "user_id", id)
+			resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Forbidden"))
+			return
+		}
+		access := user_attributes.AccessFor(claims, id)
+
 		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
 
@@ -52,22 +89,44 @@ func UpdateUserHandler(log *slog.Logger, userRepository users_db.UserRepository,
 		err = body.DecodeAndValidateJson(r, &UpdateUserDto)
 		if err != nil {
 			log.Error("Failed decoding body", "err", err, "body", r.Body)
-			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Failed reading body"))
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Failed to read request body"))
 			return
 		}
 
-		err = user_service.UpdateUser(log, userRepository, ctx, UpdateUserDto, id)
+		user, err := user_service.UpdateUser(log, userRepository, emailChanger, attributeSchema, ctx, UpdateUserDto, id, version, access)
 		if err != nil {
+			if errors.Is(err, users_db.ErrVersionMismatch) {
+				resp.RenderResponse(w, r, http.StatusPreconditionFailed, resp.Error("User was modified by another request"))
+				return
+			}
 			if errors.Is(err, users_db.ErrUserNotFound) {
 				resp.RenderResponse(w, r, http.StatusNotFound, resp.Error(err.Error()))
 				return
 			}
+			if errors.Is(err, user_service.ErrRoleChangeForbidden) {
+				resp.RenderResponse(w, r, http.StatusForbidden, resp.Error("Only administrator can change user role"))
+				return
+			}
+			if errors.Is(err, users_db.ErrEmailAlreadyExists) || errors.Is(err, users_db.ErrPhoneAlreadyExists) ||
+				errors.Is(err, users_db.ErrAttributeNotUnique) {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+				return
+			}
+			if attributeErr, ok := user_attributes.AsError(err); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(attributeErr.Error()))
+				return
+			}
+			if domainErr, ok := email_domains.AsDomainError(err); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ErrorWithCode(domainErr.Code, domainErr.Message))
+				return
+			}
 			log.Error("Failed to update user", "err", err)
 			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Failed updating user"))
 			return
 		}
 		log.Debug("Successfully updated user", "id", id)
-		resp.RenderResponse(w, r, http.StatusOK, create_user.CreateUserResponse{UserID: id, Response: resp.OK()})
+		w.Header().Set("ETag", etag.Format(user.Version))
+		resp.RenderResponse(w, r, http.StatusOK, user)
 
 	}
 }
@@ -0,0 +1,73 @@
+package update_user_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/update_user"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPatchUserEmailDomainRules смена email на адрес запрещённого домена отклоняется с кодом правила доменов
+func TestPatchUserEmailDomainRules(t *testing.T) {
+	domainRules, err := email_domains.NewRules(email_domains.Config{
+		AllowedDomains:         []string{"example.com"},
+		DeniedDomains:          []string{"guest.example.com"},
+		BlockDisposableDomains: true,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		email        string
+		expectedBody string
+	}{
+		{name: "Domain outside of allow-list", email: "ivan@example.org",
+			expectedBody: `{"status":"ERROR","error":"email domain is not in the list of allowed domains","code":"email_domain_not_allowed"}`},
+		{name: "Denied subdomain", email: "ivan@mx.Guest.Example.com",
+			expectedBody: `{"status":"ERROR","error":"email domain is denied","code":"email_domain_denied"}`},
+		{name: "Disposable domain", email: "ivan@mailinator.com",
+			expectedBody: `{"status":"ERROR","error":"disposable email addresses are not allowed","code":"email_disposable"}`},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			userRepository.On("GetUser", mock.Anything, userId).
+				Return(users_db.UserInfo{ID: userId, Email: "ivan@example.com", Version: 3}, nil).Once()
+			router := chi.NewRouter()
+			router.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					claims := jwt.MapClaims{"sub": "7", "user_role": "user"}
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)))
+				})
+			})
+			router.Patch("/users/{id}", update_user.PatchUserHandler(logger, userRepository,
+				user_service.EmailChanger{DomainRules: domainRules}, definitions, time.Second))
+
+			req := httptest.NewRequest(http.MethodPatch, "/users/7", strings.NewReader(`{"email":"`+test.email+`"}`))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.Header.Set("If-Match", `"3"`)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.JSONEq(t, test.expectedBody, w.Body.String())
+			// Пользователь не меняется и запрос на смену email не создаётся
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
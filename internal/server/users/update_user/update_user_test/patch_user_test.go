@@ -0,0 +1,174 @@
+package update_user_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/update_user"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const userId = int64(7)
+
+func TestMain(m *testing.M) {
+	if err := validators.InitValidator(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// attributeSchema определения атрибутов пользователей
+type attributeSchema []attribute_definition.Definition
+
+func (s attributeSchema) ListDefinitions(ctx context.Context) ([]attribute_definition.Definition, error) {
+	return s, nil
+}
+
+var definitions = attributeSchema{
+	{Name: "department", Type: "string", Visibility: user_attributes.VisibilityPublic},
+	{Name: "clearance", Type: "string", Visibility: user_attributes.VisibilityAdmin},
+}
+
+// newRouter подключает PatchUserHandler от имени пользователя 7 с ролью role
+func newRouter(userRepository users_db.UserRepository, role string) http.Handler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.MapClaims{"sub": "7", "user_role": role}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)))
+		})
+	})
+	router.Patch("/users/{id}", update_user.PatchUserHandler(logger, userRepository, user_service.EmailChanger{}, definitions, time.Second))
+	return router
+}
+
+func TestPatchUserHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		setupMock    func(*users_db_mock.MockUserRepository)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Change first name",
+			body: `{"first_name":"Ivan"}`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("PatchUser", mock.Anything, userId, int64(3), map[string]interface{}{"first_name": "Ivan"}).
+					Return(users_db.UserInfo{ID: userId, FirstName: "Ivan", Version: 4}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Null clears display name",
+			body: `{"display_name":null}`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("PatchUser", mock.Anything, userId, int64(3), map[string]interface{}{"display_name": ""}).
+					Return(users_db.UserInfo{ID: userId, Version: 4}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Null attributes removes attributes the user can change",
+			body: `{"attributes":null}`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("PatchUser", mock.Anything, userId, int64(3), map[string]interface{}{
+					"attributes": map[string]interface{}{"department": nil},
+				}).Return(users_db.UserInfo{ID: userId, Version: 4}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Null for required field",
+			body:         `{"first_name":null}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"status":"ERROR","error":"field first_name cannot be removed","code":"bad_request"}`,
+		},
+		{
+			name:         "Unknown field",
+			body:         `{"nickname":"ivan"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"status":"ERROR","error":"field nickname cannot be changed","code":"bad_request"}`,
+		},
+		{
+			name:         "Read-only field",
+			body:         `{"version":10}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"status":"ERROR","error":"field version cannot be changed","code":"bad_request"}`,
+		},
+		{
+			name:         "Patch is not an object",
+			body:         `["first_name"]`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid value",
+			body:         `{"first_name":"Iv"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Role change by user",
+			body:         `{"role":"admin"}`,
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"status":"ERROR","error":"Only administrator can change user role","code":"role_change_forbidden"}`,
+		},
+		{
+			name:         "Unsupported content type",
+			contentType:  "text/plain",
+			body:         `{"first_name":"Ivan"}`,
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "Patch too large",
+			body:         `{"display_name":"` + strings.Repeat("a", body.MaxMergePatchBytes) + `"}`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			if test.setupMock != nil {
+				test.setupMock(userRepository)
+			}
+			contentType := test.contentType
+			if contentType == "" {
+				contentType = body.MergePatchContentType
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/users/7", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("If-Match", `"3"`)
+			w := httptest.NewRecorder()
+
+			newRouter(userRepository, "user").ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+			if test.expectedCode == http.StatusOK {
+				require.Equal(t, `"4"`, w.Header().Get("ETag"))
+			}
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
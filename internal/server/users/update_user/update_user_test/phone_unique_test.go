@@ -0,0 +1,55 @@
+package update_user_test
+
+import (
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestPatchUserPhoneUniqueness проверяет ответы на смену телефона при включённой и выключенной настройке PHONE_UNIQUE.
+// Занятый телефон отклоняет триггер check_users_phone_unique, репозиторий возвращает ErrPhoneAlreadyExists
+func TestPatchUserPhoneUniqueness(t *testing.T) {
+	tests := []struct {
+		name         string
+		phoneUnique  bool
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "Taken phone with uniqueness enabled", phoneUnique: true,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"status":"ERROR","error":"user with this phone already exists","code":"phone_exists"}`},
+		{name: "Taken phone with uniqueness disabled",
+			expectedCode: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			call := userRepository.On("PatchUser", mock.Anything, userId, int64(3), map[string]interface{}{"phone": "+79512345678"})
+			if test.phoneUnique {
+				call.Return(users_db.UserInfo{}, users_db.ErrPhoneAlreadyExists).Once()
+			} else {
+				call.Return(users_db.UserInfo{ID: userId, Phone: "+79512345678", Version: 4}, nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/users/7", strings.NewReader(`{"phone":"+7 951 234-56-78"}`))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.Header.Set("If-Match", `"3"`)
+			w := httptest.NewRecorder()
+
+			newRouter(userRepository, "user").ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.JSONEq(t, test.expectedBody, w.Body.String())
+			} else {
+				require.Contains(t, w.Body.String(), `"phone":"+79512345678"`)
+			}
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package update_user_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/update_user"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAdminRouter подключает PUT и PATCH /users/{id} от имени администратора
+func newAdminRouter(userRepository users_db.UserRepository) http.Handler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.MapClaims{"sub": "1", "user_role": "admin"}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)))
+		})
+	})
+	router.Put("/users/{id}", update_user.UpdateUserHandler(logger, userRepository, user_service.EmailChanger{}, nil, time.Second))
+	router.Patch("/users/{id}", update_user.PatchUserHandler(logger, userRepository, user_service.EmailChanger{}, nil, time.Second))
+	return router
+}
+
+func TestUpdateUserPreconditions(t *testing.T) {
+	const putBody = `{"first_name":"Ivan","last_name":"Petrov","email":"ivan@example.com","phone":"+79512345678","role":"user"}`
+	const patchBody = `{"first_name":"Ivan"}`
+
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		ifMatch      string
+		setupMock    func(*users_db_mock.MockUserRepository)
+		expectedCode int
+		expectedETag string
+	}{
+		{
+			name:         "PUT without If-Match",
+			method:       http.MethodPut,
+			body:         putBody,
+			expectedCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "PUT with weak ETag",
+			method:       http.MethodPut,
+			body:         putBody,
+			ifMatch:      `W/"3"`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:    "PUT with stale ETag",
+			method:  http.MethodPut,
+			body:    putBody,
+			ifMatch: `"3"`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("GetUser", mock.Anything, userId).
+					Return(users_db.UserInfo{ID: userId, Email: "ivan@example.com", Version: 5}, nil).Once()
+			},
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "PATCH without If-Match",
+			method:       http.MethodPatch,
+			body:         patchBody,
+			expectedCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "PATCH with several ETags",
+			method:       http.MethodPatch,
+			body:         patchBody,
+			ifMatch:      `"3", "4"`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:    "PATCH with stale ETag",
+			method:  http.MethodPatch,
+			body:    patchBody,
+			ifMatch: `"3"`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("PatchUser", mock.Anything, userId, int64(3), mock.Anything).
+					Return(users_db.UserInfo{}, users_db.ErrVersionMismatch).Once()
+			},
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "PATCH with representation ETag",
+			method:  http.MethodPatch,
+			body:    patchBody,
+			ifMatch: `"3-0123456789abcdef"`,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("PatchUser", mock.Anything, userId, int64(3), mock.Anything).
+					Return(users_db.UserInfo{ID: userId, Version: 4}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:    "PATCH with any version",
+			method:  http.MethodPatch,
+			body:    patchBody,
+			ifMatch: "*",
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("PatchUser", mock.Anything, userId, users_db.AnyVersion, mock.Anything).
+					Return(users_db.UserInfo{ID: userId, Version: 9}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedETag: `"9"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			if test.setupMock != nil {
+				test.setupMock(userRepository)
+			}
+
+			req := httptest.NewRequest(test.method, "/users/7", strings.NewReader(test.body))
+			if test.method == http.MethodPatch {
+				req.Header.Set("Content-Type", body.MergePatchContentType)
+			}
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			newAdminRouter(userRepository).ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedCode == http.StatusPreconditionFailed {
+				require.Contains(t, w.Body.String(), "User was modified by another request")
+			}
+			require.Equal(t, test.expectedETag, w.Header().Get("ETag"))
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package update_user_test
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/update_user"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateUserHandlerAccess(t *testing.T) {
+	const userBody = `{"first_name":"Ivan","last_name":"Petrov","email":"ivan@example.com","phone":"+79512345678","role":"user"}`
+	const adminBody = `{"first_name":"Ivan","last_name":"Petrov","email":"ivan@example.com","phone":"+79512345678","role":"admin"}`
+	current := users_db.UserInfo{ID: userId, Email: "ivan@example.com", Role: "user", Version: 3}
+
+	tests := []struct {
+		name         string
+		claims       jwt.MapClaims // nil — запрос без токена
+		body         string
+		setupMock    func(*users_db_mock.MockUserRepository)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Anonymous",
+			body:         adminBody,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Other user",
+			claims:       jwt.MapClaims{"sub": "8", "user_role": "user"},
+			body:         userBody,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:   "Role change by user",
+			claims: jwt.MapClaims{"sub": "7", "user_role": "user"},
+			body:   adminBody,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("GetUser", mock.Anything, userId).Return(current, nil).Once()
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"status":"ERROR","error":"Only administrator can change user role","code":"role_change_forbidden"}`,
+		},
+		{
+			name:   "User keeps own role",
+			claims: jwt.MapClaims{"sub": "7", "user_role": "user"},
+			body:   userBody,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("GetUser", mock.Anything, userId).Return(current, nil).Once()
+				m.On("UpdateUser", mock.Anything, userId, int64(3), "Ivan", "Petrov", "+79512345678", "user", mock.Anything,
+					map[string]interface{}(nil)).Return(users_db.UserInfo{ID: userId, Role: "user", Version: 4}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Role change by admin",
+			claims: jwt.MapClaims{"sub": "1", "user_role": "admin"},
+			body:   adminBody,
+			setupMock: func(m *users_db_mock.MockUserRepository) {
+				m.On("GetUser", mock.Anything, userId).Return(current, nil).Once()
+				m.On("UpdateUser", mock.Anything, userId, int64(3), "Ivan", "Petrov", "+79512345678", "admin", mock.Anything,
+					map[string]interface{}(nil)).Return(users_db.UserInfo{ID: userId, Role: "admin", Version: 4}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			if test.setupMock != nil {
+				test.setupMock(userRepository)
+			}
+			router := chi.NewRouter()
+			router.Put("/users/{id}", update_user.UpdateUserHandler(logger, userRepository, user_service.EmailChanger{}, nil, time.Second))
+
+			req := httptest.NewRequest(http.MethodPut, "/users/7", strings.NewReader(test.body))
+			req.Header.Set("If-Match", `"3"`)
+			if test.claims != nil {
+				req = req.WithContext(context.WithValue(req.Context(), authorization.TokenClaimsKey, test.claims))
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				require.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package user_emails
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/body"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/user_emails_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/user_emails"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetUserEmailsHandler godoc
+// @Summary Адреса email текущего пользователя
+// @Description Возвращает адреса текущего пользователя: сначала основной, остальные в порядке добавления
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} user_emails.GetUserEmailsResponse
+// @Router /users/me/emails [get]
+func GetUserEmailsHandler(logger *slog.Logger, userEmails user_service.UserEmails, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/user_emails/user_emails_handler.go/GetUserEmailsHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		emails, err := userEmails.GetEmails(log, ctx, userId)
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		response := user_emails.GetUserEmailsResponse{
+			Response: resp.OK(),
+			Emails:   make([]user_emails.UserEmail, 0, len(emails)),
+		}
+		for _, email := range emails {
+			response.Emails = append(response.Emails, toUserEmail(email))
+		}
+		resp.RenderResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// AddUserEmailHandler godoc
+// @Summary Добавить адрес email
+// @Description Добавляет текущему пользователю адрес и отправляет на него токен подтверждения, действующий USER_EMAIL_VERIFICATION_TTL.
+// @Description По адресу можно войти после подтверждения. Повторный запрос для неподтверждённого адреса отправляет новый токен.
+// @Description У пользователя может быть не больше USER_EMAILS_MAX адресов, включая основной
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param input body user_emails.AddUserEmailRequest true "Адрес"
+// @Success 202 {object} user_emails.AddUserEmailResponse
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /users/me/emails [post]
+func AddUserEmailHandler(logger *slog.Logger, userRepository users_db.UserRepository, userEmails user_service.UserEmails, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/user_emails/user_emails_handler.go/AddUserEmailHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+
+		var request user_emails.AddUserEmailRequest
+		if err := body.DecodeAndValidateJson(r, &request); err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		email, err := userEmails.AddEmail(log, userRepository, ctx, userId, request.Email)
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusAccepted, user_emails.AddUserEmailResponse{
+			Response:  resp.OK(),
+			Email:     toUserEmail(email),
+			ExpiresAt: email.TokenExpiresAt,
+		})
+	}
+}
+
+// VerifyUserEmailHandler godoc
+// @Summary Подтвердить адрес email
+// @Description Подтверждает добавленный адрес по токену, отправленному на этот адрес
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param input body user_emails.VerifyUserEmailRequest true "Токен подтверждения"
+// @Success 200 {object} user_emails.UserEmailResponse
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /users/emails/verify [post]
+func VerifyUserEmailHandler(logger *slog.Logger, userEmails user_service.UserEmails, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/user_emails/user_emails_handler.go/VerifyUserEmailHandler"
+		log := logger.With(slog.String("op", op))
+
+		var request user_emails.VerifyUserEmailRequest
+		if err := body.DecodeAndValidateJson(r, &request); err != nil {
+			log.Error("Error while decoding request body", "err", err)
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.ValidationError(validationErrors))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		email, err := userEmails.VerifyEmail(log, ctx, request.Token)
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, user_emails.UserEmailResponse{
+			Response: resp.OK(),
+			UserID:   email.UserID,
+			Email:    toUserEmail(email),
+		})
+	}
+}
+
+// DeleteUserEmailHandler godoc
+// @Summary Удалить адрес email
+// @Description Удаляет адрес текущего пользователя. Основной адрес удалить нельзя, сначала нужно сделать основным другой адрес
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID адреса"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /users/me/emails/{id} [delete]
+func DeleteUserEmailHandler(logger *slog.Logger, userEmails user_service.UserEmails, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/user_emails/user_emails_handler.go/DeleteUserEmailHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+		emailId, ok := emailID(w, r, log)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if err := userEmails.DeleteEmail(log, ctx, userId, emailId); err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, resp.OK())
+	}
+}
+
+// SetPrimaryUserEmailHandler godoc
+// @Summary Сделать адрес email основным
+// @Description Делает подтверждённый адрес текущего пользователя основным: он становится email пользователя.
+// @Description Прежний основной адрес остаётся подтверждённым, на него отправляется уведомление о смене email
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID адреса"
+// @Success 200 {object} user_emails.UserEmailResponse
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Router /users/me/emails/{id}/primary [post]
+func SetPrimaryUserEmailHandler(logger *slog.Logger, userEmails user_service.UserEmails, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/user_emails/user_emails_handler.go/SetPrimaryUserEmailHandler"
+		log := logger.With(slog.String("op", op))
+
+		userId, ok := currentUserID(w, r, log)
+		if !ok {
+			return
+		}
+		emailId, ok := emailID(w, r, log)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		email, err := userEmails.SetPrimaryEmail(log, ctx, userId, emailId)
+		if err != nil {
+			renderError(w, r, log, err)
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, user_emails.UserEmailResponse{
+			Response: resp.OK(),
+			UserID:   userId,
+			Email:    toUserEmail(email),
+		})
+	}
+}
+
+func toUserEmail(email user_emails_db.UserEmail) user_emails.UserEmail {
+	return user_emails.UserEmail{
+		ID:         email.ID,
+		Email:      email.Email,
+		Primary:    email.Primary,
+		Verified:   email.VerifiedAt != nil,
+		VerifiedAt: email.VerifiedAt,
+		CreatedAt:  email.CreatedAt,
+	}
+}
+
+func emailID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		log.Error("Email ID is invalid", "error", err)
+		resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("Invalid email ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+func currentUserID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	claims, err := authorization.GetClaims(r.Context())
+	if err != nil {
+		log.Error("Failed to retrieve claims from context", "error", err)
+		resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Internal server error"))
+		return 0, false
+	}
+	userId, err := authorization.GetUserID(claims)
+	if err != nil {
+		log.Error("Failed to retrieve user id from token", "error", err)
+		resp.RenderResponse(w, r, http.StatusUnauthorized, resp.Error("Authorization token is invalid"))
+		return 0, false
+	}
+	return userId, true
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	if domainErr, ok := email_domains.AsDomainError(err); ok {
+		resp.RenderResponse(w, r, http.StatusBadRequest, resp.ErrorWithCode(domainErr.Code, domainErr.Message))
+		return
+	}
+	switch {
+	case errors.Is(err, user_emails_db.ErrEmailVerificationInvalid),
+		errors.Is(err, user_emails_db.ErrEmailNotVerified),
+		errors.Is(err, user_service.ErrTooManyEmails):
+		resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+	case errors.Is(err, user_emails_db.ErrEmailAlreadyAdded), errors.Is(err, user_emails_db.ErrPrimaryEmail):
+		resp.RenderResponse(w, r, http.StatusConflict, resp.Error(err.Error()))
+	case errors.Is(err, users_db.ErrEmailAlreadyExists):
+		resp.RenderResponse(w, r, http.StatusConflict, resp.Error("User email is already taken by another user"))
+	case errors.Is(err, user_emails_db.ErrEmailNotFound):
+		resp.RenderResponse(w, r, http.StatusNotFound, resp.Error(err.Error()))
+	case errors.Is(err, users_db.ErrUserNotFound):
+		resp.RenderResponse(w, r, http.StatusNotFound, resp.Error("User not found"))
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
+	default:
+		log.Error("Failed to process user email", "error", err)
+		resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while processing user email"))
+	}
+}
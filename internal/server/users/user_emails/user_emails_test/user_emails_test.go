@@ -0,0 +1,175 @@
+package user_emails_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/authorization"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/notifications"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/user_emails"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/user_emails_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	user_emails_dto "github.com/ShlykovPavel/users-microservice/models/users/user_emails"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+type MockUserEmailRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserEmailRepository) GetEmails(ctx context.Context, userId int64) ([]user_emails_db.UserEmail, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]user_emails_db.UserEmail), args.Error(1)
+}
+
+func (m *MockUserEmailRepository) AddEmail(ctx context.Context, email *user_emails_db.UserEmail) (int64, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserEmailRepository) VerifyEmail(ctx context.Context, tokenHash string) (user_emails_db.UserEmail, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(user_emails_db.UserEmail), args.Error(1)
+}
+
+func (m *MockUserEmailRepository) DeleteEmail(ctx context.Context, userId, id int64) error {
+	args := m.Called(ctx, userId, id)
+	return args.Error(0)
+}
+
+func (m *MockUserEmailRepository) SetPrimaryEmail(ctx context.Context, userId, id int64) (user_emails_db.UserEmail, string, error) {
+	args := m.Called(ctx, userId, id)
+	return args.Get(0).(user_emails_db.UserEmail), args.String(1), args.Error(2)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	args := m.Called(ctx, notification)
+	return args.Error(0)
+}
+
+const userId = int64(7)
+
+func newRouter(userEmails user_service.UserEmails) http.Handler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.MapClaims{"sub": "7"}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authorization.TokenClaimsKey, claims)))
+		})
+	})
+	router.Get("/users/me/emails", user_emails.GetUserEmailsHandler(logger, userEmails, time.Second))
+	router.Delete("/users/me/emails/{id}", user_emails.DeleteUserEmailHandler(logger, userEmails, time.Second))
+	router.Post("/users/me/emails/{id}/primary", user_emails.SetPrimaryUserEmailHandler(logger, userEmails, time.Second))
+	return router
+}
+
+func TestGetUserEmails(t *testing.T) {
+	verifiedAt := time.Now().UTC()
+	repository := new(MockUserEmailRepository)
+	repository.On("GetEmails", mock.Anything, userId).Return([]user_emails_db.UserEmail{
+		{ID: 1, UserID: userId, Email: "john@example.com", Primary: true, VerifiedAt: &verifiedAt},
+		{ID: 2, UserID: userId, Email: "john@work.example.com"},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	newRouter(user_service.UserEmails{Repository: repository}).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/me/emails", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response user_emails_dto.GetUserEmailsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Emails, 2)
+	assert.True(t, response.Emails[0].Primary)
+	assert.True(t, response.Emails[0].Verified)
+	assert.False(t, response.Emails[1].Verified)
+	assert.Nil(t, response.Emails[1].VerifiedAt)
+}
+
+func TestDeleteUserEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		err        error
+		wantStatus int
+	}{
+		{name: "Deleted", path: "/users/me/emails/2", wantStatus: http.StatusOK},
+		{name: "Primary", path: "/users/me/emails/1", err: user_emails_db.ErrPrimaryEmail, wantStatus: http.StatusConflict},
+		{name: "Not found", path: "/users/me/emails/3", err: user_emails_db.ErrEmailNotFound, wantStatus: http.StatusNotFound},
+		{name: "Invalid ID", path: "/users/me/emails/abc", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := new(MockUserEmailRepository)
+			repository.On("DeleteEmail", mock.Anything, userId, mock.Anything).Return(tt.err)
+
+			rec := httptest.NewRecorder()
+			newRouter(user_service.UserEmails{Repository: repository}).
+				ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestSetPrimaryUserEmail(t *testing.T) {
+	verifiedAt := time.Now().UTC()
+	email := user_emails_db.UserEmail{ID: 2, UserID: userId, Email: "john@work.example.com", Primary: true, VerifiedAt: &verifiedAt}
+
+	t.Run("Notifies previous address", func(t *testing.T) {
+		repository := new(MockUserEmailRepository)
+		repository.On("SetPrimaryEmail", mock.Anything, userId, int64(2)).Return(email, "john@example.com", nil)
+		notifier := new(MockNotifier)
+		notifier.On("Notify", mock.Anything, mock.MatchedBy(func(n notifications.Notification) bool {
+			return n.Type == notifications.TypeEmailChanged && n.Recipient == "john@example.com" &&
+				n.Data["new_email"] == "john@work.example.com"
+		})).Return(nil).Once()
+
+		rec := httptest.NewRecorder()
+		newRouter(user_service.UserEmails{Repository: repository, Notifier: notifier}).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/me/emails/2/primary", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response user_emails_dto.UserEmailResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, userId, response.UserID)
+		assert.True(t, response.Email.Primary)
+		notifier.AssertExpectations(t)
+	})
+
+	errorTests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "Not verified", err: user_emails_db.ErrEmailNotVerified, wantStatus: http.StatusBadRequest},
+		{name: "Taken", err: users_db.ErrEmailAlreadyExists, wantStatus: http.StatusConflict},
+		{name: "Not found", err: user_emails_db.ErrEmailNotFound, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := new(MockUserEmailRepository)
+			repository.On("SetPrimaryEmail", mock.Anything, userId, int64(2)).Return(user_emails_db.UserEmail{}, "", tt.err)
+
+			rec := httptest.NewRecorder()
+			newRouter(user_service.UserEmails{Repository: repository}).
+				ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/me/emails/2/primary", nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package users_import
+
+import (
+	"context"
+	"errors"
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/export_writer"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/import_reader"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/services/user_service"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	_ "github.com/ShlykovPavel/users-microservice/models/users/import_users"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRowBytes допустимый средний размер строки файла импорта, из него считается ограничение размера тела запроса
+const maxRowBytes = 1024
+
+// ImportUsersHandler godoc
+// @Summary Импортировать пользователей
+// @Description Создаёт пользователей из CSV (с заголовком) или NDJSON. Доступно только администратору.
+// @Description Колонки: first_name, last_name, email, phone, password или password_hash (готовый bcrypt хеш), role
+// @Description и дополнительные атрибуты attributes.<имя> (в NDJSON — объект attributes), обязательные атрибуты нужны в каждой строке.
+// @Description Строки проверяются по тем же правилам, что и при регистрации, и записываются одной транзакцией.
+// @Description Строки с ошибками не записываются и попадают в отчёт со статусом failed.
+// @Description on_conflict задаёт, что делать с уже занятым email: skip — пропустить строку, update — обновить пользователя,
+// @Description fail — отменить весь импорт (ответ 409 с отчётом). dry_run=true проверяет импорт без записи
+// @Tags Users
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "Формат файла, по умолчанию определяется по Content-Type" Enums(csv, ndjson)
+// @Param on_conflict query string false "Обработка занятого email" Enums(skip, update, fail) default(skip)
+// @Param dry_run query bool false "Проверить импорт без записи"
+// @Success 200 {object} import_users.ImportUsersResponse
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} import_users.ImportUsersResponse
+// @Failure 413 {object} response.Response
+// @Router /users/import [post]
+func ImportUsersHandler(logger *slog.Logger, userDbRepository users_db.UserRepository, attributeSchema user_service.AttributeSchema,
+	maxRows int, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal/server/users/users_import/import_users_handler.go/ImportUsersHandler"
+		log := logger.With(slog.String("op", op))
+		requestQuery := r.URL.Query()
+
+		format := requestQuery.Get("format")
+		if format == "" {
+			format = import_reader.FormatFromContentType(r.Header.Get("Content-Type"))
+		}
+		if format != export_writer.FormatCSV && format != export_writer.FormatNDJSON {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(export_writer.ErrUnknownFormat.Error()))
+			return
+		}
+		onConflict := requestQuery.Get("on_conflict")
+		if onConflict == "" {
+			onConflict = users_db.OnConflictSkip
+		}
+		if onConflict != users_db.OnConflictSkip && onConflict != users_db.OnConflictUpdate && onConflict != users_db.OnConflictFail {
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("on_conflict must be one of skip, update, fail"))
+			return
+		}
+		dryRun := false
+		if value := requestQuery.Get("dry_run"); value != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(value); err != nil {
+				resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error("dry_run must be a boolean"))
+				return
+			}
+		}
+
+		// Хеширование паролей занимает больше обычного запроса, поэтому дедлайны чтения и записи продлеваются до таймаута импорта
+		controller := http.NewResponseController(w)
+		if err := controller.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			log.Warn("Failed to extend read deadline", "error", err)
+		}
+		if err := controller.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			log.Warn("Failed to extend write deadline", "error", err)
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		rows, err := import_reader.Read(format, http.MaxBytesReader(w, r.Body, int64(maxRows)*maxRowBytes), maxRows)
+		if err != nil {
+			log.Debug("Failed to read import file", "error", err)
+			var maxBytesErr *http.MaxBytesError
+			if errors.Is(err, import_reader.ErrTooManyRows) || errors.As(err, &maxBytesErr) {
+				resp.RenderResponse(w, r, http.StatusRequestEntityTooLarge, resp.Error(
+					"import file must have at most "+strconv.Itoa(maxRows)+" rows"))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusBadRequest, resp.Error(err.Error()))
+			return
+		}
+
+		report, err := user_service.ImportUsers(log, userDbRepository, attributeSchema, ctx, rows, onConflict, dryRun)
+		if err != nil {
+			if errors.Is(err, users_db.ErrImportConflict) {
+				resp.RenderResponse(w, r, http.StatusConflict, report)
+				return
+			}
+			if errors.Is(err, users_db.ErrPhoneAlreadyExists) || errors.Is(err, users_db.ErrEmailAlreadyExists) ||
+				errors.Is(err, users_db.ErrAttributeNotUnique) {
+				resp.RenderResponse(w, r, http.StatusConflict, resp.Error(err.Error()))
+				return
+			}
+			log.Error("Failed to import users", "error", err)
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				resp.RenderResponse(w, r, http.StatusGatewayTimeout, resp.Error("Request timed out or canceled"))
+				return
+			}
+			resp.RenderResponse(w, r, http.StatusInternalServerError, resp.Error("Something went wrong, while importing users"))
+			return
+		}
+		resp.RenderResponse(w, r, http.StatusOK, report)
+	}
+}
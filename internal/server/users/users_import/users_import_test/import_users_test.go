@@ -0,0 +1,213 @@
+package users_import_test
+
+import (
+	"encoding/json"
+	validators "github.com/ShlykovPavel/users-microservice/internal/lib/api/validator"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users"
+	"github.com/ShlykovPavel/users-microservice/internal/server/users/users_import"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db/users_db_mock"
+	"github.com/ShlykovPavel/users-microservice/models/users/import_users"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const maxRows = 5
+
+// csvFile первая строка записывается, вторая не проходит валидацию, третья повторяет email первой
+const csvFile = "first_name,last_name,email,phone,password\n" +
+	"Ivan,Petrov,ivan@example.com,+7 951 234-56-78,secret\n" +
+	"Maria,Ivanova,not-an-email,+79512345679,secret\n" +
+	"Petr,Sidorov,IVAN@example.com,+79512345670,secret\n"
+
+// ndjsonFile те же строки, что и csvFile
+const ndjsonFile = `{"first_name":"Ivan","last_name":"Petrov","email":"ivan@example.com","phone":"+7 951 234-56-78","password":"secret"}
+{"first_name":"Maria","last_name":"Ivanova","email":"not-an-email","phone":"+79512345679","password":"secret"}
+{"first_name":"Petr","last_name":"Sidorov","email":"IVAN@example.com","phone":"+79512345670","password":"secret"}
+`
+
+func TestMain(m *testing.M) {
+	if err := validators.InitValidator(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func newHandler(userRepository users_db.UserRepository) http.HandlerFunc {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	return users_import.ImportUsersHandler(logger, userRepository, nil, maxRows, time.Second)
+}
+
+// importedUser проверяет, что в репозиторий передана только первая строка файла с нормализованным телефоном
+func importedUser(password func(string) bool) interface{} {
+	return mock.MatchedBy(func(importUsers []import_users.ImportUser) bool {
+		return len(importUsers) == 1 && importUsers[0].Email == "ivan@example.com" &&
+			importUsers[0].Phone == "+79512345678" && importUsers[0].PasswordHash == "" && password(importUsers[0].Password)
+	})
+}
+
+func decodeReport(t *testing.T, w *httptest.ResponseRecorder) import_users.ImportUsersResponse {
+	var report import_users.ImportUsersResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	return report
+}
+
+func TestImportUsersHandlerParsesFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+	}{
+		{name: "CSV by Content-Type", url: "/users/import", contentType: "text/csv; charset=utf-8", body: csvFile},
+		{name: "NDJSON by Content-Type", url: "/users/import", contentType: "application/x-ndjson", body: ndjsonFile},
+		{name: "CSV by format parameter", url: "/users/import?format=csv", contentType: "application/octet-stream", body: csvFile},
+		{name: "NDJSON by format parameter", url: "/users/import?format=ndjson", body: ndjsonFile},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			userRepository.On("ImportUsers", mock.Anything, importedUser(users.IsPasswordHash), users_db.OnConflictSkip, false).
+				Return([]users_db.ImportResult{{ID: 10, Status: import_users.RowCreated}}, nil).Once()
+
+			req := httptest.NewRequest(http.MethodPost, test.url, strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			w := httptest.NewRecorder()
+			newHandler(userRepository).ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			report := decodeReport(t, w)
+			require.False(t, report.DryRun)
+			require.Equal(t, users_db.OnConflictSkip, report.OnConflict)
+			require.Equal(t, 3, report.Total)
+			require.Equal(t, 1, report.Created)
+			require.Equal(t, 2, report.Failed)
+			require.Equal(t, import_users.ImportRowResult{Row: 1, Email: "ivan@example.com", Status: import_users.RowCreated, UserId: 10}, report.Rows[0])
+			// Строки с ошибками попадают в отчёт с номером строки и причиной, но не передаются в репозиторий
+			require.Equal(t, 2, report.Rows[1].Row)
+			require.Equal(t, import_users.RowFailed, report.Rows[1].Status)
+			require.Contains(t, report.Rows[1].Error, "Email")
+			require.Equal(t, import_users.ImportRowResult{Row: 3, Email: "IVAN@example.com", Status: import_users.RowFailed,
+				Error: "email is duplicated in import file"}, report.Rows[2])
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImportUsersHandlerDryRun(t *testing.T) {
+	userRepository := new(users_db_mock.MockUserRepository)
+	// При проверке пароль не хешируется: транзакция всё равно откатывается
+	userRepository.On("ImportUsers", mock.Anything, importedUser(func(password string) bool { return password == "dry-run" }),
+		users_db.OnConflictUpdate, true).
+		Return([]users_db.ImportResult{{ID: 10, Status: import_users.RowUpdated}}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/import?dry_run=true&on_conflict=update", strings.NewReader(csvFile))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	newHandler(userRepository).ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	report := decodeReport(t, w)
+	require.True(t, report.DryRun)
+	require.Equal(t, users_db.OnConflictUpdate, report.OnConflict)
+	require.Equal(t, 1, report.Updated)
+	require.Equal(t, 2, report.Failed)
+	// Записи откатываются, поэтому id пользователей в отчёт не попадают
+	require.Equal(t, import_users.ImportRowResult{Row: 1, Email: "ivan@example.com", Status: import_users.RowUpdated}, report.Rows[0])
+	userRepository.AssertExpectations(t)
+}
+
+func TestImportUsersHandlerConflictRollsBack(t *testing.T) {
+	const file = "first_name,last_name,email,phone,password\n" +
+		"Ivan,Petrov,ivan@example.com,+79512345678,secret\n" +
+		"Maria,Ivanova,maria@example.com,+79512345679,secret\n" +
+		"Petr,Sidorov,not-an-email,+79512345670,secret\n"
+
+	for _, dryRun := range []bool{false, true} {
+		name := "Import"
+		if dryRun {
+			name = "Dry run"
+		}
+		t.Run(name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+			userRepository.On("ImportUsers", mock.Anything, mock.AnythingOfType("[]import_users.ImportUser"), users_db.OnConflictFail, dryRun).
+				Return([]users_db.ImportResult{
+					{ID: 10, Status: import_users.RowCreated},
+					{Status: import_users.RowSkipped},
+				}, users_db.ErrImportConflict).Once()
+
+			url := "/users/import?on_conflict=fail"
+			if dryRun {
+				url += "&dry_run=1"
+			}
+			req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(file))
+			req.Header.Set("Content-Type", "text/csv")
+			w := httptest.NewRecorder()
+			newHandler(userRepository).ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusConflict, w.Code)
+			report := decodeReport(t, w)
+			require.Equal(t, dryRun, report.DryRun)
+			require.Equal(t, 0, report.Created)
+			require.Equal(t, 2, report.Failed)
+			// Записанная строка отменена вместе со всем импортом, строка с занятым email — причина отмены
+			require.Equal(t, import_users.ImportRowResult{Row: 1, Email: "ivan@example.com", Status: import_users.RowRolledBack}, report.Rows[0])
+			require.Equal(t, import_users.ImportRowResult{Row: 2, Email: "maria@example.com", Status: import_users.RowFailed,
+				Error: users_db.ErrEmailAlreadyExists.Error()}, report.Rows[1])
+			require.Equal(t, import_users.RowFailed, report.Rows[2].Status)
+			userRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImportUsersHandlerRejectsRequest(t *testing.T) {
+	tooManyRows := "first_name,last_name,email,phone,password\n" +
+		strings.Repeat("Ivan,Petrov,ivan@example.com,+79512345678,secret\n", maxRows+1)
+
+	tests := []struct {
+		name         string
+		url          string
+		contentType  string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "Unknown format", url: "/users/import", contentType: "application/json", body: ndjsonFile,
+			expectedCode: http.StatusBadRequest},
+		{name: "Unknown format parameter", url: "/users/import?format=xlsx", contentType: "text/csv", body: csvFile,
+			expectedCode: http.StatusBadRequest},
+		{name: "Invalid on_conflict", url: "/users/import?on_conflict=replace", contentType: "text/csv", body: csvFile,
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"on_conflict must be one of skip, update, fail"`},
+		{name: "Invalid dry_run", url: "/users/import?dry_run=maybe", contentType: "text/csv", body: csvFile,
+			expectedCode: http.StatusBadRequest, expectedBody: `"error":"dry_run must be a boolean"`},
+		{name: "Unknown CSV column", url: "/users/import", contentType: "text/csv", body: "first_name,nickname\nIvan,ivan\n",
+			expectedCode: http.StatusBadRequest, expectedBody: `nickname`},
+		{name: "Empty file", url: "/users/import", contentType: "text/csv", body: "",
+			expectedCode: http.StatusBadRequest},
+		{name: "Too many rows", url: "/users/import", contentType: "text/csv", body: tooManyRows,
+			expectedCode: http.StatusRequestEntityTooLarge, expectedBody: `"error":"import file must have at most 5 rows"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userRepository := new(users_db_mock.MockUserRepository)
+
+			req := httptest.NewRequest(http.MethodPost, test.url, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			w := httptest.NewRecorder()
+			newHandler(userRepository).ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedCode, w.Code)
+			require.Contains(t, w.Body.String(), test.expectedBody)
+			userRepository.AssertNotCalled(t, "ImportUsers", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -33,3 +33,9 @@ func ComparePassword(hashedPassword string, password string, log *slog.Logger) b
 	}
 	return true
 }
+
+// IsPasswordHash Проверяет, что строка — bcrypt хеш пароля. Используется для импорта готовых хешей
+func IsPasswordHash(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
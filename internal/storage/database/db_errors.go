@@ -19,17 +19,17 @@ func PsqlErrorHandler(err error) error {
 	if pgErr, ok := err.(*pgconn.PgError); ok {
 		switch pgErr.Code {
 		case PSQLUniqueError: // unique_violation
-			return fmt.Errorf("Нарушение уникальности: %w", err)
+			return fmt.Errorf("unique violation: %w", err)
 		case PSQLForeignKeyError: // foreign_key_violation
-			return fmt.Errorf("нарушение внешнего ключа: %w", err)
+			return fmt.Errorf("foreign key violation: %w", err)
 		case PSQLNotNullError: // not_null_violation
-			return fmt.Errorf("нельзя вставить NULL: %w", err)
+			return fmt.Errorf("not null violation: %w", err)
 		case PSQLStringDataRightTruncationError: // string_data_right_truncation
-			return fmt.Errorf("данные слишком длинные: %w", err)
+			return fmt.Errorf("value too long: %w", err)
 		case PSQLSyntaxError: // syntax_error
-			return fmt.Errorf("синтаксическая ошибка в SQL: %w", err)
+			return fmt.Errorf("SQL syntax error: %w", err)
 		default:
-			return fmt.Errorf("ошибка PostgreSQL (%s): %w", pgErr.Code, err)
+			return fmt.Errorf("PostgreSQL error (%s): %w", pgErr.Code, err)
 		}
 	}
 	return err
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS login_events_user_id_created_at_idx;
+DROP TABLE IF EXISTS login_events;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS login_events
+(
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    email          VARCHAR(256) NOT NULL,
+    success        BOOLEAN      NOT NULL,
+    failure_reason VARCHAR(64),
+    ip_address     VARCHAR(64)  NOT NULL,
+    ip_network     VARCHAR(64)  NOT NULL,
+    user_agent     VARCHAR(512) NOT NULL,
+    method         VARCHAR(32)  NOT NULL,
+    suspicious     BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at     TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_events_user_id_created_at_idx ON login_events (user_id, created_at DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS impersonation_audit_target_user_id_idx;
+DROP INDEX IF EXISTS impersonation_audit_admin_id_idx;
+DROP TABLE IF EXISTS impersonation_audit;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS impersonation_audit
+(
+    id             BIGSERIAL PRIMARY KEY,
+    admin_id       INTEGER     NOT NULL,
+    target_user_id INTEGER     NOT NULL,
+    action         VARCHAR(32) NOT NULL,
+    method         VARCHAR(16),
+    path           VARCHAR(2048),
+    status         INTEGER,
+    request_id     VARCHAR(128),
+    ip_address     VARCHAR(64),
+    created_at     TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_audit_admin_id_idx ON impersonation_audit (admin_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS impersonation_audit_target_user_id_idx ON impersonation_audit (target_user_id, created_at DESC);
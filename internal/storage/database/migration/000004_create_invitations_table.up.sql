@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          SERIAL PRIMARY KEY,
+    email       VARCHAR(256),
+    role        VARCHAR      NOT NULL DEFAULT 'user',
+    token_hash  VARCHAR(64)  NOT NULL UNIQUE,
+    created_by  INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at TIMESTAMP WITH TIME ZONE,
+    consumed_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    revoked_at  TIMESTAMP WITH TIME ZONE,
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
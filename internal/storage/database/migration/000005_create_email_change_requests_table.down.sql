@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS email_change_requests_user_id_idx;
+DROP TABLE IF EXISTS email_change_requests;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS email_change_requests
+(
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    old_email    VARCHAR(256) NOT NULL,
+    new_email    VARCHAR(256) NOT NULL,
+    token_hash   VARCHAR(64)  NOT NULL UNIQUE,
+    expires_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    created_at   TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS email_change_requests_user_id_idx ON email_change_requests (user_id);
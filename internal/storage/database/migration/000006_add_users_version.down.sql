@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+
+CREATE TRIGGER update_users_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+DROP FUNCTION IF EXISTS update_users_updated_at_and_version;
+
+ALTER TABLE users DROP COLUMN IF EXISTS version;
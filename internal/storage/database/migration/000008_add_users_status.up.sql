@@ -0,0 +1,12 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status            VARCHAR(16) NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_reason     TEXT,
+    ADD COLUMN IF NOT EXISTS status_until      TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS status_changed_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_status_check
+        CHECK (status IN ('pending', 'active', 'suspended', 'locked', 'deactivated'));
+
+CREATE INDEX IF NOT EXISTS users_status_idx ON users (status);
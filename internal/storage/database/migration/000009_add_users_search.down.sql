@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_search_vector_idx;
+DROP INDEX IF EXISTS users_search_text_trgm_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_text;
@@ -0,0 +1,13 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Текст для нечёткого поиска (pg_trgm) и полнотекстовый вектор по имени, фамилии, email и телефону
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS search_text   TEXT GENERATED ALWAYS AS (
+        lower(first_name || ' ' || last_name || ' ' || email || ' ' || phone)
+        ) STORED,
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || phone)
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS users_search_text_trgm_idx ON users USING GIN (search_text gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_search_vector_idx ON users USING GIN (search_vector);
@@ -0,0 +1,5 @@
+-- Индексы атрибутов (users_attr_*) создаются вместе с определениями и удаляются вместе с колонкой
+DROP TABLE IF EXISTS user_attribute_definitions;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS attributes;
@@ -0,0 +1,22 @@
+-- Дополнительные атрибуты пользователей, набор которых задаёт администратор
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS user_attribute_definitions
+(
+    name        VARCHAR(40) PRIMARY KEY,
+    type        VARCHAR(16) NOT NULL CHECK (type IN ('string', 'integer', 'number', 'boolean', 'date')),
+    required    BOOLEAN     NOT NULL DEFAULT FALSE,
+    enum_values TEXT[],
+    pattern     TEXT,
+    is_unique   BOOLEAN     NOT NULL DEFAULT FALSE,
+    indexed     BOOLEAN     NOT NULL DEFAULT FALSE,
+    visibility  VARCHAR(16) NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'private', 'admin')),
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_user_attribute_definitions_updated_at
+    BEFORE UPDATE ON user_attribute_definitions
+    FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,15 @@
+-- Настройки пользователей по пространствам имён (ui, notifications, ...)
+CREATE TABLE IF NOT EXISTS user_preferences
+(
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    namespace  VARCHAR(64) NOT NULL,
+    value      JSONB       NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, namespace)
+);
+
+CREATE TRIGGER update_user_preferences_updated_at
+    BEFORE UPDATE ON user_preferences
+    FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
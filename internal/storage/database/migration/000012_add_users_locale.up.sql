@@ -0,0 +1,5 @@
+-- Отображаемое имя, язык сообщений и часовой пояс пользователя. NULL — не заданы
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS display_name VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS locale       VARCHAR(16),
+    ADD COLUMN IF NOT EXISTS timezone     VARCHAR(64);
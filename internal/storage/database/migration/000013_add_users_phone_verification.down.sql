@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS check_users_phone_unique ON users;
+DROP FUNCTION IF EXISTS check_users_phone_unique();
+DROP INDEX IF EXISTS users_phone_active_idx;
+DROP TABLE IF EXISTS service_settings;
+
+DROP TABLE IF EXISTS phone_verifications;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS phone_verified_at,
+    DROP COLUMN IF EXISTS phone_country_code;
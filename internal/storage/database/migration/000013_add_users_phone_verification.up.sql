@@ -0,0 +1,71 @@
+-- Код страны телефона в формате E.164 и время подтверждения телефона по SMS. NULL — не подтверждён
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS phone_country_code SMALLINT,
+    ADD COLUMN IF NOT EXISTS phone_verified_at  TIMESTAMP WITH TIME ZONE;
+
+-- Коды подтверждения телефона. У пользователя действует только последний код
+CREATE TABLE IF NOT EXISTS phone_verifications
+(
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    phone       VARCHAR(16) NOT NULL,
+    code_hash   VARCHAR(64) NOT NULL,
+    attempts    INTEGER     NOT NULL DEFAULT 0,
+    expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS phone_verifications_user_id_idx ON phone_verifications (user_id);
+
+-- Настройки сервиса, которые нужны триггерам. Сервис записывает их при запуске (см. SetPhoneUnique),
+-- схема при этом не меняется
+CREATE TABLE IF NOT EXISTS service_settings
+(
+    name    VARCHAR(64) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO service_settings (name, enabled)
+VALUES ('phone_unique', FALSE)
+ON CONFLICT (name) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS users_phone_active_idx ON users (phone) WHERE deleted_at IS NULL;
+
+-- При включённой настройке phone_unique телефон не может совпадать с телефоном другого действующего пользователя.
+-- Телефон блокируется рекомендательной блокировкой, так что параллельные записи одного телефона проверяются по очереди
+CREATE OR REPLACE FUNCTION check_users_phone_unique()
+    RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.deleted_at IS NOT NULL THEN
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'UPDATE' AND OLD.phone = NEW.phone AND OLD.deleted_at IS NULL THEN
+        RETURN NEW;
+    END IF;
+    IF NOT COALESCE((SELECT enabled FROM service_settings WHERE name = 'phone_unique'), FALSE) THEN
+        RETURN NEW;
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('user_phone:' || NEW.phone));
+    IF EXISTS (SELECT 1
+               FROM users
+               WHERE phone = NEW.phone
+                 AND id <> NEW.id
+                 AND deleted_at IS NULL
+                 -- INSERT ... ON CONFLICT (lower(email)) вызывает триггер и для строки, которая обновит
+                 -- существующего пользователя с тем же email. Телефон этого пользователя занятым не считается:
+                 -- если строка всё же вставится, её отклонит уникальный индекс email
+                 AND NOT (TG_OP = 'INSERT' AND lower(email) = lower(NEW.email))) THEN
+        RAISE EXCEPTION 'phone % is already used by another user', NEW.phone
+            USING ERRCODE = 'unique_violation', CONSTRAINT = 'users_phone_active_key';
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS check_users_phone_unique ON users;
+
+CREATE TRIGGER check_users_phone_unique
+    BEFORE INSERT OR UPDATE OF phone, deleted_at ON users
+    FOR EACH ROW
+EXECUTE FUNCTION check_users_phone_unique();
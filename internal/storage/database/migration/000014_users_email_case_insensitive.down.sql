@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_email_active_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_active_key ON users (email) WHERE deleted_at IS NULL;
@@ -0,0 +1,12 @@
+-- Email уникален без учёта регистра. Перед применением проверьте, нет ли адресов, которые станут дубликатами:
+--   go run ./cmd/email_collisions
+-- Если дубликаты есть, создание индекса завершится ошибкой и миграция не применится
+DROP INDEX IF EXISTS users_email_active_key;
+
+-- Канонический вид email: без пробелов по краям, домен в нижнем регистре
+UPDATE users
+SET email = substring(trim(email) FROM '^(.*)@') || '@' || lower(substring(trim(email) FROM '@([^@]*)$'))
+WHERE trim(email) LIKE '%_@_%'
+  AND email <> substring(trim(email) FROM '^(.*)@') || '@' || lower(substring(trim(email) FROM '@([^@]*)$'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_active_key ON users (lower(email)) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS sync_users_primary_email ON users;
+DROP FUNCTION IF EXISTS sync_users_primary_email();
+DROP TABLE IF EXISTS user_emails;
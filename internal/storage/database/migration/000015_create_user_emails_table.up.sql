@@ -0,0 +1,67 @@
+-- Адреса email пользователя. Ровно один адрес основной, он же хранится в users.email.
+-- Войти можно по любому подтверждённому адресу
+CREATE TABLE IF NOT EXISTS user_emails
+(
+    id               SERIAL PRIMARY KEY,
+    user_id          INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email            VARCHAR(256) NOT NULL,
+    is_primary       BOOLEAN      NOT NULL DEFAULT FALSE,
+    verified_at      TIMESTAMP WITH TIME ZONE,
+    token_hash       VARCHAR(64) UNIQUE,
+    token_expires_at TIMESTAMP WITH TIME ZONE,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_emails_user_email_key ON user_emails (user_id, lower(email));
+CREATE UNIQUE INDEX IF NOT EXISTS user_emails_primary_key ON user_emails (user_id) WHERE is_primary;
+CREATE INDEX IF NOT EXISTS user_emails_email_idx ON user_emails (lower(email)) WHERE verified_at IS NOT NULL;
+
+-- Текущие email пользователей становятся основными подтверждёнными адресами
+INSERT INTO user_emails (user_id, email, is_primary, verified_at, created_at)
+SELECT id, email, TRUE, created_at, created_at
+FROM users
+ON CONFLICT DO NOTHING;
+
+-- users.email — основной адрес пользователя. Запись email в users (регистрация, импорт, смена email)
+-- заменяет основной адрес в user_emails. Email не может совпадать с подтверждённым адресом другого действующего пользователя.
+-- На время проверки адрес блокируется рекомендательной блокировкой pg_advisory_xact_lock(hashtext('user_email:' || lower(email))):
+-- без неё запись users.email и подтверждение того же адреса у другого пользователя в параллельных транзакциях
+-- не видят друг друга и обе проходят проверку
+CREATE OR REPLACE FUNCTION sync_users_primary_email()
+    RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.deleted_at IS NOT NULL THEN
+        RETURN NEW;
+    END IF;
+    -- Та же блокировка берётся при подтверждении дополнительного адреса (VerifyEmail)
+    PERFORM pg_advisory_xact_lock(hashtext('user_email:' || lower(NEW.email)));
+    IF EXISTS (SELECT 1
+               FROM user_emails ue
+                        JOIN users u ON u.id = ue.user_id
+               WHERE lower(ue.email) = lower(NEW.email)
+                 AND ue.user_id <> NEW.id
+                 AND ue.verified_at IS NOT NULL
+                 AND u.deleted_at IS NULL) THEN
+        RAISE EXCEPTION 'email % is already used by another user', NEW.email
+            USING ERRCODE = 'unique_violation', CONSTRAINT = 'users_email_active_key';
+    END IF;
+
+    DELETE FROM user_emails WHERE user_id = NEW.id AND is_primary AND lower(email) <> lower(NEW.email);
+    INSERT INTO user_emails (user_id, email, is_primary, verified_at)
+    VALUES (NEW.id, NEW.email, TRUE, CURRENT_TIMESTAMP)
+    ON CONFLICT (user_id, lower(email)) DO UPDATE
+        SET email            = EXCLUDED.email,
+            is_primary       = TRUE,
+            verified_at      = COALESCE(user_emails.verified_at, EXCLUDED.verified_at),
+            token_hash       = NULL,
+            token_expires_at = NULL;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS sync_users_primary_email ON users;
+
+CREATE TRIGGER sync_users_primary_email
+    AFTER INSERT OR UPDATE OF email, deleted_at ON users
+    FOR EACH ROW
+EXECUTE FUNCTION sync_users_primary_email();
@@ -0,0 +1,201 @@
+package attributes_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/attribute_definition"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+)
+
+var ErrDefinitionNotFound = errors.New("attribute definition not found")
+var ErrDefinitionExists = errors.New("attribute definition already exists")
+
+// ErrValuesNotUnique атрибут нельзя сделать уникальным: у пользователей уже есть одинаковые значения
+var ErrValuesNotUnique = errors.New("users already have duplicate values of the attribute")
+
+type AttributeRepository interface {
+	ListDefinitions(ctx context.Context) ([]attribute_definition.Definition, error)
+	CreateDefinition(ctx context.Context, definition attribute_definition.Definition) (attribute_definition.Definition, error)
+	UpdateDefinition(ctx context.Context, definition attribute_definition.Definition) (attribute_definition.Definition, error)
+	DeleteDefinition(ctx context.Context, name string) error
+}
+
+type AttributeRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+const definitionColumns = `name, type, required, COALESCE(enum_values, '{}'), COALESCE(pattern, ''), is_unique, indexed, visibility,
+    created_at, updated_at`
+
+func NewAttributesDB(dbPoll *pgxpool.Pool, log *slog.Logger) *AttributeRepositoryImpl {
+	return &AttributeRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+func scanDefinition(row pgx.Row) (attribute_definition.Definition, error) {
+	var definition attribute_definition.Definition
+	err := row.Scan(&definition.Name, &definition.Type, &definition.Required, &definition.EnumValues, &definition.Pattern,
+		&definition.Unique, &definition.Indexed, &definition.Visibility, &definition.CreatedAt, &definition.UpdatedAt)
+	if len(definition.EnumValues) == 0 {
+		definition.EnumValues = nil
+	}
+	return definition, err
+}
+
+// ListDefinitions Возвращает все определения атрибутов, упорядоченные по имени
+func (ar *AttributeRepositoryImpl) ListDefinitions(ctx context.Context) ([]attribute_definition.Definition, error) {
+	rows, err := ar.db.Query(ctx, `SELECT `+definitionColumns+` FROM user_attribute_definitions ORDER BY name`)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ar.log); ctxErr != nil {
+			return nil, ctxErr
+		}
+		ar.log.Error("Failed to query attribute definitions", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to query attribute definitions: %w", err)
+	}
+	defer rows.Close()
+
+	var definitions []attribute_definition.Definition
+	for rows.Next() {
+		definition, err := scanDefinition(rows)
+		if err != nil {
+			ar.log.Error("Error scanning attribute definition row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning attribute definition row: %w", err)
+		}
+		definitions = append(definitions, definition)
+	}
+	if err := rows.Err(); err != nil {
+		ar.log.Error("Error reading rows", slog.Any("error", err))
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+	return definitions, nil
+}
+
+// CreateDefinition Сохраняет определение атрибута и в той же транзакции строит его индексы:
+// уникальный по значениям действующих пользователей (Unique) и индекс для фильтрации и сортировки (Indexed).
+// Индексы строятся с блокировкой записи в users, поэтому создавать атрибуты лучше в спокойное время
+func (ar *AttributeRepositoryImpl) CreateDefinition(ctx context.Context, definition attribute_definition.Definition) (attribute_definition.Definition, error) {
+	tx, err := ar.db.Begin(ctx)
+	if err != nil {
+		ar.log.Error("Failed to begin transaction", slog.Any("error", err))
+		return attribute_definition.Definition{}, database.PsqlErrorHandler(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+INSERT INTO user_attribute_definitions (name, type, required, enum_values, pattern, is_unique, indexed, visibility)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+RETURNING ` + definitionColumns
+	created, err := scanDefinition(tx.QueryRow(ctx, query, definition.Name, definition.Type, definition.Required, definition.EnumValues,
+		definition.Pattern, definition.Unique, definition.Indexed, definition.Visibility))
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ar.log); ctxErr != nil {
+			return attribute_definition.Definition{}, ctxErr
+		}
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == database.PSQLUniqueError {
+			return attribute_definition.Definition{}, ErrDefinitionExists
+		}
+		ar.log.Error("Failed to create attribute definition in db", slog.String("error", err.Error()))
+		return attribute_definition.Definition{}, database.PsqlErrorHandler(err)
+	}
+
+	// Имя атрибута проверено user_attributes.ValidName, поэтому подставляется в DDL как есть
+	var statements []string
+	if definition.Unique {
+		statements = append(statements, fmt.Sprintf("CREATE UNIQUE INDEX %s ON users ((attributes->>'%s')) WHERE deleted_at IS NULL",
+			users_db.AttributeUniqueIndex(definition.Name), definition.Name))
+	}
+	if definition.Indexed {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX %s ON users (%s)",
+			users_db.AttributeIndex(definition.Name), users_db.AttributeSQL(definition.Name, definition.Type)))
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(ctx, statement); err != nil {
+			if ctxErr := database.DbCtxError(ctx, err, ar.log); ctxErr != nil {
+				return attribute_definition.Definition{}, ctxErr
+			}
+			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == database.PSQLUniqueError {
+				return attribute_definition.Definition{}, ErrValuesNotUnique
+			}
+			ar.log.Error("Failed to create attribute index", slog.String("error", err.Error()))
+			return attribute_definition.Definition{}, database.PsqlErrorHandler(err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		ar.log.Error("Failed to commit attribute definition", slog.Any("error", err))
+		return attribute_definition.Definition{}, database.PsqlErrorHandler(err)
+	}
+	return created, nil
+}
+
+// UpdateDefinition Меняет обязательность, правила значения и видимость атрибута
+func (ar *AttributeRepositoryImpl) UpdateDefinition(ctx context.Context, definition attribute_definition.Definition) (attribute_definition.Definition, error) {
+	query := `
+UPDATE user_attribute_definitions SET required = $2, enum_values = $3, pattern = NULLIF($4, ''), visibility = $5
+WHERE name = $1
+RETURNING ` + definitionColumns
+	updated, err := scanDefinition(ar.db.QueryRow(ctx, query, definition.Name, definition.Required, definition.EnumValues,
+		definition.Pattern, definition.Visibility))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return attribute_definition.Definition{}, ErrDefinitionNotFound
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ar.log); ctxErr != nil {
+			return attribute_definition.Definition{}, ctxErr
+		}
+		ar.log.Error("Failed to update attribute definition in db", slog.String("error", err.Error()))
+		return attribute_definition.Definition{}, database.PsqlErrorHandler(err)
+	}
+	return updated, nil
+}
+
+// DeleteDefinition Удаляет определение атрибута, его индексы и значения атрибута у всех пользователей
+func (ar *AttributeRepositoryImpl) DeleteDefinition(ctx context.Context, name string) error {
+	tx, err := ar.db.Begin(ctx)
+	if err != nil {
+		ar.log.Error("Failed to begin transaction", slog.Any("error", err))
+		return database.PsqlErrorHandler(err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM user_attribute_definitions WHERE name = $1`, name)
+	if err != nil {
+		ar.log.Error("Failed to delete attribute definition in db", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrDefinitionNotFound
+	}
+	statements := []string{
+		"DROP INDEX IF EXISTS " + users_db.AttributeUniqueIndex(name),
+		"DROP INDEX IF EXISTS " + users_db.AttributeIndex(name),
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(ctx, statement); err != nil {
+			ar.log.Error("Failed to drop attribute index", slog.String("error", err.Error()))
+			return database.PsqlErrorHandler(err)
+		}
+	}
+	if _, err = tx.Exec(ctx, `UPDATE users SET attributes = attributes - $1 WHERE attributes ? $1`, name); err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ar.log); ctxErr != nil {
+			return ctxErr
+		}
+		ar.log.Error("Failed to remove attribute values", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		ar.log.Error("Failed to commit attribute definition removal", slog.Any("error", err))
+		return database.PsqlErrorHandler(err)
+	}
+	return nil
+}
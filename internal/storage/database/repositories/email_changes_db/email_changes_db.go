@@ -0,0 +1,121 @@
+package email_changes_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// ErrEmailChangeInvalid запрос на смену email не найден, уже подтверждён или истёк
+var ErrEmailChangeInvalid = errors.New("email change token is invalid or expired")
+
+type EmailChangeRepository interface {
+	CreateEmailChange(ctx context.Context, change *EmailChange) (int64, error)
+	ConfirmEmailChange(ctx context.Context, tokenHash string) (EmailChange, error)
+}
+
+type EmailChangeRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+// EmailChange Запрос на смену email, ожидающий подтверждения нового адреса
+type EmailChange struct {
+	ID          int64
+	UserID      int64
+	OldEmail    string
+	NewEmail    string
+	TokenHash   string
+	ExpiresAt   time.Time
+	ConfirmedAt *time.Time
+}
+
+func NewEmailChangesDB(dbPoll *pgxpool.Pool, log *slog.Logger) *EmailChangeRepositoryImpl {
+	return &EmailChangeRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+// CreateEmailChange Сохраняет запрос на смену email.
+// Предыдущие неподтверждённые запросы пользователя удаляются, действует только последний
+func (ec *EmailChangeRepositoryImpl) CreateEmailChange(ctx context.Context, change *EmailChange) (int64, error) {
+	query := `
+WITH deleted AS (
+    DELETE FROM email_change_requests WHERE user_id = $1 AND confirmed_at IS NULL
+)
+INSERT INTO email_change_requests (user_id, old_email, new_email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id`
+	var id int64
+	err := ec.db.QueryRow(ctx, query, change.UserID, change.OldEmail, change.NewEmail, change.TokenHash, change.ExpiresAt).Scan(&id)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ec.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return id, nil
+}
+
+// ConfirmEmailChange Подтверждает смену email: в одной транзакции меняет email пользователя
+// и помечает запрос подтверждённым.
+// Если новый email за это время занял другой пользователь, возвращается users_db.ErrEmailAlreadyExists
+func (ec *EmailChangeRepositoryImpl) ConfirmEmailChange(ctx context.Context, tokenHash string) (EmailChange, error) {
+	tx, err := ec.db.Begin(ctx)
+	if err != nil {
+		return EmailChange{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+SELECT id, user_id, old_email, new_email, expires_at
+FROM email_change_requests
+WHERE token_hash = $1 AND confirmed_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+FOR UPDATE`
+	var change EmailChange
+	err = tx.QueryRow(ctx, query, tokenHash).Scan(
+		&change.ID,
+		&change.UserID,
+		&change.OldEmail,
+		&change.NewEmail,
+		&change.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return EmailChange{}, ErrEmailChangeInvalid
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ec.log); ctxErr != nil {
+			return EmailChange{}, ctxErr
+		}
+		return EmailChange{}, database.PsqlErrorHandler(err)
+	}
+
+	result, err := tx.Exec(ctx, `UPDATE users SET email = $1 WHERE id = $2 AND deleted_at IS NULL`, change.NewEmail, change.UserID)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == database.PSQLUniqueError {
+			return EmailChange{}, users_db.ErrEmailAlreadyExists
+		}
+		ec.log.Error("Failed to update user email in db", slog.String("error", err.Error()))
+		return EmailChange{}, database.PsqlErrorHandler(err)
+	}
+	if result.RowsAffected() == 0 {
+		return EmailChange{}, users_db.ErrUserNotFound
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE email_change_requests SET confirmed_at = CURRENT_TIMESTAMP WHERE id = $1`, change.ID)
+	if err != nil {
+		ec.log.Error("Failed to confirm email change in db", slog.String("error", err.Error()))
+		return EmailChange{}, database.PsqlErrorHandler(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return EmailChange{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return change, nil
+}
@@ -0,0 +1,58 @@
+package impersonation_audit_db
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+)
+
+// Действия, которые записываются в журнал входа от имени пользователя
+const (
+	ActionTokenIssued = "token_issued" // Администратор получил токен для входа от имени пользователя
+	ActionRequest     = "request"      // Запрос, выполненный с таким токеном
+)
+
+type ImpersonationAuditRepository interface {
+	AddAuditRecord(ctx context.Context, record *AuditRecord) error
+}
+
+type ImpersonationAuditRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+// AuditRecord Запись журнала действий администратора от имени пользователя
+type AuditRecord struct {
+	AdminID      int64
+	TargetUserID int64
+	Action       string
+	Method       string
+	Path         string
+	Status       int
+	RequestID    string
+	IPAddress    string
+}
+
+func NewImpersonationAuditDB(dbPoll *pgxpool.Pool, log *slog.Logger) *ImpersonationAuditRepositoryImpl {
+	return &ImpersonationAuditRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+// AddAuditRecord Сохраняет запись в журнал входа от имени пользователя
+func (ia *ImpersonationAuditRepositoryImpl) AddAuditRecord(ctx context.Context, record *AuditRecord) error {
+	query := `
+INSERT INTO impersonation_audit (admin_id, target_user_id, action, method, path, status, request_id, ip_address)
+VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, 0), NULLIF($7, ''), NULLIF($8, ''))`
+	_, err := ia.db.Exec(ctx, query, record.AdminID, record.TargetUserID, record.Action, record.Method,
+		record.Path, record.Status, record.RequestID, record.IPAddress)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ia.log); ctxErr != nil {
+			return ctxErr
+		}
+		return database.PsqlErrorHandler(err)
+	}
+	return nil
+}
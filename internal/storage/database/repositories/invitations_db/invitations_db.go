@@ -0,0 +1,198 @@
+package invitations_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// ErrInvitationInvalid приглашение не найдено, уже использовано, отозвано, истекло или выписано на другой email
+var ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+
+type InvitationRepository interface {
+	CreateInvitation(ctx context.Context, invitation *Invitation) (int64, error)
+	GetInvitations(ctx context.Context, limit, offset int, sortParams []query_params.SortParam) (InvitationListResult, error)
+	RevokeInvitation(ctx context.Context, id int64) error
+	ConsumeInvitation(ctx context.Context, tokenHash, email string) (Invitation, error)
+	ReleaseInvitation(ctx context.Context, id int64) error
+	SetConsumedBy(ctx context.Context, id, userId int64) error
+}
+
+type InvitationRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+// Invitation Приглашение на регистрацию
+type Invitation struct {
+	ID         int64
+	Email      string // Пустой email означает, что приглашением может воспользоваться кто угодно
+	Role       string
+	TokenHash  string
+	CreatedBy  *int64
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	ConsumedBy *int64
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+type InvitationListResult struct {
+	Invitations []Invitation
+	Total       int64
+}
+
+func NewInvitationsDB(dbPoll *pgxpool.Pool, log *slog.Logger) *InvitationRepositoryImpl {
+	return &InvitationRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+// CreateInvitation Сохраняет приглашение и возвращает его Id
+func (ir *InvitationRepositoryImpl) CreateInvitation(ctx context.Context, invitation *Invitation) (int64, error) {
+	query := `
+INSERT INTO invitations (email, role, token_hash, created_by, expires_at)
+VALUES (NULLIF($1, ''), $2, $3, $4, $5)
+RETURNING id`
+	var id int64
+	err := ir.db.QueryRow(ctx, query, invitation.Email, invitation.Role, invitation.TokenHash,
+		invitation.CreatedBy, invitation.ExpiresAt).Scan(&id)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ir.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return id, nil
+}
+
+func (ir *InvitationRepositoryImpl) GetInvitations(ctx context.Context, limit, offset int, sortParams []query_params.SortParam) (InvitationListResult, error) {
+	query := `
+SELECT id, COALESCE(email, ''), role, created_by, expires_at, consumed_at, consumed_by, revoked_at, created_at
+FROM invitations`
+	countQuery := "SELECT COUNT(*) FROM invitations"
+
+	// Сортировка
+	var orderBy []string
+	if len(sortParams) > 0 {
+		for _, sortParam := range sortParams {
+			orderBy = append(orderBy, sortParam.SQL())
+		}
+		query += " ORDER BY " + strings.Join(orderBy, ", ")
+	} else {
+		query += " ORDER BY created_at DESC, id DESC"
+	}
+	query += " LIMIT $1 OFFSET $2"
+
+	var total int64
+	err := ir.db.QueryRow(ctx, countQuery).Scan(&total)
+	if err != nil {
+		ir.log.Error("Failed to count invitations", slog.Any("error", err))
+		return InvitationListResult{}, fmt.Errorf("failed to count invitations: %w", err)
+	}
+
+	rows, err := ir.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		ir.log.Error("Failed to query invitations", slog.Any("error", err))
+		return InvitationListResult{}, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []Invitation
+	for rows.Next() {
+		var invitation Invitation
+		if err := rows.Scan(&invitation.ID, &invitation.Email, &invitation.Role, &invitation.CreatedBy, &invitation.ExpiresAt,
+			&invitation.ConsumedAt, &invitation.ConsumedBy, &invitation.RevokedAt, &invitation.CreatedAt); err != nil {
+			ir.log.Error("Error scanning invitation row", slog.Any("error", err))
+			return InvitationListResult{}, fmt.Errorf("error scanning invitation row: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		ir.log.Error("Error reading rows", slog.Any("error", err))
+		return InvitationListResult{}, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return InvitationListResult{
+		Invitations: invitations,
+		Total:       total,
+	}, nil
+}
+
+// RevokeInvitation Отзывает ещё не использованное приглашение
+func (ir *InvitationRepositoryImpl) RevokeInvitation(ctx context.Context, id int64) error {
+	query := `UPDATE invitations SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND consumed_at IS NULL AND revoked_at IS NULL`
+	result, err := ir.db.Exec(ctx, query, id)
+	if err != nil {
+		ir.log.Error("Failed to revoke invitation in db", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// ConsumeInvitation Атомарно помечает приглашение использованным.
+// Приглашение должно быть действующим и, если оно выписано на конкретный email, совпадать с ним.
+// Если найти такое приглашение не удалось, возвращается ErrInvitationInvalid
+func (ir *InvitationRepositoryImpl) ConsumeInvitation(ctx context.Context, tokenHash, email string) (Invitation, error) {
+	query := `
+UPDATE invitations SET consumed_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1
+  AND consumed_at IS NULL
+  AND revoked_at IS NULL
+  AND expires_at > CURRENT_TIMESTAMP
+  AND (email IS NULL OR lower(email) = lower($2))
+RETURNING id, COALESCE(email, ''), role, expires_at`
+
+	var invitation Invitation
+	err := ir.db.QueryRow(ctx, query, tokenHash, email).Scan(
+		&invitation.ID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Invitation{}, ErrInvitationInvalid
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ir.log); ctxErr != nil {
+			return Invitation{}, ctxErr
+		}
+		return Invitation{}, database.PsqlErrorHandler(err)
+	}
+	return invitation, nil
+}
+
+// ReleaseInvitation Возвращает приглашение в действующее состояние,
+// если после ConsumeInvitation не удалось создать пользователя
+func (ir *InvitationRepositoryImpl) ReleaseInvitation(ctx context.Context, id int64) error {
+	query := `UPDATE invitations SET consumed_at = NULL WHERE id = $1 AND consumed_by IS NULL`
+	_, err := ir.db.Exec(ctx, query, id)
+	if err != nil {
+		ir.log.Error("Failed to release invitation in db", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+	return nil
+}
+
+// SetConsumedBy Запоминает пользователя, который зарегистрировался по приглашению
+func (ir *InvitationRepositoryImpl) SetConsumedBy(ctx context.Context, id, userId int64) error {
+	query := `UPDATE invitations SET consumed_by = $2 WHERE id = $1`
+	_, err := ir.db.Exec(ctx, query, id, userId)
+	if err != nil {
+		ir.log.Error("Failed to set invitation consumer in db", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+	return nil
+}
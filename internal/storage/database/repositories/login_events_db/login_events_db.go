@@ -0,0 +1,234 @@
+package login_events_db
+
+import (
+	"context"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Способы аутентификации, которые записываются в историю входов
+const (
+	MethodPassword = "password"
+)
+
+// Размеры колонок login_events (в символах). Более длинные значения из запроса обрезаются,
+// чтобы попытка входа всё равно попала в историю
+const (
+	maxEmailLength     = 256
+	maxUserAgentLength = 512
+)
+
+type LoginEventsRepository interface {
+	AddLoginEvent(ctx context.Context, event *LoginEvent) (int64, error)
+	GetKnownDevice(ctx context.Context, userId int64, userAgent, ipNetwork string) (KnownDevice, error)
+	GetLoginEvents(ctx context.Context, userId int64, limit, offset int, sortParams []query_params.SortParam) (LoginEventsListResult, error)
+	GetLastLogins(ctx context.Context, userIds []int64) (map[int64]LoginEvent, error)
+	CountFailedLogins(ctx context.Context, userId int64, since time.Time) (int, error)
+}
+
+type LoginEventsRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+// LoginEvent Запись о попытке аутентификации
+type LoginEvent struct {
+	ID            int64
+	UserID        *int64 // nil, если пользователь с таким email не найден
+	Email         string
+	Success       bool
+	FailureReason string
+	IPAddress     string
+	IPNetwork     string
+	UserAgent     string
+	Method        string
+	Suspicious    bool
+	CreatedAt     time.Time
+}
+
+// KnownDevice Результат сравнения попытки входа с историей успешных входов пользователя
+type KnownDevice struct {
+	HasHistory     bool // У пользователя уже были успешные входы
+	KnownUserAgent bool // С этого user agent уже был успешный вход
+	KnownNetwork   bool // Из этой IP сети уже был успешный вход
+}
+
+type LoginEventsListResult struct {
+	Events []LoginEvent
+	Total  int64
+}
+
+func NewLoginEventsDB(dbPoll *pgxpool.Pool, log *slog.Logger) *LoginEventsRepositoryImpl {
+	return &LoginEventsRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+// AddLoginEvent Сохраняет попытку аутентификации и возвращает её Id
+func (le *LoginEventsRepositoryImpl) AddLoginEvent(ctx context.Context, event *LoginEvent) (int64, error) {
+	query := `
+INSERT INTO login_events (user_id, email, success, failure_reason, ip_address, ip_network, user_agent, method, suspicious)
+VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)
+RETURNING id`
+	var id int64
+	err := le.db.QueryRow(ctx, query, event.UserID, truncate(event.Email, maxEmailLength), event.Success, event.FailureReason,
+		event.IPAddress, event.IPNetwork, truncate(event.UserAgent, maxUserAgentLength), event.Method, event.Suspicious).Scan(&id)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, le.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return id, nil
+}
+
+// GetKnownDevice Проверяет, входил ли пользователь ранее с этого user agent и из этой IP сети.
+// Учитываются только успешные входы. user agent сравнивается в том виде, в котором он сохраняется (см. AddLoginEvent)
+func (le *LoginEventsRepositoryImpl) GetKnownDevice(ctx context.Context, userId int64, userAgent, ipNetwork string) (KnownDevice, error) {
+	query := `
+SELECT COUNT(*) > 0,
+       COALESCE(BOOL_OR(user_agent = $2), FALSE),
+       COALESCE(BOOL_OR(ip_network = $3), FALSE)
+FROM login_events
+WHERE user_id = $1 AND success`
+
+	var device KnownDevice
+	err := le.db.QueryRow(ctx, query, userId, truncate(userAgent, maxUserAgentLength), ipNetwork).Scan(
+		&device.HasHistory,
+		&device.KnownUserAgent,
+		&device.KnownNetwork)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, le.log); ctxErr != nil {
+			return KnownDevice{}, ctxErr
+		}
+		return KnownDevice{}, database.PsqlErrorHandler(err)
+	}
+	return device, nil
+}
+
+// CountFailedLogins Возвращает число входов пользователя с неверным паролем после since и после последнего успешного входа
+func (le *LoginEventsRepositoryImpl) CountFailedLogins(ctx context.Context, userId int64, since time.Time) (int, error) {
+	query := `
+SELECT COUNT(*)
+FROM login_events
+WHERE user_id = $1
+  AND NOT success
+  AND failure_reason = 'wrong_password'
+  AND created_at > $2
+  AND created_at > COALESCE((SELECT MAX(created_at) FROM login_events WHERE user_id = $1 AND success), '-infinity')`
+
+	var count int
+	if err := le.db.QueryRow(ctx, query, userId, since).Scan(&count); err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, le.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return count, nil
+}
+
+func (le *LoginEventsRepositoryImpl) GetLoginEvents(ctx context.Context, userId int64, limit, offset int, sortParams []query_params.SortParam) (LoginEventsListResult, error) {
+	query := `
+SELECT id, user_id, email, success, COALESCE(failure_reason, ''), ip_address, ip_network, user_agent, method, suspicious, created_at
+FROM login_events WHERE user_id = $1`
+	countQuery := "SELECT COUNT(*) FROM login_events WHERE user_id = $1"
+
+	// Сортировка
+	var orderBy []string
+	if len(sortParams) > 0 {
+		for _, sortParam := range sortParams {
+			orderBy = append(orderBy, sortParam.SQL())
+		}
+		query += " ORDER BY " + strings.Join(orderBy, ", ")
+	} else {
+		// Дефолтная сортировка: сначала последние входы
+		query += " ORDER BY created_at DESC, id DESC"
+	}
+	query += " LIMIT $2 OFFSET $3"
+
+	var total int64
+	err := le.db.QueryRow(ctx, countQuery, userId).Scan(&total)
+	if err != nil {
+		le.log.Error("Failed to count login events", slog.Any("error", err))
+		return LoginEventsListResult{}, fmt.Errorf("failed to count login events: %w", err)
+	}
+
+	rows, err := le.db.Query(ctx, query, userId, limit, offset)
+	if err != nil {
+		le.log.Error("Failed to query login events", slog.Any("error", err))
+		return LoginEventsListResult{}, fmt.Errorf("failed to query login events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []LoginEvent
+	for rows.Next() {
+		var event LoginEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Email, &event.Success, &event.FailureReason,
+			&event.IPAddress, &event.IPNetwork, &event.UserAgent, &event.Method, &event.Suspicious, &event.CreatedAt); err != nil {
+			le.log.Error("Error scanning login event row", slog.Any("error", err))
+			return LoginEventsListResult{}, fmt.Errorf("error scanning login event row: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		le.log.Error("Error reading rows", slog.Any("error", err))
+		return LoginEventsListResult{}, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return LoginEventsListResult{
+		Events: events,
+		Total:  total,
+	}, nil
+}
+
+// GetLastLogins Возвращает последний успешный вход каждого из пользователей userIds.
+// Пользователей без успешных входов в результате нет
+func (le *LoginEventsRepositoryImpl) GetLastLogins(ctx context.Context, userIds []int64) (map[int64]LoginEvent, error) {
+	query := `
+SELECT DISTINCT ON (user_id) id, user_id, email, success, ip_address, ip_network, user_agent, method, suspicious, created_at
+FROM login_events
+WHERE user_id = ANY($1) AND success
+ORDER BY user_id, created_at DESC, id DESC`
+
+	rows, err := le.db.Query(ctx, query, userIds)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, le.log); ctxErr != nil {
+			return nil, ctxErr
+		}
+		le.log.Error("Failed to query last logins", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to query last logins: %w", err)
+	}
+	defer rows.Close()
+
+	lastLogins := make(map[int64]LoginEvent, len(userIds))
+	for rows.Next() {
+		var event LoginEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Email, &event.Success,
+			&event.IPAddress, &event.IPNetwork, &event.UserAgent, &event.Method, &event.Suspicious, &event.CreatedAt); err != nil {
+			le.log.Error("Error scanning login event row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning login event row: %w", err)
+		}
+		lastLogins[*event.UserID] = event
+	}
+	if err := rows.Err(); err != nil {
+		le.log.Error("Error reading rows", slog.Any("error", err))
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+	return lastLogins, nil
+}
+
+// truncate обрезает s до maxLength символов. Невалидные для UTF-8 байты заменяются: PostgreSQL их не примет
+func truncate(s string, maxLength int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+	return string([]rune(s)[:maxLength])
+}
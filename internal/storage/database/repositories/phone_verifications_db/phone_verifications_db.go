@@ -0,0 +1,183 @@
+package phone_verifications_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// ErrVerificationNotFound у пользователя нет действующего кода подтверждения телефона
+var ErrVerificationNotFound = errors.New("phone verification not found")
+
+// ErrVerificationInvalid код неверный, истёк или телефон пользователя сменился после отправки кода
+var ErrVerificationInvalid = errors.New("phone verification code is invalid or expired")
+
+// ErrTooManyAttempts код введён неверно слишком много раз, нужно запросить новый
+var ErrTooManyAttempts = errors.New("too many attempts, request a new verification code")
+
+type PhoneVerificationRepository interface {
+	CreateVerification(ctx context.Context, verification *Verification) (int64, error)
+	GetPendingVerification(ctx context.Context, userId int64) (Verification, error)
+	ConfirmVerification(ctx context.Context, userId int64, codeHash string, maxAttempts int) (Verification, error)
+}
+
+type PhoneVerificationRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+// Verification Код подтверждения телефона, отправленный пользователю по SMS
+type Verification struct {
+	ID         int64
+	UserID     int64
+	Phone      string // Телефон, на который отправлен код, в формате E.164
+	CodeHash   string
+	Attempts   int // Сколько раз код вводили неверно
+	ExpiresAt  time.Time
+	VerifiedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func NewPhoneVerificationsDB(dbPoll *pgxpool.Pool, log *slog.Logger) *PhoneVerificationRepositoryImpl {
+	return &PhoneVerificationRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+// CreateVerification Сохраняет код подтверждения телефона.
+// Предыдущие неподтверждённые коды пользователя удаляются, действует только последний
+func (pv *PhoneVerificationRepositoryImpl) CreateVerification(ctx context.Context, verification *Verification) (int64, error) {
+	query := `
+WITH deleted AS (
+    DELETE FROM phone_verifications WHERE user_id = $1 AND verified_at IS NULL
+)
+INSERT INTO phone_verifications (user_id, phone, code_hash, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id`
+	var id int64
+	err := pv.db.QueryRow(ctx, query, verification.UserID, verification.Phone, verification.CodeHash, verification.ExpiresAt).Scan(&id)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, pv.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		pv.log.Error("Failed to create phone verification in db", slog.String("error", err.Error()))
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return id, nil
+}
+
+// GetPendingVerification Возвращает последний неподтверждённый код пользователя, в том числе истёкший.
+// Нужен для ограничения частоты повторной отправки
+func (pv *PhoneVerificationRepositoryImpl) GetPendingVerification(ctx context.Context, userId int64) (Verification, error) {
+	query := `
+SELECT id, user_id, phone, code_hash, attempts, expires_at, created_at
+FROM phone_verifications
+WHERE user_id = $1 AND verified_at IS NULL
+ORDER BY id DESC
+LIMIT 1`
+	var verification Verification
+	err := pv.db.QueryRow(ctx, query, userId).Scan(
+		&verification.ID,
+		&verification.UserID,
+		&verification.Phone,
+		&verification.CodeHash,
+		&verification.Attempts,
+		&verification.ExpiresAt,
+		&verification.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Verification{}, ErrVerificationNotFound
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, pv.log); ctxErr != nil {
+			return Verification{}, ctxErr
+		}
+		return Verification{}, database.PsqlErrorHandler(err)
+	}
+	return verification, nil
+}
+
+// ConfirmVerification Проверяет код пользователя и в одной транзакции помечает код и телефон пользователя подтверждёнными.
+// Телефон подтверждается, только если он не менялся после отправки кода.
+// Неверный код увеличивает счётчик попыток, после maxAttempts неверных попыток код перестаёт действовать
+func (pv *PhoneVerificationRepositoryImpl) ConfirmVerification(ctx context.Context, userId int64, codeHash string, maxAttempts int) (Verification, error) {
+	tx, err := pv.db.Begin(ctx)
+	if err != nil {
+		return Verification{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+SELECT id, user_id, phone, code_hash, attempts, expires_at, created_at
+FROM phone_verifications
+WHERE user_id = $1 AND verified_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+ORDER BY id DESC
+LIMIT 1
+FOR UPDATE`
+	var verification Verification
+	err = tx.QueryRow(ctx, query, userId).Scan(
+		&verification.ID,
+		&verification.UserID,
+		&verification.Phone,
+		&verification.CodeHash,
+		&verification.Attempts,
+		&verification.ExpiresAt,
+		&verification.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Verification{}, ErrVerificationInvalid
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, pv.log); ctxErr != nil {
+			return Verification{}, ctxErr
+		}
+		return Verification{}, database.PsqlErrorHandler(err)
+	}
+	if verification.Attempts >= maxAttempts {
+		return Verification{}, ErrTooManyAttempts
+	}
+
+	if verification.CodeHash != codeHash {
+		// Неверная попытка фиксируется, даже если запрос завершится ошибкой
+		_, err = tx.Exec(ctx, `UPDATE phone_verifications SET attempts = attempts + 1 WHERE id = $1`, verification.ID)
+		if err != nil {
+			pv.log.Error("Failed to count phone verification attempt in db", slog.String("error", err.Error()))
+			return Verification{}, database.PsqlErrorHandler(err)
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return Verification{}, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		if verification.Attempts+1 >= maxAttempts {
+			return Verification{}, ErrTooManyAttempts
+		}
+		return Verification{}, ErrVerificationInvalid
+	}
+
+	var verifiedAt time.Time
+	err = tx.QueryRow(ctx, `
+UPDATE users SET phone_verified_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND phone = $2 AND deleted_at IS NULL
+RETURNING phone_verified_at`, userId, verification.Phone).Scan(&verifiedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Verification{}, ErrVerificationInvalid
+	}
+	if err != nil {
+		pv.log.Error("Failed to verify user phone in db", slog.String("error", err.Error()))
+		return Verification{}, database.PsqlErrorHandler(err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE phone_verifications SET verified_at = $1 WHERE id = $2`, verifiedAt, verification.ID)
+	if err != nil {
+		pv.log.Error("Failed to confirm phone verification in db", slog.String("error", err.Error()))
+		return Verification{}, database.PsqlErrorHandler(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return Verification{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	verification.VerifiedAt = &verifiedAt
+	return verification, nil
+}
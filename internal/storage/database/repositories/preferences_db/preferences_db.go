@@ -0,0 +1,103 @@
+package preferences_db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+var ErrPreferencesNotFound = errors.New("preferences not found")
+
+type PreferencesRepository interface {
+	GetPreferences(ctx context.Context, userId int64, namespace string) (Preferences, error)
+	PutPreferences(ctx context.Context, userId int64, namespace string, value json.RawMessage) (Preferences, bool, error)
+	DeletePreferences(ctx context.Context, userId int64, namespace string) error
+}
+
+type PreferencesRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+// Preferences Настройки пользователя в одном пространстве имён
+type Preferences struct {
+	UserID    int64
+	Namespace string
+	Value     json.RawMessage // JSON объект настроек
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func NewPreferencesDB(dbPoll *pgxpool.Pool, log *slog.Logger) *PreferencesRepositoryImpl {
+	return &PreferencesRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+// GetPreferences Возвращает настройки пользователя в пространстве имён namespace
+func (pr *PreferencesRepositoryImpl) GetPreferences(ctx context.Context, userId int64, namespace string) (Preferences, error) {
+	query := `
+SELECT value, created_at, updated_at FROM user_preferences
+WHERE user_id = $1 AND namespace = $2`
+	preferences := Preferences{UserID: userId, Namespace: namespace}
+	err := pr.db.QueryRow(ctx, query, userId, namespace).Scan(&preferences.Value, &preferences.CreatedAt, &preferences.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Preferences{}, ErrPreferencesNotFound
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, pr.log); ctxErr != nil {
+			return Preferences{}, ctxErr
+		}
+		pr.log.Error("Failed to get preferences from db", slog.String("error", err.Error()))
+		return Preferences{}, database.PsqlErrorHandler(err)
+	}
+	return preferences, nil
+}
+
+// PutPreferences Сохраняет настройки пользователя в пространстве имён namespace, заменяя прежние.
+// Второе значение — true, если настроек в этом пространстве имён раньше не было
+func (pr *PreferencesRepositoryImpl) PutPreferences(ctx context.Context, userId int64, namespace string, value json.RawMessage) (Preferences, bool, error) {
+	query := `
+INSERT INTO user_preferences (user_id, namespace, value)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, namespace) DO UPDATE SET value = EXCLUDED.value
+RETURNING value, created_at, updated_at, xmax = 0`
+	preferences := Preferences{UserID: userId, Namespace: namespace}
+	var created bool
+	err := pr.db.QueryRow(ctx, query, userId, namespace, value).Scan(&preferences.Value, &preferences.CreatedAt, &preferences.UpdatedAt, &created)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, pr.log); ctxErr != nil {
+			return Preferences{}, false, ctxErr
+		}
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == database.PSQLForeignKeyError {
+			return Preferences{}, false, users_db.ErrUserNotFound
+		}
+		pr.log.Error("Failed to save preferences in db", slog.String("error", err.Error()))
+		return Preferences{}, false, database.PsqlErrorHandler(err)
+	}
+	return preferences, created, nil
+}
+
+// DeletePreferences Удаляет настройки пользователя в пространстве имён namespace
+func (pr *PreferencesRepositoryImpl) DeletePreferences(ctx context.Context, userId int64, namespace string) error {
+	result, err := pr.db.Exec(ctx, `DELETE FROM user_preferences WHERE user_id = $1 AND namespace = $2`, userId, namespace)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, pr.log); ctxErr != nil {
+			return ctxErr
+		}
+		pr.log.Error("Failed to delete preferences in db", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPreferencesNotFound
+	}
+	return nil
+}
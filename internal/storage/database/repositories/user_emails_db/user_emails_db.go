@@ -0,0 +1,287 @@
+package user_emails_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// ErrEmailNotFound у пользователя нет такого адреса
+var ErrEmailNotFound = errors.New("email address not found")
+
+// ErrEmailAlreadyAdded адрес уже добавлен пользователю и подтверждён
+var ErrEmailAlreadyAdded = errors.New("email address is already added")
+
+// ErrEmailVerificationInvalid токен подтверждения адреса не найден, уже использован или истёк
+var ErrEmailVerificationInvalid = errors.New("email verification token is invalid or expired")
+
+// ErrPrimaryEmail основной адрес нельзя удалить, сначала нужно сделать основным другой адрес
+var ErrPrimaryEmail = errors.New("primary email address cannot be removed")
+
+// ErrEmailNotVerified основным можно сделать только подтверждённый адрес
+var ErrEmailNotVerified = errors.New("email address is not verified")
+
+// emailLockSQL рекомендательная блокировка адреса $1 до конца транзакции (см. триггер sync_users_primary_email в миграции 000015)
+const emailLockSQL = "SELECT pg_advisory_xact_lock(hashtext('user_email:' || lower($1)))"
+
+type UserEmailRepository interface {
+	GetEmails(ctx context.Context, userId int64) ([]UserEmail, error)
+	AddEmail(ctx context.Context, email *UserEmail) (int64, error)
+	VerifyEmail(ctx context.Context, tokenHash string) (UserEmail, error)
+	DeleteEmail(ctx context.Context, userId, id int64) error
+	SetPrimaryEmail(ctx context.Context, userId, id int64) (UserEmail, string, error)
+}
+
+type UserEmailRepositoryImpl struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+// UserEmail Адрес email пользователя
+type UserEmail struct {
+	ID             int64
+	UserID         int64
+	Email          string
+	Primary        bool       // Основной адрес, он же users.email
+	VerifiedAt     *time.Time // nil — адрес не подтверждён, по нему нельзя войти
+	TokenHash      string     // Хеш токена подтверждения адреса
+	TokenExpiresAt time.Time
+	CreatedAt      time.Time
+}
+
+func NewUserEmailsDB(dbPoll *pgxpool.Pool, log *slog.Logger) *UserEmailRepositoryImpl {
+	return &UserEmailRepositoryImpl{
+		db:  dbPoll,
+		log: log,
+	}
+}
+
+// GetEmails Возвращает адреса пользователя: сначала основной, остальные в порядке добавления
+func (ue *UserEmailRepositoryImpl) GetEmails(ctx context.Context, userId int64) ([]UserEmail, error) {
+	query := `
+SELECT id, user_id, email, is_primary, verified_at, created_at
+FROM user_emails
+WHERE user_id = $1
+ORDER BY is_primary DESC, id`
+	rows, err := ue.db.Query(ctx, query, userId)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ue.log); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, database.PsqlErrorHandler(err)
+	}
+	defer rows.Close()
+
+	emails := make([]UserEmail, 0)
+	for rows.Next() {
+		var email UserEmail
+		if err = rows.Scan(&email.ID, &email.UserID, &email.Email, &email.Primary, &email.VerifiedAt, &email.CreatedAt); err != nil {
+			ue.log.Error("Failed to scan user email", slog.String("error", err.Error()))
+			return nil, database.PsqlErrorHandler(err)
+		}
+		emails = append(emails, email)
+	}
+	if err = rows.Err(); err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ue.log); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, database.PsqlErrorHandler(err)
+	}
+	return emails, nil
+}
+
+// AddEmail Добавляет пользователю неподтверждённый адрес с токеном подтверждения.
+// Если адрес уже добавлен, но не подтверждён, токен заменяется новым, действует только последний.
+// Если адрес уже подтверждён, возвращается ErrEmailAlreadyAdded
+func (ue *UserEmailRepositoryImpl) AddEmail(ctx context.Context, email *UserEmail) (int64, error) {
+	query := `
+INSERT INTO user_emails (user_id, email, token_hash, token_expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, lower(email)) DO UPDATE
+    SET email            = EXCLUDED.email,
+        token_hash       = EXCLUDED.token_hash,
+        token_expires_at = EXCLUDED.token_expires_at
+WHERE user_emails.verified_at IS NULL
+RETURNING id`
+	var id int64
+	err := ue.db.QueryRow(ctx, query, email.UserID, email.Email, email.TokenHash, email.TokenExpiresAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrEmailAlreadyAdded
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ue.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		ue.log.Error("Failed to add user email in db", slog.String("error", err.Error()))
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return id, nil
+}
+
+// VerifyEmail Подтверждает адрес по токену.
+// Если адрес за это время стал основным или подтверждённым у другого действующего пользователя,
+// возвращается users_db.ErrEmailAlreadyExists. На время проверки адрес блокируется emailLockSQL
+// (та же блокировка, что в триггере sync_users_primary_email), так что параллельная запись того же адреса ждёт подтверждения
+func (ue *UserEmailRepositoryImpl) VerifyEmail(ctx context.Context, tokenHash string) (UserEmail, error) {
+	tx, err := ue.db.Begin(ctx)
+	if err != nil {
+		return UserEmail{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+SELECT ue.id, ue.user_id, ue.email, ue.created_at
+FROM user_emails ue
+JOIN users u ON u.id = ue.user_id AND u.deleted_at IS NULL
+WHERE ue.token_hash = $1 AND ue.verified_at IS NULL AND ue.token_expires_at > CURRENT_TIMESTAMP
+FOR UPDATE OF ue`
+	var email UserEmail
+	err = tx.QueryRow(ctx, query, tokenHash).Scan(&email.ID, &email.UserID, &email.Email, &email.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserEmail{}, ErrEmailVerificationInvalid
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ue.log); ctxErr != nil {
+			return UserEmail{}, ctxErr
+		}
+		return UserEmail{}, database.PsqlErrorHandler(err)
+	}
+
+	if _, err = tx.Exec(ctx, emailLockSQL, email.Email); err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ue.log); ctxErr != nil {
+			return UserEmail{}, ctxErr
+		}
+		ue.log.Error("Failed to lock email in db", slog.String("error", err.Error()))
+		return UserEmail{}, database.PsqlErrorHandler(err)
+	}
+	var taken bool
+	err = tx.QueryRow(ctx, `
+SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1) AND id <> $2 AND deleted_at IS NULL)
+    OR EXISTS (SELECT 1
+               FROM user_emails ue
+                        JOIN users u ON u.id = ue.user_id AND u.deleted_at IS NULL
+               WHERE lower(ue.email) = lower($1) AND ue.user_id <> $2 AND ue.verified_at IS NOT NULL)`,
+		email.Email, email.UserID).Scan(&taken)
+	if err != nil {
+		ue.log.Error("Failed to check email owner in db", slog.String("error", err.Error()))
+		return UserEmail{}, database.PsqlErrorHandler(err)
+	}
+	if taken {
+		return UserEmail{}, users_db.ErrEmailAlreadyExists
+	}
+
+	var verifiedAt time.Time
+	err = tx.QueryRow(ctx, `
+UPDATE user_emails SET verified_at = CURRENT_TIMESTAMP, token_hash = NULL, token_expires_at = NULL
+WHERE id = $1
+RETURNING verified_at`, email.ID).Scan(&verifiedAt)
+	if err != nil {
+		ue.log.Error("Failed to verify user email in db", slog.String("error", err.Error()))
+		return UserEmail{}, database.PsqlErrorHandler(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return UserEmail{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	email.VerifiedAt = &verifiedAt
+	return email, nil
+}
+
+// DeleteEmail Удаляет адрес пользователя. Основной адрес удалить нельзя, возвращается ErrPrimaryEmail
+func (ue *UserEmailRepositoryImpl) DeleteEmail(ctx context.Context, userId, id int64) error {
+	var primary bool
+	err := ue.db.QueryRow(ctx, `
+WITH deleted AS (
+    DELETE FROM user_emails WHERE id = $1 AND user_id = $2 AND NOT is_primary
+    RETURNING is_primary
+)
+SELECT is_primary FROM deleted
+UNION ALL
+SELECT is_primary FROM user_emails WHERE id = $1 AND user_id = $2 AND is_primary`, id, userId).Scan(&primary)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrEmailNotFound
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ue.log); ctxErr != nil {
+			return ctxErr
+		}
+		ue.log.Error("Failed to delete user email in db", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+	if primary {
+		return ErrPrimaryEmail
+	}
+	return nil
+}
+
+// SetPrimaryEmail Делает подтверждённый адрес основным: в одной транзакции меняет основной адрес
+// в user_emails и email пользователя. Прежний основной адрес остаётся подтверждённым адресом пользователя.
+// Возвращает новый основной адрес и прежний email пользователя
+func (ue *UserEmailRepositoryImpl) SetPrimaryEmail(ctx context.Context, userId, id int64) (UserEmail, string, error) {
+	tx, err := ue.db.Begin(ctx)
+	if err != nil {
+		return UserEmail{}, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previous string
+	err = tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, userId).Scan(&previous)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserEmail{}, "", users_db.ErrUserNotFound
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, ue.log); ctxErr != nil {
+			return UserEmail{}, "", ctxErr
+		}
+		return UserEmail{}, "", database.PsqlErrorHandler(err)
+	}
+
+	email := UserEmail{ID: id, UserID: userId}
+	err = tx.QueryRow(ctx, `
+SELECT email, is_primary, verified_at, created_at
+FROM user_emails
+WHERE id = $1 AND user_id = $2
+FOR UPDATE`, id, userId).Scan(&email.Email, &email.Primary, &email.VerifiedAt, &email.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserEmail{}, "", ErrEmailNotFound
+	}
+	if err != nil {
+		return UserEmail{}, "", database.PsqlErrorHandler(err)
+	}
+	if email.VerifiedAt == nil {
+		return UserEmail{}, "", ErrEmailNotVerified
+	}
+	if email.Primary {
+		return email, previous, nil
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_emails SET is_primary = FALSE WHERE user_id = $1 AND is_primary`, userId)
+	if err != nil {
+		ue.log.Error("Failed to reset primary email in db", slog.String("error", err.Error()))
+		return UserEmail{}, "", database.PsqlErrorHandler(err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE user_emails SET is_primary = TRUE WHERE id = $1`, id)
+	if err != nil {
+		ue.log.Error("Failed to set primary email in db", slog.String("error", err.Error()))
+		return UserEmail{}, "", database.PsqlErrorHandler(err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE users SET email = $1 WHERE id = $2`, email.Email, userId)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == database.PSQLUniqueError {
+			return UserEmail{}, "", users_db.ErrEmailAlreadyExists
+		}
+		ue.log.Error("Failed to update user email in db", slog.String("error", err.Error()))
+		return UserEmail{}, "", database.PsqlErrorHandler(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return UserEmail{}, "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	email.Primary = true
+	return email, previous, nil
+}
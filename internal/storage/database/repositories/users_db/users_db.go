@@ -2,49 +2,315 @@ package users_db
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/phone_numbers"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_attributes"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/user_status"
 	"github.com/ShlykovPavel/users-microservice/internal/storage/database"
 	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
+	"github.com/ShlykovPavel/users-microservice/models/users/import_users"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"log/slog"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
-var ErrEmailAlreadyExists = errors.New("Пользователь с email уже существует. ")
-var ErrUserNotFound = errors.New("Пользователь не найден ")
+var ErrEmailAlreadyExists = errors.New("user with this email already exists")
+
+// ErrPhoneAlreadyExists телефон уже есть у другого пользователя (только при включённой уникальности телефонов)
+var ErrPhoneAlreadyExists = errors.New("user with this phone already exists")
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrVersionMismatch пользователь изменился после того, как клиент получил его версию
+var ErrVersionMismatch = errors.New("user was modified by another request")
+
+// ErrInvalidKeyset значения ключа сортировки не подходят к сортировке списка
+var ErrInvalidKeyset = errors.New("keyset does not match list sorting")
+
+// ErrAttributeNotUnique значение уникального атрибута уже есть у другого пользователя
+var ErrAttributeNotUnique = errors.New("attribute value is already used by another user")
+
+// ErrUserNotDeleted восстановить можно только удалённого пользователя
+var ErrUserNotDeleted = errors.New("user is not deleted")
+
+// ErrImportConflict при on_conflict=fail email из импорта уже занят, импорт отменён
+var ErrImportConflict = errors.New("import canceled: user with this email already exists")
+
+// Режимы обработки уже занятого email при импорте
+const (
+	OnConflictSkip   = "skip"
+	OnConflictUpdate = "update"
+	OnConflictFail   = "fail"
+)
+
+// importBatchSize сколько строк импорта отправляется в БД одним пакетом
+const importBatchSize = 500
+
+// AnyVersion версия, при которой UpdateUser, PatchUser и DeleteUser не проверяют текущую версию пользователя
+const AnyVersion int64 = 0
+
+// patchableColumns колонки, которые можно менять через PatchUser.
+// Email сюда не входит: его смена проходит через подтверждение нового адреса
+var patchableColumns = map[string]struct{}{
+	"first_name":   {},
+	"last_name":    {},
+	"phone":        {},
+	"role":         {},
+	"display_name": {}, // Пустая строка очищает значение, как и у locale и timezone
+	"locale":       {},
+	"timezone":     {},
+	"attributes":   {}, // JSON Merge Patch атрибутов: null удаляет атрибут
+}
+
+// nullableColumns текстовые колонки, в которых пустая строка сохраняется как NULL
+var nullableColumns = map[string]struct{}{
+	"display_name": {},
+	"locale":       {},
+	"timezone":     {},
+}
+
+// emailUniqueIndex уникальный индекс email действующих пользователей без учёта регистра (по lower(email))
+const emailUniqueIndex = "users_email_active_key"
+
+// phoneUniqueIndex имя ограничения, с которым триггер check_users_phone_unique отклоняет занятый телефон
+// (см. миграцию 000013 и SetPhoneUnique)
+const phoneUniqueIndex = "users_phone_active_key"
+
+// phoneVerifiedSQL обнуляет подтверждение телефона, если новый телефон (плейсхолдер %s) отличается от текущего
+const phoneVerifiedSQL = "phone_verified_at = CASE WHEN phone = %s THEN phone_verified_at END"
+
+// effectiveStatusSQL статус пользователя с учётом срока: истёкшая приостановка или блокировка считается active
+const effectiveStatusSQL = `CASE WHEN status IN ('suspended', 'locked') AND status_until <= CURRENT_TIMESTAMP THEN 'active' ELSE status END`
+
+// effectiveStatusUntilSQL срок действия статуса, для истёкшего статуса — NULL
+const effectiveStatusUntilSQL = `CASE WHEN status_until <= CURRENT_TIMESTAMP THEN NULL ELSE status_until END`
+
+// filterableColumns SQL выражения полей, по которым разрешена фильтрация списка пользователей
+var filterableColumns = map[string]string{
+	"id":                 "id",
+	"first_name":         "first_name",
+	"last_name":          "last_name",
+	"email":              "email",
+	"phone":              "phone",
+	"phone_country_code": "phone_country_code",
+	"phone_verified":     "phone_verified_at IS NOT NULL",
+	"role":               "role",
+	"locale":             "locale",
+	"timezone":           "timezone",
+	"status":             effectiveStatusSQL,
+	"created_at":         "created_at",
+	"updated_at":         "updated_at",
+}
+
+// userColumn поле пользователя, которое можно выбрать через fields: SQL выражение и поле UserInfo для Scan
+type userColumn struct {
+	sql  string
+	dest func(user *UserInfo) interface{}
+}
+
+// nullTime цель Scan для колонки времени, допускающей NULL: NULL сканируется в нулевое время
+type nullTime struct {
+	value *time.Time
+}
+
+func (t nullTime) ScanTimestamptz(v pgtype.Timestamptz) error {
+	*t.value = time.Time{}
+	if v.Valid {
+		*t.value = v.Time
+	}
+	return nil
+}
+
+// selectableColumns поля пользователя, которые можно выбрать в GetUserFields и GetUserList
+var selectableColumns = map[string]userColumn{
+	"id":                 {"id", func(user *UserInfo) interface{} { return &user.ID }},
+	"first_name":         {"first_name", func(user *UserInfo) interface{} { return &user.FirstName }},
+	"last_name":          {"last_name", func(user *UserInfo) interface{} { return &user.LastName }},
+	"email":              {"email", func(user *UserInfo) interface{} { return &user.Email }},
+	"phone":              {"phone", func(user *UserInfo) interface{} { return &user.Phone }},
+	"phone_country_code": {"COALESCE(phone_country_code, 0)", func(user *UserInfo) interface{} { return &user.PhoneCountryCode }},
+	"phone_verified":     {"phone_verified_at IS NOT NULL", func(user *UserInfo) interface{} { return &user.PhoneVerified }},
+	"role":               {"COALESCE(role, '')", func(user *UserInfo) interface{} { return &user.Role }},
+	"display_name":       {"COALESCE(display_name, '')", func(user *UserInfo) interface{} { return &user.DisplayName }},
+	"locale":             {"COALESCE(locale, '')", func(user *UserInfo) interface{} { return &user.Locale }},
+	"timezone":           {"COALESCE(timezone, '')", func(user *UserInfo) interface{} { return &user.Timezone }},
+	"version":            {"version", func(user *UserInfo) interface{} { return &user.Version }},
+	"status":             {effectiveStatusSQL, func(user *UserInfo) interface{} { return &user.Status }},
+	"status_reason":      {"COALESCE(status_reason, '')", func(user *UserInfo) interface{} { return &user.StatusReason }},
+	"status_until":       {effectiveStatusUntilSQL, func(user *UserInfo) interface{} { return &user.StatusUntil }},
+	"status_changed_at":  {"status_changed_at", func(user *UserInfo) interface{} { return &user.StatusChangedAt }},
+	"status_changed_by":  {"status_changed_by", func(user *UserInfo) interface{} { return &user.StatusChangedBy }},
+	"deleted_at":         {"deleted_at", func(user *UserInfo) interface{} { return &user.DeletedAt }},
+	"created_at":         {"created_at", func(user *UserInfo) interface{} { return nullTime{&user.CreatedAt} }},
+	"updated_at":         {"updated_at", func(user *UserInfo) interface{} { return &user.UpdatedAt }},
+	"attributes":         {"attributes", func(user *UserInfo) interface{} { return &user.Attributes }},
+}
+
+// resourceFields поля, которые CreateUser, UpdateUser и PatchUser возвращают после записи
+var resourceFields = []string{"id", "first_name", "last_name", "email", "phone", "phone_country_code", "phone_verified", "role", "display_name", "locale", "timezone",
+	"version", "status", "created_at", "updated_at", "attributes"}
+
+// defaultListFields поля, которые выбирает GetUserList, если UserListParams.Fields не задан
+var defaultListFields = []string{"id", "first_name", "last_name", "email", "role", "phone", "phone_country_code", "phone_verified", "display_name", "locale", "timezone",
+	"version", "deleted_at", "status", "status_until", "created_at", "updated_at", "attributes"}
+
+// comparisonOperators SQL операторы сравнения для операторов фильтрации
+var comparisonOperators = map[string]string{
+	query_params.OpEq:  "=",
+	query_params.OpNe:  "<>",
+	query_params.OpGt:  ">",
+	query_params.OpGte: ">=",
+	query_params.OpLt:  "<",
+	query_params.OpLte: "<=",
+}
+
+// likeEscaper экранирует спецсимволы LIKE в пользовательском значении
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
 
 type UserRepository interface {
-	CreateUser(ctx context.Context, userinfo *create_user.UserCreate) (int64, error)
+	CreateUser(ctx context.Context, userinfo *create_user.UserCreate) (UserInfo, error)
 	GetUser(ctx context.Context, userId int64) (UserInfo, error)
-	GetUserList(ctx context.Context, search string, limit, offset int, sortParams []query_params.SortParam) (UserListResult, error)
+	GetUserFields(ctx context.Context, userId int64, fields []string) (UserInfo, error)
+	GetUserByEmail(ctx context.Context, email string) (UserInfo, error)
+	GetUserList(ctx context.Context, params UserListParams) (UserListResult, error)
+	ExportUsers(ctx context.Context, params UserListParams, fn func(user UserInfo) error) error
+	ImportUsers(ctx context.Context, users []import_users.ImportUser, onConflict string, dryRun bool) ([]ImportResult, error)
 	CheckAdminInDB(ctx context.Context) (UserInfo, error)
 	AddFirstAdmin(ctx context.Context, passwordHash string) error
-	UpdateUser(ctx context.Context, id int64, firstName, lastName, email, phone, role string) error
-	DeleteUser(ctx context.Context, id int64) error
+	UpdateUser(ctx context.Context, id, version int64, firstName, lastName, phone, role string, profile Profile, attributes map[string]interface{}) (UserInfo, error)
+	PatchUser(ctx context.Context, id, version int64, fields map[string]interface{}) (UserInfo, error)
+	DeleteUser(ctx context.Context, id, version int64) error
+	RestoreUser(ctx context.Context, id int64) (int64, error)
+	ChangeStatus(ctx context.Context, id, version int64, change StatusChange) (int64, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error)
+	CountBulkUpdate(ctx context.Context, params UserListParams, update BulkUpdate) (int64, error)
+	BulkUpdateUsers(ctx context.Context, params UserListParams, update BulkUpdate, batchSize int, progress func(updated int64) error) (int64, error)
+	WithTx(ctx context.Context, fn func(txRepository UserRepository) error) error
 }
 
 type UserRepositoryImpl struct {
-	db  *pgxpool.Pool
+	db  database.Querier
 	log *slog.Logger
 }
 
 // UserInfo Структура с информацие о пользователе
 type UserInfo struct {
-	ID           int64
-	FirstName    string
-	LastName     string
-	Email        string
-	PasswordHash string
-	Role         string
-	Phone        string
+	ID               int64
+	FirstName        string
+	LastName         string
+	Email            string
+	PasswordHash     string
+	Role             string
+	Phone            string // В формате E.164 (см. phone_numbers), у пользователей до нормализации — как был сохранён
+	PhoneCountryCode int    // Код страны телефона, 0 — не определён
+	PhoneVerified    bool   // Телефон подтверждён кодом из SMS
+	DisplayName      string
+	Locale           string     // Язык сообщений API для пользователя (см. i18n), пустой — не задан
+	Timezone         string     // Часовой пояс IANA, пустой — не задан
+	Version          int64      // Увеличивается при каждом изменении пользователя
+	DeletedAt        *time.Time // Время мягкого удаления, nil для действующих пользователей
+	Status           string     // Статус учётной записи с учётом срока его действия (см. user_status)
+	StatusReason     string
+	StatusUntil      *time.Time // До какого времени действует статус, nil — бессрочно
+	// Кто и когда последний раз менял статус, nil — статус не менялся
+	StatusChangedAt *time.Time
+	StatusChangedBy *int64
+	CreatedAt       time.Time // Нулевое время, если created_at в БД NULL (колонка допускает NULL)
+	UpdatedAt       time.Time
+	Score           *float64 // Релевантность для поиска по search, заполняется только в GetUserList
+	// Attributes дополнительные атрибуты (см. user_attributes), заполняются только при выборе поля attributes
+	Attributes map[string]interface{}
+}
+
+// Profile Отображаемое имя, язык и часовой пояс пользователя для UpdateUser.
+// nil поля не меняются, пустая строка очищает значение
+type Profile struct {
+	DisplayName *string
+	Locale      *string
+	Timezone    *string
+}
+
+// UserListFilter Фильтры списка пользователей
+type UserListFilter struct {
+	IncludeDeleted bool     // Включать в выборку удалённых пользователей
+	Statuses       []string // Если не пуст, выбираются только пользователи с этими статусами
+	IDs            []int64  // Если не пуст, выбираются только пользователи с этими id
+	// Attributes типы индексируемых атрибутов по именам. Фильтры и сортировка по полям
+	// user_attributes.FieldPrefix + имя разрешены только для атрибутов из этого списка
+	Attributes map[string]string
+	Filters    []query_params.FilterParam
+}
+
+// Режимы подсчёта total для списка пользователей
+const (
+	CountExact     = "exact"     // Точный COUNT(*) по фильтру (по умолчанию)
+	CountEstimated = "estimated" // Оценка размера таблицы из статистики PostgreSQL, без учёта фильтров
+	CountNone      = "none"      // Не считать
+)
+
+// Keyset Граничная запись страницы для keyset пагинации
+type Keyset struct {
+	Values   []interface{} // Значения ключа сортировки (см. KeysetSort) граничной записи
+	Backward bool          // Выбрать записи перед граничной, а не после неё
+}
+
+// UserListParams Параметры выборки списка пользователей
+type UserListParams struct {
+	Search     string
+	Limit      int
+	Offset     int
+	SortParams []query_params.SortParam
+	Keyset     *Keyset // Если задан, Offset не используется
+	CountMode  string  // CountExact, CountEstimated или CountNone
+	// Fields поля из selectableColumns, которые нужно выбрать, nil — defaultListFields.
+	// id и поля сортировки выбираются всегда: они нужны для курсоров
+	Fields []string
+	UserListFilter
+}
+
+// StatusChange Новый статус пользователя
+type StatusChange struct {
+	Status    string
+	Reason    string
+	Until     *time.Time // До какого времени действует статус, nil — бессрочно
+	ChangedBy int64      // Id администратора, сменившего статус, 0 — статус сменил сервис (например, блокировка после неверных паролей)
+	// From если задан, статус меняется, только если текущий статус пользователя такой, иначе ChangeStatus возвращает ErrVersionMismatch
+	From string
+}
+
+// BulkUpdate Изменения массового обновления пользователей по фильтру списка. nil поля не меняются.
+// Обновляются только пользователи, которых изменение действительно затрагивает: при смене статуса —
+// те, для кого переход в Status.Status допустим, при смене только роли — те, у кого роль другая.
+// Запись администратора AdminId не меняется
+type BulkUpdate struct {
+	Role    *string
+	Status  *StatusChange
+	AdminId int64
+}
+
+// ImportResult Результат записи строки импорта: id пользователя и статус import_users.RowCreated,
+// import_users.RowUpdated, import_users.RowSkipped, если email занят и строка не записана,
+// или import_users.RowFailed с причиной в Err
+type ImportResult struct {
+	ID     int64
+	Status string
+	Err    error
 }
+
 type UserListResult struct {
-	Users []UserInfo
-	Total int64
+	Users          []UserInfo
+	Total          *int64 // nil, если total не считался (CountNone)
+	TotalEstimated bool
+	HasMore        bool // За последней записью есть ещё записи (для Keyset.Backward — перед первой)
+	RankedByScore  bool // Записи упорядочены по релевантности поиска, keyset пагинация для них недоступна
 }
 
 func NewUsersDB(dbPoll *pgxpool.Pool, log *slog.Logger) *UserRepositoryImpl {
@@ -54,44 +320,266 @@ func NewUsersDB(dbPoll *pgxpool.Pool, log *slog.Logger) *UserRepositoryImpl {
 	}
 }
 
-// CreateUser Создание пользователя
-// Принимает:
-// ctx - внешний контекст, что б вызывающая сторона могла контролировать запрос (например выставить таймаут)
-// userinfo - структуру UserInfo с необходимыми полями для добавления
-//
-// После запроса возвращается Id созданного пользователя
-func (us *UserRepositoryImpl) CreateUser(ctx context.Context, userinfo *create_user.UserCreate) (int64, error) {
+// WithTx Выполняет fn с репозиторием, все запросы которого идут в одной транзакции.
+// Если fn вернула ошибку, транзакция откатывается и ошибка возвращается как есть, иначе транзакция фиксируется
+func (us *UserRepositoryImpl) WithTx(ctx context.Context, fn func(txRepository UserRepository) error) error {
+	tx, err := us.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = fn(&UserRepositoryImpl{db: tx, log: us.log}); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CreateUser Создаёт пользователя и возвращает его со всеми полями resourceFields.
+// ctx - внешний контекст, что б вызывающая сторона могла контролировать запрос (например выставить таймаут).
+// Если роль в userinfo не указана, пользователь получает роль user, если статус не указан — active
+func (us *UserRepositoryImpl) CreateUser(ctx context.Context, userinfo *create_user.UserCreate) (UserInfo, error) {
+	columns, scanDest, err := selectColumns(resourceFields)
+	if err != nil {
+		return UserInfo{}, err
+	}
 	query := `
-INSERT INTO users (first_name, last_name, email, password, Role, phone)
-VALUES ($1, $2, $3, $4, 'user', $5)
-RETURNING id`
-	var id int64
-	err := us.db.QueryRow(ctx, query, userinfo.FirstName, userinfo.LastName, userinfo.Email, userinfo.Password, userinfo.Phone).Scan(&id)
+INSERT INTO users (first_name, last_name, email, password, Role, phone, attributes, display_name, locale, timezone, phone_country_code, status)
+VALUES ($1, $2, $3, $4, COALESCE(NULLIF($6, ''), 'user'), $5, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11,
+        COALESCE(NULLIF($12, ''), 'active'))
+RETURNING ` + strings.Join(columns, ", ")
+	attributes := userinfo.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	var user UserInfo
+	err = us.db.QueryRow(ctx, query, userinfo.FirstName, userinfo.LastName, userinfo.Email, userinfo.Password, userinfo.Phone, userinfo.Role,
+		attributes, userinfo.DisplayName, userinfo.Locale, userinfo.Timezone, phoneCountryCode(userinfo.Phone), userinfo.Status).Scan(scanDest(&user)...)
 	if err != nil {
 		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
-			return 0, ctxErr
+			return UserInfo{}, ctxErr
 		}
-		dbErr := database.PsqlErrorHandler(err)
-		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == database.PSQLUniqueError {
-			return 0, ErrEmailAlreadyExists
+		if uniqueErr := uniqueViolationError(err); uniqueErr != nil {
+			return UserInfo{}, uniqueErr
+		}
+		return UserInfo{}, database.PsqlErrorHandler(err)
+	}
+
+	return user, nil
+}
+
+// ImportUsers Записывает пользователей импорта в одной транзакции пакетами по importBatchSize строк.
+// Password пользователей уже должен быть хешем. Результаты возвращаются в порядке users.
+// onConflict задаёт, что делать с занятым email: OnConflictSkip — пропустить строку,
+// OnConflictUpdate — обновить имя, фамилию, телефон, пароль, роль (если она передана) и переданные атрибуты,
+// OnConflictFail — отменить весь импорт и вернуть результаты вместе с ErrImportConflict.
+// Строки, email которых подтверждён у другого пользователя как дополнительный адрес, не записываются:
+// они получают import_users.RowFailed с ErrEmailAlreadyExists и при OnConflictFail тоже отменяют импорт.
+// При включённой уникальности телефонов строки с телефоном другого действующего пользователя или более ранней строки
+// импорта тоже не записываются и получают import_users.RowFailed с ErrPhoneAlreadyExists.
+// При dryRun строки записываются и транзакция откатывается, так что результаты совпадают с настоящим импортом
+func (us *UserRepositoryImpl) ImportUsers(ctx context.Context, users []import_users.ImportUser, onConflict string, dryRun bool) ([]ImportResult, error) {
+	conflictAction := "DO NOTHING"
+	if onConflict == OnConflictUpdate {
+		conflictAction = `DO UPDATE SET first_name = EXCLUDED.first_name, last_name = EXCLUDED.last_name, phone = EXCLUDED.phone,
+    phone_country_code = EXCLUDED.phone_country_code, ` + fmt.Sprintf(phoneVerifiedSQL, "EXCLUDED.phone") + `,
+    password = EXCLUDED.password, role = CASE WHEN $5 = '' THEN users.role ELSE EXCLUDED.role END,
+    attributes = users.attributes || EXCLUDED.attributes`
+	}
+	query := `
+INSERT INTO users (first_name, last_name, email, password, role, phone, phone_country_code, attributes)
+VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'user'), $6, $7, $8)
+ON CONFLICT (lower(email)) WHERE deleted_at IS NULL ` + conflictAction + `
+RETURNING id, xmax = 0`
+
+	tx, err := us.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rowErrors, err := importRowErrors(ctx, tx, users)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, database.PsqlErrorHandler(err)
+	}
+
+	results := make([]ImportResult, 0, len(users))
+	conflicts := 0
+	for start := 0; start < len(users); start += importBatchSize {
+		end := min(start+importBatchSize, len(users))
+		batch := &pgx.Batch{}
+		for i, user := range users[start:end] {
+			if _, ok := rowErrors[start+i]; ok {
+				continue
+			}
+			attributes := user.Attributes
+			if attributes == nil {
+				attributes = map[string]interface{}{}
+			}
+			batch.Queue(query, user.FirstName, user.LastName, user.Email, user.Password, user.Role, user.Phone, phoneCountryCode(user.Phone), attributes)
+		}
+		batchResults := tx.SendBatch(ctx, batch)
+		for i := range users[start:end] {
+			if rowErr, ok := rowErrors[start+i]; ok {
+				results = append(results, ImportResult{Status: import_users.RowFailed, Err: rowErr})
+				if errors.Is(rowErr, ErrEmailAlreadyExists) {
+					conflicts++
+				}
+				continue
+			}
+			var result ImportResult
+			var inserted bool
+			err = batchResults.QueryRow().Scan(&result.ID, &inserted)
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				result.Status = import_users.RowSkipped
+				conflicts++
+			case err != nil:
+				batchResults.Close()
+				if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+					return nil, ctxErr
+				}
+				if uniqueErr := uniqueViolationError(err); uniqueErr != nil {
+					return nil, uniqueErr
+				}
+				us.log.Error("Failed to import users", slog.String("error", err.Error()))
+				return nil, database.PsqlErrorHandler(err)
+			case inserted:
+				result.Status = import_users.RowCreated
+			default:
+				result.Status = import_users.RowUpdated
+			}
+			results = append(results, result)
+		}
+		if err = batchResults.Close(); err != nil {
+			return nil, database.PsqlErrorHandler(err)
+		}
+	}
+
+	if onConflict == OnConflictFail && conflicts > 0 {
+		return results, ErrImportConflict
+	}
+	if dryRun {
+		return results, nil
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	us.log.Info("Users imported", "rows", len(results), "conflicts", conflicts)
+	return results, nil
+}
+
+// importRowErrors возвращает ошибки строк импорта (по индексу в users), которые нельзя записать:
+// ErrEmailAlreadyExists — email подтверждён у другого действующего пользователя как дополнительный адрес,
+// такой email нельзя сделать основным (триггер sync_users_primary_email отменил бы запись всего импорта);
+// ErrPhoneAlreadyExists — при включённой уникальности телефон есть у другого действующего пользователя
+// или у более ранней строки импорта (триггер check_users_phone_unique)
+func importRowErrors(ctx context.Context, tx pgx.Tx, users []import_users.ImportUser) (map[int]error, error) {
+	emails := make([]string, 0, len(users))
+	phones := make([]string, 0, len(users))
+	for _, user := range users {
+		emails = append(emails, strings.ToLower(user.Email))
+		phones = append(phones, user.Phone)
+	}
+	rows, err := tx.Query(ctx, `
+SELECT DISTINCT lower(ue.email)
+FROM user_emails ue
+         JOIN users u ON u.id = ue.user_id
+WHERE lower(ue.email) = ANY ($1)
+  AND NOT ue.is_primary
+  AND ue.verified_at IS NOT NULL
+  AND u.deleted_at IS NULL`, emails)
+	if err != nil {
+		return nil, err
+	}
+	taken, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	takenEmails := make(map[string]struct{}, len(taken))
+	for _, email := range taken {
+		takenEmails[email] = struct{}{}
+	}
+
+	var phoneUnique bool
+	err = tx.QueryRow(ctx, `SELECT enabled FROM service_settings WHERE name = 'phone_unique'`).Scan(&phoneUnique)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	// phoneOwners email действующих пользователей (в нижнем регистре) по телефону
+	phoneOwners := make(map[string]string)
+	if phoneUnique {
+		rows, err = tx.Query(ctx, `
+SELECT phone, lower(email) FROM users WHERE phone = ANY ($1) AND deleted_at IS NULL`, phones)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var phone, email string
+			if err = rows.Scan(&phone, &email); err != nil {
+				return nil, err
+			}
+			phoneOwners[phone] = email
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
 		}
-		return 0, dbErr
 	}
 
-	return id, nil
+	rowErrors := make(map[int]error)
+	for i, user := range users {
+		email := strings.ToLower(user.Email)
+		if _, ok := takenEmails[email]; ok {
+			rowErrors[i] = ErrEmailAlreadyExists
+			continue
+		}
+		if !phoneUnique {
+			continue
+		}
+		// Телефон пользователя с тем же email занятым не считается: строка обновит этого пользователя
+		if owner, ok := phoneOwners[user.Phone]; ok && owner != email {
+			rowErrors[i] = ErrPhoneAlreadyExists
+			continue
+		}
+		phoneOwners[user.Phone] = email
+	}
+	return rowErrors, nil
 }
 
 func (us *UserRepositoryImpl) GetUser(ctx context.Context, userId int64) (UserInfo, error) {
-	query := `SELECT first_name, last_name, email, password, role, phone FROM users WHERE id = $1`
+	query := `
+SELECT id, first_name, last_name, email, password, role, phone, COALESCE(phone_country_code, 0), phone_verified_at IS NOT NULL,
+       COALESCE(display_name, ''), COALESCE(locale, ''), COALESCE(timezone, ''), version,
+       ` + effectiveStatusSQL + `, COALESCE(status_reason, ''), ` + effectiveStatusUntilSQL + `, created_at, updated_at
+FROM users WHERE id = $1 AND deleted_at IS NULL`
 
 	var user UserInfo
 	err := us.db.QueryRow(ctx, query, userId).Scan(
+		&user.ID,
 		&user.FirstName,
 		&user.LastName,
 		&user.Email,
 		&user.PasswordHash,
 		&user.Role,
-		&user.Phone)
+		&user.Phone,
+		&user.PhoneCountryCode,
+		&user.PhoneVerified,
+		&user.DisplayName,
+		&user.Locale,
+		&user.Timezone,
+		&user.Version,
+		&user.Status,
+		&user.StatusReason,
+		&user.StatusUntil,
+		&user.CreatedAt,
+		&user.UpdatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return UserInfo{}, ErrUserNotFound
 	}
@@ -105,45 +593,396 @@ func (us *UserRepositoryImpl) GetUser(ctx context.Context, userId int64) (UserIn
 	return user, nil
 }
 
-func (us *UserRepositoryImpl) GetUserList(ctx context.Context, search string, limit, offset int, sortParams []query_params.SortParam) (UserListResult, error) {
-	// Базовый SQL-запрос для пользователей
-	query := "SELECT id, first_name, last_name, email, role, phone FROM users"
-	countQuery := "SELECT COUNT(*) FROM users"
-	searchQuery := " WHERE first_name ILIKE $1 OR last_name ILIKE $1 OR email ILIKE $1"
-	args := []interface{}{}
-	countArgs := []interface{}{}
+// GetUserByEmail Поиск пользователя по email (логину) без учёта регистра. Используется при аутентификации.
+// Пользователь находится по основному email или по любому своему подтверждённому адресу (см. user_emails_db),
+// в Email возвращается основной адрес
+func (us *UserRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (UserInfo, error) {
+	query := `
+SELECT id, first_name, last_name, email, password, role, phone, COALESCE(locale, ''),
+       ` + effectiveStatusSQL + `, COALESCE(status_reason, ''), ` + effectiveStatusUntilSQL + `, created_at, updated_at
+FROM users
+WHERE deleted_at IS NULL
+  AND (lower(email) = lower($1)
+    OR id IN (SELECT user_id FROM user_emails WHERE lower(email) = lower($1) AND verified_at IS NOT NULL))
+ORDER BY lower(email) = lower($1) DESC
+LIMIT 1`
 
-	// Фильтрация по search
+	var user UserInfo
+	err := us.db.QueryRow(ctx, query, email).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Role,
+		&user.Phone,
+		&user.Locale,
+		&user.Status,
+		&user.StatusReason,
+		&user.StatusUntil,
+		&user.CreatedAt,
+		&user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserInfo{}, ErrUserNotFound
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return UserInfo{}, ctxErr
+		}
+		dbErr := database.PsqlErrorHandler(err)
+		return UserInfo{}, dbErr
+	}
+	return user, nil
+}
+
+// GetUserFields Возвращает пользователя, у которого выбраны только поля fields (см. selectableColumns).
+// id и version выбираются всегда
+func (us *UserRepositoryImpl) GetUserFields(ctx context.Context, userId int64, fields []string) (UserInfo, error) {
+	columns, scanDest, err := selectColumns(fields, "id", "version")
+	if err != nil {
+		return UserInfo{}, err
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM users WHERE id = $1 AND deleted_at IS NULL"
+
+	var user UserInfo
+	err = us.db.QueryRow(ctx, query, userId).Scan(scanDest(&user)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserInfo{}, ErrUserNotFound
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return UserInfo{}, ctxErr
+		}
+		return UserInfo{}, database.PsqlErrorHandler(err)
+	}
+	return user, nil
+}
+
+// selectColumns возвращает SQL выражения для полей fields и обязательных полей required
+// и функцию, возвращающую приёмники Scan в том же порядке
+func selectColumns(fields []string, required ...string) ([]string, func(user *UserInfo) []interface{}, error) {
+	var selected []userColumn
+	seen := make(map[string]bool)
+	for _, field := range append(required, fields...) {
+		if seen[field] {
+			continue
+		}
+		column, ok := selectableColumns[field]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: field %s is not supported", query_params.ErrInvalidFields, field)
+		}
+		seen[field] = true
+		selected = append(selected, column)
+	}
+
+	columns := make([]string, 0, len(selected))
+	for _, column := range selected {
+		columns = append(columns, column.sql)
+	}
+	scanDest := func(user *UserInfo) []interface{} {
+		dest := make([]interface{}, 0, len(selected))
+		for _, column := range selected {
+			dest = append(dest, column.dest(user))
+		}
+		return dest
+	}
+	return columns, scanDest, nil
+}
+
+// ExportUsers Передаёт в fn по одному всех пользователей, подходящих под поиск, фильтры и сортировку params.
+// Limit, Offset, Keyset и CountMode не используются. Строки читаются из соединения по мере обработки,
+// поэтому выборка целиком в память не загружается. Ошибка fn прерывает выгрузку и возвращается как есть
+func (us *UserRepositoryImpl) ExportUsers(ctx context.Context, params UserListParams, fn func(user UserInfo) error) error {
+	selection, err := newUserListSelection(params)
+	if err != nil {
+		return err
+	}
+	columns, scanDest, err := listColumns(params)
+	if err != nil {
+		return err
+	}
+	orderBy := make([]string, 0, len(params.SortParams)+2)
+	if selection.search && len(params.SortParams) == 0 {
+		orderBy = append(orderBy, "score DESC")
+	}
+	for _, sortParam := range KeysetSort(params.SortParams) {
+		sortSQL, err := sortParamSQL(sortParam, params.Attributes)
+		if err != nil {
+			return err
+		}
+		orderBy = append(orderBy, sortSQL)
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + ", " + selection.scoreSQL + " AS score FROM users" +
+		selection.where() + " ORDER BY " + strings.Join(orderBy, ", ")
+
+	rows, err := us.db.Query(ctx, query, selection.args...)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return ctxErr
+		}
+		us.log.Error("Failed to query users for export", slog.Any("error", err))
+		return fmt.Errorf("failed to query users for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user UserInfo
+		if err := rows.Scan(append(scanDest(&user), &user.Score)...); err != nil {
+			us.log.Error("Error scanning user row", slog.Any("error", err))
+			return fmt.Errorf("error scanning user row: %w", err)
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return ctxErr
+		}
+		us.log.Error("Error reading rows", slog.Any("error", err))
+		return fmt.Errorf("error reading rows: %w", err)
+	}
+	return nil
+}
+
+// userListSelection Условия выборки пользователей по поиску и фильтрам списка
+type userListSelection struct {
+	conditions []string
+	args       []interface{}
+	scoreSQL   string // Выражение релевантности поиска, NULL если search не задан
+	search     bool
+}
+
+// where возвращает WHERE для условий выборки и дополнительных условий extra
+func (s userListSelection) where(extra ...string) string {
+	conditions := append(append([]string{}, s.conditions...), extra...)
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// newUserListSelection строит условия выборки для search, IncludeDeleted, Statuses и Filters
+func newUserListSelection(params UserListParams) (userListSelection, error) {
+	selection := userListSelection{scoreSQL: "NULL::float8"}
+
+	// Поиск по search: запись подходит, если совпадает полнотекстово или каждое слово запроса
+	// нечётко (pg_trgm) встречается в имени, фамилии, email или телефоне. score — релевантность записи
+	search := strings.ToLower(strings.TrimSpace(params.Search))
 	if search != "" {
-		query += searchQuery
-		countQuery += searchQuery
-		args = append(args, "%"+search+"%")
-		countArgs = append(countArgs, "%"+search+"%")
+		selection.search = true
+		selection.args = append(selection.args, search)
+		selection.scoreSQL = "(ts_rank(search_vector, plainto_tsquery('simple', $1)) + word_similarity($1, search_text))::float8"
+		var tokenConditions []string
+		for _, token := range strings.Fields(search) {
+			selection.args = append(selection.args, "%"+likeEscaper.Replace(token)+"%", token)
+			tokenConditions = append(tokenConditions,
+				fmt.Sprintf("(search_text LIKE $%d OR $%d <%% search_text)", len(selection.args)-1, len(selection.args)))
+		}
+		selection.conditions = append(selection.conditions, fmt.Sprintf("(search_vector @@ plainto_tsquery('simple', $1) OR (%s))",
+			strings.Join(tokenConditions, " AND ")))
 	}
 
-	// Сортировка
-	var orderBy []string
-	if len(sortParams) > 0 {
-		for _, sortParam := range sortParams {
-			orderBy = append(orderBy, fmt.Sprintf("%s %s", sortParam.Field, strings.ToUpper(sortParam.Order)))
+	if !params.IncludeDeleted {
+		selection.conditions = append(selection.conditions, "deleted_at IS NULL")
+	}
+	if len(params.IDs) > 0 {
+		selection.args = append(selection.args, params.IDs)
+		selection.conditions = append(selection.conditions, fmt.Sprintf("id = ANY($%d::bigint[])", len(selection.args)))
+	}
+	// Фильтрация по статусу
+	if len(params.Statuses) > 0 {
+		selection.args = append(selection.args, params.Statuses)
+		selection.conditions = append(selection.conditions, fmt.Sprintf("(%s) = ANY($%d)", effectiveStatusSQL, len(selection.args)))
+	}
+	for _, filter := range params.Filters {
+		condition, value, err := filterCondition(filter, params.Attributes, len(selection.args)+1)
+		if err != nil {
+			return userListSelection{}, err
 		}
-		query += " ORDER BY " + strings.Join(orderBy, ", ")
+		selection.args = append(selection.args, value)
+		selection.conditions = append(selection.conditions, condition)
+	}
+	return selection, nil
+}
 
-	} else {
-		// Дефолтная сортировка
-		query += " ORDER BY id ASC"
+// listColumns возвращает колонки выборки списка: params.Fields (или defaultListFields), id и поля сортировки
+func listColumns(params UserListParams) ([]string, func(user *UserInfo) []interface{}, error) {
+	fields := params.Fields
+	if fields == nil {
+		fields = defaultListFields
+	}
+	required := []string{"id"}
+	for _, sortParam := range params.SortParams {
+		// Значения атрибутов для курсоров не нужны: по сортировке атрибутами курсоры не выдаются
+		if !strings.HasPrefix(sortParam.Field, user_attributes.FieldPrefix) {
+			required = append(required, sortParam.Field)
+		}
 	}
+	return selectColumns(fields, required...)
+}
 
-	// Пагинация
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
-	args = append(args, limit, offset)
+// bulkUpdateSelection возвращает условия выборки пользователей, которых затрагивает массовое обновление
+func bulkUpdateSelection(params UserListParams, update BulkUpdate) (userListSelection, error) {
+	selection, err := newUserListSelection(params)
+	if err != nil {
+		return userListSelection{}, err
+	}
+	selection.args = append(selection.args, update.AdminId)
+	selection.conditions = append(selection.conditions, fmt.Sprintf("id <> $%d", len(selection.args)))
+	switch {
+	case update.Status != nil:
+		selection.args = append(selection.args, user_status.AllowedFrom(update.Status.Status))
+		selection.conditions = append(selection.conditions, fmt.Sprintf("(%s) = ANY($%d)", effectiveStatusSQL, len(selection.args)))
+	case update.Role != nil:
+		selection.args = append(selection.args, *update.Role)
+		selection.conditions = append(selection.conditions, fmt.Sprintf("role IS DISTINCT FROM $%d", len(selection.args)))
+	}
+	return selection, nil
+}
+
+// CountBulkUpdate Возвращает количество пользователей, которых изменит BulkUpdateUsers с теми же параметрами
+func (us *UserRepositoryImpl) CountBulkUpdate(ctx context.Context, params UserListParams, update BulkUpdate) (int64, error) {
+	selection, err := bulkUpdateSelection(params, update)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = us.db.QueryRow(ctx, "SELECT COUNT(*) FROM users"+selection.where(), selection.args...).Scan(&count)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return count, nil
+}
+
+// BulkUpdateUsers Применяет update ко всем пользователям, подходящим под поиск и фильтры params, пачками по batchSize в порядке id.
+// Каждая пачка фиксируется отдельно, после неё в progress передаётся общее количество обновлённых пользователей.
+// Если progress вернула ошибку, обновление останавливается. Возвращает количество обновлённых пользователей
+func (us *UserRepositoryImpl) BulkUpdateUsers(ctx context.Context, params UserListParams, update BulkUpdate, batchSize int,
+	progress func(updated int64) error) (int64, error) {
+	selection, err := bulkUpdateSelection(params, update)
+	if err != nil {
+		return 0, err
+	}
+	args := append(selection.args, int64(0), batchSize)
+	lastIdArg, limitArg := len(args)-1, len(args)
+
+	var set []string
+	if update.Role != nil {
+		args = append(args, *update.Role)
+		set = append(set, fmt.Sprintf("role = $%d", len(args)))
+	}
+	if update.Status != nil {
+		args = append(args, update.Status.Status, update.Status.Reason, update.Status.Until, update.Status.ChangedBy)
+		set = append(set, fmt.Sprintf("status = $%d, status_reason = NULLIF($%d, ''), status_until = $%d, status_changed_by = $%d, status_changed_at = CURRENT_TIMESTAMP",
+			len(args)-3, len(args)-2, len(args)-1, len(args)))
+	}
+	if len(set) == 0 {
+		return 0, nil
+	}
+	query := fmt.Sprintf(`
+WITH batch AS (
+    SELECT id FROM users%s ORDER BY id LIMIT $%d FOR UPDATE
+)
+UPDATE users SET %s
+FROM batch WHERE users.id = batch.id
+RETURNING users.id`, selection.where(fmt.Sprintf("id > $%d", lastIdArg)), limitArg, strings.Join(set, ", "))
+
+	var updated int64
+	for {
+		rows, err := us.db.Query(ctx, query, args...)
+		if err != nil {
+			return updated, database.PsqlErrorHandler(err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+				return updated, ctxErr
+			}
+			us.log.Error("Failed to bulk update users in db", slog.String("error", err.Error()))
+			return updated, database.PsqlErrorHandler(err)
+		}
+		if len(ids) == 0 {
+			return updated, nil
+		}
+		updated += int64(len(ids))
+		args[lastIdArg-1] = slices.Max(ids)
+		if err = progress(updated); err != nil {
+			return updated, err
+		}
+		if len(ids) < batchSize {
+			return updated, nil
+		}
+	}
+}
+
+// GetUserList Возвращает страницу пользователей и их общее количество.
+// Удалённые пользователи попадают в выборку только при params.IncludeDeleted
+func (us *UserRepositoryImpl) GetUserList(ctx context.Context, params UserListParams) (UserListResult, error) {
+	selection, err := newUserListSelection(params)
+	if err != nil {
+		return UserListResult{}, err
+	}
+	columns, scanDest, err := listColumns(params)
+	if err != nil {
+		return UserListResult{}, err
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + ", " + selection.scoreSQL + " AS score FROM users"
+	countQuery := "SELECT COUNT(*) FROM users" + selection.where()
+	countArgs := selection.args
+	args := append([]interface{}{}, selection.args...)
+
+	// Без явной сортировки результаты поиска упорядочиваются по релевантности.
+	// Такой порядок не поддерживает keyset пагинацию, курсоры всегда содержат явную сортировку
+	rankedByScore := selection.search && len(params.SortParams) == 0 && params.Keyset == nil
+
+	// Условие keyset пагинации применяется только к выборке, total считается по всему фильтру
+	sortParams := KeysetSort(params.SortParams)
+	var keysetConditions []string
+	if params.Keyset != nil {
+		condition, values, err := KeysetCondition(sortParams, *params.Keyset, len(args)+1)
+		if err != nil {
+			return UserListResult{}, err
+		}
+		keysetConditions = append(keysetConditions, condition)
+		args = append(args, values...)
+	}
+	query += selection.where(keysetConditions...)
+
+	// Сортировка. Для предыдущей страницы порядок обратный, записи разворачиваются после выборки
+	backward := params.Keyset != nil && params.Keyset.Backward
+	orderBy := make([]string, 0, len(sortParams))
+	for _, sortParam := range sortParams {
+		if backward {
+			sortParam = reverseSort(sortParam)
+		}
+		sortSQL, err := sortParamSQL(sortParam, params.Attributes)
+		if err != nil {
+			return UserListResult{}, err
+		}
+		orderBy = append(orderBy, sortSQL)
+	}
+	if rankedByScore {
+		orderBy = append([]string{"score DESC"}, orderBy...)
+	}
+	query += " ORDER BY " + strings.Join(orderBy, ", ")
+
+	// Пагинация. Берём на одну запись больше, что б понять, есть ли следующая страница
+	if params.Keyset != nil {
+		query += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+		args = append(args, params.Limit+1)
+	} else {
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args, params.Limit+1, params.Offset)
+	}
 
 	// Подсчёт total
-	var total int64
-	err := us.db.QueryRow(ctx, countQuery, countArgs...).Scan(&total)
+	total, estimated, err := us.countUsers(ctx, params.CountMode, countQuery, countArgs)
 	if err != nil {
-		us.log.Error("Failed to count users", slog.Any("error", err))
-		return UserListResult{}, fmt.Errorf("failed to count users: %w", err)
+		return UserListResult{}, err
 	}
 
 	// Получение пользователей
@@ -157,7 +996,7 @@ func (us *UserRepositoryImpl) GetUserList(ctx context.Context, search string, li
 	var users []UserInfo
 	for rows.Next() {
 		var user UserInfo
-		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.Phone); err != nil {
+		if err := rows.Scan(append(scanDest(&user), &user.Score)...); err != nil {
 			us.log.Error("Error scanning user row", slog.Any("error", err))
 			return UserListResult{}, fmt.Errorf("error scanning user row: %w", err)
 		}
@@ -168,14 +1007,424 @@ func (us *UserRepositoryImpl) GetUserList(ctx context.Context, search string, li
 		return UserListResult{}, fmt.Errorf("error reading rows: %w", err)
 	}
 
+	hasMore := len(users) > params.Limit
+	if hasMore {
+		users = users[:params.Limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
 	return UserListResult{
-		Users: users,
-		Total: total,
+		Users:          users,
+		Total:          total,
+		TotalEstimated: estimated,
+		HasMore:        hasMore,
+		RankedByScore:  rankedByScore,
 	}, nil
 }
 
+// countUsers считает пользователей в выборке в зависимости от режима подсчёта.
+// Для CountNone возвращает nil, для CountEstimated — оценку размера таблицы из статистики pg_class
+func (us *UserRepositoryImpl) countUsers(ctx context.Context, mode, countQuery string, countArgs []interface{}) (*int64, bool, error) {
+	switch mode {
+	case CountNone:
+		return nil, false, nil
+	case CountEstimated:
+		var estimate int64
+		err := us.db.QueryRow(ctx, `SELECT reltuples::bigint FROM pg_class WHERE oid = 'users'::regclass`).Scan(&estimate)
+		if err != nil {
+			us.log.Error("Failed to estimate users count", slog.Any("error", err))
+			return nil, false, fmt.Errorf("failed to estimate users count: %w", err)
+		}
+		// Для таблицы, по которой ещё не собиралась статистика, reltuples равен -1
+		if estimate >= 0 {
+			return &estimate, true, nil
+		}
+	}
+
+	var total int64
+	err := us.db.QueryRow(ctx, countQuery, countArgs...).Scan(&total)
+	if err != nil {
+		us.log.Error("Failed to count users", slog.Any("error", err))
+		return nil, false, fmt.Errorf("failed to count users: %w", err)
+	}
+	return &total, false, nil
+}
+
+// KeysetSort возвращает полный ключ сортировки списка пользователей: к переданной сортировке добавляется id,
+// что б ключ был уникальным и keyset пагинация не теряла и не повторяла записи
+func KeysetSort(sortParams []query_params.SortParam) []query_params.SortParam {
+	for _, sortParam := range sortParams {
+		if sortParam.Field == "id" {
+			return sortParams
+		}
+	}
+	result := make([]query_params.SortParam, 0, len(sortParams)+1)
+	result = append(result, sortParams...)
+	return append(result, query_params.SortParam{Field: "id", Order: "asc"})
+}
+
+// SortValue возвращает значение поля сортировки пользователя для построения курсора.
+// Для пустого created_at (NULL в БД) возвращает nil, положение такой записи определяет KeysetCondition
+func (u UserInfo) SortValue(field string) interface{} {
+	switch field {
+	case "id":
+		return u.ID
+	case "first_name":
+		return u.FirstName
+	case "last_name":
+		return u.LastName
+	case "email":
+		return u.Email
+	case "created_at":
+		if u.CreatedAt.IsZero() {
+			return nil
+		}
+		return u.CreatedAt.Format(time.RFC3339Nano)
+	default:
+		return nil
+	}
+}
+
+// nullableKeysetFields поля ключа сортировки, которые могут быть NULL в БД
+var nullableKeysetFields = map[string]bool{"created_at": true}
+
+// KeysetCondition строит условие "запись после (или перед) граничной" для ключа сортировки:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ... с учётом направления сортировки каждого поля.
+// Для полей из nullableKeysetFields значение nil означает NULL. NULL расположены так же, как в ORDER BY: по SortParam.Nulls,
+// а без него — как в PostgreSQL, после остальных значений при asc и перед ними при desc.
+// Поэтому после непустого значения идут и записи с NULL, если NULL в конце, а после NULL — все непустые, если NULL в начале.
+// argN — номер плейсхолдера для первого значения ключа. Возвращает условие и значения плейсхолдеров, NULL в них не передаются
+func KeysetCondition(sortParams []query_params.SortParam, keyset Keyset, argN int) (string, []interface{}, error) {
+	if len(keyset.Values) != len(sortParams) {
+		return "", nil, ErrInvalidKeyset
+	}
+	values := make([]interface{}, 0, len(sortParams))
+	// placeholders плейсхолдеры значений ключа, пустая строка для NULL
+	placeholders := make([]string, len(sortParams))
+	for i, sortParam := range sortParams {
+		value, err := keysetValue(sortParam.Field, keyset.Values[i])
+		if err != nil {
+			return "", nil, err
+		}
+		if value != nil {
+			values = append(values, value)
+			placeholders[i] = fmt.Sprintf("$%d", argN+len(values)-1)
+		}
+	}
+
+	alternatives := make([]string, 0, len(sortParams))
+	for i, sortParam := range sortParams {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			if placeholders[j] == "" {
+				parts = append(parts, sortParams[j].Field+" IS NULL")
+			} else {
+				parts = append(parts, sortParams[j].Field+" = "+placeholders[j])
+			}
+		}
+		descending := strings.EqualFold(sortParam.Order, "desc")
+		nullsAfter := sortParam.Nulls == query_params.NullsLast || (sortParam.Nulls == "" && !descending)
+		nullsAfter = nullableKeysetFields[sortParam.Field] && nullsAfter != keyset.Backward
+		switch {
+		case placeholders[i] == "" && nullsAfter:
+			// После NULL по этому полю записей нет, только среди равных по следующим полям
+			continue
+		case placeholders[i] == "":
+			parts = append(parts, sortParam.Field+" IS NOT NULL")
+		default:
+			operator := ">"
+			if descending != keyset.Backward {
+				operator = "<"
+			}
+			condition := fmt.Sprintf("%s %s %s", sortParam.Field, operator, placeholders[i])
+			if nullsAfter {
+				condition = fmt.Sprintf("(%s OR %s IS NULL)", condition, sortParam.Field)
+			}
+			parts = append(parts, condition)
+		}
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	if len(alternatives) == 0 {
+		return "FALSE", values, nil
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", values, nil
+}
+
+// keysetValue приводит значение ключа из курсора к типу колонки. nil (NULL) допускается только для nullableKeysetFields
+func keysetValue(field string, raw interface{}) (interface{}, error) {
+	if raw == nil && nullableKeysetFields[field] {
+		return nil, nil
+	}
+	switch field {
+	case "id":
+		switch value := raw.(type) {
+		case json.Number:
+			id, err := value.Int64()
+			if err != nil {
+				return nil, ErrInvalidKeyset
+			}
+			return id, nil
+		case int64:
+			return value, nil
+		}
+	case "first_name", "last_name", "email":
+		if value, ok := raw.(string); ok {
+			return value, nil
+		}
+	case "created_at":
+		if value, ok := raw.(string); ok {
+			createdAt, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, ErrInvalidKeyset
+			}
+			return createdAt, nil
+		}
+	}
+	return nil, ErrInvalidKeyset
+}
+
+// reverseSort меняет направление сортировки и положение NULL на противоположные
+func reverseSort(sortParam query_params.SortParam) query_params.SortParam {
+	if strings.EqualFold(sortParam.Order, "desc") {
+		sortParam.Order = "asc"
+	} else {
+		sortParam.Order = "desc"
+	}
+	switch sortParam.Nulls {
+	case query_params.NullsFirst:
+		sortParam.Nulls = query_params.NullsLast
+	case query_params.NullsLast:
+		sortParam.Nulls = query_params.NullsFirst
+	}
+	return sortParam
+}
+
+// AttributeSQL возвращает SQL выражение значения атрибута name типа attributeType.
+// Имя атрибута проверено user_attributes.ValidName, поэтому подставляется в запрос как есть
+func AttributeSQL(name, attributeType string) string {
+	value := fmt.Sprintf("(attributes->>'%s')", name)
+	switch attributeType {
+	case user_attributes.TypeInteger, user_attributes.TypeNumber:
+		return "(" + value + "::numeric)"
+	case user_attributes.TypeBoolean:
+		return "(" + value + "::boolean)"
+	default:
+		return value
+	}
+}
+
+// AttributeUniqueIndex имя уникального индекса атрибута name
+func AttributeUniqueIndex(name string) string {
+	return "users_attr_" + name + "_key"
+}
+
+// AttributeIndex имя индекса для фильтрации и сортировки по атрибуту name
+func AttributeIndex(name string) string {
+	return "users_attr_" + name + "_idx"
+}
+
+// uniqueViolationError переводит нарушение уникальности при записи пользователя в ErrEmailAlreadyExists,
+// ErrPhoneAlreadyExists или ErrAttributeNotUnique. Для остальных ошибок возвращает nil
+func uniqueViolationError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != database.PSQLUniqueError {
+		return nil
+	}
+	if name, ok := strings.CutPrefix(pgErr.ConstraintName, "users_attr_"); ok {
+		return fmt.Errorf("%w: %s", ErrAttributeNotUnique, strings.TrimSuffix(name, "_key"))
+	}
+	if pgErr.ConstraintName == phoneUniqueIndex {
+		return ErrPhoneAlreadyExists
+	}
+	return ErrEmailAlreadyExists
+}
+
+// phoneCountryCode код страны телефона в формате E.164 для колонки phone_country_code, nil — код не определён
+func phoneCountryCode(phone string) interface{} {
+	if code := phone_numbers.CountryCodeOf(phone); code != 0 {
+		return code
+	}
+	return nil
+}
+
+// SetPhoneUnique Включает или выключает уникальность телефонов действующих пользователей.
+// Уникальность проверяет триггер check_users_phone_unique по настройке phone_unique в service_settings,
+// схема БД не меняется. Если настройка уже такая, ничего не делается. Включить уникальность не получится,
+// пока у действующих пользователей есть одинаковые телефоны: на время этой проверки запись в users блокируется
+func (us *UserRepositoryImpl) SetPhoneUnique(ctx context.Context, unique bool) error {
+	tx, err := us.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var enabled bool
+	err = tx.QueryRow(ctx, `SELECT enabled FROM service_settings WHERE name = 'phone_unique' FOR UPDATE`).Scan(&enabled)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return ctxErr
+		}
+		return database.PsqlErrorHandler(err)
+	}
+	if err == nil && enabled == unique {
+		return nil
+	}
+	if unique {
+		if _, err = tx.Exec(ctx, `LOCK TABLE users IN SHARE MODE`); err != nil {
+			return database.PsqlErrorHandler(err)
+		}
+		var phone string
+		err = tx.QueryRow(ctx, `
+SELECT phone FROM users WHERE deleted_at IS NULL GROUP BY phone HAVING count(*) > 1 LIMIT 1`).Scan(&phone)
+		if err == nil {
+			return fmt.Errorf("%w: active users have duplicate phone %s", ErrPhoneAlreadyExists, phone)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+				return ctxErr
+			}
+			return database.PsqlErrorHandler(err)
+		}
+	}
+	_, err = tx.Exec(ctx, `
+INSERT INTO service_settings (name, enabled) VALUES ('phone_unique', $1)
+ON CONFLICT (name) DO UPDATE SET enabled = EXCLUDED.enabled`, unique)
+	if err != nil {
+		us.log.Error("Failed to save phone uniqueness setting", slog.String("error", err.Error()))
+		return database.PsqlErrorHandler(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	us.log.Info("Phone uniqueness setting changed", "phone_unique", unique)
+	return nil
+}
+
+// RewritePhones В одной транзакции меняет телефоны действующих пользователей на phones (телефон по Id пользователя)
+// и пересчитывает код страны. Подтверждение телефона сохраняется: меняется только запись номера.
+// Используется для приведения сохранённых телефонов к E.164 (см. cmd/phone_normalize).
+// Если телефон занят при включённой уникальности, ничего не меняется и возвращается ErrPhoneAlreadyExists
+func (us *UserRepositoryImpl) RewritePhones(ctx context.Context, phones map[int64]string) error {
+	tx, err := us.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for id, phone := range phones {
+		_, err = tx.Exec(ctx, `UPDATE users SET phone = $1, phone_country_code = $2 WHERE id = $3 AND deleted_at IS NULL`,
+			phone, phoneCountryCode(phone), id)
+		if err != nil {
+			if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+				return ctxErr
+			}
+			if uniqueErr := uniqueViolationError(err); uniqueErr != nil {
+				return fmt.Errorf("%w: %s", uniqueErr, phone)
+			}
+			us.log.Error("Failed to rewrite user phone in db", slog.String("error", err.Error()))
+			return database.PsqlErrorHandler(err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RewriteEmails В одной транзакции меняет email действующих пользователей на emails (email по Id пользователя).
+// Используется для приведения сохранённых адресов к каноническому виду (см. cmd/email_collisions).
+// Если новый email уже занят, ничего не меняется и возвращается ErrEmailAlreadyExists
+func (us *UserRepositoryImpl) RewriteEmails(ctx context.Context, emails map[int64]string) error {
+	tx, err := us.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for id, email := range emails {
+		_, err = tx.Exec(ctx, `UPDATE users SET email = $1 WHERE id = $2 AND deleted_at IS NULL`, email, id)
+		if err != nil {
+			if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+				return ctxErr
+			}
+			if uniqueErr := uniqueViolationError(err); uniqueErr != nil {
+				return fmt.Errorf("%w: %s", uniqueErr, email)
+			}
+			us.log.Error("Failed to rewrite user email in db", slog.String("error", err.Error()))
+			return database.PsqlErrorHandler(err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// listColumn возвращает SQL выражение поля фильтрации или сортировки списка.
+// Поля атрибутов (user_attributes.FieldPrefix + имя) разрешены только для индексируемых атрибутов из attributes
+func listColumn(field string, attributes map[string]string) (string, bool) {
+	if name, ok := strings.CutPrefix(field, user_attributes.FieldPrefix); ok {
+		attributeType, ok := attributes[name]
+		if !ok {
+			return "", false
+		}
+		return AttributeSQL(name, attributeType), true
+	}
+	column, ok := filterableColumns[field]
+	return column, ok
+}
+
+// sortParamSQL возвращает ORDER BY выражение для поля сортировки с учётом атрибутов
+func sortParamSQL(sortParam query_params.SortParam, attributes map[string]string) (string, error) {
+	if !strings.HasPrefix(sortParam.Field, user_attributes.FieldPrefix) {
+		return sortParam.SQL(), nil
+	}
+	column, ok := listColumn(sortParam.Field, attributes)
+	if !ok {
+		return "", fmt.Errorf("%w: sorting by %s is not supported", query_params.ErrInvalidFilter, sortParam.Field)
+	}
+	sortParam.Field = column
+	return sortParam.SQL(), nil
+}
+
+// filterCondition переводит фильтр в параметризованное SQL условие.
+// argN — номер плейсхолдера, под которым в запрос будет передано возвращаемое значение
+func filterCondition(filter query_params.FilterParam, attributes map[string]string, argN int) (string, interface{}, error) {
+	column, ok := listColumn(filter.Field, attributes)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: filtering by %s is not supported", query_params.ErrInvalidFilter, filter.Field)
+	}
+	if sqlOperator, ok := comparisonOperators[filter.Operator]; ok {
+		return fmt.Sprintf("(%s) %s $%d", column, sqlOperator, argN), filter.Value, nil
+	}
+	switch filter.Operator {
+	case query_params.OpIn:
+		return fmt.Sprintf("(%s) = ANY($%d)", column, argN), filter.Value, nil
+	case query_params.OpContains, query_params.OpPrefix, query_params.OpSuffix:
+		value, ok := filter.Value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: operator %s requires a string value", query_params.ErrInvalidFilter, filter.Operator)
+		}
+		pattern := likeEscaper.Replace(value)
+		switch filter.Operator {
+		case query_params.OpContains:
+			pattern = "%" + pattern + "%"
+		case query_params.OpPrefix:
+			pattern = pattern + "%"
+		case query_params.OpSuffix:
+			pattern = "%" + pattern
+		}
+		return fmt.Sprintf("(%s) ILIKE $%d", column, argN), pattern, nil
+	}
+	return "", nil, fmt.Errorf("%w: operator %s is not supported", query_params.ErrInvalidFilter, filter.Operator)
+}
+
 func (us *UserRepositoryImpl) CheckAdminInDB(ctx context.Context) (UserInfo, error) {
-	query := `SELECT id, first_name, last_name, email, password FROM users WHERE Role LIKE '%admin%'`
+	query := `SELECT id, first_name, last_name, email, password FROM users WHERE Role LIKE '%admin%' AND deleted_at IS NULL`
 
 	var user UserInfo
 	err := us.db.QueryRow(ctx, query).Scan(
@@ -196,9 +1445,10 @@ func (us *UserRepositoryImpl) CheckAdminInDB(ctx context.Context) (UserInfo, err
 }
 
 func (us *UserRepositoryImpl) AddFirstAdmin(ctx context.Context, passwordHash string) error {
-	query := `INSERT INTO users (id, first_name, last_name, email, password, Role, phone) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	query := `INSERT INTO users (id, first_name, last_name, email, password, Role, phone, phone_country_code) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	_, err := us.db.Exec(ctx, query, 0, "Admin first name", "Admin last name", "admin@admin.com", passwordHash, "admin", +78901234567)
+	const phone = "+78901234567"
+	_, err := us.db.Exec(ctx, query, 0, "Admin first name", "Admin last name", "admin@admin.com", passwordHash, "admin", phone, phoneCountryCode(phone))
 	if err != nil {
 		dbErr := database.PsqlErrorHandler(err)
 		return dbErr
@@ -207,7 +1457,7 @@ func (us *UserRepositoryImpl) AddFirstAdmin(ctx context.Context, passwordHash st
 }
 
 func (us *UserRepositoryImpl) SetAdminRole(ctx context.Context, id int64) error {
-	query := `UPDATE users SET Role = 'admin' WHERE id = $1`
+	query := `UPDATE users SET Role = 'admin' WHERE id = $1 AND deleted_at IS NULL`
 	result, err := us.db.Exec(ctx, query, id)
 	if err != nil {
 		dbErr := database.PsqlErrorHandler(err)
@@ -219,28 +1469,137 @@ func (us *UserRepositoryImpl) SetAdminRole(ctx context.Context, id int64) error
 	return nil
 }
 
-func (us *UserRepositoryImpl) UpdateUser(ctx context.Context, id int64, firstName, lastName, email, phone, role string) error {
-	query := `UPDATE users SET first_name = $1, last_name = $2, email = $3, phone = $4, role = $5 WHERE id = $6`
+// UpdateUser Обновляет данные пользователя и возвращает пользователя после обновления.
+// Email здесь не меняется: смена email проходит через подтверждение нового адреса.
+// phone должен быть в формате E.164, при смене телефона подтверждение телефона сбрасывается.
+// Если version не AnyVersion и не совпадает с текущей, возвращается ErrVersionMismatch
+// profile — отображаемое имя, язык и часовой пояс, nil поля не меняются.
+// attributes — JSON Merge Patch атрибутов (null удаляет атрибут), nil — атрибуты не меняются.
+func (us *UserRepositoryImpl) UpdateUser(ctx context.Context, id, version int64, firstName, lastName, phone, role string, profile Profile,
+	attributes map[string]interface{}) (UserInfo, error) {
+	returning, scanDest, err := selectColumns(resourceFields)
+	if err != nil {
+		return UserInfo{}, err
+	}
+	query := `
+UPDATE users SET first_name = $1, last_name = $2, phone = $3, role = $4, phone_country_code = $11,
+    ` + fmt.Sprintf(phoneVerifiedSQL, "$3") + `,
+    attributes = CASE WHEN $7::jsonb IS NULL THEN attributes ELSE jsonb_strip_nulls(attributes || $7::jsonb) END,
+    display_name = CASE WHEN $8::text IS NULL THEN display_name ELSE NULLIF($8, '') END,
+    locale = CASE WHEN $9::text IS NULL THEN locale ELSE NULLIF($9, '') END,
+    timezone = CASE WHEN $10::text IS NULL THEN timezone ELSE NULLIF($10, '') END
+WHERE id = $5 AND deleted_at IS NULL AND ($6::bigint = 0 OR version = $6)
+RETURNING ` + strings.Join(returning, ", ")
 
-	result, err := us.db.Exec(ctx, query, firstName, lastName, email, phone, role, id)
+	// nil map передаётся как NULL, что б атрибуты остались без изменений
+	var attributesArg interface{}
+	if attributes != nil {
+		attributesArg = attributes
+	}
+	var user UserInfo
+	err = us.db.QueryRow(ctx, query, firstName, lastName, phone, role, id, version, attributesArg,
+		profile.DisplayName, profile.Locale, profile.Timezone, phoneCountryCode(phone)).Scan(scanDest(&user)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserInfo{}, us.notChangedError(ctx, id)
+	}
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return ErrUserNotFound
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return UserInfo{}, ctxErr
+		}
+		if uniqueErr := uniqueViolationError(err); uniqueErr != nil {
+			return UserInfo{}, uniqueErr
 		}
 		dbErr := database.PsqlErrorHandler(err)
 		us.log.Error("Failed to update user in db", slog.String("error", err.Error()))
-		return dbErr
+		return UserInfo{}, dbErr
 	}
-	if result.RowsAffected() == 0 {
-		return ErrUserNotFound
+	us.log.Debug("User updated successfully", "id", id, "version", user.Version)
+	return user, nil
+}
+
+// PatchUser Обновляет только переданные колонки пользователя и возвращает пользователя после обновления.
+// fields — значения по именам колонок, допустимы только колонки из patchableColumns.
+// Если fields пуст, пользователь возвращается без изменений.
+// Телефон должен быть в формате E.164: вместе с ним обновляется код страны, а при смене сбрасывается подтверждение.
+// Если version не AnyVersion и не совпадает с текущей, возвращается ErrVersionMismatch
+func (us *UserRepositoryImpl) PatchUser(ctx context.Context, id, version int64, fields map[string]interface{}) (UserInfo, error) {
+	if len(fields) == 0 {
+		user, err := us.GetUser(ctx, id)
+		if err != nil {
+			return UserInfo{}, err
+		}
+		if version != AnyVersion && user.Version != version {
+			return UserInfo{}, ErrVersionMismatch
+		}
+		user.ID = id
+		return user, nil
 	}
-	us.log.Debug("User updated successfully", "id", id)
-	return nil
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if _, ok := patchableColumns[column]; !ok {
+			return UserInfo{}, fmt.Errorf("column %s cannot be patched", column)
+		}
+		columns = append(columns, column)
+	}
+	// Сортируем колонки, что б для одинакового набора полей получался одинаковый запрос
+	sort.Strings(columns)
+
+	setClauses := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns)+2)
+	for _, column := range columns {
+		if column == "attributes" {
+			setClauses = append(setClauses, fmt.Sprintf("attributes = jsonb_strip_nulls(attributes || $%d::jsonb)", len(args)+1))
+		} else if _, ok := nullableColumns[column]; ok {
+			setClauses = append(setClauses, fmt.Sprintf("%s = NULLIF($%d, '')", column, len(args)+1))
+		} else {
+			setClauses = append(setClauses, fmt.Sprintf("%s = $%d", column, len(args)+1))
+		}
+		args = append(args, fields[column])
+		if column == "phone" {
+			phone, _ := fields[column].(string)
+			args = append(args, phoneCountryCode(phone))
+			setClauses = append(setClauses, fmt.Sprintf("phone_country_code = $%d", len(args)),
+				fmt.Sprintf(phoneVerifiedSQL, fmt.Sprintf("$%d", len(args)-1)))
+		}
+	}
+	args = append(args, id, version)
+	returning, scanDest, err := selectColumns(resourceFields)
+	if err != nil {
+		return UserInfo{}, err
+	}
+	query := fmt.Sprintf(`
+UPDATE users SET %s
+WHERE id = $%d AND deleted_at IS NULL AND ($%d::bigint = 0 OR version = $%d)
+RETURNING %s`,
+		strings.Join(setClauses, ", "), len(args)-1, len(args), len(args), strings.Join(returning, ", "))
+
+	var user UserInfo
+	err = us.db.QueryRow(ctx, query, args...).Scan(scanDest(&user)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserInfo{}, us.notChangedError(ctx, id)
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return UserInfo{}, ctxErr
+		}
+		if uniqueErr := uniqueViolationError(err); uniqueErr != nil {
+			return UserInfo{}, uniqueErr
+		}
+		us.log.Error("Failed to patch user in db", slog.String("error", err.Error()))
+		return UserInfo{}, database.PsqlErrorHandler(err)
+	}
+	us.log.Debug("User patched successfully", "id", id, "columns", columns)
+	return user, nil
 }
 
-func (us *UserRepositoryImpl) DeleteUser(ctx context.Context, id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := us.db.Exec(ctx, query, id)
+// DeleteUser Мягко удаляет пользователя: строка остаётся в БД до PurgeDeletedUsers, но перестаёт находиться при чтении.
+// Если version не AnyVersion и не совпадает с текущей, возвращается ErrVersionMismatch
+func (us *UserRepositoryImpl) DeleteUser(ctx context.Context, id, version int64) error {
+	query := `
+UPDATE users SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL AND ($2::bigint = 0 OR version = $2)`
+	result, err := us.db.Exec(ctx, query, id, version)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrUserNotFound
@@ -250,8 +1609,96 @@ func (us *UserRepositoryImpl) DeleteUser(ctx context.Context, id int64) error {
 		return dbErr
 	}
 	if result.RowsAffected() == 0 {
-		return ErrUserNotFound
+		return us.notChangedError(ctx, id)
 	}
 	us.log.Debug("User deleted successfully", "id", id)
 	return nil
 }
+
+// RestoreUser Восстанавливает мягко удалённого пользователя и возвращает его новую версию.
+// Если email пользователя за это время занял другой пользователь, возвращается ErrEmailAlreadyExists
+func (us *UserRepositoryImpl) RestoreUser(ctx context.Context, id int64) (int64, error) {
+	query := `UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING version`
+
+	var version int64
+	err := us.db.QueryRow(ctx, query, id).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Пользователя либо нет совсем, либо он не удалён
+		// notChangedError вернёт ErrVersionMismatch, если пользователь существует и не удалён
+		notChangedErr := us.notChangedError(ctx, id)
+		if errors.Is(notChangedErr, ErrVersionMismatch) {
+			return 0, ErrUserNotDeleted
+		}
+		return 0, notChangedErr
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		if uniqueErr := uniqueViolationError(err); uniqueErr != nil {
+			return 0, uniqueErr
+		}
+		us.log.Error("Failed to restore user in db", slog.String("error", err.Error()))
+		return 0, database.PsqlErrorHandler(err)
+	}
+	us.log.Debug("User restored successfully", "id", id)
+	return version, nil
+}
+
+// ChangeStatus Меняет статус пользователя и возвращает его новую версию.
+// Допустимость перехода проверяет вызывающая сторона, version защищает от одновременной смены статуса.
+// Если version не совпадает с текущей, возвращается ErrVersionMismatch
+func (us *UserRepositoryImpl) ChangeStatus(ctx context.Context, id, version int64, change StatusChange) (int64, error) {
+	query := `
+UPDATE users
+SET status = $1, status_reason = NULLIF($2, ''), status_until = $3, status_changed_by = NULLIF($4, 0), status_changed_at = CURRENT_TIMESTAMP
+WHERE id = $5 AND deleted_at IS NULL AND ($6::bigint = 0 OR version = $6) AND ($7::text = '' OR ` + effectiveStatusSQL + ` = $7)
+RETURNING version`
+
+	var newVersion int64
+	err := us.db.QueryRow(ctx, query, change.Status, change.Reason, change.Until, change.ChangedBy, id, version, change.From).Scan(&newVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, us.notChangedError(ctx, id)
+	}
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		us.log.Error("Failed to change user status in db", slog.String("error", err.Error()))
+		return 0, database.PsqlErrorHandler(err)
+	}
+	us.log.Debug("User status changed successfully", "id", id, "status", change.Status)
+	return newVersion, nil
+}
+
+// PurgeDeletedUsers Окончательно удаляет пользователей, мягко удалённых раньше deletedBefore.
+// Возвращает количество удалённых строк
+func (us *UserRepositoryImpl) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+	result, err := us.db.Exec(ctx, query, deletedBefore)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return 0, ctxErr
+		}
+		us.log.Error("Failed to purge deleted users in db", slog.String("error", err.Error()))
+		return 0, database.PsqlErrorHandler(err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// notChangedError объясняет, почему запрос с проверкой версии не затронул ни одной строки:
+// пользователя нет (ErrUserNotFound) или его версия уже другая (ErrVersionMismatch)
+func (us *UserRepositoryImpl) notChangedError(ctx context.Context, id int64) error {
+	var exists bool
+	err := us.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+	if err != nil {
+		if ctxErr := database.DbCtxError(ctx, err, us.log); ctxErr != nil {
+			return ctxErr
+		}
+		return database.PsqlErrorHandler(err)
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return ErrVersionMismatch
+}
@@ -0,0 +1,113 @@
+// Package users_db_mock мок users_db.UserRepository для тестов хендлеров и сервисов
+package users_db_mock
+
+import (
+	"context"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/ShlykovPavel/users-microservice/models/users/create_user"
+	"github.com/ShlykovPavel/users-microservice/models/users/import_users"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+// MockUserRepository мок users_db.UserRepository. WithTx не открывает транзакцию,
+// а вызывает fn с этим же моком и возвращает её ошибку: откат транзакции проверяется по этой ошибке
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) CreateUser(ctx context.Context, userinfo *create_user.UserCreate) (users_db.UserInfo, error) {
+	args := m.Called(ctx, userinfo)
+	return args.Get(0).(users_db.UserInfo), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUser(ctx context.Context, userId int64) (users_db.UserInfo, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).(users_db.UserInfo), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserFields(ctx context.Context, userId int64, fields []string) (users_db.UserInfo, error) {
+	args := m.Called(ctx, userId, fields)
+	return args.Get(0).(users_db.UserInfo), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (users_db.UserInfo, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(users_db.UserInfo), args.Error(1)
+}
+
+func (m *MockUserRepository) ExportUsers(ctx context.Context, params users_db.UserListParams, fn func(user users_db.UserInfo) error) error {
+	args := m.Called(ctx, params)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ImportUsers(ctx context.Context, users []import_users.ImportUser, onConflict string, dryRun bool) ([]users_db.ImportResult, error) {
+	args := m.Called(ctx, users, onConflict, dryRun)
+	return args.Get(0).([]users_db.ImportResult), args.Error(1)
+}
+
+func (m *MockUserRepository) WithTx(ctx context.Context, fn func(txRepository users_db.UserRepository) error) error {
+	return fn(m)
+}
+
+func (m *MockUserRepository) CountBulkUpdate(ctx context.Context, params users_db.UserListParams, update users_db.BulkUpdate) (int64, error) {
+	args := m.Called(ctx, params, update)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) BulkUpdateUsers(ctx context.Context, params users_db.UserListParams, update users_db.BulkUpdate, batchSize int, progress func(updated int64) error) (int64, error) {
+	args := m.Called(ctx, params, update, batchSize)
+	// Третье возвращаемое значение, если задано, — количества обновлённых пользователей, передаваемые в progress
+	if len(args) > 2 {
+		for _, updated := range args.Get(2).([]int64) {
+			if err := progress(updated); err != nil {
+				return updated, err
+			}
+		}
+	}
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) CheckAdminInDB(ctx context.Context) (users_db.UserInfo, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(users_db.UserInfo), args.Error(1)
+}
+
+func (m *MockUserRepository) AddFirstAdmin(ctx context.Context, passwordHash string) error {
+	args := m.Called(ctx, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetUserList(ctx context.Context, params users_db.UserListParams) (users_db.UserListResult, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(users_db.UserListResult), args.Error(1)
+}
+func (m *MockUserRepository) UpdateUser(ctx context.Context, id, version int64, firstName, lastName, phone, role string, profile users_db.Profile, attributes map[string]interface{}) (users_db.UserInfo, error) {
+	args := m.Called(ctx, id, version, firstName, lastName, phone, role, profile, attributes)
+	return args.Get(0).(users_db.UserInfo), args.Error(1)
+}
+
+func (m *MockUserRepository) PatchUser(ctx context.Context, id, version int64, fields map[string]interface{}) (users_db.UserInfo, error) {
+	args := m.Called(ctx, id, version, fields)
+	return args.Get(0).(users_db.UserInfo), args.Error(1)
+}
+
+func (m *MockUserRepository) DeleteUser(ctx context.Context, id, version int64) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) RestoreUser(ctx context.Context, id int64) (int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) ChangeStatus(ctx context.Context, id, version int64, change users_db.StatusChange) (int64, error) {
+	args := m.Called(ctx, id, version, change)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,107 @@
+package users_db_test
+
+import (
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/api/query_params"
+	"github.com/ShlykovPavel/users-microservice/internal/storage/database/repositories/users_db"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestUserInfoSortValueCreatedAt(t *testing.T) {
+	createdAt := time.Date(2026, 10, 1, 12, 0, 0, 500, time.UTC)
+
+	require.Equal(t, "2026-10-01T12:00:00.0000005Z", users_db.UserInfo{CreatedAt: createdAt}.SortValue("created_at"))
+	// Пустой created_at попадает в курсор как NULL, а не как нулевая дата
+	require.Nil(t, users_db.UserInfo{}.SortValue("created_at"))
+}
+
+func TestKeysetCondition(t *testing.T) {
+	createdAt := "2026-10-01T12:00:00Z"
+	createdAtValue, err := time.Parse(time.RFC3339Nano, createdAt)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name              string
+		sort              query_params.SortParam
+		values            []interface{}
+		backward          bool
+		expectedCondition string
+		expectedValues    []interface{}
+	}{
+		{
+			name:              "Ascending, NULL last by default",
+			sort:              query_params.SortParam{Field: "created_at", Order: "asc"},
+			values:            []interface{}{createdAt, json.Number("8")},
+			expectedCondition: "(((created_at > $3 OR created_at IS NULL)) OR (created_at = $3 AND id > $4))",
+			expectedValues:    []interface{}{createdAtValue, int64(8)},
+		},
+		{
+			name:              "Descending, NULL first by default",
+			sort:              query_params.SortParam{Field: "created_at", Order: "desc"},
+			values:            []interface{}{createdAt, json.Number("8")},
+			expectedCondition: "((created_at < $3) OR (created_at = $3 AND id > $4))",
+			expectedValues:    []interface{}{createdAtValue, int64(8)},
+		},
+		{
+			name:              "Explicit NULL first",
+			sort:              query_params.SortParam{Field: "created_at", Order: "asc", Nulls: query_params.NullsFirst},
+			values:            []interface{}{createdAt, json.Number("8")},
+			expectedCondition: "((created_at > $3) OR (created_at = $3 AND id > $4))",
+			expectedValues:    []interface{}{createdAtValue, int64(8)},
+		},
+		{
+			name:              "Boundary with NULL, NULL last",
+			sort:              query_params.SortParam{Field: "created_at", Order: "asc"},
+			values:            []interface{}{nil, json.Number("8")},
+			expectedCondition: "((created_at IS NULL AND id > $3))",
+			expectedValues:    []interface{}{int64(8)},
+		},
+		{
+			name:              "Boundary with NULL, NULL first",
+			sort:              query_params.SortParam{Field: "created_at", Order: "desc"},
+			values:            []interface{}{nil, json.Number("8")},
+			expectedCondition: "((created_at IS NOT NULL) OR (created_at IS NULL AND id > $3))",
+			expectedValues:    []interface{}{int64(8)},
+		},
+		{
+			// Предыдущая страница: NULL, которые идут после границы, для обратного обхода идут перед ней
+			name:              "Backward from value, NULL last",
+			sort:              query_params.SortParam{Field: "created_at", Order: "asc"},
+			values:            []interface{}{createdAt, json.Number("8")},
+			backward:          true,
+			expectedCondition: "((created_at < $3) OR (created_at = $3 AND id < $4))",
+			expectedValues:    []interface{}{createdAtValue, int64(8)},
+		},
+		{
+			name:              "Backward from NULL, NULL last",
+			sort:              query_params.SortParam{Field: "created_at", Order: "asc"},
+			values:            []interface{}{nil, json.Number("8")},
+			backward:          true,
+			expectedCondition: "((created_at IS NOT NULL) OR (created_at IS NULL AND id < $3))",
+			expectedValues:    []interface{}{int64(8)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sortKey := users_db.KeysetSort([]query_params.SortParam{test.sort})
+			keyset := users_db.Keyset{Values: test.values, Backward: test.backward}
+
+			condition, values, err := users_db.KeysetCondition(sortKey, keyset, 3)
+
+			require.NoError(t, err)
+			require.Equal(t, test.expectedCondition, condition)
+			require.Equal(t, test.expectedValues, values)
+		})
+	}
+}
+
+func TestKeysetConditionRejectsNull(t *testing.T) {
+	// NULL допустим только для полей, которые могут быть пустыми
+	sortKey := users_db.KeysetSort([]query_params.SortParam{{Field: "last_name", Order: "asc"}})
+
+	_, _, err := users_db.KeysetCondition(sortKey, users_db.Keyset{Values: []interface{}{nil, json.Number("8")}}, 1)
+
+	require.ErrorIs(t, err, users_db.ErrInvalidKeyset)
+}
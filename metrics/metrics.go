@@ -11,6 +11,7 @@ type Metrics struct {
 	PgxPoolMaxConns     prometheus.Gauge
 	PgxPoolUsedConns    prometheus.Gauge
 	PgxPoolIdleConns    prometheus.Gauge
+	UsersExportedTotal  *prometheus.CounterVec
 }
 
 // NewMetrics Создаёт экземпляры метрик из структуры
@@ -48,6 +49,12 @@ func NewMetrics() *Metrics {
 				Help: "Currently idle connections in the pool",
 			},
 		),
+		UsersExportedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "users_exported_rows_total",
+				Help: "Total number of user rows exported",
+			},
+			[]string{"format"}),
 	}
 }
 
@@ -60,6 +67,7 @@ func InitMetrics() *Metrics {
 		metrics.PgxPoolMaxConns,
 		metrics.PgxPoolUsedConns,
 		metrics.PgxPoolIdleConns,
+		metrics.UsersExportedTotal,
 	)
 	return metrics
 
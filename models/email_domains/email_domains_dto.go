@@ -0,0 +1,12 @@
+package email_domains
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/internal/lib/email_domains"
+)
+
+// ReloadResponse Размеры списков доменов после перезагрузки
+type ReloadResponse struct {
+	resp.Response
+	Domains email_domains.Stats `json:"domains"`
+}
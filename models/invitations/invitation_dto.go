@@ -0,0 +1,38 @@
+package invitations
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
+	"time"
+)
+
+type CreateInvitationRequest struct {
+	Email          string `json:"email,omitempty" validate:"omitempty,email"`
+	Role           string `json:"role" validate:"required,oneof=user admin"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty" validate:"omitempty,gte=1,lte=2160"`
+}
+
+type InvitationInfo struct {
+	Id         int64      `json:"id"`
+	Email      string     `json:"email,omitempty"`
+	Role       string     `json:"role"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	ConsumedBy *int64     `json:"consumed_by,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy  *int64     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateInvitationResponse Ответ на создание приглашения.
+// Токен возвращается только один раз, в БД хранится лишь его хеш
+type CreateInvitationResponse struct {
+	resp.Response
+	Invitation InvitationInfo `json:"invitation"`
+	Token      string         `json:"token"`
+}
+
+type InvitationsList struct {
+	Invitations []InvitationInfo                 `json:"data"`
+	Meta        get_users_list.UsersListMetaData `json:"meta"`
+}
@@ -0,0 +1,43 @@
+package attribute_definition
+
+import "time"
+
+// Definition Определение дополнительного атрибута пользователя
+type Definition struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Required   bool      `json:"required"`
+	EnumValues []string  `json:"enum_values,omitempty"`
+	Pattern    string    `json:"pattern,omitempty"`
+	Unique     bool      `json:"unique"`
+	Indexed    bool      `json:"indexed"`
+	Visibility string    `json:"visibility"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CreateDefinitionRequest Новое определение атрибута.
+// Имя, тип, unique и indexed после создания не меняются: от них зависят индексы и уже сохранённые значения
+type CreateDefinitionRequest struct {
+	Name       string   `json:"name" validate:"required,max=40"`
+	Type       string   `json:"type" validate:"required,oneof=string integer number boolean date"`
+	Required   bool     `json:"required"`
+	EnumValues []string `json:"enum_values,omitempty" validate:"omitempty,max=100,dive,required,max=256"`
+	Pattern    string   `json:"pattern,omitempty" validate:"max=512"`
+	Unique     bool     `json:"unique"`
+	Indexed    bool     `json:"indexed"`
+	Visibility string   `json:"visibility,omitempty" validate:"omitempty,oneof=public private admin"`
+}
+
+// UpdateDefinitionRequest Изменение правил атрибута. Новые правила применяются только к следующим записям
+type UpdateDefinitionRequest struct {
+	Required   bool     `json:"required"`
+	EnumValues []string `json:"enum_values,omitempty" validate:"omitempty,max=100,dive,required,max=256"`
+	Pattern    string   `json:"pattern,omitempty" validate:"max=512"`
+	Visibility string   `json:"visibility" validate:"required,oneof=public private admin"`
+}
+
+// DefinitionsList Список определений атрибутов
+type DefinitionsList struct {
+	Definitions []Definition `json:"data"`
+}
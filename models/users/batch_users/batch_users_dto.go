@@ -0,0 +1,55 @@
+package batch_users
+
+import (
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/models/users/user_resource"
+)
+
+// Операции пакетного запроса
+const (
+	OpCreate  = "create"
+	OpPatch   = "patch"
+	OpDelete  = "delete"
+	OpSetRole = "set_role"
+)
+
+// MaxOperations сколько операций можно передать в одном пакетном запросе
+const MaxOperations = 200
+
+// BatchRequest Пакет операций над пользователями.
+// При atomic=true операции выполняются в одной транзакции и первая ошибка отменяет весь пакет,
+// иначе каждая операция выполняется независимо
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,max=200,dive"`
+}
+
+// BatchOperation Операция пакетного запроса.
+// create: data — поля create_user.UserCreate, role — роль нового пользователя (по умолчанию user).
+// patch: id, data — поля update_user.PatchUserDto кроме email.
+// delete: id. set_role: id, role (user или admin).
+// version — версия пользователя для проверки, как в If-Match (0 — не проверять)
+type BatchOperation struct {
+	Op      string          `json:"op" validate:"required,oneof=create patch delete set_role"`
+	Id      int64           `json:"id,omitempty" validate:"omitempty,gt=0"`
+	Version int64           `json:"version,omitempty" validate:"omitempty,gt=0"`
+	Role    string          `json:"role,omitempty" validate:"omitempty,oneof=user admin"`
+	Data    json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+}
+
+// BatchOperationResult Результат операции. Status — HTTP статус, который вернул бы отдельный запрос
+type BatchOperationResult struct {
+	Index  int                 `json:"index"`
+	Op     string              `json:"op"`
+	Status int                 `json:"status"`
+	User   *user_resource.User `json:"user,omitempty"`
+	Error  string              `json:"error,omitempty"`
+}
+
+// BatchResponse Результаты операций в порядке запроса
+type BatchResponse struct {
+	Atomic    bool                   `json:"atomic"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []BatchOperationResult `json:"results"`
+}
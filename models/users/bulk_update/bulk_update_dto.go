@@ -0,0 +1,39 @@
+package bulk_update
+
+import (
+	"github.com/ShlykovPavel/users-microservice/models/users/status_change"
+	"time"
+)
+
+// BulkPatch Изменения массового обновления. Нужно передать хотя бы role или status
+type BulkPatch struct {
+	Role   *string `json:"role,omitempty" validate:"omitempty,oneof=user admin"`
+	Status *string `json:"status,omitempty" validate:"omitempty,oneof=pending active suspended locked deactivated"`
+	// StatusChange причина и срок нового статуса, используются только вместе со status
+	StatusChange status_change.ChangeStatusRequest `json:"status_change"`
+}
+
+// BulkUpdateRequest Запрос массового обновления пользователей, подходящих под фильтры из query.
+// При dry_run=true возвращается предпросмотр с токеном подтверждения, без него обновление выполняется
+// только с confirmation_token, выданным этому же администратору для тех же фильтров и изменений
+type BulkUpdateRequest struct {
+	Patch             BulkPatch `json:"patch"`
+	DryRun            bool      `json:"dry_run"`
+	ConfirmationToken string    `json:"confirmation_token,omitempty"`
+}
+
+// BulkUpdatePreview Результат предпросмотра: сколько пользователей будет изменено
+type BulkUpdatePreview struct {
+	Matched           int64     `json:"matched"`
+	ConfirmationToken string    `json:"confirmation_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// BulkUpdateProgress Строка прогресса массового обновления (NDJSON).
+// Последняя строка содержит done=true или error
+type BulkUpdateProgress struct {
+	Matched int64  `json:"matched"`
+	Updated int64  `json:"updated"`
+	Done    bool   `json:"done,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
@@ -5,5 +5,19 @@ type UserCreate struct {
 	LastName  string `json:"last_name" validate:"required,min=3,max=64"`
 	Email     string `json:"email" validate:"required,email"`
 	Password  string `json:"password"  validate:"required,min=3,max=64"`
-	Phone     string `json:"phone" validate:"required,numeric"`
+	// Phone телефон в международном формате (+79001234567) или национальном для региона PHONE_DEFAULT_REGION.
+	// Сохраняется в формате E.164
+	Phone string `json:"phone" validate:"required,phone"`
+	// DisplayName, Locale и Timezone необязательны. Locale — язык сообщений API (en, ru), Timezone — часовой пояс IANA
+	DisplayName string `json:"display_name,omitempty" validate:"omitempty,max=100"`
+	Locale      string `json:"locale,omitempty" validate:"omitempty,locale"`
+	Timezone    string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	// InvitationToken токен приглашения. Обязателен в режиме регистрации invite_only
+	InvitationToken string `json:"invitation_token,omitempty"`
+	// Attributes дополнительные атрибуты, проверяются по определениям атрибутов (GET /admin/attributes)
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// Role роль нового пользователя. Не принимается от клиента, берётся из приглашения
+	Role string `json:"-"`
+	// Status начальный статус учётной записи (см. registration.Policy.InitialStatus). Не принимается от клиента, пусто — active
+	Status string `json:"-"`
 }
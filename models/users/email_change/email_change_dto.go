@@ -0,0 +1,5 @@
+package email_change
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
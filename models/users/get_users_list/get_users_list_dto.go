@@ -1,22 +1,29 @@
 package get_users_list
 
-type UserInfoList struct {
-	Id        int64  `json:"id"`
-	Email     string `json:"email" validate:"required,email"`
-	Phone     string `json:"phone" validate:"required,numeric"`
-	LastName  string `json:"last_name" validate:"required"`
-	FirstName string `json:"first_name" validate:"required"`
-	Role      string `json:"role" validate:"required"`
-}
+import (
+	"encoding/json"
+	"github.com/ShlykovPavel/users-microservice/models/users/user_resource"
+)
 
 type UsersListMetaData struct {
-	Page   int   `json:"page"`
-	Limit  int   `json:"limit"`
-	Offset int   `json:"offset"`
-	Total  int64 `json:"total"`
+	Page   int    `json:"page"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Total  *int64 `json:"total,omitempty"` // Не заполняется при count=none
+	// TotalEstimated total — приблизительная оценка (count=estimated)
+	TotalEstimated bool `json:"total_estimated,omitempty"`
+	// NextCursor и PrevCursor — курсоры соседних страниц для keyset пагинации (?cursor=...)
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 type UsersList struct {
-	Users []UserInfoList    `json:"data"`
-	Meta  UsersListMetaData `json:"meta"`
+	Users []user_resource.User `json:"data"`
+	Meta  UsersListMetaData    `json:"meta"`
+}
+
+// SparseUsersList Список пользователей, у которых оставлены только запрошенные поля (?fields=)
+type SparseUsersList struct {
+	Users []map[string]json.RawMessage `json:"data"`
+	Meta  UsersListMetaData            `json:"meta"`
 }
@@ -0,0 +1,14 @@
+package impersonate
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"time"
+)
+
+// ImpersonateResponse Токен для работы от имени пользователя
+type ImpersonateResponse struct {
+	resp.Response
+	Token     string    `json:"token"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
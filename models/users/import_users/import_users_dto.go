@@ -0,0 +1,48 @@
+package import_users
+
+// Статусы строки импорта
+const (
+	RowCreated    = "created"
+	RowUpdated    = "updated"
+	RowSkipped    = "skipped"
+	RowFailed     = "failed"
+	RowRolledBack = "rolled_back"
+)
+
+// ImportUser строка импорта пользователей. Правила те же, что у create_user.UserCreate,
+// но вместо пароля можно передать готовый bcrypt хеш в password_hash
+type ImportUser struct {
+	FirstName    string `json:"first_name" validate:"required,min=3,max=64"`
+	LastName     string `json:"last_name" validate:"required,min=3,max=64"`
+	Email        string `json:"email" validate:"required,email,max=256"`
+	Password     string `json:"password" validate:"required_without=PasswordHash,omitempty,min=3,max=64"`
+	PasswordHash string `json:"password_hash,omitempty" validate:"omitempty,max=128"`
+	Phone        string `json:"phone" validate:"required,max=64,phone"`
+	// Role роль пользователя, по умолчанию user. При обновлении пустая роль не меняет текущую
+	Role string `json:"role,omitempty" validate:"omitempty,oneof=user admin"`
+	// Attributes дополнительные атрибуты (см. /attributes), проверяются с правами администратора.
+	// В CSV передаются колонками attributes.<имя>, пустое значение — атрибут не передан.
+	// При обновлении переданные атрибуты заменяют текущие, остальные не меняются
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// ImportRowResult результат импорта одной строки. Row — номер строки данных, начиная с 1
+type ImportRowResult struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email,omitempty"`
+	Status string `json:"status"`
+	UserId int64  `json:"user_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportUsersResponse отчёт об импорте пользователей
+type ImportUsersResponse struct {
+	DryRun     bool              `json:"dry_run"`
+	OnConflict string            `json:"on_conflict"`
+	Total      int               `json:"total"`
+	Created    int               `json:"created"`
+	Updated    int               `json:"updated"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Rows       []ImportRowResult `json:"rows"`
+}
@@ -0,0 +1,16 @@
+package login
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+)
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// LoginResponse Структура ответа на успешную аутентификацию
+type LoginResponse struct {
+	resp.Response
+	Token string `json:"token"`
+}
@@ -0,0 +1,22 @@
+package login_events
+
+import (
+	"github.com/ShlykovPavel/users-microservice/models/users/get_users_list"
+	"time"
+)
+
+type LoginEventInfo struct {
+	Id            int64     `json:"id"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent"`
+	Method        string    `json:"method"`
+	Suspicious    bool      `json:"suspicious"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type LoginEventsList struct {
+	Events []LoginEventInfo                 `json:"data"`
+	Meta   get_users_list.UsersListMetaData `json:"meta"`
+}
@@ -0,0 +1,25 @@
+package phone_verification
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"time"
+)
+
+// SendPhoneVerificationResponse Код подтверждения отправлен на телефон Phone и действует до ExpiresAt
+type SendPhoneVerificationResponse struct {
+	resp.Response
+	Phone     string    `json:"phone"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ConfirmPhoneVerificationRequest Код подтверждения из SMS
+type ConfirmPhoneVerificationRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// ConfirmPhoneVerificationResponse Телефон Phone подтверждён в VerifiedAt
+type ConfirmPhoneVerificationResponse struct {
+	resp.Response
+	Phone      string    `json:"phone"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
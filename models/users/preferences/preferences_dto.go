@@ -0,0 +1,14 @@
+package preferences
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Preferences Настройки пользователя в одном пространстве имён
+type Preferences struct {
+	Namespace string          `json:"namespace"`
+	Values    json.RawMessage `json:"values" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,12 @@
+package restore_user
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+)
+
+// RestoreUserResponse Структура ответа на восстановление пользователя
+type RestoreUserResponse struct {
+	resp.Response
+	UserID  int64 `json:"id"`
+	Version int64 `json:"version"`
+}
@@ -0,0 +1,21 @@
+package status_change
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"time"
+)
+
+// ChangeStatusRequest Причина и срок смены статуса пользователя
+type ChangeStatusRequest struct {
+	Reason string     `json:"reason" validate:"max=512"`
+	Until  *time.Time `json:"until,omitempty"` // Только для приостановки: после этого времени пользователь снова активен
+}
+
+// ChangeStatusResponse Структура ответа на смену статуса пользователя
+type ChangeStatusResponse struct {
+	resp.Response
+	UserID      int64      `json:"id"`
+	Status      string     `json:"status"`
+	StatusUntil *time.Time `json:"status_until,omitempty"`
+	Version     int64      `json:"version"`
+}
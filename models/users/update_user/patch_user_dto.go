@@ -0,0 +1,25 @@
+package update_user
+
+// PatchUserDto Частичное обновление пользователя (JSON Merge Patch).
+// nil означает, что поле не передано и не меняется
+type PatchUserDto struct {
+	FirstName *string `json:"first_name" validate:"omitempty,min=3,max=64"`
+	LastName  *string `json:"last_name" validate:"omitempty,min=3,max=64"`
+	Email     *string `json:"email" validate:"omitempty,email"`
+	Phone     *string `json:"phone" validate:"omitempty,phone"`
+	Role      *string `json:"role" validate:"omitempty,min=1"`
+	// Пустая строка очищает display_name, locale и timezone
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	Locale      *string `json:"locale" validate:"omitempty,locale"`
+	Timezone    *string `json:"timezone" validate:"omitempty,timezone"`
+	// Attributes изменяемые атрибуты (JSON Merge Patch): null удаляет атрибут
+	Attributes map[string]interface{} `json:"attributes"`
+	// RemoveAttributes "attributes": null — удалить все атрибуты, которые может менять пользователь, сделавший запрос
+	RemoveAttributes bool `json:"-"`
+}
+
+// PatchUserFields поля, которые можно передавать в PATCH /users/{id}
+var PatchUserFields = []string{"first_name", "last_name", "email", "phone", "role", "display_name", "locale", "timezone", "attributes"}
+
+// PatchUserRemovableFields поля PATCH /users/{id}, которые можно удалить, передав null
+var PatchUserRemovableFields = []string{"display_name", "locale", "timezone", "attributes"}
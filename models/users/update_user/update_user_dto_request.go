@@ -4,6 +4,13 @@ type UpdateUserDto struct {
 	FirstName string `json:"first_name" validate:"required,min=3,max=64"`
 	LastName  string `json:"last_name" validate:"required,min=3,max=64"`
 	Email     string `json:"email" validate:"required,email"`
-	Phone     string `json:"phone" validate:"required,numeric"`
+	Phone     string `json:"phone" validate:"required,phone"`
 	Role      string `json:"role" validate:"required"`
+	// DisplayName, Locale и Timezone меняются, только если переданы. Пустая строка очищает значение
+	DisplayName *string `json:"display_name,omitempty" validate:"omitempty,max=100"`
+	Locale      *string `json:"locale,omitempty" validate:"omitempty,locale"`
+	Timezone    *string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	// Attributes полный набор атрибутов: не переданные атрибуты, которые можно менять, удаляются.
+	// Если поле не передано, атрибуты не меняются
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
 }
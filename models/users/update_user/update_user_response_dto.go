@@ -0,0 +1,12 @@
+package update_user
+
+import (
+	"github.com/ShlykovPavel/users-microservice/models/users/user_resource"
+)
+
+// UpdateUserResponse Пользователь после обновления (PUT и PATCH)
+type UpdateUserResponse struct {
+	user_resource.User
+	// EmailChangePending email изменится только после подтверждения с нового адреса
+	EmailChangePending bool `json:"email_change_pending,omitempty"`
+}
@@ -0,0 +1,47 @@
+package user_emails
+
+import (
+	resp "github.com/ShlykovPavel/users-microservice/internal/lib/api/response"
+	"time"
+)
+
+// UserEmail Адрес email пользователя. Основной адрес совпадает с email пользователя,
+// войти можно по любому подтверждённому адресу
+type UserEmail struct {
+	ID         int64      `json:"id"`
+	Email      string     `json:"email"`
+	Primary    bool       `json:"primary"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// GetUserEmailsResponse Адреса пользователя: сначала основной, остальные в порядке добавления
+type GetUserEmailsResponse struct {
+	resp.Response
+	Emails []UserEmail `json:"emails"`
+}
+
+// AddUserEmailRequest Адрес, который нужно добавить пользователю
+type AddUserEmailRequest struct {
+	Email string `json:"email" validate:"required,email,max=256"`
+}
+
+// AddUserEmailResponse Адрес добавлен, токен подтверждения отправлен на него и действует до ExpiresAt
+type AddUserEmailResponse struct {
+	resp.Response
+	Email     UserEmail `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// VerifyUserEmailRequest Токен подтверждения из письма
+type VerifyUserEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UserEmailResponse Подтверждённый или ставший основным адрес
+type UserEmailResponse struct {
+	resp.Response
+	UserID int64     `json:"user_id"`
+	Email  UserEmail `json:"email"`
+}
@@ -0,0 +1,50 @@
+package user_resource
+
+import "time"
+
+// User Представление пользователя, которое возвращают все эндпоинты пользователей:
+// чтение, список, регистрация и обновление
+type User struct {
+	Id    int64  `json:"id"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+	// PhoneCountryCode код страны телефона, не отдаётся, если телефон сохранён до нормализации в E.164
+	PhoneCountryCode int    `json:"phone_country_code,omitempty"`
+	PhoneVerified    bool   `json:"phone_verified"`
+	LastName         string `json:"last_name"`
+	FirstName        string `json:"first_name"`
+	Role             string `json:"role"`
+	// DisplayName, Locale и Timezone отдаются, только если заданы
+	DisplayName string    `json:"display_name,omitempty"`
+	Locale      string    `json:"locale,omitempty"`
+	Timezone    string    `json:"timezone,omitempty"`
+	Status      string    `json:"status"`
+	Version     int64     `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// DeletedAt заполнено только у удалённых пользователей (include_deleted=true)
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Attributes дополнительные атрибуты, которые видны читающему
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// Score релевантность записи поисковому запросу, заполняется только в списке при search
+	Score *float64 `json:"score,omitempty"`
+	// StatusDetails и LastLogin заполняются только по запросу (?include=status_details,last_login)
+	StatusDetails *StatusDetails `json:"status_details,omitempty"`
+	LastLogin     *LastLogin     `json:"last_login,omitempty"`
+}
+
+// StatusDetails Подробности текущего статуса пользователя
+type StatusDetails struct {
+	Reason    string     `json:"reason,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+	ChangedBy *int64     `json:"changed_by,omitempty"`
+}
+
+// LastLogin Последний успешный вход пользователя
+type LastLogin struct {
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Method    string    `json:"method"`
+	CreatedAt time.Time `json:"created_at"`
+}